@@ -1,6 +1,7 @@
 package main
 
 import (
+	"accommodation_service/internal/booking"
 	"accommodation_service/internal/routes"
 	"accommodation_service/utils/db"
 	"context"
@@ -14,6 +15,8 @@ import (
 func main() {
 	db.ConnectDB()
 
+	booking.StartExpiry(time.Minute)
+
 	r := routes.SetupRoutes()
 
 	port := os.Getenv("PORT")
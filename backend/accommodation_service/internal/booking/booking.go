@@ -0,0 +1,517 @@
+package booking
+
+import (
+	models "accommodation_service/internal/model"
+	"accommodation_service/internal/pricing"
+	"accommodation_service/utils/db"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DateLayout    = "2006-01-02"
+	MaxStayNights = 30
+)
+
+var (
+	ErrInvalidStay      = errors.New("check_out must be after check_in, check_in cannot be in the past and stays are limited to 30 nights")
+	ErrNotFound         = errors.New("not found")
+	ErrTooManyGuests    = errors.New("too many guests for the selected rooms")
+	ErrNoAvailability   = errors.New("not enough rooms available for the selected dates")
+	ErrInvalidState     = errors.New("reservation cannot change from its current status")
+	ErrHoldExpired      = errors.New("reservation hold has expired")
+	ErrPaymentRequired  = errors.New("reservation is confirmed once its payment is captured")
+	ErrBelowBooked      = errors.New("inventory cannot be lower than rooms already booked")
+	ErrInvalidInventory = errors.New("total must not be negative")
+)
+
+// HoldDuration is how long a held reservation keeps its rooms before it has
+// to be confirmed. RESERVATION_HOLD_MINUTES overrides the default.
+func HoldDuration() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("RESERVATION_HOLD_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// ParseDate reads a YYYY-MM-DD date as midnight UTC.
+func ParseDate(value string) (time.Time, error) {
+	return time.ParseInLocation(DateLayout, value, time.UTC)
+}
+
+// ParseStay reads check-in and check-out dates and validates the stay.
+func ParseStay(checkIn, checkOut string) (time.Time, time.Time, error) {
+	in, err := ParseDate(checkIn)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidStay
+	}
+	out, err := ParseDate(checkOut)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidStay
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	nights := int(out.Sub(in).Hours() / 24)
+	if nights < 1 || nights > MaxStayNights || in.Before(today) {
+		return time.Time{}, time.Time{}, ErrInvalidStay
+	}
+
+	return in, out, nil
+}
+
+// Nights lists the nights of a stay; the check-out day is not included.
+func Nights(checkIn, checkOut time.Time) []time.Time {
+	var nights []time.Time
+	for night := checkIn; night.Before(checkOut); night = night.AddDate(0, 0, 1) {
+		nights = append(nights, night)
+	}
+	return nights
+}
+
+// lastNight is the final night of a stay, for inclusive SQL date ranges.
+func lastNight(checkOut time.Time) string {
+	return checkOut.AddDate(0, 0, -1).Format(DateLayout)
+}
+
+const availableRoomTypesSQL = `SELECT rt.id FROM room_types rt
+	WHERE rt.deleted_at IS NULL AND rt.max_guests * ? >= ? AND NOT EXISTS (
+		SELECT 1 FROM generate_series(?::date, ?::date, interval '1 day') AS night
+		LEFT JOIN room_inventories inv ON inv.room_type_id = rt.id AND inv.date = night::date
+		WHERE COALESCE(inv.total, rt.quantity) - COALESCE(inv.booked, 0) < ?)`
+
+// AvailableAccommodationsSQL selects the IDs of accommodations with a room
+// type that fits the guests and has enough rooms free on every night.
+func AvailableAccommodationsSQL(checkIn, checkOut time.Time, guests, rooms int) (string, []interface{}) {
+	query := `SELECT accommodation_id FROM room_types WHERE id IN (` + availableRoomTypesSQL + `)`
+	return query, []interface{}{rooms, guests, checkIn.Format(DateLayout), lastNight(checkOut), rooms}
+}
+
+// StayPrices finds the cheapest stay among the available room types of each
+// accommodation, keyed by accommodation ID.
+func StayPrices(accommodationIDs []uint, checkIn, checkOut time.Time, guests, rooms int) (map[uint]*models.PriceQuote, error) {
+	prices := make(map[uint]*models.PriceQuote)
+	if len(accommodationIDs) == 0 {
+		return prices, nil
+	}
+
+	var roomTypes []models.RoomType
+	err := db.DB.Where("accommodation_id IN ? AND id IN ("+availableRoomTypesSQL+")", accommodationIDs,
+		rooms, guests, checkIn.Format(DateLayout), lastNight(checkOut), rooms).
+		Order("id").Find(&roomTypes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byAccommodation := make(map[uint][]models.RoomType)
+	for _, roomType := range roomTypes {
+		byAccommodation[roomType.AccommodationID] = append(byAccommodation[roomType.AccommodationID], roomType)
+	}
+	for accommodationID, candidates := range byAccommodation {
+		quote, err := pricing.Cheapest(db.DB, candidates, checkIn, checkOut, guests, rooms)
+		if err != nil {
+			return nil, err
+		}
+		prices[accommodationID] = quote
+	}
+	return prices, nil
+}
+
+type RoomAvailability struct {
+	RoomTypeID uint              `json:"room_type_id"`
+	Name       string            `json:"name"`
+	MaxGuests  int               `json:"max_guests"`
+	Available  int               `json:"available"`
+	Nights     int               `json:"nights"`
+	TotalPrice float64           `json:"total_price"`
+	Currency   string            `json:"currency"`
+	Price      models.PriceQuote `json:"price"`
+}
+
+// Availability reports how many rooms of each type are free on every night
+// of the stay, with the price of one room for the guests.
+func Availability(accommodationID uint, checkIn, checkOut time.Time, guests int) ([]RoomAvailability, error) {
+	var roomTypes []models.RoomType
+	if err := db.DB.Where("accommodation_id = ?", accommodationID).Order("id").Find(&roomTypes).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(roomTypes))
+	for i, roomType := range roomTypes {
+		ids[i] = roomType.ID
+	}
+	rules, err := pricing.LoadRules(db.DB, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		RoomTypeID uint
+		Available  int
+	}
+	err = db.DB.Raw(`SELECT rt.id AS room_type_id,
+			MIN(COALESCE(inv.total, rt.quantity) - COALESCE(inv.booked, 0)) AS available
+		FROM room_types rt
+		CROSS JOIN generate_series(?::date, ?::date, interval '1 day') AS night
+		LEFT JOIN room_inventories inv ON inv.room_type_id = rt.id AND inv.date = night::date
+		WHERE rt.accommodation_id = ? AND rt.deleted_at IS NULL
+		GROUP BY rt.id`,
+		checkIn.Format(DateLayout), lastNight(checkOut), accommodationID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		available[row.RoomTypeID] = row.Available
+	}
+
+	result := make([]RoomAvailability, 0, len(roomTypes))
+	for _, roomType := range roomTypes {
+		roomGuests := guests
+		if roomGuests <= 0 {
+			roomGuests = 1
+		} else if roomGuests > roomType.MaxGuests {
+			roomGuests = roomType.MaxGuests
+		}
+		quote := pricing.Calculate(roomType, rules[roomType.ID], checkIn, checkOut, roomGuests, 1)
+		result = append(result, RoomAvailability{
+			RoomTypeID: roomType.ID,
+			Name:       roomType.Name,
+			MaxGuests:  roomType.MaxGuests,
+			Available:  available[roomType.ID],
+			Nights:     quote.Nights,
+			TotalPrice: quote.Total,
+			Currency:   quote.Currency,
+			Price:      quote,
+		})
+	}
+	return result, nil
+}
+
+// lockNights makes sure an inventory row exists for every night in the
+// range and locks them in date order, so concurrent bookings of the same
+// room type queue up instead of overselling or deadlocking.
+func lockNights(tx *gorm.DB, roomType models.RoomType, from, to time.Time) ([]models.RoomInventory, error) {
+	nights := Nights(from, to)
+	rows := make([]models.RoomInventory, len(nights))
+	for i, night := range nights {
+		rows[i] = models.RoomInventory{RoomTypeID: roomType.ID, Date: night, Total: roomType.Quantity}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var inventory []models.RoomInventory
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("room_type_id = ? AND date >= ? AND date < ?", roomType.ID, from.Format(DateLayout), to.Format(DateLayout)).
+		Order("date").
+		Find(&inventory).Error
+	return inventory, err
+}
+
+func adjustBooked(tx *gorm.DB, roomTypeID uint, from, to time.Time, delta int) error {
+	return tx.Model(&models.RoomInventory{}).
+		Where("room_type_id = ? AND date >= ? AND date < ?", roomTypeID, from.Format(DateLayout), to.Format(DateLayout)).
+		Update("booked", gorm.Expr("booked + ?", delta)).Error
+}
+
+// Hold reserves rooms for a user until HoldDuration passes. The rooms count
+// against inventory right away; Confirm makes the reservation final.
+func Hold(reservation *models.Reservation) error {
+	if reservation.Rooms <= 0 {
+		reservation.Rooms = 1
+	}
+
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	var accommodation models.Accommodation
+	if err := tx.Where("id = ? AND is_published = ?", reservation.AccommodationID, true).First(&accommodation).Error; err != nil {
+		return ErrNotFound
+	}
+
+	var roomType models.RoomType
+	err := tx.Where("id = ? AND accommodation_id = ?", reservation.RoomTypeID, reservation.AccommodationID).
+		First(&roomType).Error
+	if err != nil {
+		return ErrNotFound
+	}
+
+	if reservation.Guests > roomType.MaxGuests*reservation.Rooms {
+		return ErrTooManyGuests
+	}
+
+	inventory, err := lockNights(tx, roomType, reservation.CheckIn, reservation.CheckOut)
+	if err != nil {
+		return err
+	}
+	for _, night := range inventory {
+		if night.Total-night.Booked < reservation.Rooms {
+			return ErrNoAvailability
+		}
+	}
+	if err := adjustBooked(tx, roomType.ID, reservation.CheckIn, reservation.CheckOut, reservation.Rooms); err != nil {
+		return err
+	}
+
+	quote, err := pricing.Quote(tx, roomType, reservation.CheckIn, reservation.CheckOut, reservation.Guests, reservation.Rooms)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(HoldDuration())
+	reservation.ID = 0
+	reservation.Status = models.ReservationHeld
+	reservation.HoldExpiresAt = &expiresAt
+	reservation.TotalPrice = quote.Total
+	reservation.Currency = quote.Currency
+	reservation.PriceLines = quote.Lines
+	if err := tx.Create(reservation).Error; err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// lockReservation loads a reservation for update. userID limits the lookup
+// to the guest's own reservations; zero skips the check.
+func lockReservation(tx *gorm.DB, id, userID uint) (*models.Reservation, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var reservation models.Reservation
+	if err := query.First(&reservation).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	return &reservation, nil
+}
+
+// release gives the reservation's rooms back to inventory and moves it to
+// the given final status.
+func release(tx *gorm.DB, reservation *models.Reservation, status string) error {
+	var roomType models.RoomType
+	if err := tx.Unscoped().First(&roomType, reservation.RoomTypeID).Error; err != nil {
+		return err
+	}
+	if _, err := lockNights(tx, roomType, reservation.CheckIn, reservation.CheckOut); err != nil {
+		return err
+	}
+	if err := adjustBooked(tx, roomType.ID, reservation.CheckIn, reservation.CheckOut, -reservation.Rooms); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reservation.Status = status
+	reservation.HoldExpiresAt = nil
+	if status == models.ReservationCancelled {
+		reservation.CancelledAt = &now
+	}
+	return tx.Save(reservation).Error
+}
+
+// Confirm turns a hold with nothing to pay into a booking. Holds with a
+// price are confirmed by payment_service when it captures their payment.
+func Confirm(id, userID uint) (*models.Reservation, error) {
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	reservation, err := lockReservation(tx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status != models.ReservationHeld {
+		return nil, ErrInvalidState
+	}
+	if reservation.TotalPrice > 0 {
+		return nil, ErrPaymentRequired
+	}
+
+	if reservation.HoldExpiresAt != nil && reservation.HoldExpiresAt.Before(time.Now()) {
+		if err := release(tx, reservation, models.ReservationExpired); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		return nil, ErrHoldExpired
+	}
+
+	now := time.Now()
+	reservation.Status = models.ReservationConfirmed
+	reservation.ConfirmedAt = &now
+	reservation.HoldExpiresAt = nil
+	if err := tx.Save(reservation).Error; err != nil {
+		return nil, err
+	}
+
+	return reservation, tx.Commit().Error
+}
+
+// Cancel releases a held or confirmed reservation. Guests pass their user
+// ID; admins pass zero.
+func Cancel(id, userID uint) (*models.Reservation, error) {
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	reservation, err := lockReservation(tx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status != models.ReservationHeld && reservation.Status != models.ReservationConfirmed {
+		return nil, ErrInvalidState
+	}
+
+	if err := release(tx, reservation, models.ReservationCancelled); err != nil {
+		return nil, err
+	}
+
+	return reservation, tx.Commit().Error
+}
+
+// ExpireHolds releases the rooms of holds that were never confirmed.
+func ExpireHolds() (int, error) {
+	var ids []uint
+	err := db.DB.Model(&models.Reservation{}).
+		Where("status = ? AND hold_expires_at < ?", models.ReservationHeld, time.Now()).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			reservation, err := lockReservation(tx, id, 0)
+			if err != nil {
+				return err
+			}
+			// Confirmed or cancelled since the IDs were read.
+			if reservation.Status != models.ReservationHeld {
+				return nil
+			}
+			if err := release(tx, reservation, models.ReservationExpired); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// StartExpiry runs ExpireHolds in the background.
+func StartExpiry(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if count, err := ExpireHolds(); err != nil {
+				log.Printf("Failed to expire reservation holds: %v", err)
+			} else if count > 0 {
+				log.Printf("Expired %d reservation holds", count)
+			}
+		}
+	}()
+}
+
+// SetInventory sets how many rooms of a type can be sold on each night of
+// the range [from, to).
+func SetInventory(roomType models.RoomType, from, to time.Time, total int) error {
+	if total < 0 {
+		return ErrInvalidInventory
+	}
+
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	inventory, err := lockNights(tx, roomType, from, to)
+	if err != nil {
+		return err
+	}
+	for _, night := range inventory {
+		if night.Booked > total {
+			return ErrBelowBooked
+		}
+	}
+
+	err = tx.Model(&models.RoomInventory{}).
+		Where("room_type_id = ? AND date >= ? AND date < ?", roomType.ID, from.Format(DateLayout), to.Format(DateLayout)).
+		Update("total", total).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+type NightOccupancy struct {
+	Date   string `json:"date"`
+	Total  int    `json:"total"`
+	Booked int    `json:"booked"`
+}
+
+type RoomTypeOccupancy struct {
+	RoomTypeID    uint             `json:"room_type_id"`
+	Name          string           `json:"name"`
+	Nights        []NightOccupancy `json:"nights"`
+	OccupancyRate float64          `json:"occupancy_rate"`
+}
+
+// Occupancy reports sold and sellable rooms per room type and night for the
+// range [from, to). Held rooms count as sold.
+func Occupancy(accommodationID uint, from, to time.Time) ([]RoomTypeOccupancy, error) {
+	var rows []struct {
+		RoomTypeID uint
+		Name       string
+		Date       time.Time
+		Total      int
+		Booked     int
+	}
+	err := db.DB.Raw(`SELECT rt.id AS room_type_id, rt.name, night::date AS date,
+			COALESCE(inv.total, rt.quantity) AS total, COALESCE(inv.booked, 0) AS booked
+		FROM room_types rt
+		CROSS JOIN generate_series(?::date, ?::date, interval '1 day') AS night
+		LEFT JOIN room_inventories inv ON inv.room_type_id = rt.id AND inv.date = night::date
+		WHERE rt.accommodation_id = ? AND rt.deleted_at IS NULL
+		ORDER BY rt.id, night`,
+		from.Format(DateLayout), lastNight(to), accommodationID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := []RoomTypeOccupancy{}
+	totals := map[uint][2]int{}
+	for _, row := range rows {
+		if len(result) == 0 || result[len(result)-1].RoomTypeID != row.RoomTypeID {
+			result = append(result, RoomTypeOccupancy{RoomTypeID: row.RoomTypeID, Name: row.Name})
+		}
+		current := &result[len(result)-1]
+		current.Nights = append(current.Nights, NightOccupancy{
+			Date:   row.Date.Format(DateLayout),
+			Total:  row.Total,
+			Booked: row.Booked,
+		})
+		sums := totals[row.RoomTypeID]
+		totals[row.RoomTypeID] = [2]int{sums[0] + row.Total, sums[1] + row.Booked}
+	}
+
+	for i := range result {
+		sums := totals[result[i].RoomTypeID]
+		if sums[0] > 0 {
+			result[i].OccupancyRate = float64(sums[1]) / float64(sums[0])
+		}
+	}
+	return result, nil
+}
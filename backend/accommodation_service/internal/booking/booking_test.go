@@ -0,0 +1,297 @@
+package booking
+
+import (
+	models "accommodation_service/internal/model"
+	"accommodation_service/utils/db"
+	"accommodation_service/utils/testdb"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useTestDB points db.DB at a schema of its own. Booking relies on row
+// locks and ON CONFLICT, so it is tested against PostgreSQL only.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	conn := testdb.Open(t)
+	err := conn.AutoMigrate(&models.Accommodation{}, &models.RoomType{},
+		&models.RoomInventory{}, &models.Reservation{}, &models.PricingRule{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+}
+
+// seedRoomType adds a published accommodation with one room type of
+// quantity rooms at price a night.
+func seedRoomType(t *testing.T, quantity int, price float64) models.RoomType {
+	t.Helper()
+	accommodation := models.Accommodation{Name: "Hotel", City: "Almaty", Address: "Abay 1", Type: "hotel", IsPublished: true}
+	if err := db.DB.Create(&accommodation).Error; err != nil {
+		t.Fatal(err)
+	}
+	roomType := models.RoomType{AccommodationID: accommodation.ID, Name: "Double", Price: price, Currency: "KZT", MaxGuests: 2, Quantity: quantity}
+	if err := db.DB.Create(&roomType).Error; err != nil {
+		t.Fatal(err)
+	}
+	return roomType
+}
+
+// day returns midnight UTC n days from now, so stays are never in the past.
+func day(n int) time.Time {
+	return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, n)
+}
+
+func stay(roomType models.RoomType, userID uint, checkIn, checkOut int) *models.Reservation {
+	return &models.Reservation{
+		AccommodationID: roomType.AccommodationID,
+		RoomTypeID:      roomType.ID,
+		UserID:          userID,
+		CheckIn:         day(checkIn),
+		CheckOut:        day(checkOut),
+		Guests:          1,
+		Rooms:           1,
+	}
+}
+
+// booked returns the booked count of each night from day from to day to.
+func booked(t *testing.T, roomType models.RoomType, from, to int) []int {
+	t.Helper()
+	var counts []int
+	err := db.DB.Model(&models.RoomInventory{}).
+		Where("room_type_id = ? AND date >= ? AND date < ?", roomType.ID, day(from).Format(DateLayout), day(to).Format(DateLayout)).
+		Order("date").Pluck("booked", &counts).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counts
+}
+
+func reload(t *testing.T, id uint) models.Reservation {
+	t.Helper()
+	var reservation models.Reservation
+	if err := db.DB.First(&reservation, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return reservation
+}
+
+func TestHoldLastRoom(t *testing.T) {
+	useTestDB(t)
+	roomType := seedRoomType(t, 1, 10000)
+
+	first := stay(roomType, 1, 30, 33)
+	if err := Hold(first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != models.ReservationHeld || first.HoldExpiresAt == nil || first.TotalPrice != 30000 {
+		t.Fatalf("held reservation = %+v", first)
+	}
+
+	tests := []struct {
+		name              string
+		checkIn, checkOut int
+		rooms, guests     int
+		want              error
+	}{
+		{"same nights", 30, 33, 1, 1, ErrNoAvailability},
+		{"overlapping the first night", 28, 31, 1, 1, ErrNoAvailability},
+		{"overlapping the last night", 32, 35, 1, 1, ErrNoAvailability},
+		{"inside the stay", 31, 32, 1, 1, ErrNoAvailability},
+		{"more rooms than exist", 40, 41, 2, 1, ErrNoAvailability},
+		{"too many guests", 40, 41, 1, 3, ErrTooManyGuests},
+		{"ending on check-in day", 27, 30, 1, 1, nil},
+		{"starting on check-out day", 33, 35, 1, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservation := stay(roomType, 2, tt.checkIn, tt.checkOut)
+			reservation.Rooms = tt.rooms
+			reservation.Guests = tt.guests
+			if err := Hold(reservation); !errors.Is(err, tt.want) {
+				t.Fatalf("Hold = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if got := booked(t, roomType, 27, 35); fmt.Sprint(got) != "[1 1 1 1 1 1 1 1]" {
+		t.Errorf("booked nights = %v", got)
+	}
+
+	unknown := stay(roomType, 2, 50, 51)
+	unknown.RoomTypeID = roomType.ID + 100
+	if err := Hold(unknown); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Hold of an unknown room type = %v", err)
+	}
+}
+
+// Concurrent holds of the same nights queue on the inventory rows, so no
+// more rooms are held than exist.
+func TestConcurrentHolds(t *testing.T) {
+	useTestDB(t)
+	roomType := seedRoomType(t, 2, 10000)
+
+	const guests = 8
+	var wg sync.WaitGroup
+	results := make(chan error, guests)
+	for i := 0; i < guests; i++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			results <- Hold(stay(roomType, userID, 30, 32))
+		}(uint(i + 1))
+	}
+	wg.Wait()
+	close(results)
+
+	held := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			held++
+		case !errors.Is(err, ErrNoAvailability):
+			t.Errorf("Hold = %v", err)
+		}
+	}
+	if held != 2 {
+		t.Errorf("%d holds succeeded for 2 rooms", held)
+	}
+	if got := booked(t, roomType, 30, 32); fmt.Sprint(got) != "[2 2]" {
+		t.Errorf("booked nights = %v", got)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	useTestDB(t)
+	free := seedRoomType(t, 1, 0)
+	priced := seedRoomType(t, 1, 10000)
+
+	paid := stay(priced, 1, 30, 31)
+	if err := Hold(paid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Confirm(paid.ID, 1); !errors.Is(err, ErrPaymentRequired) {
+		t.Errorf("Confirm of a priced hold = %v, want ErrPaymentRequired", err)
+	}
+
+	hold := stay(free, 1, 30, 31)
+	if err := Hold(hold); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Confirm(hold.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Confirm by another user = %v, want ErrNotFound", err)
+	}
+	confirmed, err := Confirm(hold.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Status != models.ReservationConfirmed || confirmed.ConfirmedAt == nil || confirmed.HoldExpiresAt != nil {
+		t.Errorf("confirmed reservation = %+v", confirmed)
+	}
+	if _, err := Confirm(hold.ID, 1); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second Confirm = %v, want ErrInvalidState", err)
+	}
+
+	// A confirmed booking keeps its room until it is cancelled.
+	if _, err := ExpireHolds(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Hold(stay(free, 2, 30, 31)); !errors.Is(err, ErrNoAvailability) {
+		t.Errorf("Hold of a confirmed room = %v, want ErrNoAvailability", err)
+	}
+	if _, err := Cancel(hold.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := booked(t, free, 30, 31); fmt.Sprint(got) != "[0]" {
+		t.Errorf("booked nights after Cancel = %v", got)
+	}
+	if _, err := Cancel(hold.ID, 1); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second Cancel = %v, want ErrInvalidState", err)
+	}
+}
+
+func expire(t *testing.T, reservation *models.Reservation) {
+	t.Helper()
+	err := db.DB.Model(&models.Reservation{}).Where("id = ?", reservation.ID).
+		UpdateColumn("hold_expires_at", time.Now().Add(-time.Minute)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpireHolds(t *testing.T) {
+	useTestDB(t)
+	roomType := seedRoomType(t, 1, 0)
+
+	stale := stay(roomType, 1, 30, 32)
+	if err := Hold(stale); err != nil {
+		t.Fatal(err)
+	}
+	other := seedRoomType(t, 1, 0)
+	fresh := stay(other, 1, 30, 32)
+	if err := Hold(fresh); err != nil {
+		t.Fatal(err)
+	}
+
+	// The last room is held, so an overlapping stay waits for the hold
+	// to run out.
+	if err := Hold(stay(roomType, 2, 31, 33)); !errors.Is(err, ErrNoAvailability) {
+		t.Fatalf("Hold over a live hold = %v", err)
+	}
+	expire(t, stale)
+
+	expired, err := ExpireHolds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 {
+		t.Errorf("ExpireHolds expired %d holds, want 1", expired)
+	}
+	if got := reload(t, stale.ID); got.Status != models.ReservationExpired || got.HoldExpiresAt != nil {
+		t.Errorf("stale hold = %s, expires %v", got.Status, got.HoldExpiresAt)
+	}
+	if got := reload(t, fresh.ID); got.Status != models.ReservationHeld {
+		t.Errorf("fresh hold = %s", got.Status)
+	}
+	if got := booked(t, roomType, 30, 32); fmt.Sprint(got) != "[0 0]" {
+		t.Errorf("booked nights after expiry = %v", got)
+	}
+
+	if err := Hold(stay(roomType, 2, 31, 33)); err != nil {
+		t.Errorf("Hold after the expiry = %v", err)
+	}
+	if expired, err := ExpireHolds(); err != nil || expired != 0 {
+		t.Errorf("second ExpireHolds = %d, %v", expired, err)
+	}
+}
+
+// A hold confirmed after it ran out but before ExpireHolds saw it is
+// expired by Confirm itself.
+func TestConfirmExpiredHold(t *testing.T) {
+	useTestDB(t)
+	roomType := seedRoomType(t, 1, 0)
+
+	hold := stay(roomType, 1, 30, 31)
+	if err := Hold(hold); err != nil {
+		t.Fatal(err)
+	}
+	expire(t, hold)
+
+	if _, err := Confirm(hold.ID, 1); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("Confirm = %v, want ErrHoldExpired", err)
+	}
+	if got := reload(t, hold.ID); got.Status != models.ReservationExpired {
+		t.Errorf("status = %s, want expired", got.Status)
+	}
+	if got := booked(t, roomType, 30, 31); fmt.Sprint(got) != "[0]" {
+		t.Errorf("booked nights = %v", got)
+	}
+	if expired, err := ExpireHolds(); err != nil || expired != 0 {
+		t.Errorf("ExpireHolds after Confirm = %d, %v", expired, err)
+	}
+}
@@ -1,62 +1,38 @@
 package controllers
 
 import (
+	"accommodation_service/internal/booking"
 	"accommodation_service/internal/model"
 	"accommodation_service/utils/db"
-	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type AccommodationController struct{}
 
-func generateRandomFilename(originalFilename string) string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes) + filepath.Ext(originalFilename)
-}
-
 func uploadImages(files []*multipart.FileHeader) ([]string, error) {
 	var imageURLs []string
-	uploadDir := "/app/uploads/accommodations"
-
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create upload directory: %v", err)
-	}
-
 	for _, fileHeader := range files {
 		file, err := fileHeader.Open()
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file: %v", err)
 		}
-		defer file.Close()
-
-		randomFilename := generateRandomFilename(fileHeader.Filename)
-		filePath := filepath.Join(uploadDir, randomFilename)
-
-		dst, err := os.Create(filePath)
+		url, err := storeMedia(file, fileHeader.Filename)
+		file.Close()
 		if err != nil {
-			return nil, fmt.Errorf("failed to create file: %v", err)
+			return nil, err
 		}
-		defer dst.Close()
-
-		if _, err := io.Copy(dst, file); err != nil {
-			return nil, fmt.Errorf("failed to save file: %v", err)
-		}
-
-		imageURLs = append(imageURLs, "/uploads/accommodations/"+randomFilename)
+		imageURLs = append(imageURLs, url)
 	}
 	return imageURLs, nil
 }
@@ -97,7 +73,7 @@ func (c *AccommodationController) CreateAccommodation(w http.ResponseWriter, r *
 	files := r.MultipartForm.File["images"]
 	imageURLs, err := uploadImages(files)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 		return
 	}
 
@@ -113,6 +89,18 @@ func (c *AccommodationController) CreateAccommodation(w http.ResponseWriter, r *
 		Amenities:   amenities,
 	}
 
+	if check := checkAddress(accommodation.Address, accommodation.City, accommodation.Location); check != nil {
+		accommodation.Address = check.Address
+		accommodation.Location = check.Location
+		accommodation.FormattedAddress = check.FormattedAddress
+		accommodation.LocationMismatch = check.Mismatch
+	}
+
+	if cityID, cityName := resolveCity(accommodation.City, accommodation.Location); cityID != nil {
+		accommodation.CityID = cityID
+		accommodation.City = cityName
+	}
+
 	tx := db.DB.Begin()
 	if err := tx.Create(&accommodation).Error; err != nil {
 		tx.Rollback()
@@ -166,6 +154,16 @@ func (c *AccommodationController) CreateAccommodation(w http.ResponseWriter, r *
 				roomType.Price = price
 			}
 
+			if currency, ok := rawRoom["Currency"].(string); ok {
+				roomType.Currency = normalizeCurrency(currency)
+			} else if currency, ok := rawRoom["currency"].(string); ok {
+				roomType.Currency = normalizeCurrency(currency)
+			}
+
+			if roomType.Currency == "" {
+				roomType.Currency = models.DefaultCurrency
+			}
+
 			if maxGuests, ok := rawRoom["MaxGuests"].(float64); ok {
 				roomType.MaxGuests = int(maxGuests)
 			} else if maxGuests, ok := rawRoom["max_guests"].(float64); ok {
@@ -176,6 +174,16 @@ func (c *AccommodationController) CreateAccommodation(w http.ResponseWriter, r *
 				roomType.MaxGuests = 1
 			}
 
+			if quantity, ok := rawRoom["Quantity"].(float64); ok {
+				roomType.Quantity = int(quantity)
+			} else if quantity, ok := rawRoom["quantity"].(float64); ok {
+				roomType.Quantity = int(quantity)
+			}
+
+			if roomType.Quantity <= 0 {
+				roomType.Quantity = 1
+			}
+
 			if bedType, ok := rawRoom["BedType"].(string); ok {
 				roomType.BedType = bedType
 			} else if bedType, ok := rawRoom["bed_type"].(string); ok {
@@ -228,11 +236,18 @@ func (c *AccommodationController) GetAccommodation(w http.ResponseWriter, r *htt
 	id := vars["id"]
 
 	var accommodation models.Accommodation
-	if err := db.DB.Preload("Images").Preload("RoomTypes.Images").Preload("Reviews").Preload("Reviews.Images").First(&accommodation, id).Error; err != nil {
+	if err := db.DB.Preload("Images").Preload("RoomTypes.Images").First(&accommodation, id).Error; err != nil {
 		http.Error(w, "Accommodation not found", http.StatusNotFound)
 		return
 	}
 
+	accommodation.Reviews = []models.AccommodationReview{}
+	if result, _, err := fetchAccommodationReviews(r, id, url.Values{"page_size": {strconv.Itoa(accommodationReviewsLimit)}}); err == nil {
+		accommodation.Reviews = accommodationReviews(result.Reviews)
+	} else {
+		log.Printf("Error fetching reviews of accommodation %s: %v", id, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(accommodation)
 }
@@ -263,6 +278,8 @@ func (c *AccommodationController) UpdateAccommodation(w http.ResponseWriter, r *
 		return
 	}
 
+	previousCity, previousAddress, previousLocation := accommodation.City, accommodation.Address, accommodation.Location
+
 	if name := r.FormValue("name"); name != "" {
 		accommodation.Name = name
 	}
@@ -293,6 +310,25 @@ func (c *AccommodationController) UpdateAccommodation(w http.ResponseWriter, r *
 		accommodation.Amenities = amenities
 	}
 
+	if accommodation.Address != previousAddress || accommodation.Location != previousLocation {
+		accommodation.FormattedAddress = ""
+		accommodation.LocationMismatch = false
+		if check := checkAddress(accommodation.Address, accommodation.City, accommodation.Location); check != nil {
+			accommodation.Address = check.Address
+			accommodation.Location = check.Location
+			accommodation.FormattedAddress = check.FormattedAddress
+			accommodation.LocationMismatch = check.Mismatch
+		}
+	}
+
+	if accommodation.City != previousCity || accommodation.Location != previousLocation {
+		accommodation.CityID = nil
+		if cityID, cityName := resolveCity(accommodation.City, accommodation.Location); cityID != nil {
+			accommodation.CityID = cityID
+			accommodation.City = cityName
+		}
+	}
+
 	tx := db.DB.Begin()
 
 	files := r.MultipartForm.File["images"]
@@ -300,7 +336,7 @@ func (c *AccommodationController) UpdateAccommodation(w http.ResponseWriter, r *
 		imageURLs, err := uploadImages(files)
 		if err != nil {
 			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 			return
 		}
 
@@ -371,6 +407,7 @@ func (c *AccommodationController) UpdateAccommodation(w http.ResponseWriter, r *
 			for i := range roomTypes {
 				roomTypes[i].AccommodationID = accommodation.ID
 				roomTypes[i].ID = 0
+				roomTypes[i].Currency = normalizeCurrency(roomTypes[i].Currency)
 				if err := tx.Create(&roomTypes[i]).Error; err != nil {
 					tx.Rollback()
 					http.Error(w, "Failed to create new room type", http.StatusInternalServerError)
@@ -396,7 +433,13 @@ func (c *AccommodationController) UpdateAccommodation(w http.ResponseWriter, r *
 					existingRoomType.Name = roomType.Name
 					existingRoomType.Description = roomType.Description
 					existingRoomType.Price = roomType.Price
+					if currency := normalizeCurrency(roomType.Currency); currency != "" {
+						existingRoomType.Currency = currency
+					}
 					existingRoomType.MaxGuests = roomType.MaxGuests
+					if roomType.Quantity > 0 {
+						existingRoomType.Quantity = roomType.Quantity
+					}
 					existingRoomType.BedType = roomType.BedType
 					existingRoomType.Amenities = roomType.Amenities
 
@@ -407,6 +450,7 @@ func (c *AccommodationController) UpdateAccommodation(w http.ResponseWriter, r *
 					}
 				} else {
 					roomType.AccommodationID = accommodation.ID
+					roomType.Currency = normalizeCurrency(roomType.Currency)
 					if err := tx.Create(&roomType).Error; err != nil {
 						tx.Rollback()
 						http.Error(w, "Failed to create room type", http.StatusInternalServerError)
@@ -468,10 +512,23 @@ func (c *AccommodationController) DeleteAccommodation(w http.ResponseWriter, r *
 }
 
 func (c *AccommodationController) ListAccommodations(w http.ResponseWriter, r *http.Request) {
+	nearby, errMsg := parseNearby(r)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
 	var accommodations []models.Accommodation
 	query := db.DB.Preload("Images").Preload("RoomTypes.Images").Preload("RoomTypes")
 
-	if city := r.URL.Query().Get("city"); city != "" {
+	if cityIDParam := r.URL.Query().Get("city_id"); cityIDParam != "" {
+		cityID, err := strconv.ParseUint(cityIDParam, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid city_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("city_id = ?", cityID)
+	} else if city := r.URL.Query().Get("city"); city != "" {
 		query = query.Where("city LIKE ?", "%"+city+"%")
 	}
 
@@ -506,62 +563,39 @@ func (c *AccommodationController) ListAccommodations(w http.ResponseWriter, r *h
 		}
 	}
 
-	if lat := r.URL.Query().Get("lat"); lat != "" {
-		if lng := r.URL.Query().Get("lng"); lng != "" {
-			latFloat, latErr := strconv.ParseFloat(lat, 64)
-			lngFloat, lngErr := strconv.ParseFloat(lng, 64)
-			if latErr == nil && lngErr == nil {
-				maxDistance := 10.0
-				if distance := r.URL.Query().Get("distance"); distance != "" {
-					if distFloat, err := strconv.ParseFloat(distance, 64); err == nil {
-						maxDistance = distFloat
-					}
-				}
-
-				var results []struct {
-					ID       uint
-					Distance float64
-				}
-
-				var allAccommodations []models.Accommodation
-				if err := db.DB.Find(&allAccommodations).Error; err != nil {
-					http.Error(w, "Failed to fetch accommodations", http.StatusInternalServerError)
-					return
-				}
-
-				for _, acc := range allAccommodations {
-					parts := strings.Split(acc.Location, ",")
-					if len(parts) != 2 {
-						continue
-					}
-					accLat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
-					accLng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
-					if err1 != nil || err2 != nil {
-						continue
-					}
-
-					distance := calculateDistance(latFloat, lngFloat, accLat, accLng)
-					if distance <= maxDistance {
-						results = append(results, struct {
-							ID       uint
-							Distance float64
-						}{ID: acc.ID, Distance: distance})
-					}
-				}
-
-				var ids []uint
-				for _, result := range results {
-					ids = append(ids, result.ID)
-				}
+	guests := 1
+	if guestsStr := r.URL.Query().Get("guests"); guestsStr != "" {
+		guestsInt, err := strconv.Atoi(guestsStr)
+		if err != nil || guestsInt < 1 {
+			http.Error(w, "Invalid guests", http.StatusBadRequest)
+			return
+		}
+		guests = guestsInt
+	}
+	rooms := 1
+	if roomsStr := r.URL.Query().Get("rooms"); roomsStr != "" {
+		roomsInt, err := strconv.Atoi(roomsStr)
+		if err != nil || roomsInt < 1 {
+			http.Error(w, "Invalid rooms", http.StatusBadRequest)
+			return
+		}
+		rooms = roomsInt
+	}
 
-				if len(ids) > 0 {
-					query = query.Where("id IN ?", ids)
-				} else {
-					json.NewEncoder(w).Encode([]models.Accommodation{})
-					return
-				}
-			}
+	var checkIn, checkOut time.Time
+	checkInStr, checkOutStr := r.URL.Query().Get("check_in"), r.URL.Query().Get("check_out")
+	hasStay := checkInStr != "" || checkOutStr != ""
+	if hasStay {
+		var err error
+		checkIn, checkOut, err = booking.ParseStay(checkInStr, checkOutStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		availableSQL, args := booking.AvailableAccommodationsSQL(checkIn, checkOut, guests, rooms)
+		query = query.Where("accommodations.id IN ("+availableSQL+")", args...)
+	} else if r.URL.Query().Get("guests") != "" {
+		query = query.Where("accommodations.id IN (SELECT accommodation_id FROM room_types WHERE deleted_at IS NULL AND max_guests * ? >= ?)", rooms, guests)
 	}
 
 	page := 1
@@ -577,6 +611,63 @@ func (c *AccommodationController) ListAccommodations(w http.ResponseWriter, r *h
 		}
 	}
 
+	if nearby != nil {
+		rows, total, err := nearby.page(query.Model(&models.Accommodation{}).Where("accommodations.is_published = ?", true), "accommodations", page, pageSize)
+		if err != nil {
+			http.Error(w, "Failed to fetch accommodations", http.StatusInternalServerError)
+			return
+		}
+
+		ids := make([]uint, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+
+		var found []models.Accommodation
+		if len(ids) > 0 {
+			err := db.DB.Preload("Images").Preload("RoomTypes.Images").Preload("RoomTypes").
+				Where("id IN ?", ids).Find(&found).Error
+			if err != nil {
+				http.Error(w, "Failed to fetch accommodations", http.StatusInternalServerError)
+				return
+			}
+		}
+		if hasStay {
+			if err := attachStayPrices(found, checkIn, checkOut, guests, rooms); err != nil {
+				http.Error(w, "Failed to price stays", http.StatusInternalServerError)
+				return
+			}
+		}
+		byID := make(map[uint]models.Accommodation, len(found))
+		for _, accommodation := range found {
+			byID[accommodation.ID] = accommodation
+		}
+
+		withDistance := []models.AccommodationWithDistance{}
+		for _, row := range rows {
+			if accommodation, ok := byID[row.ID]; ok {
+				withDistance = append(withDistance, models.AccommodationWithDistance{
+					Accommodation: accommodation,
+					Distance:      math.Round(row.Distance*10) / 10,
+				})
+			}
+		}
+
+		response := map[string]interface{}{
+			"accommodations": withDistance,
+			"pagination": map[string]interface{}{
+				"page":        page,
+				"page_size":   pageSize,
+				"total":       total,
+				"total_pages": int(math.Ceil(float64(total) / float64(pageSize))),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
 	var totalCount int64
 	query.Model(&models.Accommodation{}).Count(&totalCount)
 
@@ -592,6 +683,13 @@ func (c *AccommodationController) ListAccommodations(w http.ResponseWriter, r *h
 		return
 	}
 
+	if hasStay {
+		if err := attachStayPrices(accommodations, checkIn, checkOut, guests, rooms); err != nil {
+			http.Error(w, "Failed to price stays", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	response := map[string]interface{}{
 		"accommodations": accommodations,
 		"pagination": map[string]interface{}{
@@ -723,12 +821,10 @@ func (c *AccommodationController) UnpublishAccommodation(w http.ResponseWriter,
 	json.NewEncoder(w).Encode(map[string]string{"message": "Accommodation unpublished successfully"})
 }
 
-func (c *AccommodationController) AddReview(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
+// Accommodation reviews are stored by review-service. These handlers keep
+// the original endpoints and response shapes working on top of it.
 
+func (c *AccommodationController) AddReview(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	accommodationID, err := strconv.ParseUint(vars["id"], 10, 32)
 	if err != nil {
@@ -742,92 +838,36 @@ func (c *AccommodationController) AddReview(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	userIDValue := r.Context().Value("user_id")
-	if userIDValue == nil {
+	if r.Context().Value("user_id") == nil {
 		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
 		return
 	}
-	userID, ok := userIDValue.(uint)
-	if !ok {
-		http.Error(w, "Internal Server Error - Invalid User ID", http.StatusInternalServerError)
-		return
-	}
-
-	usernameValue := r.Context().Value("username")
-	username := ""
-	if usernameValue != nil {
-		if usernameStr, ok := usernameValue.(string); ok {
-			username = usernameStr
-		}
-	}
-
-	ratingStr := r.FormValue("rating")
-	rating, err := strconv.Atoi(ratingStr)
-	if err != nil || rating < 1 || rating > 5 {
-		http.Error(w, "Invalid rating - must be between 1 and 5", http.StatusBadRequest)
-		return
-	}
 
-	comment := r.FormValue("comment")
-
-	review := models.AccommodationReview{
-		AccommodationID: uint(accommodationID),
-		UserID:          userID,
-		Username:        username,
-		Rating:          rating,
-		Comment:         comment,
-	}
-
-	tx := db.DB.Begin()
-	if err := tx.Create(&review).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to create review", http.StatusInternalServerError)
+	query := url.Values{"item_type": {reviewItemType}, "item_id": {vars["id"]}}
+	resp, err := reviewRequest(r, http.MethodPost, "/reviews?"+query.Encode(), r.Body)
+	if err != nil {
+		log.Printf("Error creating review: %v", err)
+		http.Error(w, "Failed to create review", http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	files := r.MultipartForm.File["images"]
-	if len(files) > 0 {
-		imageURLs, err := uploadImages(files)
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		for _, url := range imageURLs {
-			image := models.ReviewImage{
-				ReviewID: review.ID,
-				URL:      url,
-			}
-			if err := tx.Create(&image).Error; err != nil {
-				tx.Rollback()
-				http.Error(w, "Failed to save image", http.StatusInternalServerError)
-				return
-			}
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+	if resp.StatusCode != http.StatusCreated {
+		relayReviewError(w, resp)
 		return
 	}
-
-	if err := db.DB.Preload("Images").First(&review, review.ID).Error; err != nil {
-		http.Error(w, "Failed to reload review", http.StatusInternalServerError)
+	var created review
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		http.Error(w, "Failed to read review", http.StatusBadGateway)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(review)
+	json.NewEncoder(w).Encode(created.accommodationReview())
 }
 
 func (c *AccommodationController) UpdateReview(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
-
 	vars := mux.Vars(r)
 	reviewID, err := strconv.ParseUint(vars["review_id"], 10, 32)
 	if err != nil {
@@ -835,9 +875,9 @@ func (c *AccommodationController) UpdateReview(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	var review models.AccommodationReview
-	if err := db.DB.First(&review, reviewID).Error; err != nil {
-		http.Error(w, "Review not found", http.StatusNotFound)
+	existing, status, err := fetchAccommodationReview(r, reviewID)
+	if err != nil {
+		writeReviewLookupError(w, status, err)
 		return
 	}
 
@@ -847,75 +887,31 @@ func (c *AccommodationController) UpdateReview(w http.ResponseWriter, r *http.Re
 		return
 	}
 	userID, ok := userIDValue.(uint)
-	if !ok || userID != review.UserID {
+	if !ok || userID != existing.UserID {
 		http.Error(w, "Unauthorized - not the review owner", http.StatusUnauthorized)
 		return
 	}
 
-	if ratingStr := r.FormValue("rating"); ratingStr != "" {
-		rating, err := strconv.Atoi(ratingStr)
-		if err != nil || rating < 1 || rating > 5 {
-			http.Error(w, "Invalid rating - must be between 1 and 5", http.StatusBadRequest)
-			return
-		}
-		review.Rating = rating
-	}
-
-	if comment := r.FormValue("comment"); comment != "" {
-		review.Comment = comment
-	}
-
-	tx := db.DB.Begin()
-
-	files := r.MultipartForm.File["images"]
-	if len(files) > 0 {
-
-		if deleteExisting := r.FormValue("delete_existing_images"); deleteExisting == "true" {
-			if err := tx.Where("review_id = ?", review.ID).Delete(&models.ReviewImage{}).Error; err != nil {
-				tx.Rollback()
-				http.Error(w, "Failed to delete existing images", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		imageURLs, err := uploadImages(files)
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		for _, url := range imageURLs {
-			image := models.ReviewImage{
-				ReviewID: review.ID,
-				URL:      url,
-			}
-			if err := tx.Create(&image).Error; err != nil {
-				tx.Rollback()
-				http.Error(w, "Failed to save image", http.StatusInternalServerError)
-				return
-			}
-		}
-	}
-
-	if err := tx.Save(&review).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to update review", http.StatusInternalServerError)
+	resp, err := reviewRequest(r, http.MethodPut, fmt.Sprintf("/reviews/%d", reviewID), r.Body)
+	if err != nil {
+		log.Printf("Error updating review %d: %v", reviewID, err)
+		http.Error(w, "Failed to update review", http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	if err := tx.Commit().Error; err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+	if resp.StatusCode != http.StatusOK {
+		relayReviewError(w, resp)
 		return
 	}
-
-	if err := db.DB.Preload("Images").First(&review, review.ID).Error; err != nil {
-		http.Error(w, "Failed to reload review", http.StatusInternalServerError)
+	var updated review
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		http.Error(w, "Failed to read review", http.StatusBadGateway)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(review)
+	json.NewEncoder(w).Encode(updated.accommodationReview())
 }
 
 func (c *AccommodationController) DeleteReview(w http.ResponseWriter, r *http.Request) {
@@ -926,9 +922,9 @@ func (c *AccommodationController) DeleteReview(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	var review models.AccommodationReview
-	if err := db.DB.First(&review, reviewID).Error; err != nil {
-		http.Error(w, "Review not found", http.StatusNotFound)
+	existing, status, err := fetchAccommodationReview(r, reviewID)
+	if err != nil {
+		writeReviewLookupError(w, status, err)
 		return
 	}
 
@@ -937,11 +933,12 @@ func (c *AccommodationController) DeleteReview(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
 		return
 	}
+	path := fmt.Sprintf("/reviews/%d", reviewID)
 	userID, ok := userIDValue.(uint)
-	if !ok || userID != review.UserID {
+	if !ok || userID != existing.UserID {
 
 		var accommodation models.Accommodation
-		if err := db.DB.First(&accommodation, review.AccommodationID).Error; err != nil {
+		if err := db.DB.First(&accommodation, existing.ItemID).Error; err != nil {
 			http.Error(w, "Unauthorized - not the review owner", http.StatusUnauthorized)
 			return
 		}
@@ -956,10 +953,19 @@ func (c *AccommodationController) DeleteReview(w http.ResponseWriter, r *http.Re
 			http.Error(w, "Unauthorized - not the review owner or accommodation owner", http.StatusUnauthorized)
 			return
 		}
+		path = fmt.Sprintf("/internal/reviews/%d", reviewID)
+	}
+
+	resp, err := reviewRequest(r, http.MethodDelete, path, nil)
+	if err != nil {
+		log.Printf("Error deleting review %d: %v", reviewID, err)
+		http.Error(w, "Failed to delete review", http.StatusBadGateway)
+		return
 	}
+	defer resp.Body.Close()
 
-	if err := db.DB.Delete(&review).Error; err != nil {
-		http.Error(w, "Failed to delete review", http.StatusInternalServerError)
+	if resp.StatusCode != http.StatusNoContent {
+		relayReviewError(w, resp)
 		return
 	}
 
@@ -971,68 +977,23 @@ func (c *AccommodationController) GetAccommodationReviews(w http.ResponseWriter,
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	var reviews []models.AccommodationReview
-	query := db.DB.Preload("Images").Where("accommodation_id = ?", id)
-
-	page := 1
-	pageSize := 20
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if pageInt, err := strconv.Atoi(pageStr); err == nil && pageInt > 0 {
-			page = pageInt
-		}
-	}
-	if pageSizeStr := r.URL.Query().Get("page_size"); pageSizeStr != "" {
-		if pageSizeInt, err := strconv.Atoi(pageSizeStr); err == nil && pageSizeInt > 0 {
-			pageSize = pageSizeInt
-		}
-	}
-
-	var totalCount int64
-	query.Model(&models.AccommodationReview{}).Count(&totalCount)
-
-	offset := (page - 1) * pageSize
-	query = query.Offset(offset).Limit(pageSize)
-
-	query = query.Order("created_at desc")
-
-	if err := query.Find(&reviews).Error; err != nil {
-		http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+	result, status, err := fetchAccommodationReviews(r, id, r.URL.Query())
+	if err != nil {
+		log.Printf("Error fetching reviews of accommodation %s: %v", id, err)
+		http.Error(w, "Failed to fetch reviews", status)
 		return
 	}
 
 	response := map[string]interface{}{
-		"reviews": reviews,
-		"pagination": map[string]interface{}{
-			"page":        page,
-			"page_size":   pageSize,
-			"total":       totalCount,
-			"total_pages": int(math.Ceil(float64(totalCount) / float64(pageSize))),
-		},
+		"reviews":    accommodationReviews(result.Reviews),
+		"pagination": result.Pagination,
+		"stats":      result.Stats,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
-	const R = 6371
-
-	lat1Rad := lat1 * math.Pi / 180
-	lon1Rad := lon1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	lon2Rad := lon2 * math.Pi / 180
-
-	dLat := lat2Rad - lat1Rad
-	dLon := lon2Rad - lon1Rad
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1Rad)*math.Cos(lat2Rad)*
-			math.Sin(dLon/2)*math.Sin(dLon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	distance := R * c
-
-	return distance
-}
-
 func (c *AccommodationController) UploadRoomTypeImages(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
@@ -1084,7 +1045,7 @@ func (c *AccommodationController) UploadRoomTypeImages(w http.ResponseWriter, r
 		imageURLs, err := uploadImages(files)
 		if err != nil {
 			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 			return
 		}
 
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	models "accommodation_service/internal/model"
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func getPaymentServiceURL() string {
+	if u := os.Getenv("PAYMENT_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://payment-service:8093"
+}
+
+// refundReservation asks payment_service to refund a cancelled
+// reservation that was paid for. If it cannot be reached, its periodic
+// sweep refunds the reservation later.
+func refundReservation(reservation *models.Reservation) {
+	if reservation.PaymentStatus != "captured" {
+		return
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"subject_type": "reservation", "subject_id": reservation.ID})
+	req, err := http.NewRequest(http.MethodPost, getPaymentServiceURL()+"/internal/payments/refunds", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error requesting refund of reservation %d: %v", reservation.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error requesting refund of reservation %d: %v", reservation.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Refund of reservation %d returned %s", reservation.ID, resp.Status)
+	}
+}
@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"accommodation_service/internal/booking"
+	models "accommodation_service/internal/model"
+	"accommodation_service/internal/pricing"
+	"accommodation_service/utils/db"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PricingRuleRequest struct {
+	Kind       string  `json:"kind"`
+	Name       string  `json:"name"`
+	StartDate  string  `json:"start_date"`
+	EndDate    string  `json:"end_date"`
+	Rate       float64 `json:"rate"`
+	Percent    float64 `json:"percent"`
+	Amount     float64 `json:"amount"`
+	MinNights  int     `json:"min_nights"`
+	BaseGuests int     `json:"base_guests"`
+	Weekdays   string  `json:"weekdays"`
+}
+
+// apply copies the request onto a rule. Dates are only kept for the kinds
+// that use them.
+func (req PricingRuleRequest) apply(rule *models.PricingRule) string {
+	rule.Kind = req.Kind
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Rate = req.Rate
+	rule.Percent = req.Percent
+	rule.Amount = req.Amount
+	rule.MinNights = req.MinNights
+	rule.BaseGuests = req.BaseGuests
+	rule.Weekdays = strings.ReplaceAll(req.Weekdays, " ", "")
+	rule.StartDate, rule.EndDate = nil, nil
+
+	if req.Kind == models.RuleSeason || req.Kind == models.RuleHoliday {
+		if req.StartDate == "" || req.EndDate == "" {
+			return pricing.ErrInvalidDates.Error()
+		}
+		start, err := booking.ParseDate(req.StartDate)
+		if err != nil {
+			return "Invalid start_date - use YYYY-MM-DD"
+		}
+		end, err := booking.ParseDate(req.EndDate)
+		if err != nil {
+			return "Invalid end_date - use YYYY-MM-DD"
+		}
+		rule.StartDate, rule.EndDate = &start, &end
+	}
+	return ""
+}
+
+// normalizeCurrency upper-cases an ISO 4217 code and drops anything that is
+// not three letters.
+func normalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) != 3 {
+		return ""
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+	}
+	return currency
+}
+
+func writePricingError(w http.ResponseWriter, err error) {
+	switch err {
+	case pricing.ErrOverlap:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case pricing.ErrInvalidKind, pricing.ErrInvalidDates, pricing.ErrInvalidRate, pricing.ErrInvalidPercent,
+		pricing.ErrInvalidDiscount, pricing.ErrInvalidWeekdays, pricing.ErrInvalidGuests:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to save pricing rule", http.StatusInternalServerError)
+	}
+}
+
+// ownedRoomType loads a room type and checks that its accommodation belongs
+// to the calling admin.
+func ownedRoomType(w http.ResponseWriter, r *http.Request, roomTypeID uint) (*models.RoomType, bool) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - admin ID missing", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var roomType models.RoomType
+	if err := db.DB.First(&roomType, roomTypeID).Error; err != nil {
+		http.Error(w, "Room type not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	var accommodation models.Accommodation
+	if err := db.DB.First(&accommodation, roomType.AccommodationID).Error; err != nil {
+		http.Error(w, "Accommodation not found", http.StatusNotFound)
+		return nil, false
+	}
+	if accommodation.AdminID != adminID {
+		http.Error(w, "Unauthorized - not the accommodation owner", http.StatusUnauthorized)
+		return nil, false
+	}
+	return &roomType, true
+}
+
+// saveRule validates and stores a rule while the room type row is locked,
+// so two concurrent requests cannot add overlapping rules.
+func saveRule(rule *models.PricingRule) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var roomType models.RoomType
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&roomType, rule.RoomTypeID).Error; err != nil {
+			return err
+		}
+
+		rules, err := pricing.LoadRules(tx, rule.RoomTypeID)
+		if err != nil {
+			return err
+		}
+		if err := pricing.Validate(*rule, rules[rule.RoomTypeID]); err != nil {
+			return err
+		}
+		return tx.Save(rule).Error
+	})
+}
+
+// attachStayPrices sets the cheapest available stay on each accommodation.
+func attachStayPrices(accommodations []models.Accommodation, checkIn, checkOut time.Time, guests, rooms int) error {
+	ids := make([]uint, len(accommodations))
+	for i, accommodation := range accommodations {
+		ids[i] = accommodation.ID
+	}
+
+	prices, err := booking.StayPrices(ids, checkIn, checkOut, guests, rooms)
+	if err != nil {
+		return err
+	}
+	for i := range accommodations {
+		accommodations[i].StayPrice = prices[accommodations[i].ID]
+	}
+	return nil
+}
+
+// GetQuote prices a stay in one room type with its line items.
+func (c *AccommodationController) GetQuote(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	roomTypeID, err := strconv.ParseUint(query.Get("room_type_id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid room_type_id", http.StatusBadRequest)
+		return
+	}
+
+	checkIn, checkOut, err := booking.ParseStay(query.Get("check_in"), query.Get("check_out"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	guests, rooms := 1, 1
+	if value := query.Get("guests"); value != "" {
+		if guests, err = strconv.Atoi(value); err != nil || guests < 1 {
+			http.Error(w, "Invalid guests", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("rooms"); value != "" {
+		if rooms, err = strconv.Atoi(value); err != nil || rooms < 1 {
+			http.Error(w, "Invalid rooms", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var roomType models.RoomType
+	err = db.DB.Joins("JOIN accommodations ON accommodations.id = room_types.accommodation_id").
+		Where("room_types.id = ? AND room_types.accommodation_id = ? AND accommodations.is_published = ?",
+			roomTypeID, mux.Vars(r)["id"], true).
+		First(&roomType).Error
+	if err != nil {
+		http.Error(w, "Room type not found", http.StatusNotFound)
+		return
+	}
+	if guests > roomType.MaxGuests*rooms {
+		http.Error(w, booking.ErrTooManyGuests.Error(), http.StatusBadRequest)
+		return
+	}
+
+	quote, err := pricing.Quote(db.DB, roomType, checkIn, checkOut, guests, rooms)
+	if err != nil {
+		http.Error(w, "Failed to price stay", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, quote)
+}
+
+func (c *AccommodationController) ListPricingRules(w http.ResponseWriter, r *http.Request) {
+	roomTypeID, err := strconv.ParseUint(mux.Vars(r)["room_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid room type ID", http.StatusBadRequest)
+		return
+	}
+
+	roomType, ok := ownedRoomType(w, r, uint(roomTypeID))
+	if !ok {
+		return
+	}
+
+	rules := []models.PricingRule{}
+	if err := db.DB.Where("room_type_id = ?", roomType.ID).Order("kind, start_date, min_nights").Find(&rules).Error; err != nil {
+		http.Error(w, "Failed to fetch pricing rules", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"room_type_id":  roomType.ID,
+		"base_price":    roomType.Price,
+		"currency":      roomType.Currency,
+		"pricing_rules": rules,
+	})
+}
+
+func (c *AccommodationController) CreatePricingRule(w http.ResponseWriter, r *http.Request) {
+	roomTypeID, err := strconv.ParseUint(mux.Vars(r)["room_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid room type ID", http.StatusBadRequest)
+		return
+	}
+
+	roomType, ok := ownedRoomType(w, r, uint(roomTypeID))
+	if !ok {
+		return
+	}
+
+	var req PricingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule := models.PricingRule{RoomTypeID: roomType.ID}
+	if errMsg := req.apply(&rule); errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	if err := saveRule(&rule); err != nil {
+		writePricingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (c *AccommodationController) UpdatePricingRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.PricingRule
+	if err := db.DB.First(&rule, mux.Vars(r)["rule_id"]).Error; err != nil {
+		http.Error(w, "Pricing rule not found", http.StatusNotFound)
+		return
+	}
+
+	if _, ok := ownedRoomType(w, r, rule.RoomTypeID); !ok {
+		return
+	}
+
+	var req PricingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if errMsg := req.apply(&rule); errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	if err := saveRule(&rule); err != nil {
+		writePricingError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (c *AccommodationController) DeletePricingRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.PricingRule
+	if err := db.DB.First(&rule, mux.Vars(r)["rule_id"]).Error; err != nil {
+		http.Error(w, "Pricing rule not found", http.StatusNotFound)
+		return
+	}
+
+	if _, ok := ownedRoomType(w, r, rule.RoomTypeID); !ok {
+		return
+	}
+
+	if err := db.DB.Delete(&rule).Error; err != nil {
+		http.Error(w, "Failed to delete pricing rule", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Pricing rule deleted successfully"})
+}
@@ -0,0 +1,392 @@
+package controllers
+
+import (
+	"accommodation_service/internal/booking"
+	models "accommodation_service/internal/model"
+	"accommodation_service/utils/db"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const maxOccupancyDays = 92
+
+type ReservationRequest struct {
+	RoomTypeID uint   `json:"room_type_id"`
+	CheckIn    string `json:"check_in"`
+	CheckOut   string `json:"check_out"`
+	Guests     int    `json:"guests"`
+	Rooms      int    `json:"rooms"`
+	Notes      string `json:"notes"`
+}
+
+type InventoryRequest struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Total int    `json:"total"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeBookingError maps booking errors to HTTP statuses.
+func writeBookingError(w http.ResponseWriter, err error, notFound string) {
+	switch err {
+	case booking.ErrNotFound:
+		http.Error(w, notFound, http.StatusNotFound)
+	case booking.ErrInvalidStay, booking.ErrTooManyGuests, booking.ErrInvalidInventory:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case booking.ErrNoAvailability, booking.ErrInvalidState, booking.ErrHoldExpired, booking.ErrBelowBooked:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case booking.ErrPaymentRequired:
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	default:
+		log.Printf("Booking error: %v", err)
+		http.Error(w, "Failed to process reservation", http.StatusInternalServerError)
+	}
+}
+
+func contextUserID(r *http.Request) (uint, bool) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	return userID, ok
+}
+
+// ownedAccommodation loads the accommodation in the {id} route variable and
+// checks that it belongs to the calling admin.
+func ownedAccommodation(w http.ResponseWriter, r *http.Request) (*models.Accommodation, bool) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - admin ID missing", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var accommodation models.Accommodation
+	if err := db.DB.First(&accommodation, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Accommodation not found", http.StatusNotFound)
+		return nil, false
+	}
+	if accommodation.AdminID != adminID {
+		http.Error(w, "Unauthorized - not the accommodation owner", http.StatusUnauthorized)
+		return nil, false
+	}
+	return &accommodation, true
+}
+
+func (c *AccommodationController) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	accommodationID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid accommodation ID", http.StatusBadRequest)
+		return
+	}
+
+	checkIn, checkOut, err := booking.ParseStay(r.URL.Query().Get("check_in"), r.URL.Query().Get("check_out"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	guests := 0
+	if value := r.URL.Query().Get("guests"); value != "" {
+		if guests, err = strconv.Atoi(value); err != nil || guests < 1 {
+			http.Error(w, "Invalid guests", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var accommodation models.Accommodation
+	if err := db.DB.Where("id = ? AND is_published = ?", accommodationID, true).First(&accommodation).Error; err != nil {
+		http.Error(w, "Accommodation not found", http.StatusNotFound)
+		return
+	}
+
+	rooms, err := booking.Availability(accommodation.ID, checkIn, checkOut, guests)
+	if err != nil {
+		http.Error(w, "Failed to check availability", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"accommodation_id": accommodation.ID,
+		"check_in":         checkIn.Format(booking.DateLayout),
+		"check_out":        checkOut.Format(booking.DateLayout),
+		"room_types":       rooms,
+	})
+}
+
+func (c *AccommodationController) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	accommodationID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid accommodation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RoomTypeID == 0 {
+		http.Error(w, "room_type_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.Guests < 1 {
+		http.Error(w, "guests must be at least 1", http.StatusBadRequest)
+		return
+	}
+	if req.Rooms < 0 {
+		http.Error(w, "rooms must be positive", http.StatusBadRequest)
+		return
+	}
+
+	checkIn, checkOut, err := booking.ParseStay(req.CheckIn, req.CheckOut)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reservation := models.Reservation{
+		AccommodationID: uint(accommodationID),
+		RoomTypeID:      req.RoomTypeID,
+		UserID:          userID,
+		CheckIn:         checkIn,
+		CheckOut:        checkOut,
+		Guests:          req.Guests,
+		Rooms:           req.Rooms,
+		Notes:           req.Notes,
+	}
+	if err := booking.Hold(&reservation); err != nil {
+		writeBookingError(w, err, "Accommodation or room type not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, reservation)
+}
+
+func (c *AccommodationController) ListMyReservations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	query := db.DB.Preload("RoomType").Where("user_id = ?", userID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	reservations := []models.Reservation{}
+	if err := query.Order("check_in DESC").Find(&reservations).Error; err != nil {
+		http.Error(w, "Failed to fetch reservations", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservations)
+}
+
+func (c *AccommodationController) ConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	reservationID, err := strconv.ParseUint(mux.Vars(r)["reservation_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	reservation, err := booking.Confirm(uint(reservationID), userID)
+	if err != nil {
+		writeBookingError(w, err, "Reservation not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (c *AccommodationController) CancelReservation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	reservationID, err := strconv.ParseUint(mux.Vars(r)["reservation_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	reservation, err := booking.Cancel(uint(reservationID), userID)
+	if err != nil {
+		writeBookingError(w, err, "Reservation not found")
+		return
+	}
+	refundReservation(reservation)
+
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func (c *AccommodationController) ListAccommodationReservations(w http.ResponseWriter, r *http.Request) {
+	accommodation, ok := ownedAccommodation(w, r)
+	if !ok {
+		return
+	}
+
+	query := db.DB.Preload("RoomType").Where("accommodation_id = ?", accommodation.ID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if from := r.URL.Query().Get("from"); from != "" {
+		if _, err := booking.ParseDate(from); err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("check_out > ?", from)
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		if _, err := booking.ParseDate(to); err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("check_in < ?", to)
+	}
+
+	reservations := []models.Reservation{}
+	if err := query.Order("check_in ASC").Find(&reservations).Error; err != nil {
+		http.Error(w, "Failed to fetch reservations", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reservations)
+}
+
+func (c *AccommodationController) AdminCancelReservation(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - admin ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	var reservation models.Reservation
+	if err := db.DB.First(&reservation, mux.Vars(r)["reservation_id"]).Error; err != nil {
+		http.Error(w, "Reservation not found", http.StatusNotFound)
+		return
+	}
+
+	var accommodation models.Accommodation
+	if err := db.DB.Unscoped().First(&accommodation, reservation.AccommodationID).Error; err != nil || accommodation.AdminID != adminID {
+		http.Error(w, "Unauthorized - not the accommodation owner", http.StatusUnauthorized)
+		return
+	}
+
+	cancelled, err := booking.Cancel(reservation.ID, 0)
+	if err != nil {
+		writeBookingError(w, err, "Reservation not found")
+		return
+	}
+	refundReservation(cancelled)
+
+	writeJSON(w, http.StatusOK, cancelled)
+}
+
+// parseDateRange reads from and to as a half-open range of nights.
+func parseDateRange(from, to string, maxDays int) (time.Time, time.Time, string) {
+	start, err := booking.ParseDate(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, "from must be a YYYY-MM-DD date"
+	}
+	end, err := booking.ParseDate(to)
+	if err != nil {
+		return time.Time{}, time.Time{}, "to must be a YYYY-MM-DD date"
+	}
+	days := int(end.Sub(start).Hours() / 24)
+	if days < 1 || days > maxDays {
+		return time.Time{}, time.Time{}, "to must be after from and the range at most " + strconv.Itoa(maxDays) + " days"
+	}
+	return start, end, ""
+}
+
+func (c *AccommodationController) GetOccupancy(w http.ResponseWriter, r *http.Request) {
+	accommodation, ok := ownedAccommodation(w, r)
+	if !ok {
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = time.Now().UTC().Format(booking.DateLayout)
+	}
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		start, _ := booking.ParseDate(from)
+		to = start.AddDate(0, 0, 30).Format(booking.DateLayout)
+	}
+
+	start, end, errMsg := parseDateRange(from, to, maxOccupancyDays)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	occupancy, err := booking.Occupancy(accommodation.ID, start, end)
+	if err != nil {
+		http.Error(w, "Failed to fetch occupancy", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"accommodation_id": accommodation.ID,
+		"from":             start.Format(booking.DateLayout),
+		"to":               end.Format(booking.DateLayout),
+		"room_types":       occupancy,
+	})
+}
+
+// SetRoomInventory sets how many rooms of a type can be sold on each night
+// from "from" up to, but not including, "to". A total of 0 closes sales.
+func (c *AccommodationController) SetRoomInventory(w http.ResponseWriter, r *http.Request) {
+	roomTypeID, err := strconv.ParseUint(mux.Vars(r)["room_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid room type ID", http.StatusBadRequest)
+		return
+	}
+
+	roomType, ok := ownedRoomType(w, r, uint(roomTypeID))
+	if !ok {
+		return
+	}
+
+	var req InventoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	from, to, errMsg := parseDateRange(req.From, req.To, 366)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	if err := booking.SetInventory(*roomType, from, to, req.Total); err != nil {
+		writeBookingError(w, err, "Room type not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Inventory updated successfully"})
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	models "accommodation_service/internal/model"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// reviewItemType is what review-service calls accommodations.
+const reviewItemType = "accommodation"
+
+// accommodationReviewsLimit caps the reviews embedded in an accommodation's
+// details; the rest are paged through GetAccommodationReviews.
+const accommodationReviewsLimit = 100
+
+func getReviewServiceURL() string {
+	if u := os.Getenv("REVIEW_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://review-service:8086"
+}
+
+// review is a review as review-service returns it.
+type review struct {
+	ID          uint      `json:"id"`
+	ItemType    string    `json:"item_type"`
+	ItemID      uint      `json:"item_id"`
+	UserID      uint      `json:"user_id"`
+	Username    string    `json:"username"`
+	ProfileImg  string    `json:"profile_img"`
+	Rating      int       `json:"rating"`
+	Comment     string    `json:"comment"`
+	Verified    bool      `json:"verified"`
+	VerifiedVia string    `json:"verified_via"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Images      []struct {
+		ID       uint   `json:"id"`
+		ReviewID uint   `json:"review_id"`
+		URL      string `json:"url"`
+		MediaID  *uint  `json:"media_id"`
+	} `json:"images"`
+	Aspects      []models.ReviewAspect `json:"aspects"`
+	HelpfulCount int                   `json:"helpful_count"`
+	VotedHelpful bool                  `json:"voted_helpful"`
+	Reply        *models.ReviewReply   `json:"reply"`
+}
+
+func (rv review) accommodationReview() models.AccommodationReview {
+	result := models.AccommodationReview{
+		ID:              rv.ID,
+		CreatedAt:       rv.CreatedAt,
+		UpdatedAt:       rv.UpdatedAt,
+		AccommodationID: rv.ItemID,
+		UserID:          rv.UserID,
+		Username:        rv.Username,
+		ProfileImg:      rv.ProfileImg,
+		Rating:          rv.Rating,
+		Comment:         rv.Comment,
+		Verified:        rv.Verified,
+		VerifiedVia:     rv.VerifiedVia,
+		Images:          []models.ReviewImage{},
+		Aspects:         rv.Aspects,
+		HelpfulCount:    rv.HelpfulCount,
+		VotedHelpful:    rv.VotedHelpful,
+		Reply:           rv.Reply,
+	}
+	if result.Aspects == nil {
+		result.Aspects = []models.ReviewAspect{}
+	}
+	for _, image := range rv.Images {
+		result.Images = append(result.Images, models.ReviewImage{
+			ID:       image.ID,
+			ReviewID: image.ReviewID,
+			URL:      image.URL,
+			MediaID:  image.MediaID,
+		})
+	}
+	return result
+}
+
+func accommodationReviews(reviews []review) []models.AccommodationReview {
+	result := make([]models.AccommodationReview, 0, len(reviews))
+	for _, rv := range reviews {
+		result = append(result, rv.accommodationReview())
+	}
+	return result
+}
+
+var reviewClient = &http.Client{Timeout: 60 * time.Second}
+
+// reviewRequest calls review-service on behalf of the user of r, passing
+// the body through with its content type. Calls to /internal routes carry the
+// service token.
+func reviewRequest(r *http.Request, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.Context(), method, getReviewServiceURL()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+	}
+	req.Header.Set("X-User-ID", r.Header.Get("X-User-ID"))
+	req.Header.Set("X-Username", r.Header.Get("X-Username"))
+	if strings.HasPrefix(path, "/internal/") {
+		req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+	}
+	return reviewClient.Do(req)
+}
+
+// relayReviewError passes a failed review-service response on to the client.
+func relayReviewError(w http.ResponseWriter, resp *http.Response) {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	http.Error(w, strings.TrimSpace(string(message)), resp.StatusCode)
+}
+
+// fetchAccommodationReview loads a review and makes sure it is about an
+// accommodation.
+func fetchAccommodationReview(r *http.Request, id uint64) (*review, int, error) {
+	resp, err := reviewRequest(r, http.MethodGet, fmt.Sprintf("/reviews/%d", id), nil)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("review service returned %d", resp.StatusCode)
+	}
+	var rv review
+	if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	if rv.ItemType != reviewItemType {
+		return nil, http.StatusNotFound, fmt.Errorf("review %d is not about an accommodation", id)
+	}
+	return &rv, http.StatusOK, nil
+}
+
+func writeReviewLookupError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusNotFound {
+		http.Error(w, "Review not found", status)
+		return
+	}
+	log.Printf("Error loading review: %v", err)
+	http.Error(w, "Failed to load review", status)
+}
+
+// reviewListParams are the listing parameters passed on to review-service:
+// paging, ?verified=true and
+// ?sort=newest|verified|helpful|highest|lowest.
+var reviewListParams = []string{"page", "page_size", "verified", "sort"}
+
+type reviewPage struct {
+	Reviews    []review               `json:"reviews"`
+	Pagination map[string]interface{} `json:"pagination"`
+	// Stats are the item's rating stats, histogram and aspect averages
+	// included, passed through as they are.
+	Stats json.RawMessage `json:"stats"`
+}
+
+// fetchAccommodationReviews loads one page of an accommodation's reviews,
+// with the listing parameters of params.
+func fetchAccommodationReviews(r *http.Request, accommodationID string, params url.Values) (*reviewPage, int, error) {
+	query := url.Values{"item_type": {reviewItemType}, "item_id": {accommodationID}}
+	for _, name := range reviewListParams {
+		if value := params.Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	resp, err := reviewRequest(r, http.MethodGet, "/reviews?"+query.Encode(), nil)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("review service returned %d", resp.StatusCode)
+	}
+	var result reviewPage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return &result, http.StatusOK, nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -38,26 +39,48 @@ func (s *StringArray) Scan(value interface{}) error {
 
 type Accommodation struct {
 	gorm.Model
-	Name        string      `json:"name" gorm:"not null"`
-	Description string      `json:"description"`
-	City        string      `json:"city" gorm:"index;not null"`
-	Address     string      `json:"address" gorm:"not null"`
-	Location    string      `json:"location" gorm:"index"`
-	Type        string      `json:"type" gorm:"index;not null"`
-	AdminID     uint        `json:"admin_id"`
-	Website     string      `json:"website" gorm:"index;not null"`
-	IsPublished bool        `json:"is_published" gorm:"default:false"`
-	Amenities   StringArray `json:"amenities" gorm:"type:json"`
+	Name             string      `json:"name" gorm:"not null"`
+	Description      string      `json:"description"`
+	City             string      `json:"city" gorm:"index;not null"`
+	CityID           *uint       `json:"city_id" gorm:"index"`
+	Address          string      `json:"address" gorm:"not null"`
+	Location         string      `json:"location" gorm:"index"`
+	FormattedAddress string      `json:"formatted_address"`
+	LocationMismatch bool        `json:"location_mismatch" gorm:"default:false"`
+	Latitude         *float64    `json:"-"`
+	Longitude        *float64    `json:"-"`
+	Geohash          string      `json:"-" gorm:"size:12"`
+	Type             string      `json:"type" gorm:"index;not null"`
+	AdminID          uint        `json:"admin_id"`
+	Website          string      `json:"website" gorm:"index;not null"`
+	IsPublished      bool        `json:"is_published" gorm:"default:false"`
+	Amenities        StringArray `json:"amenities" gorm:"type:json"`
+
+	// StayPrice is the cheapest available stay when a list is filtered by dates.
+	StayPrice *PriceQuote `json:"stay_price,omitempty" gorm:"-"`
 
 	Images    []AccommodationImage  `json:"images" gorm:"foreignKey:AccommodationID;constraint:OnDelete:CASCADE;"`
 	RoomTypes []RoomType            `json:"room_types" gorm:"foreignKey:AccommodationID;constraint:OnDelete:CASCADE;"`
-	Reviews   []AccommodationReview `json:"reviews" gorm:"foreignKey:AccommodationID;constraint:OnDelete:CASCADE;"`
+	Reviews   []AccommodationReview `json:"reviews" gorm:"-"`
+}
+
+// BeforeSave keeps the indexed coordinate columns in sync with Location.
+func (a *Accommodation) BeforeSave(tx *gorm.DB) error {
+	a.Latitude, a.Longitude, a.Geohash = GeoColumns(a.Location)
+	return nil
 }
 
 type AccommodationImage struct {
 	gorm.Model
 	AccommodationID uint   `json:"accommodation_id" gorm:"index;not null"`
 	URL             string `json:"url" gorm:"not null"`
+	MediaID         *uint  `json:"media_id,omitempty" gorm:"index"`
+}
+
+// BeforeCreate records the media-service ID behind the image URL.
+func (i *AccommodationImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
 }
 
 type RoomType struct {
@@ -66,32 +89,74 @@ type RoomType struct {
 	Name            string      `json:"name" gorm:"not null"`
 	Description     string      `json:"description"`
 	Price           float64     `json:"price" gorm:"not null"`
+	Currency        string      `json:"currency" gorm:"size:3;not null;default:'KZT'"`
 	MaxGuests       int         `json:"max_guests,MaxGuests" gorm:"not null"`
+	Quantity        int         `json:"quantity" gorm:"not null;default:1"`
 	BedType         string      `json:"bed_type,BedType"`
 	Amenities       StringArray `json:"amenities,Amenities" gorm:"type:json"`
 
 	Images []RoomImage `json:"images" gorm:"foreignKey:RoomTypeID;constraint:OnDelete:CASCADE;"`
 }
 
+// AccommodationReview is an accommodation review in the shape this service
+// has always returned. Reviews are stored by review-service.
 type AccommodationReview struct {
-	gorm.Model
-	AccommodationID uint   `json:"accommodation_id" gorm:"index;not null"`
-	UserID          uint   `json:"user_id" gorm:"index;not null"`
-	Username        string `json:"username"`
-	Rating          int    `json:"rating" gorm:"not null;check:rating >= 1 AND rating <= 5"`
-	Comment         string `json:"comment"`
+	ID              uint      `json:"ID"`
+	CreatedAt       time.Time `json:"CreatedAt"`
+	UpdatedAt       time.Time `json:"UpdatedAt"`
+	AccommodationID uint      `json:"accommodation_id"`
+	UserID          uint      `json:"user_id"`
+	Username        string    `json:"username"`
+	ProfileImg      string    `json:"profile_img"`
+	Rating          int       `json:"rating"`
+	Comment         string    `json:"comment"`
+	// Verified marks a verified visit: a confirmed stay ("stay") or a
+	// plan that has ended ("plan").
+	Verified    bool   `json:"verified"`
+	VerifiedVia string `json:"verified_via,omitempty"`
+
+	Images []ReviewImage `json:"images"`
+
+	// Aspects rate cleanliness, service and value; Reply is the answer of
+	// the admin who owns the accommodation.
+	Aspects      []ReviewAspect `json:"aspects"`
+	HelpfulCount int            `json:"helpful_count"`
+	VotedHelpful bool           `json:"voted_helpful"`
+	Reply        *ReviewReply   `json:"reply,omitempty"`
+}
 
-	Images []ReviewImage `json:"images" gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE;"`
+type ReviewAspect struct {
+	Aspect string `json:"aspect"`
+	Rating int    `json:"rating"`
+}
+
+type ReviewReply struct {
+	AdminID   uint      `json:"admin_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type ReviewImage struct {
-	gorm.Model
-	ReviewID uint   `json:"review_id" gorm:"index;not null"`
-	URL      string `json:"url" gorm:"not null"`
+	ID       uint   `json:"ID"`
+	ReviewID uint   `json:"review_id"`
+	URL      string `json:"url"`
+	MediaID  *uint  `json:"media_id,omitempty"`
 }
 
 type RoomImage struct {
 	gorm.Model
 	RoomTypeID uint   `json:"room_type_id" gorm:"index;not null"`
 	URL        string `json:"url" gorm:"not null"`
+	MediaID    *uint  `json:"media_id,omitempty" gorm:"index"`
+}
+
+func (i *RoomImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
+}
+
+type AccommodationWithDistance struct {
+	Accommodation
+	Distance float64 `json:"distance"`
 }
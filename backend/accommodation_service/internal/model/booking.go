@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReservationHeld      = "held"
+	ReservationConfirmed = "confirmed"
+	ReservationCancelled = "cancelled"
+	ReservationExpired   = "expired"
+)
+
+// RoomInventory is the number of rooms of a type that can be sold for one
+// night. Rows are created on first use from RoomType.Quantity; admins can
+// override Total per night, e.g. to close a room type for renovation.
+type RoomInventory struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	RoomTypeID uint      `json:"room_type_id" gorm:"not null;uniqueIndex:idx_room_inventory_night"`
+	Date       time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_room_inventory_night"`
+	Total      int       `json:"total" gorm:"not null"`
+	Booked     int       `json:"booked" gorm:"not null;default:0;check:chk_room_inventory_booked,booked >= 0 AND booked <= total"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type Reservation struct {
+	gorm.Model
+	AccommodationID uint       `json:"accommodation_id" gorm:"index;not null"`
+	RoomTypeID      uint       `json:"room_type_id" gorm:"index;not null"`
+	UserID          uint       `json:"user_id" gorm:"index;not null"`
+	CheckIn         time.Time  `json:"check_in" gorm:"type:date;not null"`
+	CheckOut        time.Time  `json:"check_out" gorm:"type:date;not null"`
+	Guests          int        `json:"guests" gorm:"not null"`
+	Rooms           int        `json:"rooms" gorm:"not null;default:1"`
+	Status          string     `json:"status" gorm:"index;not null"`
+	TotalPrice      float64    `json:"total_price"`
+	Currency        string     `json:"currency" gorm:"size:3"`
+	PriceLines      PriceLines `json:"price_lines" gorm:"type:json"`
+	// PaymentID and PaymentStatus are kept up to date by payment_service.
+	PaymentID     *uint      `json:"payment_id,omitempty"`
+	PaymentStatus string     `json:"payment_status,omitempty"`
+	Notes         string     `json:"notes"`
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+	ConfirmedAt   *time.Time `json:"confirmed_at,omitempty"`
+	CancelledAt   *time.Time `json:"cancelled_at,omitempty"`
+
+	RoomType *RoomType `json:"room_type,omitempty" gorm:"foreignKey:RoomTypeID"`
+}
+
+// Nights is the number of nights between check-in and check-out.
+func (r *Reservation) Nights() int {
+	return int(r.CheckOut.Sub(r.CheckIn).Hours() / 24)
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKm = 6371.0
+
+// GeoPoint is a WGS84 coordinate. Locations are stored as "lat,lng"
+// strings, which ParseGeoPoint and String convert from and to.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func ParseGeoPoint(location string) (GeoPoint, error) {
+	parts := strings.Split(location, ",")
+	if len(parts) != 2 {
+		return GeoPoint{}, fmt.Errorf("location must be \"lat,lng\"")
+	}
+
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil {
+		return GeoPoint{}, fmt.Errorf("location must be \"lat,lng\"")
+	}
+
+	point := GeoPoint{Lat: lat, Lng: lng}
+	if !point.Valid() {
+		return GeoPoint{}, fmt.Errorf("coordinates out of range")
+	}
+	return point, nil
+}
+
+func (p GeoPoint) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+func (p GeoPoint) String() string {
+	return strconv.FormatFloat(p.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lng, 'f', -1, 64)
+}
+
+// DistanceKm is the haversine distance between two points.
+func (p GeoPoint) DistanceKm(other GeoPoint) float64 {
+	lat1 := p.Lat * math.Pi / 180
+	lat2 := other.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (other.Lng - p.Lng) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+const (
+	geohashAlphabet  = "0123456789bcdefghjkmnpqrstuvwxyz"
+	GeohashPrecision = 9
+	maxCoverCells    = 16
+)
+
+// EncodeGeohash returns the geohash of the point at the given precision.
+func EncodeGeohash(p GeoPoint, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+	hash := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	even := true
+
+	for len(hash) < precision {
+		if even {
+			mid := (minLng + maxLng) / 2
+			if p.Lng >= mid {
+				ch |= 1 << (4 - bit)
+				minLng = mid
+			} else {
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if p.Lat >= mid {
+				ch |= 1 << (4 - bit)
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return string(hash)
+}
+
+func geohashCellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	lngBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lngBits))
+}
+
+// GeohashCover returns geohash prefixes whose cells together cover the box.
+// It uses the finest precision that needs no more than maxCoverCells cells,
+// so a prefix match on an indexed geohash column narrows rows cheaply.
+func GeohashCover(b BoundingBox) []string {
+	for precision := GeohashPrecision - 1; precision >= 1; precision-- {
+		height, width := geohashCellSize(precision)
+		rows := int(math.Floor((b.MaxLat-b.MinLat)/height)) + 2
+		cols := int(math.Floor((b.MaxLng-b.MinLng)/width)) + 2
+		if rows*cols > maxCoverCells*2 {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var cells []string
+		for lat := b.MinLat; ; lat += height {
+			if lat > b.MaxLat {
+				lat = b.MaxLat
+			}
+			for lng := b.MinLng; ; lng += width {
+				if lng > b.MaxLng {
+					lng = b.MaxLng
+				}
+				cell := EncodeGeohash(GeoPoint{Lat: lat, Lng: lng}, precision)
+				if !seen[cell] {
+					seen[cell] = true
+					cells = append(cells, cell)
+				}
+				if lng == b.MaxLng {
+					break
+				}
+			}
+			if lat == b.MaxLat {
+				break
+			}
+		}
+
+		if len(cells) <= maxCoverCells {
+			return cells
+		}
+	}
+	return nil
+}
+
+type BoundingBox struct {
+	MinLat float64
+	MinLng float64
+	MaxLat float64
+	MaxLng float64
+}
+
+// BoundingBoxAround returns a box extending radiusKm from the center in
+// every direction, clamped to valid coordinates.
+func BoundingBoxAround(center GeoPoint, radiusKm float64) BoundingBox {
+	latDelta := radiusKm / 111.0
+	lngDelta := radiusKm / (111.0 * math.Max(math.Cos(center.Lat*math.Pi/180), 0.01))
+	return BoundingBox{
+		MinLat: math.Max(center.Lat-latDelta, -90),
+		MinLng: math.Max(center.Lng-lngDelta, -180),
+		MaxLat: math.Min(center.Lat+latDelta, 90),
+		MaxLng: math.Min(center.Lng+lngDelta, 180),
+	}
+}
+
+// GeoColumns derives the indexed coordinate columns from a "lat,lng" string.
+func GeoColumns(location string) (*float64, *float64, string) {
+	point, err := ParseGeoPoint(location)
+	if err != nil {
+		return nil, nil, ""
+	}
+	return &point.Lat, &point.Lng, EncodeGeohash(point, GeohashPrecision)
+}
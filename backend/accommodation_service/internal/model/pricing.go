@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// RuleSeason replaces the nightly rate between two dates.
+	RuleSeason = "season"
+	// RuleWeekend adds a percentage on the configured weekdays.
+	RuleWeekend = "weekend"
+	// RuleHoliday adds a percentage between two dates, instead of any
+	// weekend surcharge.
+	RuleHoliday = "holiday"
+	// RuleLengthOfStay takes a percentage off stays of at least MinNights.
+	RuleLengthOfStay = "length_of_stay"
+	// RuleExtraGuest charges Amount per night for every guest above
+	// BaseGuests in a room.
+	RuleExtraGuest = "extra_guest"
+
+	DefaultCurrency = "KZT"
+)
+
+type PricingRule struct {
+	gorm.Model
+	RoomTypeID uint       `json:"room_type_id" gorm:"index;not null"`
+	Kind       string     `json:"kind" gorm:"not null"`
+	Name       string     `json:"name"`
+	StartDate  *time.Time `json:"start_date,omitempty" gorm:"type:date"`
+	EndDate    *time.Time `json:"end_date,omitempty" gorm:"type:date"`
+	Rate       float64    `json:"rate,omitempty"`
+	Percent    float64    `json:"percent,omitempty"`
+	Amount     float64    `json:"amount,omitempty"`
+	MinNights  int        `json:"min_nights,omitempty"`
+	BaseGuests int        `json:"base_guests,omitempty"`
+	// Weekdays lists the nights a weekend rule applies to as comma separated
+	// numbers, Sunday being 0. "5,6" are Friday and Saturday nights.
+	Weekdays string `json:"weekdays,omitempty"`
+}
+
+// Covers reports whether a dated rule applies to the night.
+func (r *PricingRule) Covers(night time.Time) bool {
+	if r.StartDate == nil || r.EndDate == nil {
+		return false
+	}
+	return !night.Before(*r.StartDate) && !night.After(*r.EndDate)
+}
+
+type PriceLine struct {
+	Date        string  `json:"date,omitempty"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// PriceQuote is the price of a stay with the line items it adds up from.
+type PriceQuote struct {
+	RoomTypeID uint       `json:"room_type_id"`
+	Currency   string     `json:"currency"`
+	Nights     int        `json:"nights"`
+	Rooms      int        `json:"rooms"`
+	Guests     int        `json:"guests"`
+	Lines      PriceLines `json:"lines"`
+	Total      float64    `json:"total"`
+}
+
+// PriceLines stores the breakdown a reservation was priced with.
+type PriceLines []PriceLine
+
+func (l PriceLines) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *PriceLines) Scan(value interface{}) error {
+	if value == nil {
+		*l = PriceLines{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		return errors.New("invalid scan source for PriceLines")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
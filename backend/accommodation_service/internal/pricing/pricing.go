@@ -0,0 +1,273 @@
+package pricing
+
+import (
+	models "accommodation_service/internal/model"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrInvalidKind     = errors.New("kind must be season, weekend, holiday, length_of_stay or extra_guest")
+	ErrInvalidDates    = errors.New("start_date and end_date are required and end_date cannot be before start_date")
+	ErrInvalidRate     = errors.New("rate must be greater than zero")
+	ErrInvalidPercent  = errors.New("percent must be between -100 and 100 and not zero")
+	ErrInvalidDiscount = errors.New("percent must be between 0 and 100 and min_nights at least 2")
+	ErrInvalidWeekdays = errors.New("weekdays must list days from 0 (Sunday) to 6 (Saturday), e.g. 5,6")
+	ErrInvalidGuests   = errors.New("amount must be greater than zero and base_guests at least 1")
+	ErrOverlap         = errors.New("rule overlaps an existing rule of the same kind")
+)
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// ParseWeekdays reads a weekend rule's weekday list.
+func ParseWeekdays(value string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || day < 0 || day > 6 {
+			return nil, ErrInvalidWeekdays
+		}
+		days[time.Weekday(day)] = true
+	}
+	return days, nil
+}
+
+func validPercent(percent float64) bool {
+	return percent != 0 && percent > -100 && percent <= 100
+}
+
+func datesOverlap(a, b models.PricingRule) bool {
+	return !a.StartDate.After(*b.EndDate) && !b.StartDate.After(*a.EndDate)
+}
+
+// Validate checks a rule on its own and against the other rules of its room
+// type. Seasons and holidays may not share a night with another rule of the
+// same kind, a room type has at most one weekend and one extra guest rule,
+// and length-of-stay tiers need distinct minimum stays.
+func Validate(rule models.PricingRule, existing []models.PricingRule) error {
+	switch rule.Kind {
+	case models.RuleSeason, models.RuleHoliday:
+		if rule.StartDate == nil || rule.EndDate == nil || rule.EndDate.Before(*rule.StartDate) {
+			return ErrInvalidDates
+		}
+		if rule.Kind == models.RuleSeason && rule.Rate <= 0 {
+			return ErrInvalidRate
+		}
+		if rule.Kind == models.RuleHoliday && !validPercent(rule.Percent) {
+			return ErrInvalidPercent
+		}
+	case models.RuleWeekend:
+		if _, err := ParseWeekdays(rule.Weekdays); err != nil {
+			return err
+		}
+		if !validPercent(rule.Percent) {
+			return ErrInvalidPercent
+		}
+	case models.RuleLengthOfStay:
+		if rule.MinNights < 2 || rule.Percent <= 0 || rule.Percent >= 100 {
+			return ErrInvalidDiscount
+		}
+	case models.RuleExtraGuest:
+		if rule.Amount <= 0 || rule.BaseGuests < 1 {
+			return ErrInvalidGuests
+		}
+	default:
+		return ErrInvalidKind
+	}
+
+	for _, other := range existing {
+		if other.ID == rule.ID || other.Kind != rule.Kind {
+			continue
+		}
+		switch rule.Kind {
+		case models.RuleSeason, models.RuleHoliday:
+			if other.StartDate != nil && other.EndDate != nil && datesOverlap(rule, other) {
+				return ErrOverlap
+			}
+		case models.RuleLengthOfStay:
+			if other.MinNights == rule.MinNights {
+				return ErrOverlap
+			}
+		default:
+			return ErrOverlap
+		}
+	}
+	return nil
+}
+
+// LoadRules fetches the pricing rules of the given room types.
+func LoadRules(tx *gorm.DB, roomTypeIDs ...uint) (map[uint][]models.PricingRule, error) {
+	rules := make(map[uint][]models.PricingRule)
+	if len(roomTypeIDs) == 0 {
+		return rules, nil
+	}
+
+	var rows []models.PricingRule
+	if err := tx.Where("room_type_id IN ?", roomTypeIDs).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, rule := range rows {
+		rules[rule.RoomTypeID] = append(rules[rule.RoomTypeID], rule)
+	}
+	return rules, nil
+}
+
+// Calculate prices a stay of [checkIn, checkOut). Each night costs the
+// season rate covering it, or the room type's base price, plus a holiday
+// surcharge or, failing that, a weekend surcharge. Guests above the extra
+// guest rule's base_guests per room pay its amount per night. The best
+// length-of-stay discount the stay qualifies for is taken off the total.
+func Calculate(roomType models.RoomType, rules []models.PricingRule, checkIn, checkOut time.Time, guests, rooms int) models.PriceQuote {
+	if rooms <= 0 {
+		rooms = 1
+	}
+
+	var seasons, holidays []models.PricingRule
+	var weekend, extraGuest *models.PricingRule
+	var weekendDays map[time.Weekday]bool
+	var discounts []models.PricingRule
+	for i := range rules {
+		rule := &rules[i]
+		switch rule.Kind {
+		case models.RuleSeason:
+			seasons = append(seasons, *rule)
+		case models.RuleHoliday:
+			holidays = append(holidays, *rule)
+		case models.RuleWeekend:
+			if days, err := ParseWeekdays(rule.Weekdays); err == nil {
+				weekend, weekendDays = rule, days
+			}
+		case models.RuleExtraGuest:
+			extraGuest = rule
+		case models.RuleLengthOfStay:
+			discounts = append(discounts, *rule)
+		}
+	}
+
+	quote := models.PriceQuote{
+		RoomTypeID: roomType.ID,
+		Currency:   roomType.Currency,
+		Rooms:      rooms,
+		Guests:     guests,
+		Lines:      models.PriceLines{},
+	}
+	if quote.Currency == "" {
+		quote.Currency = models.DefaultCurrency
+	}
+
+	extraGuests := 0
+	if extraGuest != nil {
+		extraGuests = guests - extraGuest.BaseGuests*rooms
+	}
+
+	add := func(date, description string, amount float64) {
+		amount = round(amount)
+		quote.Lines = append(quote.Lines, models.PriceLine{Date: date, Description: description, Amount: amount})
+		quote.Total += amount
+	}
+
+	for night := checkIn; night.Before(checkOut); night = night.AddDate(0, 0, 1) {
+		date := night.Format(dateLayout)
+		quote.Nights++
+
+		rate, description := roomType.Price, "Nightly rate"
+		for _, season := range seasons {
+			if season.Covers(night) {
+				rate, description = season.Rate, seasonName(season)
+				break
+			}
+		}
+		base := rate * float64(rooms)
+		add(date, fmt.Sprintf("%s × %d room(s)", description, rooms), base)
+
+		surcharged := false
+		for _, holiday := range holidays {
+			if holiday.Covers(night) {
+				add(date, percentLabel(holiday.Name, "Holiday", holiday.Percent), base*holiday.Percent/100)
+				surcharged = true
+				break
+			}
+		}
+		if !surcharged && weekend != nil && weekendDays[night.Weekday()] {
+			add(date, percentLabel(weekend.Name, "Weekend", weekend.Percent), base*weekend.Percent/100)
+		}
+
+		if extraGuests > 0 {
+			add(date, fmt.Sprintf("Extra guest × %d", extraGuests), extraGuest.Amount*float64(extraGuests))
+		}
+	}
+
+	var best *models.PricingRule
+	for i := range discounts {
+		if quote.Nights >= discounts[i].MinNights && (best == nil || discounts[i].Percent > best.Percent) {
+			best = &discounts[i]
+		}
+	}
+	if best != nil {
+		label := best.Name
+		if label == "" {
+			label = fmt.Sprintf("Stay of %d+ nights", best.MinNights)
+		}
+		add("", fmt.Sprintf("%s -%g%%", label, best.Percent), -quote.Total*best.Percent/100)
+	}
+
+	quote.Total = round(quote.Total)
+	return quote
+}
+
+func seasonName(season models.PricingRule) string {
+	if season.Name != "" {
+		return season.Name
+	}
+	return "Season rate"
+}
+
+func percentLabel(name, fallback string, percent float64) string {
+	if name == "" {
+		name = fallback
+	}
+	return fmt.Sprintf("%s %+g%%", name, percent)
+}
+
+// Quote loads a room type's rules and prices the stay.
+func Quote(tx *gorm.DB, roomType models.RoomType, checkIn, checkOut time.Time, guests, rooms int) (models.PriceQuote, error) {
+	rules, err := LoadRules(tx, roomType.ID)
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+	return Calculate(roomType, rules[roomType.ID], checkIn, checkOut, guests, rooms), nil
+}
+
+// Cheapest returns the lowest quote among the room types, or nil when there
+// are none.
+func Cheapest(tx *gorm.DB, roomTypes []models.RoomType, checkIn, checkOut time.Time, guests, rooms int) (*models.PriceQuote, error) {
+	ids := make([]uint, len(roomTypes))
+	for i, roomType := range roomTypes {
+		ids[i] = roomType.ID
+	}
+	rules, err := LoadRules(tx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]models.PriceQuote, 0, len(roomTypes))
+	for _, roomType := range roomTypes {
+		quotes = append(quotes, Calculate(roomType, rules[roomType.ID], checkIn, checkOut, guests, rooms))
+	}
+	if len(quotes) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Total < quotes[j].Total })
+	return &quotes[0], nil
+}
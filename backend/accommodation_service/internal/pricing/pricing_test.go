@@ -0,0 +1,166 @@
+package pricing
+
+import (
+	models "accommodation_service/internal/model"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func day(value string) time.Time {
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func dayPtr(value string) *time.Time {
+	t := day(value)
+	return &t
+}
+
+func dated(kind, start, end string) models.PricingRule {
+	return models.PricingRule{Kind: kind, StartDate: dayPtr(start), EndDate: dayPtr(end)}
+}
+
+func TestValidate(t *testing.T) {
+	season := dated(models.RuleSeason, "2026-06-01", "2026-06-30")
+	season.Rate = 150
+	holiday := dated(models.RuleHoliday, "2026-03-21", "2026-03-23")
+	holiday.Percent = 30
+
+	withID := func(rule models.PricingRule, id uint) models.PricingRule {
+		rule.Model = gorm.Model{ID: id}
+		return rule
+	}
+	withDates := func(rule models.PricingRule, start, end string) models.PricingRule {
+		rule.StartDate, rule.EndDate = dayPtr(start), dayPtr(end)
+		return rule
+	}
+
+	tests := []struct {
+		name     string
+		rule     models.PricingRule
+		existing []models.PricingRule
+		want     error
+	}{
+		{"unknown kind", models.PricingRule{Kind: "promo"}, nil, ErrInvalidKind},
+		{"season", season, nil, nil},
+		{"season without dates", models.PricingRule{Kind: models.RuleSeason, Rate: 150}, nil, ErrInvalidDates},
+		{"season ending before it starts", withDates(season, "2026-06-30", "2026-06-01"), nil, ErrInvalidDates},
+		{"one night season", withDates(season, "2026-06-01", "2026-06-01"), nil, nil},
+		{"season without rate", dated(models.RuleSeason, "2026-06-01", "2026-06-30"), nil, ErrInvalidRate},
+		{"holiday", holiday, nil, nil},
+		{"holiday without percent", dated(models.RuleHoliday, "2026-03-21", "2026-03-23"), nil, ErrInvalidPercent},
+		{"holiday discount", models.PricingRule{Kind: models.RuleHoliday, StartDate: holiday.StartDate, EndDate: holiday.EndDate, Percent: -20}, nil, nil},
+		{"holiday taking it all off", models.PricingRule{Kind: models.RuleHoliday, StartDate: holiday.StartDate, EndDate: holiday.EndDate, Percent: -100}, nil, ErrInvalidPercent},
+		{"weekend", models.PricingRule{Kind: models.RuleWeekend, Weekdays: "5, 6", Percent: 20}, nil, nil},
+		{"weekend on day 7", models.PricingRule{Kind: models.RuleWeekend, Weekdays: "6,7", Percent: 20}, nil, ErrInvalidWeekdays},
+		{"weekend without days", models.PricingRule{Kind: models.RuleWeekend, Percent: 20}, nil, ErrInvalidWeekdays},
+		{"weekend over 100%", models.PricingRule{Kind: models.RuleWeekend, Weekdays: "5,6", Percent: 150}, nil, ErrInvalidPercent},
+		{"length of stay", models.PricingRule{Kind: models.RuleLengthOfStay, MinNights: 7, Percent: 10}, nil, nil},
+		{"length of stay of one night", models.PricingRule{Kind: models.RuleLengthOfStay, MinNights: 1, Percent: 10}, nil, ErrInvalidDiscount},
+		{"length of stay surcharge", models.PricingRule{Kind: models.RuleLengthOfStay, MinNights: 7, Percent: -10}, nil, ErrInvalidDiscount},
+		{"free length of stay", models.PricingRule{Kind: models.RuleLengthOfStay, MinNights: 7, Percent: 100}, nil, ErrInvalidDiscount},
+		{"extra guest", models.PricingRule{Kind: models.RuleExtraGuest, Amount: 5000, BaseGuests: 2}, nil, nil},
+		{"extra guest without amount", models.PricingRule{Kind: models.RuleExtraGuest, BaseGuests: 2}, nil, ErrInvalidGuests},
+		{"extra guest without base guests", models.PricingRule{Kind: models.RuleExtraGuest, Amount: 5000}, nil, ErrInvalidGuests},
+
+		{"seasons sharing a night", season, []models.PricingRule{withID(withDates(season, "2026-06-30", "2026-07-15"), 1)}, ErrOverlap},
+		{"seasons back to back", season, []models.PricingRule{withID(withDates(season, "2026-07-01", "2026-07-15"), 1)}, nil},
+		{"season inside another", season, []models.PricingRule{withID(withDates(season, "2026-05-01", "2026-08-31"), 1)}, ErrOverlap},
+		{"holiday during a season", holiday, []models.PricingRule{withID(withDates(season, "2026-03-01", "2026-03-31"), 1)}, nil},
+		{"holidays sharing a night", holiday, []models.PricingRule{withID(withDates(holiday, "2026-03-23", "2026-03-25"), 1)}, ErrOverlap},
+		{"editing a season over itself", withID(season, 1), []models.PricingRule{withID(season, 1)}, nil},
+		{"second weekend rule",
+			models.PricingRule{Kind: models.RuleWeekend, Weekdays: "0", Percent: 10},
+			[]models.PricingRule{{Model: gorm.Model{ID: 1}, Kind: models.RuleWeekend, Weekdays: "5,6", Percent: 20}}, ErrOverlap},
+		{"second extra guest rule",
+			models.PricingRule{Kind: models.RuleExtraGuest, Amount: 5000, BaseGuests: 2},
+			[]models.PricingRule{{Model: gorm.Model{ID: 1}, Kind: models.RuleExtraGuest, Amount: 3000, BaseGuests: 1}}, ErrOverlap},
+		{"length of stay tiers",
+			models.PricingRule{Kind: models.RuleLengthOfStay, MinNights: 7, Percent: 10},
+			[]models.PricingRule{{Model: gorm.Model{ID: 1}, Kind: models.RuleLengthOfStay, MinNights: 3, Percent: 5}}, nil},
+		{"length of stay tier twice",
+			models.PricingRule{Kind: models.RuleLengthOfStay, MinNights: 7, Percent: 10},
+			[]models.PricingRule{{Model: gorm.Model{ID: 1}, Kind: models.RuleLengthOfStay, MinNights: 7, Percent: 5}}, ErrOverlap},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule, tt.existing); !errors.Is(err, tt.want) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	// 2026-01-01 is a Thursday.
+	weekend := models.PricingRule{Kind: models.RuleWeekend, Weekdays: "5,6", Percent: 20}
+	holiday := dated(models.RuleHoliday, "2026-01-02", "2026-01-03")
+	holiday.Percent = 50
+	season := dated(models.RuleSeason, "2026-01-01", "2026-01-01")
+	season.Rate = 200
+	newYear := dated(models.RuleHoliday, "2026-01-01", "2026-01-01")
+	newYear.Percent = 10
+	extraGuest := models.PricingRule{Kind: models.RuleExtraGuest, Amount: 10, BaseGuests: 2}
+	tiers := []models.PricingRule{
+		{Kind: models.RuleLengthOfStay, MinNights: 3, Percent: 5},
+		{Kind: models.RuleLengthOfStay, MinNights: 7, Percent: 10},
+		{Kind: models.RuleLengthOfStay, MinNights: 5, Percent: 8},
+	}
+
+	tests := []struct {
+		name      string
+		price     float64
+		rules     []models.PricingRule
+		checkIn   string
+		checkOut  string
+		guests    int
+		rooms     int
+		wantTotal float64
+		wantLines int
+	}{
+		{"base price", 100, nil, "2026-01-05", "2026-01-07", 2, 1, 200, 2},
+		{"no nights", 100, nil, "2026-01-05", "2026-01-05", 2, 1, 0, 0},
+		{"rooms default to one", 100, nil, "2026-01-05", "2026-01-06", 2, 0, 100, 1},
+		{"weekend nights", 100, []models.PricingRule{weekend}, "2026-01-01", "2026-01-04", 2, 1, 100 + 120 + 120, 5},
+		{"holiday over weekend", 100, []models.PricingRule{weekend, holiday}, "2026-01-01", "2026-01-05", 2, 1, 100 + 150 + 150 + 100, 6},
+		{"season rate per room", 100, []models.PricingRule{season}, "2026-01-01", "2026-01-03", 2, 2, 400 + 200, 2},
+		{"holiday on the season rate", 100, []models.PricingRule{season, newYear}, "2026-01-01", "2026-01-02", 2, 1, 200 + 20, 2},
+		{"extra guests per room", 100, []models.PricingRule{extraGuest}, "2026-01-05", "2026-01-07", 5, 2, 400 + 2*10, 4},
+		{"extra guests several a night", 100, []models.PricingRule{extraGuest}, "2026-01-05", "2026-01-06", 5, 1, 100 + 3*10, 2},
+		{"guests within base per room", 100, []models.PricingRule{extraGuest}, "2026-01-05", "2026-01-07", 4, 2, 400, 2},
+		{"too short for any tier", 100, tiers, "2026-01-05", "2026-01-07", 2, 1, 200, 2},
+		{"shortest tier", 100, tiers, "2026-01-05", "2026-01-09", 2, 1, 400 - 20, 5},
+		{"best tier", 100, tiers, "2026-01-05", "2026-01-12", 2, 1, 700 - 70, 8},
+		{"discount after surcharges", 100, append([]models.PricingRule{weekend}, tiers[0]), "2026-01-01", "2026-01-04", 2, 1, 340 - 17, 6},
+		{"lines rounded to cents", 33.333, nil, "2026-01-05", "2026-01-08", 2, 1, 99.99, 3},
+		{"discount rounded to cents", 33.333, tiers[:1], "2026-01-05", "2026-01-08", 2, 1, 99.99 - 5, 4},
+		{"surcharge rounded to cents", 99.99, []models.PricingRule{holiday}, "2026-01-02", "2026-01-03", 2, 1, 99.99 + 50, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roomType := models.RoomType{Price: tt.price}
+			quote := Calculate(roomType, tt.rules, day(tt.checkIn), day(tt.checkOut), tt.guests, tt.rooms)
+			if math.Abs(quote.Total-tt.wantTotal) > 1e-9 {
+				t.Errorf("total = %v, want %v (lines %+v)", quote.Total, tt.wantTotal, quote.Lines)
+			}
+			if len(quote.Lines) != tt.wantLines {
+				t.Errorf("got %d lines, want %d: %+v", len(quote.Lines), tt.wantLines, quote.Lines)
+			}
+			for _, line := range quote.Lines {
+				if line.Amount != round(line.Amount) {
+					t.Errorf("line %+v is not rounded to cents", line)
+				}
+			}
+			if quote.Currency != models.DefaultCurrency {
+				t.Errorf("currency = %q, want %q", quote.Currency, models.DefaultCurrency)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@ package routes
 import (
 	"accommodation_service/internal/controllers"
 	"accommodation_service/internal/middleware"
-	"log"
-	"net/http"
 
 	"github.com/gorilla/mux"
 )
@@ -12,17 +10,13 @@ import (
 func SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
 
-	uploadDir := "/app/uploads/accommodations"
-	log.Printf("Serving static files from: %s", uploadDir)
-	r.PathPrefix("/uploads/accommodations/").Handler(
-		http.StripPrefix("/uploads/accommodations/", http.FileServer(http.Dir(uploadDir))),
-	).Methods("GET")
-
 	accommodationController := controllers.AccommodationController{}
 
 	r.HandleFunc("/accommodations", accommodationController.ListAccommodations).Methods("GET")
 	r.HandleFunc("/accommodations/{id:[0-9]+}", accommodationController.GetAccommodation).Methods("GET")
 	r.HandleFunc("/accommodations/{id:[0-9]+}/reviews", accommodationController.GetAccommodationReviews).Methods("GET")
+	r.HandleFunc("/accommodations/{id:[0-9]+}/availability", accommodationController.GetAvailability).Methods("GET")
+	r.HandleFunc("/accommodations/{id:[0-9]+}/quote", accommodationController.GetQuote).Methods("GET")
 
 	admin := r.PathPrefix("/admin/accommodations").Subrouter()
 	admin.Use(middleware.AdminAuthMiddleware)
@@ -34,11 +28,23 @@ func SetupRoutes() *mux.Router {
 	admin.HandleFunc("/{id:[0-9]+}/publish", accommodationController.PublishAccommodation).Methods("POST")
 	admin.HandleFunc("/{id:[0-9]+}/unpublish", accommodationController.UnpublishAccommodation).Methods("POST")
 	admin.HandleFunc("/room-types/{room_id:[0-9]+}/images", accommodationController.UploadRoomTypeImages).Methods("POST")
+	admin.HandleFunc("/room-types/{room_id:[0-9]+}/inventory", accommodationController.SetRoomInventory).Methods("PUT")
+	admin.HandleFunc("/room-types/{room_id:[0-9]+}/pricing-rules", accommodationController.ListPricingRules).Methods("GET")
+	admin.HandleFunc("/room-types/{room_id:[0-9]+}/pricing-rules", accommodationController.CreatePricingRule).Methods("POST")
+	admin.HandleFunc("/pricing-rules/{rule_id:[0-9]+}", accommodationController.UpdatePricingRule).Methods("PUT")
+	admin.HandleFunc("/pricing-rules/{rule_id:[0-9]+}", accommodationController.DeletePricingRule).Methods("DELETE")
+	admin.HandleFunc("/{id:[0-9]+}/occupancy", accommodationController.GetOccupancy).Methods("GET")
+	admin.HandleFunc("/{id:[0-9]+}/reservations", accommodationController.ListAccommodationReservations).Methods("GET")
+	admin.HandleFunc("/reservations/{reservation_id:[0-9]+}/cancel", accommodationController.AdminCancelReservation).Methods("POST")
 	user := r.PathPrefix("/user/accommodations").Subrouter()
 	user.Use(middleware.AuthMiddleware)
 	user.HandleFunc("/{id:[0-9]+}/reviews", accommodationController.AddReview).Methods("POST")
 	user.HandleFunc("/reviews/{review_id:[0-9]+}", accommodationController.UpdateReview).Methods("PUT")
 	user.HandleFunc("/reviews/{review_id:[0-9]+}", accommodationController.DeleteReview).Methods("DELETE")
+	user.HandleFunc("/{id:[0-9]+}/reservations", accommodationController.CreateReservation).Methods("POST")
+	user.HandleFunc("/reservations", accommodationController.ListMyReservations).Methods("GET")
+	user.HandleFunc("/reservations/{reservation_id:[0-9]+}/confirm", accommodationController.ConfirmReservation).Methods("POST")
+	user.HandleFunc("/reservations/{reservation_id:[0-9]+}/cancel", accommodationController.CancelReservation).Methods("POST")
 
 	return r
 }
@@ -19,12 +19,57 @@ func ConnectDB() {
 
 	DB = dbInstance
 
-	err = DB.AutoMigrate(&models.Accommodation{}, &models.AccommodationReview{},
-		&models.RoomType{}, &models.AccommodationImage{}, &models.ReviewImage{}, &models.RoomImage{},
+	err = DB.AutoMigrate(&models.Accommodation{},
+		&models.RoomType{}, &models.AccommodationImage{}, &models.RoomImage{},
+		&models.RoomInventory{}, &models.Reservation{}, &models.PricingRule{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	for _, statement := range geoIndexStatements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to create geo index: %v", err)
+		}
+	}
+	if err := backfillGeoColumns(); err != nil {
+		log.Printf("Failed to backfill coordinates: %v", err)
+	}
+
 	fmt.Println("Connected to PostgreSQL database!")
 }
+
+// geoIndexStatements create the indexes behind "near me" queries. The geohash
+// index uses text_pattern_ops so that prefix LIKE lookups can use it.
+var geoIndexStatements = []string{
+	`CREATE INDEX IF NOT EXISTS idx_accommodations_geohash ON accommodations (geohash text_pattern_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_accommodations_coordinates ON accommodations (latitude, longitude)`,
+}
+
+// backfillGeoColumns fills the coordinate columns of rows saved before they
+// existed. New writes keep them in sync through the model's BeforeSave hook.
+func backfillGeoColumns() error {
+	var accommodations []models.Accommodation
+	err := DB.Unscoped().Select("id", "location").
+		Where("location <> '' AND (geohash IS NULL OR geohash = '')").
+		Find(&accommodations).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range accommodations {
+		lat, lng, geohash := models.GeoColumns(row.Location)
+		if geohash == "" {
+			continue
+		}
+		err := DB.Unscoped().Model(&models.Accommodation{}).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{
+			"latitude":  lat,
+			"longitude": lng,
+			"geohash":   geohash,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
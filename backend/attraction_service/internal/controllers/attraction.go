@@ -1,27 +1,16 @@
 package controllers
 
 import (
-	"crypto/rand"
 	"diplomaPorject/backend/attraction/internal/models"
 	"diplomaPorject/backend/attraction/utils/db"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
-	"io"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 )
 
-func generateRandomFilename(originalFilename string) string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes) + filepath.Ext(originalFilename)
-}
-
 type AttractionRequest struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
@@ -29,7 +18,6 @@ type AttractionRequest struct {
 	Address     string `json:"address"`
 	Category    string `json:"category"`
 	Location    string `json:"location"`
-	ImageURL    string `json:"image_url"`
 }
 
 func CreateAttraction(w http.ResponseWriter, r *http.Request) {
@@ -49,16 +37,17 @@ func CreateAttraction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, header, err := r.FormFile("image")
+	imageURLs, err := uploadGalleryFiles(r)
 	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to upload image: %v", err), uploadErrorStatus(err))
+		return
+	}
+	if len(imageURLs) == 0 {
 		http.Error(w, "No image file provided", http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
-
-	imageURL, err := uploadImage(file, header.Filename)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to upload image: %v", err), http.StatusInternalServerError)
+	if len(imageURLs) > maxGalleryImages {
+		http.Error(w, errGalleryFull.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -70,45 +59,38 @@ func CreateAttraction(w http.ResponseWriter, r *http.Request) {
 		Address:     r.FormValue("address"),
 		Category:    r.FormValue("category"),
 		AdminID:     adminID,
-		ImageURL:    imageURL,
 	}
 
-	if err := db.DB.Create(&attraction).Error; err != nil {
-		http.Error(w, "Failed to create attraction", http.StatusInternalServerError)
-		return
+	if check := checkAddress(attraction.Address, attraction.City, attraction.Location); check != nil {
+		attraction.Address = check.Address
+		attraction.Location = check.Location
+		attraction.FormattedAddress = check.FormattedAddress
+		attraction.LocationMismatch = check.Mismatch
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(attraction)
-}
-func uploadImage(file io.Reader, filename string) (string, error) {
-	uploadDir := "/app/uploads"
-
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %v", err)
+	if cityID, cityName := resolveCity(attraction.City, attraction.Location); cityID != nil {
+		attraction.CityID = cityID
+		attraction.City = cityName
 	}
 
-	randomFilename := generateRandomFilename(filename)
-	filePath := filepath.Join(uploadDir, randomFilename) // Use filePath for saving
-
-	dst, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %v", err)
+	if err := db.DB.Create(&attraction).Error; err != nil {
+		http.Error(w, "Failed to create attraction", http.StatusInternalServerError)
+		return
 	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to save file: %v", err)
+	if _, err := addImages(attraction.ID, imageURLs, r.MultipartForm.Value["captions"]); err != nil {
+		http.Error(w, "Failed to save attraction images", http.StatusInternalServerError)
+		return
 	}
+	db.DB.Preload("Images", orderedImages).First(&attraction, attraction.ID)
 
-	return fmt.Sprintf("/uploads/%s", randomFilename), nil
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attraction)
 }
-
 func GetAttraction(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 	var attraction models.Attraction
-	if err := db.DB.First(&attraction, id).Error; err != nil {
+	if err := db.DB.Preload("Images", orderedImages).First(&attraction, id).Error; err != nil {
 		http.Error(w, "Not found attraction", http.StatusNotFound)
 		return
 	}
@@ -131,12 +113,30 @@ func UpdateAttraction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Address != attraction.Address || req.Location != attraction.Location {
+		attraction.FormattedAddress = ""
+		attraction.LocationMismatch = false
+		if check := checkAddress(req.Address, req.City, req.Location); check != nil {
+			req.Address = check.Address
+			req.Location = check.Location
+			attraction.FormattedAddress = check.FormattedAddress
+			attraction.LocationMismatch = check.Mismatch
+		}
+	}
+
+	if req.City != attraction.City || req.Location != attraction.Location {
+		attraction.CityID = nil
+		if cityID, cityName := resolveCity(req.City, req.Location); cityID != nil {
+			attraction.CityID = cityID
+			req.City = cityName
+		}
+	}
+
 	attraction.Title = req.Title
 	attraction.Description = req.Description
 	attraction.City = req.City
 	attraction.Address = req.Address
 	attraction.Location = req.Location
-	attraction.ImageURL = req.ImageURL
 	attraction.Category = req.Category
 
 	if err := db.DB.Save(&attraction).Error; err != nil {
@@ -157,7 +157,7 @@ func DeleteAttraction(w http.ResponseWriter, r *http.Request) {
 
 func ListAttractions(w http.ResponseWriter, r *http.Request) {
 	var attractions []models.Attraction
-	query := db.DB
+	query := db.DB.Preload("Images", orderedImages)
 	if err := query.Find(&attractions).Error; err != nil {
 		http.Error(w, "Failed to fetch attractions", http.StatusInternalServerError)
 		return
@@ -192,6 +192,16 @@ func UnpublishAttraction(w http.ResponseWriter, r *http.Request) {
 func ListPublishedAttractions(w http.ResponseWriter, r *http.Request) {
 	var attractions []models.Attraction
 	query := db.DB.Where("is_published = ?", true)
+
+	if cityIDParam := r.URL.Query().Get("city_id"); cityIDParam != "" {
+		cityID, err := strconv.ParseUint(cityIDParam, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid city_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("city_id = ?", cityID)
+	}
+
 	page := 1
 	pageSize := 10
 
@@ -205,7 +215,7 @@ func ListPublishedAttractions(w http.ResponseWriter, r *http.Request) {
 
 	var totalCount int64
 	query.Model(&models.Attraction{}).Count(&totalCount)
-	if err := query.
+	if err := query.Preload("Images", orderedImages).
 		Order("title ASC").
 		Offset(offset).
 		Limit(pageSize).
@@ -6,13 +6,37 @@ import (
 
 type Attraction struct {
 	gorm.Model
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	City        string `json:"city"`
-	Location    string `json:"location"`
-	Address     string `json:"address"`
-	IsPublished bool   `json:"is_published" gorm:"default:false"`
-	AdminID     uint   `json:"admin_id"`
-	ImageURL    string `json:"image_url"`
-	Category    string `json:"category"`
+	Title            string `json:"title"`
+	Description      string `json:"description"`
+	City             string `json:"city"`
+	CityID           *uint  `json:"city_id" gorm:"index"`
+	Location         string `json:"location"`
+	FormattedAddress string `json:"formatted_address"`
+	LocationMismatch bool   `json:"location_mismatch" gorm:"default:false"`
+	Address          string `json:"address"`
+	IsPublished      bool   `json:"is_published" gorm:"default:false"`
+	AdminID          uint   `json:"admin_id"`
+	// ImageURL mirrors the gallery's cover image.
+	ImageURL string `json:"image_url"`
+	Category string `json:"category"`
+
+	Images []AttractionImage `json:"images,omitempty" gorm:"foreignKey:AttractionID;constraint:OnDelete:CASCADE;"`
+}
+
+// AttractionImage is one picture of an attraction's gallery, shown in
+// Position order. One image per attraction is the cover.
+type AttractionImage struct {
+	gorm.Model
+	AttractionID uint   `json:"attraction_id" gorm:"index;not null"`
+	URL          string `json:"url" gorm:"not null"`
+	MediaID      *uint  `json:"media_id,omitempty" gorm:"index"`
+	Caption      string `json:"caption"`
+	Position     int    `json:"position" gorm:"not null;default:0"`
+	IsCover      bool   `json:"is_cover" gorm:"default:false"`
+}
+
+// BeforeCreate records the media-service ID behind the image URL.
+func (i *AttractionImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
 }
@@ -4,21 +4,11 @@ import (
 	"diplomaPorject/backend/attraction/internal/controllers"
 	"diplomaPorject/backend/attraction/internal/middleware"
 	"github.com/gorilla/mux"
-	"log"
-	"net/http"
 )
 
 func SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
 
-	uploadDir := "/app/uploads"
-
-	log.Printf("Serving static files from: %s", uploadDir)
-
-	r.PathPrefix("/uploads/").Handler(
-		http.StripPrefix("/uploads/", http.FileServer(http.Dir(uploadDir))),
-	).Methods("GET")
-
 	admin := r.PathPrefix("/admin/attractions").Subrouter()
 	admin.Use(middleware.AdminAuthMiddleware)
 	admin.HandleFunc("", controllers.CreateAttraction).Methods("POST")
@@ -27,9 +17,14 @@ func SetupRoutes() *mux.Router {
 	admin.HandleFunc("/{id}", controllers.DeleteAttraction).Methods("DELETE")
 	admin.HandleFunc("/{id}/publish", controllers.PublishAttraction).Methods("POST")
 	admin.HandleFunc("/{id}/unpublish", controllers.UnpublishAttraction).Methods("POST")
+	admin.HandleFunc("/{id}/images", controllers.UploadAttractionImages).Methods("POST")
+	admin.HandleFunc("/{id}/images/order", controllers.ReorderAttractionImages).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{image_id:[0-9]+}", controllers.UpdateAttractionImage).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{image_id:[0-9]+}", controllers.DeleteAttractionImage).Methods("DELETE")
 
 	r.HandleFunc("/attractions", controllers.ListPublishedAttractions).Methods("GET")
 	r.HandleFunc("/attractions/{id}", controllers.GetAttraction).Methods("GET")
+	r.HandleFunc("/attractions/{id}/images", controllers.ListAttractionImages).Methods("GET")
 
 	return r
 }
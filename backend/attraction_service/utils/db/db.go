@@ -19,10 +19,24 @@ func ConnectDB() {
 
 	DB = dbInstance
 
-	err = DB.AutoMigrate(&models.Attraction{})
+	err = DB.AutoMigrate(&models.Attraction{}, &models.AttractionImage{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	if err := migrateImageURLs(); err != nil {
+		log.Printf("Failed to move image URLs into galleries: %v", err)
+	}
+
 	fmt.Println("Connected to PostgreSQL database!")
 }
+
+// migrateImageURLs turns the single image of attractions saved before
+// galleries existed into the cover of their gallery.
+func migrateImageURLs() error {
+	return DB.Exec(`INSERT INTO attraction_images (created_at, updated_at, attraction_id, url, position, is_cover)
+		SELECT NOW(), NOW(), a.id, a.image_url, 0, TRUE
+		FROM attractions a
+		WHERE a.image_url <> '' AND a.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM attraction_images i WHERE i.attraction_id = a.id)`).Error
+}
@@ -1,19 +1,46 @@
 package main
 
 import (
+	"diplomaPorject/backend/blogs_service/internal/controllers"
+	"diplomaPorject/backend/blogs_service/internal/publishing"
 	"diplomaPorject/backend/blogs_service/internal/routes"
 	"diplomaPorject/backend/blogs_service/utils/db"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %s", name, value, fallback)
+	}
+	return fallback
+}
+
+func intFromEnv(name string, fallback int) int {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %d", name, value, fallback)
+	}
+	return fallback
+}
+
 func main() {
 	db.ConnectDB()
 
+	controllers.MaxCommentDepth = intFromEnv("COMMENT_MAX_DEPTH", controllers.MaxCommentDepth)
+	publishing.Start(durationFromEnv("BLOG_PUBLISH_INTERVAL", time.Minute))
+
 	r := mux.NewRouter()
 
 	routes.RegisterBlogRoutes(r)
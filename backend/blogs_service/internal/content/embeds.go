@@ -0,0 +1,138 @@
+package content
+
+import (
+	"diplomaPorject/backend/blogs_service/internal/models"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownEmbed is returned for embeds of items that do not exist or are
+// not public.
+var ErrUnknownEmbed = errors.New("unknown embedded item")
+
+// An embed is a line of its own such as {{place:12}}; it renders to a
+// paragraph of its own, which is replaced by the card.
+var (
+	embedPattern = regexp.MustCompile(`(?m)^[ \t]*\{\{(attraction|place|plan):([0-9]+)\}\}[ \t]*$`)
+	cardPattern  = regexp.MustCompile(`<p>\{\{(attraction|place|plan):([0-9]+)\}\}</p>`)
+)
+
+// cardSource reads the card of an item from the table of the service that
+// owns it. Query selects the title, subtitle and image_url of item @id.
+type cardSource struct {
+	Table string
+	Query string
+	Path  string
+}
+
+var cardSources = map[string]cardSource{
+	models.EmbedAttraction: {
+		Table: "attractions",
+		Query: `SELECT title, coalesce(city, '') AS subtitle, coalesce(image_url, '') AS image_url
+			FROM attractions WHERE id = @id AND is_published AND deleted_at IS NULL`,
+		Path: "/attractions/%d",
+	},
+	models.EmbedPlace: {
+		Table: "places",
+		Query: `SELECT p.name AS title, coalesce(p.city, '') AS subtitle,
+				coalesce((SELECT url FROM place_images
+					WHERE place_id = p.id AND deleted_at IS NULL ORDER BY id LIMIT 1), '') AS image_url
+			FROM places p WHERE p.id = @id AND p.is_published AND p.deleted_at IS NULL`,
+		Path: "/places/%d",
+	},
+	// Only public plans can be embedded; readers could not open the
+	// others.
+	models.EmbedPlan: {
+		Table: "plans",
+		Query: `SELECT p.title, coalesce(p.city, '') AS subtitle,
+				coalesce((SELECT image_url FROM plan_items
+					WHERE plan_id = p.id AND deleted_at IS NULL AND image_url <> ''
+					ORDER BY order_index, id LIMIT 1), '') AS image_url
+			FROM plans p WHERE p.id = @id AND p.is_public AND p.deleted_at IS NULL`,
+		Path: "/plans/%d",
+	},
+}
+
+// card loads the card of an embedded item.
+func card(tx *gorm.DB, itemType string, itemID uint) (models.BlogEmbed, error) {
+	embed := models.BlogEmbed{ItemType: itemType, ItemID: itemID}
+	src := cardSources[itemType]
+	if !tx.Migrator().HasTable(src.Table) {
+		return embed, fmt.Errorf("%w: %s:%d", ErrUnknownEmbed, itemType, itemID)
+	}
+
+	var rows []struct {
+		Title    string
+		Subtitle string
+		ImageURL string
+	}
+	if err := tx.Raw(src.Query, map[string]interface{}{"id": itemID}).Scan(&rows).Error; err != nil {
+		return embed, err
+	}
+	if len(rows) == 0 {
+		return embed, fmt.Errorf("%w: %s:%d", ErrUnknownEmbed, itemType, itemID)
+	}
+	embed.Title = rows[0].Title
+	embed.Subtitle = rows[0].Subtitle
+	embed.ImageURL = rows[0].ImageURL
+	embed.URL = fmt.Sprintf(src.Path, itemID)
+	return embed, nil
+}
+
+// cardHTML renders an embed as a link to the item.
+func cardHTML(e models.BlogEmbed) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<a class="embed-card embed-card-%s" href="%s" data-item-type="%s" data-item-id="%d">`,
+		e.ItemType, html.EscapeString(e.URL), e.ItemType, e.ItemID)
+	if e.ImageURL != "" {
+		fmt.Fprintf(&b, `<img src="%s" alt="">`, html.EscapeString(e.ImageURL))
+	}
+	fmt.Fprintf(&b, `<span class="embed-card-title">%s</span>`, html.EscapeString(e.Title))
+	if e.Subtitle != "" {
+		fmt.Fprintf(&b, `<span class="embed-card-subtitle">%s</span>`, html.EscapeString(e.Subtitle))
+	}
+	b.WriteString(`</a>`)
+	return b.String()
+}
+
+// Render converts the Markdown body of a post to sanitized HTML with its
+// embeds replaced by cards, which are read inside tx. It returns the
+// embedded items in order of first appearance.
+func Render(tx *gorm.DB, source string) (string, []models.BlogEmbed, error) {
+	rendered, err := renderMarkdown(source)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cards := make(map[string]string)
+	var embeds []models.BlogEmbed
+	for _, match := range cardPattern.FindAllStringSubmatch(rendered, -1) {
+		key := match[1] + ":" + match[2]
+		if _, ok := cards[key]; ok {
+			continue
+		}
+		itemID, err := strconv.ParseUint(match[2], 10, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnknownEmbed, key)
+		}
+		embed, err := card(tx, match[1], uint(itemID))
+		if err != nil {
+			return "", nil, err
+		}
+		embed.Position = len(embeds)
+		embeds = append(embeds, embed)
+		cards[key] = cardHTML(embed)
+	}
+
+	rendered = cardPattern.ReplaceAllStringFunc(rendered, func(paragraph string) string {
+		match := cardPattern.FindStringSubmatch(paragraph)
+		return cards[match[1]+":"+match[2]]
+	})
+	return rendered, embeds, nil
+}
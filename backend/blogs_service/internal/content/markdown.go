@@ -0,0 +1,66 @@
+// Package content turns the Markdown body of a blog post into the HTML
+// that is served with it, and derives what is computed from the body: the
+// embedded cards, the slug and the reading time.
+package content
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// wordsPerMinute is the reading speed reading times assume.
+const wordsPerMinute = 200
+
+// maxSlugLength leaves room in the slug column for the suffix that makes a
+// slug unique.
+const maxSlugLength = 100
+
+// Raw HTML in the Markdown is dropped by goldmark and whatever else gets
+// through is sanitized, so posts cannot carry scripts or styles.
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	policy   = bluemonday.UGCPolicy()
+)
+
+// renderMarkdown converts Markdown to sanitized HTML.
+func renderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
+
+// ReadingMinutes estimates how long the Markdown takes to read, at least a
+// minute. Embeds are not counted.
+func ReadingMinutes(source string) int {
+	words := len(strings.Fields(embedPattern.ReplaceAllString(source, "")))
+	return max(1, (words+wordsPerMinute-1)/wordsPerMinute)
+}
+
+// Slug makes a URL slug of a title: lower-case letters and digits in any
+// script, separated by single dashes.
+func Slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if runes := []rune(b.String()); len(runes) > maxSlugLength {
+		return strings.TrimRight(string(runes[:maxSlugLength]), "-")
+	}
+	return b.String()
+}
@@ -1,62 +1,40 @@
 package controllers
 
 import (
-	"crypto/rand"
+	"cmp"
 	"diplomaPorject/backend/blogs_service/internal/models"
 	"diplomaPorject/backend/blogs_service/utils"
 	"diplomaPorject/backend/blogs_service/utils/db"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
-	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 )
 
-func generateRandomFilename(originalFilename string) string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes) + filepath.Ext(originalFilename)
-}
-
 func uploadImages(files []*multipart.FileHeader) ([]string, error) {
 	var imageURLs []string
-	uploadDir := "/app/uploads"
-
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create upload directory: %v", err)
-	}
-
 	for _, fileHeader := range files {
 		file, err := fileHeader.Open()
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file: %v", err)
 		}
-		defer file.Close()
-
-		randomFilename := generateRandomFilename(fileHeader.Filename)
-		filePath := filepath.Join(uploadDir, randomFilename)
-
-		dst, err := os.Create(filePath)
+		url, err := storeMedia(file, fileHeader.Filename)
+		file.Close()
 		if err != nil {
-			return nil, fmt.Errorf("failed to create file: %v", err)
-		}
-		defer dst.Close()
-
-		if _, err := io.Copy(dst, file); err != nil {
-			return nil, fmt.Errorf("failed to save file: %v", err)
+			return nil, err
 		}
-
-		imageURLs = append(imageURLs, "/uploads/"+randomFilename)
+		imageURLs = append(imageURLs, url)
 	}
 	return imageURLs, nil
 }
 
+// CreateBlog takes a multipart form: title, Markdown content, category,
+// tags, status (draft, scheduled with publish_at, or published, the
+// default), an optional slug, a cover file and images.
 func CreateBlog(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
@@ -71,26 +49,48 @@ func CreateBlog(w http.ResponseWriter, r *http.Request) {
 
 	username, _ := utils.GetUsername(userID) // <- НОВАЯ строка
 
-	title := r.FormValue("title")
-	content := r.FormValue("content")
-	category := r.FormValue("category")
+	blog := models.Blog{
+		Title:    r.FormValue("title"),
+		Content:  r.FormValue("content"),
+		Category: r.FormValue("category"),
+		UserID:   userID,
+		Username: username,
+
+		ModerationStatus: models.StatusVisible,
+	}
 
-	files := r.MultipartForm.File["images"]
-	imageURLs, err := uploadImages(files)
+	// Everything that can be refused is checked before uploading.
+	tags, err := parseTags(r)
+	if err == nil {
+		err = applyPublishing(r, &blog)
+	}
+	if err == nil {
+		err = checkPost(&blog)
+	}
+	var embeds []models.BlogEmbed
+	if err == nil {
+		embeds, err = renderPost(&blog)
+	}
+	if err == nil {
+		blog.Slug, err = uniqueSlug(db.DB, cmp.Or(r.FormValue("slug"), blog.Title), 0)
+	}
 	if err != nil {
-		http.Error(w, "Image upload failed", http.StatusInternalServerError)
+		writePostError(w, err, "Failed to create blog")
 		return
 	}
 
-	blog := models.Blog{
-		Title:    title,
-		Content:  content,
-		Category: category,
-		UserID:   userID,
-		Username: username,
+	files := r.MultipartForm.File["images"]
+	imageURLs, err := uploadImages(files)
+	if err == nil {
+		blog.CoverImage, err = uploadCover(r)
+	}
+	if err != nil {
+		http.Error(w, "Image upload failed", uploadErrorStatus(err))
+		return
 	}
 
-	if err := db.DB.Create(&blog).Error; err != nil {
+	if err := savePost(&blog, tags, embeds); err != nil {
+		log.Printf("Error creating blog: %v", err)
 		http.Error(w, "Failed to create blog", http.StatusInternalServerError)
 		return
 	}
@@ -99,26 +99,39 @@ func CreateBlog(w http.ResponseWriter, r *http.Request) {
 		db.DB.Create(&models.BlogImage{BlogID: blog.ID, URL: u})
 	}
 
-	db.DB.Preload("Images").First(&blog, blog.ID)
+	screenBlog(&blog, false)
+
+	withPostDetails(db.DB).First(&blog, blog.ID)
 	json.NewEncoder(w).Encode(blog)
 }
 
+// pageParam reads the page query parameter, 1 by default.
+func pageParam(r *http.Request) int {
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		return p
+	}
+	return 1
+}
+
+// GetBlogs lists published posts, newest first, filtered by category or
+// tag.
 func GetBlogs(w http.ResponseWriter, r *http.Request) {
 	var blogs []models.Blog
 
-	query := db.DB.Preload("Comments").Preload("Images") // <- добавлено
+	query := withPostDetails(db.DB)
+	query = published(query)
 
 	if category := r.URL.Query().Get("category"); category != "" {
 		query = query.Where("category = ?", category)
 	}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		query = query.Where("id IN (?)", db.DB.Table("blog_tags").Select("blog_tags.blog_id").
+			Joins("JOIN tags ON tags.id = blog_tags.tag_id").
+			Where("tags.name = ?", strings.ToLower(strings.TrimPrefix(tag, "#"))))
+	}
 
-	page := 1
+	page := pageParam(r)
 	pageSize := 10
-	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
-		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
-			page = p
-		}
-	}
 
 	offset := (page - 1) * pageSize
 
@@ -126,7 +139,7 @@ func GetBlogs(w http.ResponseWriter, r *http.Request) {
 	query.Model(&models.Blog{}).Count(&totalCount)
 
 	if err := query.
-		Order("created_at DESC").
+		Order("published_at DESC, id DESC").
 		Offset(offset).
 		Limit(pageSize).
 		Find(&blogs).Error; err != nil {
@@ -149,6 +162,43 @@ func GetBlogs(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetMyBlogs lists the requesting user's posts in every status, most
+// recently edited first; ?status= keeps one status.
+func GetMyBlogs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := withPostDetails(db.DB).Where("user_id = ?", userID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page := pageParam(r)
+	pageSize := 10
+
+	var totalCount int64
+	query.Model(&models.Blog{}).Count(&totalCount)
+
+	blogs := []models.Blog{}
+	if err := query.Order("updated_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&blogs).Error; err != nil {
+		http.Error(w, "Failed to fetch blogs", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"blogs":     blogs,
+		"total":     totalCount,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
 func GetBlog(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, err := strconv.Atoi(params["id"])
@@ -158,7 +208,25 @@ func GetBlog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var blog models.Blog
-	if err := db.DB.Preload("Comments").Preload("Images").First(&blog, id).Error; err != nil {
+	if err := withPostDetails(db.DB).First(&blog, id).Error; err != nil {
+		http.Error(w, "Blog not found", http.StatusNotFound)
+		return
+	}
+	if !blogVisibleTo(r, &blog) {
+		http.Error(w, "Blog not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(blog)
+}
+
+func GetBlogBySlug(w http.ResponseWriter, r *http.Request) {
+	var blog models.Blog
+	if err := withPostDetails(db.DB).Where("slug = ?", mux.Vars(r)["slug"]).First(&blog).Error; err != nil {
+		http.Error(w, "Blog not found", http.StatusNotFound)
+		return
+	}
+	if !blogVisibleTo(r, &blog) {
 		http.Error(w, "Blog not found", http.StatusNotFound)
 		return
 	}
@@ -166,6 +234,8 @@ func GetBlog(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(blog)
 }
 
+// UpdateBlog takes the fields of CreateBlog. Tags, status and slug are
+// kept when the form leaves them out; remove_cover=true drops the cover.
 func UpdateBlog(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
 		http.Error(w, "Failed to parse form", http.StatusBadRequest)
@@ -191,7 +261,7 @@ func UpdateBlog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var blog models.Blog
-	if err := db.DB.Preload("Images").First(&blog, id).Error; err != nil {
+	if err := db.DB.Preload("Images").Preload("Tags").First(&blog, id).Error; err != nil {
 		http.Error(w, "Blog not found", http.StatusNotFound)
 		return
 	}
@@ -201,25 +271,51 @@ func UpdateBlog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
-	content := r.FormValue("content")
-	category := r.FormValue("category")
+	blog.Title = r.FormValue("title")
+	blog.Content = r.FormValue("content")
+	blog.Category = r.FormValue("category")
 
-	if title == "" || content == "" {
-		http.Error(w, "Title and content are required", http.StatusBadRequest)
+	var tags []string
+	if _, ok := r.MultipartForm.Value["tags"]; ok {
+		tags, err = parseTags(r)
+	} else {
+		for _, tag := range blog.Tags {
+			tags = append(tags, tag.Name)
+		}
+	}
+	if err == nil {
+		err = applyPublishing(r, &blog)
+	}
+	if err == nil {
+		err = checkPost(&blog)
+	}
+	var embeds []models.BlogEmbed
+	if err == nil {
+		embeds, err = renderPost(&blog)
+	}
+	if slug := r.FormValue("slug"); err == nil && slug != "" {
+		blog.Slug, err = uniqueSlug(db.DB, slug, blog.ID)
+	}
+	if err != nil {
+		writePostError(w, err, "Failed to update blog")
 		return
 	}
 
-	blog.Title = title
-	blog.Content = content
-	blog.Category = category
+	cover, err := uploadCover(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Image upload failed: %v", err), uploadErrorStatus(err))
+		return
+	}
+	if cover != "" || r.FormValue("remove_cover") == "true" {
+		blog.CoverImage = cover
+	}
 
 	db.DB.Where("blog_id = ?", blog.ID).Delete(&models.BlogImage{})
 
 	files := r.MultipartForm.File["images"]
 	imageURLs, err := uploadImages(files)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Image upload failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Image upload failed: %v", err), uploadErrorStatus(err))
 		return
 	}
 
@@ -231,12 +327,14 @@ func UpdateBlog(w http.ResponseWriter, r *http.Request) {
 		db.DB.Create(&image)
 	}
 
-	if err := db.DB.Save(&blog).Error; err != nil {
+	if err := savePost(&blog, tags, embeds); err != nil {
 		http.Error(w, "Failed to update blog", http.StatusInternalServerError)
 		return
 	}
 
-	db.DB.Preload("Images").First(&blog, blog.ID)
+	screenBlog(&blog, true)
+
+	withPostDetails(db.DB).First(&blog, blog.ID)
 	json.NewEncoder(w).Encode(blog)
 }
 
@@ -295,5 +393,8 @@ func SyncUsername(w http.ResponseWriter, r *http.Request) {
 	db.DB.Model(&models.Comment{}).
 		Where("user_id = ?", req.UserID).
 		Update("username", req.Username)
+	db.DB.Model(&models.CommentMention{}).
+		Where("user_id = ?", req.UserID).
+		Update("username", req.Username)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
+	"log"
 	"net/http"
 	"strconv"
 )
@@ -35,6 +36,12 @@ func AddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var blog models.Blog
+	if err := published(db.DB).First(&blog, blogID).Error; err != nil {
+		http.Error(w, "Blog not found", http.StatusNotFound)
+		return
+	}
+
 	content := r.FormValue("content")
 	if content == "" {
 		http.Error(w, "Comment content is required", http.StatusBadRequest)
@@ -44,7 +51,7 @@ func AddComment(w http.ResponseWriter, r *http.Request) {
 	files := r.MultipartForm.File["images"]
 	imageURLs, err := uploadImages(files)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Image upload failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Image upload failed: %v", err), uploadErrorStatus(err))
 		return
 	}
 	username, err := utils.GetUsername(userID)
@@ -57,36 +64,88 @@ func AddComment(w http.ResponseWriter, r *http.Request) {
 		BlogID:   uint(blogID),
 		UserID:   userID,
 		Username: username,
+
+		ModerationStatus: models.StatusVisible,
+	}
+	if value := r.FormValue("parent_id"); value != "" {
+		parentID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid parent_id", http.StatusBadRequest)
+			return
+		}
+		id := uint(parentID)
+		comment.ParentID = &id
 	}
 
-	if err := db.DB.Create(&comment).Error; err != nil {
-		http.Error(w, "Failed to add comment", http.StatusInternalServerError)
+	if err := insertComment(&comment, imageURLs); err != nil {
+		writeCommentError(w, err, "Failed to add comment")
 		return
 	}
 
-	for _, url := range imageURLs {
-		cimg := models.CommentImage{CommentID: comment.ID, URL: url}
-		db.DB.Create(&cimg)
-	}
+	screenComment(&comment, false)
+	mention(&comment, nil)
 
-	db.DB.Preload("Images").First(&comment, comment.ID)
+	db.DB.Preload("Images").Preload("Mentions").First(&comment, comment.ID)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(comment)
 }
 
+// GetComments lists the top-level comments of a blog, a page at a time,
+// each with its replies nested oldest first. ?sort= orders the top level:
+// oldest (the default), newest or top, by most replies.
 func GetComments(w http.ResponseWriter, r *http.Request) {
 	blogID, _ := strconv.Atoi(mux.Vars(r)["id"])
-	var comments []models.Comment
-	if err := db.DB.
-		Where("blog_id = ?", blogID).
-		Preload("Images").
-		Order("created_at ASC").
+
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "oldest"
+	}
+	order, ok := commentOrders[sort]
+	if !ok {
+		http.Error(w, "sort must be oldest, newest or top", http.StatusBadRequest)
+		return
+	}
+	page := pageParam(r)
+	pageSize := 20
+	if size, err := strconv.Atoi(r.URL.Query().Get("page_size")); err == nil && size > 0 {
+		pageSize = min(size, 100)
+	}
+
+	query := listedComments(db.DB.Model(&models.Comment{})).Where("blog_id = ? AND parent_id IS NULL", blogID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
+		return
+	}
+
+	comments := []models.Comment{}
+	if err := query.
+		Preload("Images").Preload("Mentions").
+		Order(order).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
 		Find(&comments).Error; err != nil {
 		http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(comments)
+	if err := withReplies(comments); err != nil {
+		http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Comments []models.Comment `json:"comments"`
+		Total    int64            `json:"total"`
+		Page     int              `json:"page"`
+		PageSize int              `json:"page_size"`
+	}{
+		Comments: comments,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func UpdateComment(w http.ResponseWriter, r *http.Request) {
@@ -99,7 +158,7 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 	userID, _ := r.Context().Value("user_id").(uint)
 
 	var comment models.Comment
-	if err := db.DB.Preload("Images").First(&comment, commentID).Error; err != nil {
+	if err := db.DB.Preload("Images").Where("NOT deleted").First(&comment, commentID).Error; err != nil {
 		http.Error(w, "Comment not found", http.StatusNotFound)
 		return
 	}
@@ -116,7 +175,7 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 		db.DB.Where("comment_id = ?", comment.ID).Delete(&models.CommentImage{})
 		urls, err := uploadImages(files)
 		if err != nil {
-			http.Error(w, "Image upload error", http.StatusInternalServerError)
+			http.Error(w, "Image upload error", uploadErrorStatus(err))
 			return
 		}
 		for _, u := range urls {
@@ -124,8 +183,11 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	db.DB.Save(&comment)
-	db.DB.Preload("Images").First(&comment, comment.ID)
+	notified := mentionedUsers(comment.ID)
+	db.DB.Omit("Images", "Mentions").Save(&comment)
+	screenComment(&comment, true)
+	mention(&comment, notified)
+	db.DB.Preload("Images").Preload("Mentions").First(&comment, comment.ID)
 	json.NewEncoder(w).Encode(comment)
 }
 
@@ -134,7 +196,7 @@ func DeleteComment(w http.ResponseWriter, r *http.Request) {
 	userID, _ := r.Context().Value("user_id").(uint)
 
 	var comment models.Comment
-	if err := db.DB.First(&comment, commentID).Error; err != nil {
+	if err := db.DB.Where("NOT deleted").First(&comment, commentID).Error; err != nil {
 		http.Error(w, "Comment not found", http.StatusNotFound)
 		return
 	}
@@ -143,7 +205,10 @@ func DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db.DB.Where("comment_id = ?", comment.ID).Delete(&models.CommentImage{})
-	db.DB.Delete(&comment)
+	if err := removeComment(comment.ID); err != nil {
+		log.Printf("Error deleting comment: %v", err)
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
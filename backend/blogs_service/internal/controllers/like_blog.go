@@ -33,7 +33,7 @@ func LikeBlog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var blog models.Blog
-	if err := db.DB.First(&blog, id).Error; err != nil {
+	if err := published(db.DB).First(&blog, id).Error; err != nil {
 		http.Error(w, "Blog not found", http.StatusNotFound)
 		return
 	}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mediaOwner tells media-service which service uploaded a file.
+const mediaOwner = "blogs"
+
+func getMediaServiceURL() string {
+	if u := os.Getenv("MEDIA_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://media-service:8094"
+}
+
+// errMediaRejected marks files media-service refused, such as ones that
+// are not images or are too large; they are the client's fault.
+var errMediaRejected = errors.New("file rejected")
+
+// uploadErrorStatus is the HTTP status for a failed storeMedia.
+func uploadErrorStatus(err error) int {
+	if errors.Is(err, errMediaRejected) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// storeMedia streams a file to media-service and returns its stable
+// "/media/{id}" URL, which the gateway routes back to media-service.
+func storeMedia(file io.Reader, filename string) (string, error) {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("file", filepath.Base(filename))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest(http.MethodPost,
+		getMediaServiceURL()+"/internal/media?owner_service="+url.QueryEscape(mediaOwner), body)
+	if err != nil {
+		body.Close()
+		return "", fmt.Errorf("failed to reach media service: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach media service: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		switch resp.StatusCode {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+			return "", fmt.Errorf("%w: %s", errMediaRejected, strings.TrimSpace(string(message)))
+		}
+		return "", fmt.Errorf("media service returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var media struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&media); err != nil || media.URL == "" {
+		return "", fmt.Errorf("invalid media service response: %v", err)
+	}
+	return media.URL, nil
+}
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"bytes"
+	"diplomaPorject/backend/blogs_service/internal/models"
+	"diplomaPorject/backend/blogs_service/utils/db"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+func getModerationServiceURL() string {
+	if u := os.Getenv("MODERATION_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://moderation-service:8095"
+}
+
+var moderationClient = &http.Client{Timeout: 5 * time.Second}
+
+func postModeration(path string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, getModerationServiceURL()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+	return moderationClient.Do(req)
+}
+
+// screen has moderation-service check content that was just posted or
+// edited, and holds visible content that a check flags. Content stays up
+// when moderation-service is unreachable.
+func screen(contentType string, model interface{}, id, authorID uint, status, text string, edit bool) string {
+	resp, err := postModeration("/internal/moderation/screen", map[string]interface{}{
+		"content_type": contentType,
+		"content_id":   id,
+		"author_id":    authorID,
+		"text":         text,
+		"edit":         edit,
+	})
+	if err != nil {
+		log.Printf("Error screening %s %d: %v", contentType, id, err)
+		return status
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Screening %s %d returned %d", contentType, id, resp.StatusCode)
+		return status
+	}
+	var result struct {
+		Verdict string   `json:"verdict"`
+		Flags   []string `json:"flags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Error decoding screening response: %v", err)
+		return status
+	}
+
+	if result.Verdict != "hold" || status != models.StatusVisible {
+		return status
+	}
+	if err := db.DB.Model(model).Where("id = ?", id).Update("moderation_status", models.StatusHeld).Error; err != nil {
+		log.Printf("Failed to hold %s %d: %v", contentType, id, err)
+		return status
+	}
+	log.Printf("%s %d held for moderation: %s", contentType, id, strings.Join(result.Flags, "; "))
+	return models.StatusHeld
+}
+
+func screenBlog(blog *models.Blog, edit bool) {
+	blog.ModerationStatus = screen("blog", &models.Blog{}, blog.ID, blog.UserID, blog.ModerationStatus,
+		blog.Title+"\n\n"+blog.Content, edit)
+}
+
+func screenComment(comment *models.Comment, edit bool) {
+	comment.ModerationStatus = screen("comment", &models.Comment{}, comment.ID, comment.UserID, comment.ModerationStatus,
+		comment.Content, edit)
+}
+
+// visibleTo reports whether content is shown to the requesting user: all
+// visible content, and held or hidden content to its author. The gateway
+// sets X-User-ID on every blog request.
+func visibleTo(r *http.Request, status string, authorID uint) bool {
+	return status == models.StatusVisible || isRequester(r, authorID)
+}
+
+// isRequester reports whether the request comes from the user.
+func isRequester(r *http.Request, userID uint) bool {
+	requester, err := strconv.ParseUint(r.Header.Get("X-User-ID"), 10, 32)
+	return err == nil && uint(requester) == userID
+}
+
+func visibleComments(tx *gorm.DB) *gorm.DB {
+	return tx.Where("moderation_status = ? AND NOT deleted", models.StatusVisible)
+}
+
+// report forwards a user's report to moderation-service. The body is
+// {"reason": "...", "details": "..."}.
+func report(w http.ResponseWriter, r *http.Request, contentType string, id, authorID uint, text string) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := postModeration("/internal/moderation/reports", map[string]interface{}{
+		"content_type": contentType,
+		"content_id":   id,
+		"author_id":    authorID,
+		"text":         text,
+		"reporter_id":  userID,
+		"reason":       input.Reason,
+		"details":      input.Details,
+	})
+	if err != nil {
+		log.Printf("Error reporting %s %d: %v", contentType, id, err)
+		http.Error(w, "Failed to send report", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func ReportBlog(w http.ResponseWriter, r *http.Request) {
+	var blog models.Blog
+	if err := published(db.DB).First(&blog, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Blog not found", http.StatusNotFound)
+		return
+	}
+	report(w, r, "blog", blog.ID, blog.UserID, blog.Title+"\n\n"+blog.Content)
+}
+
+func ReportComment(w http.ResponseWriter, r *http.Request) {
+	var comment models.Comment
+	if err := visibleComments(db.DB).First(&comment, mux.Vars(r)["comment_id"]).Error; err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	report(w, r, "comment", comment.ID, comment.UserID, comment.Content)
+}
+
+// setModerationStatus applies a moderation-service decision. The body is
+// {"status": "visible" | "held" | "hidden"}.
+func setModerationStatus(w http.ResponseWriter, r *http.Request, model interface{}, id string) {
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch input.Status {
+	case models.StatusVisible, models.StatusHeld, models.StatusHidden:
+	default:
+		http.Error(w, "Invalid moderation status", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DB.First(model, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load content", http.StatusInternalServerError)
+		return
+	}
+	if err := db.DB.Model(model).Update("moderation_status", input.Status).Error; err != nil {
+		http.Error(w, "Failed to update moderation status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model)
+}
+
+func SetBlogModeration(w http.ResponseWriter, r *http.Request) {
+	setModerationStatus(w, r, &models.Blog{}, mux.Vars(r)["id"])
+}
+
+func SetCommentModeration(w http.ResponseWriter, r *http.Request) {
+	setModerationStatus(w, r, &models.Comment{}, mux.Vars(r)["comment_id"])
+}
+
+// InternalDeleteBlog deletes a blog for moderation-service, without the
+// author check of DeleteBlog.
+func InternalDeleteBlog(w http.ResponseWriter, r *http.Request) {
+	var blog models.Blog
+	if err := db.DB.First(&blog, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Blog not found", http.StatusNotFound)
+		return
+	}
+	if err := db.DB.Delete(&blog).Error; err != nil {
+		log.Printf("Error deleting blog: %v", err)
+		http.Error(w, "Failed to delete blog", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// InternalDeleteComment deletes a comment for moderation-service, without
+// the author check of DeleteComment.
+func InternalDeleteComment(w http.ResponseWriter, r *http.Request) {
+	var comment models.Comment
+	if err := db.DB.First(&comment, mux.Vars(r)["comment_id"]).Error; err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if err := removeComment(comment.ID); err != nil {
+		log.Printf("Error deleting comment: %v", err)
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
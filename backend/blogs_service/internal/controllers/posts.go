@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/blogs_service/internal/content"
+	"diplomaPorject/backend/blogs_service/internal/models"
+	"diplomaPorject/backend/blogs_service/utils/db"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits on the tags of a post.
+const (
+	maxTags      = 10
+	maxTagLength = 32
+)
+
+// errInvalidPost marks form values a post cannot be saved with; the
+// message is shown to the client.
+var errInvalidPost = errors.New("invalid post")
+
+func invalidPost(message string) error {
+	return fmt.Errorf("%w: %s", errInvalidPost, message)
+}
+
+// postErrorStatus is the HTTP status for a failed save of a post.
+func postErrorStatus(err error) int {
+	if errors.Is(err, errInvalidPost) || errors.Is(err, content.ErrUnknownEmbed) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// applyPublishing sets the publishing status of a post from the status
+// and publish_at form fields. Without a status a post keeps its own, and a
+// new post is published right away.
+func applyPublishing(r *http.Request, blog *models.Blog) error {
+	status := r.FormValue("status")
+	if status == "" {
+		if blog.Status != "" {
+			return nil
+		}
+		status = models.PostPublished
+	}
+
+	now := time.Now()
+	switch status {
+	case models.PostDraft:
+		blog.PublishAt = nil
+	case models.PostScheduled:
+		publishAt, err := time.Parse(time.RFC3339, r.FormValue("publish_at"))
+		if err != nil {
+			return invalidPost("publish_at must be an RFC 3339 time")
+		}
+		if !publishAt.After(now) {
+			return invalidPost("publish_at must be in the future")
+		}
+		blog.PublishAt = &publishAt
+	case models.PostPublished:
+		blog.PublishAt = nil
+		if blog.PublishedAt == nil {
+			blog.PublishedAt = &now
+		}
+	default:
+		return invalidPost("status must be draft, scheduled or published")
+	}
+	blog.Status = status
+	return nil
+}
+
+// parseTags reads the tags of a post from repeated or comma separated
+// tags fields. Tags are lower-cased and a leading # is dropped.
+func parseTags(r *http.Request) ([]string, error) {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, value := range r.MultipartForm.Value["tags"] {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if utf8.RuneCountInString(tag) > maxTagLength {
+				return nil, invalidPost(fmt.Sprintf("tags can be at most %d characters", maxTagLength))
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTags {
+		return nil, invalidPost(fmt.Sprintf("a post can have at most %d tags", maxTags))
+	}
+	return tags, nil
+}
+
+// findTags returns the tags with the names, creating the missing ones.
+func findTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+	for _, name := range names {
+		tags = append(tags, models.Tag{Name: name})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+	tags = []models.Tag{}
+	err := tx.Where("name IN ?", names).Find(&tags).Error
+	return tags, err
+}
+
+// uniqueSlug makes a slug of text that no other post, deleted ones
+// included, has; a number is appended when needed.
+func uniqueSlug(tx *gorm.DB, text string, blogID uint) (string, error) {
+	base := content.Slug(text)
+	if base == "" {
+		base = "post"
+	}
+	slug := base
+	for n := 2; ; n++ {
+		var taken int64
+		err := tx.Unscoped().Model(&models.Blog{}).Where("slug = ? AND id <> ?", slug, blogID).Count(&taken).Error
+		if err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// renderPost renders the Markdown of a post and estimates its reading
+// time. It returns the embedded items, to be saved with savePost.
+func renderPost(blog *models.Blog) ([]models.BlogEmbed, error) {
+	rendered, embeds, err := content.Render(db.DB, blog.Content)
+	if err != nil {
+		return nil, err
+	}
+	blog.ContentHTML = rendered
+	blog.ReadingMinutes = content.ReadingMinutes(blog.Content)
+	return embeds, nil
+}
+
+// savePost saves a post with its tags and embeds, which replace the ones
+// it had.
+func savePost(blog *models.Blog, tagNames []string, embeds []models.BlogEmbed) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		tags, err := findTags(tx, tagNames)
+		if err != nil {
+			return err
+		}
+		if err := tx.Omit("Tags", "Embeds", "Comments", "Images").Save(blog).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(blog).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id = ?", blog.ID).Delete(&models.BlogEmbed{}).Error; err != nil {
+			return err
+		}
+		for i := range embeds {
+			embeds[i].BlogID = blog.ID
+		}
+		if len(embeds) > 0 {
+			return tx.Create(&embeds).Error
+		}
+		return nil
+	})
+}
+
+// withPostDetails loads what is shown with a post.
+func withPostDetails(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Comments", visibleComments).Preload("Images").Preload("Tags").
+		Preload("Embeds", func(tx *gorm.DB) *gorm.DB { return tx.Order("position") })
+}
+
+// published limits a query to posts everyone can read.
+func published(tx *gorm.DB) *gorm.DB {
+	return tx.Where("status = ? AND moderation_status = ?", models.PostPublished, models.StatusVisible)
+}
+
+// blogVisibleTo reports whether a post is shown to the requesting user:
+// drafts and scheduled posts only to their authors, published ones as
+// visibleTo decides.
+func blogVisibleTo(r *http.Request, blog *models.Blog) bool {
+	if blog.Status != models.PostPublished {
+		return isRequester(r, blog.UserID)
+	}
+	return visibleTo(r, blog.ModerationStatus, blog.UserID)
+}
+
+// checkPost verifies that a post has what its status needs: a title
+// always, and content once it is no longer a draft.
+func checkPost(blog *models.Blog) error {
+	if strings.TrimSpace(blog.Title) == "" {
+		return invalidPost("title is required")
+	}
+	if blog.Status != models.PostDraft && strings.TrimSpace(blog.Content) == "" {
+		return invalidPost("content is required unless the post is a draft")
+	}
+	return nil
+}
+
+// uploadCover stores the cover file of the form, if it has one.
+func uploadCover(r *http.Request) (string, error) {
+	files := r.MultipartForm.File["cover"]
+	if len(files) == 0 {
+		return "", nil
+	}
+	urls, err := uploadImages(files[:1])
+	if err != nil {
+		return "", err
+	}
+	return urls[0], nil
+}
+
+func writePostError(w http.ResponseWriter, err error, message string) {
+	if status := postErrorStatus(err); status != http.StatusInternalServerError {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("%s: %v", message, err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/blogs_service/internal/models"
+	"diplomaPorject/backend/blogs_service/utils"
+	"diplomaPorject/backend/blogs_service/utils/db"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxCommentDepth is the depth of the deepest replies; comments at this
+// depth cannot be replied to.
+var MaxCommentDepth = 3
+
+// maxMentions caps the users a comment can mention.
+const maxMentions = 10
+
+// excerptLength is how much of a comment a notification quotes, in runes.
+const excerptLength = 140
+
+// A mention is @ and a username at the start of the text or after a
+// character that cannot be part of an address or another username.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@(\w+(?:\.\w+)*)`)
+
+// errInvalidComment marks comments that cannot be saved; the message is
+// shown to the client.
+var errInvalidComment = errors.New("invalid comment")
+
+func invalidComment(message string) error {
+	return fmt.Errorf("%w: %s", errInvalidComment, message)
+}
+
+// Orders of the top-level comments of a blog.
+var commentOrders = map[string]string{
+	"oldest": "created_at ASC, id ASC",
+	"newest": "created_at DESC, id DESC",
+	"top":    "reply_count DESC, created_at ASC, id ASC",
+}
+
+// listedComments limits a query to the comments shown in threads: visible
+// ones, and placeholders of deleted ones that still have replies.
+func listedComments(tx *gorm.DB) *gorm.DB {
+	return tx.Where("moderation_status = ? AND (NOT deleted OR reply_count > 0)", models.StatusVisible)
+}
+
+// withReplies nests their replies under the comments, oldest first, down
+// to the deepest level.
+func withReplies(comments []models.Comment) error {
+	children := make(map[uint][]models.Comment)
+	ids := make([]uint, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	for len(ids) > 0 {
+		var replies []models.Comment
+		err := listedComments(db.DB).
+			Where("parent_id IN ?", ids).
+			Preload("Images").Preload("Mentions").
+			Order("created_at ASC, id ASC").
+			Find(&replies).Error
+		if err != nil {
+			return err
+		}
+		ids = ids[:0]
+		for _, reply := range replies {
+			children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+			ids = append(ids, reply.ID)
+		}
+	}
+
+	var nest func(c *models.Comment)
+	nest = func(c *models.Comment) {
+		c.Replies = children[c.ID]
+		for i := range c.Replies {
+			nest(&c.Replies[i])
+		}
+	}
+	for i := range comments {
+		nest(&comments[i])
+	}
+	return nil
+}
+
+// insertComment saves a new comment with its images. A reply is checked
+// against its parent, which must be a shown comment of the same blog and
+// not too deep, and is counted in it.
+func insertComment(comment *models.Comment, imageURLs []string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if comment.ParentID != nil {
+			var parent models.Comment
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, *comment.ParentID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return invalidComment("parent comment not found")
+			}
+			if err != nil {
+				return err
+			}
+			if parent.BlogID != comment.BlogID || parent.Deleted || parent.ModerationStatus != models.StatusVisible {
+				return invalidComment("parent comment not found")
+			}
+			if parent.Depth >= MaxCommentDepth {
+				return invalidComment(fmt.Sprintf("replies can be at most %d levels deep", MaxCommentDepth))
+			}
+			comment.Depth = parent.Depth + 1
+			if err := tx.Model(&parent).UpdateColumn("reply_count", gorm.Expr("reply_count + 1")).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Omit("Images", "Mentions").Create(comment).Error; err != nil {
+			return err
+		}
+		for _, url := range imageURLs {
+			if err := tx.Create(&models.CommentImage{CommentID: comment.ID, URL: url}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// removeComment deletes a comment. One with replies becomes a placeholder
+// that keeps them in the thread; any other is deleted outright, and so is
+// each placeholder above it left without replies.
+func removeComment(commentID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var comment models.Comment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&comment, commentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.CommentImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.CommentMention{}).Error; err != nil {
+			return err
+		}
+		if comment.ReplyCount > 0 {
+			return tx.Model(&comment).UpdateColumns(map[string]interface{}{"deleted": true, "content": ""}).Error
+		}
+
+		for {
+			if err := tx.Delete(&comment).Error; err != nil {
+				return err
+			}
+			if comment.ParentID == nil {
+				return nil
+			}
+			var parent models.Comment
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, *comment.ParentID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			parent.ReplyCount = max(0, parent.ReplyCount-1)
+			if err := tx.Model(&parent).UpdateColumn("reply_count", parent.ReplyCount).Error; err != nil {
+				return err
+			}
+			if !parent.Deleted || parent.ReplyCount > 0 {
+				return nil
+			}
+			comment = parent
+		}
+	})
+}
+
+// parseMentions returns the usernames mentioned in text, lower-cased, in
+// order of first mention.
+func parseMentions(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(match[1])
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		if len(names) == maxMentions {
+			break
+		}
+	}
+	return names
+}
+
+// mentionedUsers returns the IDs of the users a comment mentions.
+func mentionedUsers(commentID uint) map[uint]bool {
+	var ids []uint
+	db.DB.Model(&models.CommentMention{}).Where("comment_id = ?", commentID).Pluck("user_id", &ids)
+	users := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		users[id] = true
+	}
+	return users
+}
+
+// mention records the users a comment mentions, resolved by
+// profile-service, and notifies those not in notified other than its
+// author. Held comments notify nobody. Failures are logged; the comment is
+// kept either way.
+func mention(comment *models.Comment, notified map[uint]bool) {
+	var profiles []utils.Profile
+	if names := parseMentions(comment.Content); len(names) > 0 {
+		var err error
+		if profiles, err = utils.ResolveUsernames(names); err != nil {
+			log.Printf("Failed to resolve mentions of comment %d: %v", comment.ID, err)
+			return
+		}
+	}
+
+	mentions := make([]models.CommentMention, 0, len(profiles))
+	for _, p := range profiles {
+		mentions = append(mentions, models.CommentMention{CommentID: comment.ID, UserID: p.UserID, Username: p.Username})
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&models.CommentMention{}).Error; err != nil {
+			return err
+		}
+		if len(mentions) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&mentions).Error
+	})
+	if err != nil {
+		log.Printf("Failed to save mentions of comment %d: %v", comment.ID, err)
+		return
+	}
+
+	if comment.ModerationStatus != models.StatusVisible {
+		return
+	}
+	excerpt := []rune(comment.Content)
+	if len(excerpt) > excerptLength {
+		excerpt = append(excerpt[:excerptLength], '…')
+	}
+	var notifications []utils.Notification
+	for _, m := range mentions {
+		if m.UserID == comment.UserID || notified[m.UserID] {
+			continue
+		}
+		notifications = append(notifications, utils.Notification{
+			UserID:        m.UserID,
+			Type:          "mention",
+			ActorID:       comment.UserID,
+			ActorUsername: comment.Username,
+			Excerpt:       string(excerpt),
+			URL:           fmt.Sprintf("/blogs/%d#comment-%d", comment.BlogID, comment.ID),
+		})
+	}
+	if err := utils.Notify(notifications); err != nil {
+		log.Printf("Failed to notify mentions of comment %d: %v", comment.ID, err)
+	}
+}
+
+func writeCommentError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, errInvalidComment) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("%s: %v", message, err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
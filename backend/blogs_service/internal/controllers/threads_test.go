@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/blogs_service/internal/models"
+	"diplomaPorject/backend/blogs_service/utils/db"
+	"diplomaPorject/backend/blogs_service/utils/testdb"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useTestDB points db.DB at a schema of its own. Threads rely on row locks,
+// so they are tested against PostgreSQL only.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	conn := testdb.Open(t)
+	err := conn.AutoMigrate(&models.Blog{}, &models.Comment{}, &models.CommentImage{}, &models.CommentMention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+}
+
+func seedBlog(t *testing.T, slug string) models.Blog {
+	t.Helper()
+	blog := models.Blog{Title: "Trip", Content: "Text", UserID: 1, Username: "author", Slug: slug}
+	if err := db.DB.Create(&blog).Error; err != nil {
+		t.Fatal(err)
+	}
+	return blog
+}
+
+// reply saves a comment on the blog, replying to parent unless it is nil.
+func reply(t *testing.T, blogID uint, parent *models.Comment) (*models.Comment, error) {
+	t.Helper()
+	comment := &models.Comment{BlogID: blogID, UserID: 2, Username: "reader", Content: "Nice",
+		ModerationStatus: models.StatusVisible}
+	if parent != nil {
+		comment.ParentID = &parent.ID
+	}
+	return comment, insertComment(comment, nil)
+}
+
+func mustReply(t *testing.T, blogID uint, parent *models.Comment) *models.Comment {
+	t.Helper()
+	comment, err := reply(t, blogID, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return comment
+}
+
+// load reads a comment; ok is false once it was deleted outright.
+func load(t *testing.T, id uint) (models.Comment, bool) {
+	t.Helper()
+	var comment models.Comment
+	result := db.DB.Limit(1).Find(&comment, id)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	return comment, result.RowsAffected == 1
+}
+
+func TestInsertCommentDepthLimit(t *testing.T) {
+	useTestDB(t)
+	blog := seedBlog(t, "trip")
+
+	parent := mustReply(t, blog.ID, nil)
+	if parent.Depth != 0 {
+		t.Fatalf("top-level comment has depth %d", parent.Depth)
+	}
+	for depth := 1; depth <= MaxCommentDepth; depth++ {
+		parent = mustReply(t, blog.ID, parent)
+		if parent.Depth != depth {
+			t.Fatalf("reply has depth %d, want %d", parent.Depth, depth)
+		}
+	}
+
+	_, err := reply(t, blog.ID, parent)
+	if !errors.Is(err, errInvalidComment) {
+		t.Fatalf("reply below the deepest level: err = %v, want an invalid comment", err)
+	}
+	if got, _ := load(t, parent.ID); got.ReplyCount != 0 {
+		t.Errorf("deepest comment counts %d replies after the refused one", got.ReplyCount)
+	}
+}
+
+func TestInsertCommentParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, parent *models.Comment, otherBlog uint) uint
+		wantErr bool
+	}{
+		{"visible parent", func(t *testing.T, parent *models.Comment, otherBlog uint) uint {
+			return parent.BlogID
+		}, false},
+		{"parent on another blog", func(t *testing.T, parent *models.Comment, otherBlog uint) uint {
+			return otherBlog
+		}, true},
+		{"held parent", func(t *testing.T, parent *models.Comment, otherBlog uint) uint {
+			db.DB.Model(parent).Update("moderation_status", models.StatusHeld)
+			return parent.BlogID
+		}, true},
+		{"placeholder parent", func(t *testing.T, parent *models.Comment, otherBlog uint) uint {
+			db.DB.Model(parent).Update("deleted", true)
+			return parent.BlogID
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDB(t)
+			blog := seedBlog(t, "trip")
+			other := seedBlog(t, "other-trip")
+			parent := mustReply(t, blog.ID, nil)
+
+			blogID := tt.prepare(t, parent, other.ID)
+			_, err := reply(t, blogID, parent)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errInvalidComment) {
+				t.Fatalf("err = %v, want an invalid comment", err)
+			}
+
+			want := 1
+			if tt.wantErr {
+				want = 0
+			}
+			if got, _ := load(t, parent.ID); got.ReplyCount != want {
+				t.Errorf("parent counts %d replies, want %d", got.ReplyCount, want)
+			}
+		})
+	}
+}
+
+// A comment with replies stays as an empty placeholder; one without is
+// deleted outright, and takes along the placeholders left without replies.
+func TestRemoveComment(t *testing.T) {
+	useTestDB(t)
+	blog := seedBlog(t, "trip")
+	top := mustReply(t, blog.ID, nil)
+	middle := mustReply(t, blog.ID, top)
+	leaf := mustReply(t, blog.ID, middle)
+	sibling := mustReply(t, blog.ID, top)
+
+	db.DB.Create(&models.CommentImage{CommentID: middle.ID, URL: "/media/1"})
+	db.DB.Create(&models.CommentMention{CommentID: middle.ID, UserID: 3, Username: "friend"})
+
+	if err := removeComment(middle.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := load(t, middle.ID)
+	if !ok || !got.Deleted || got.Content != "" || got.ReplyCount != 1 {
+		t.Fatalf("comment with a reply = %+v (found %v), want an empty placeholder", got, ok)
+	}
+	var images, mentions int64
+	db.DB.Model(&models.CommentImage{}).Where("comment_id = ?", middle.ID).Count(&images)
+	db.DB.Model(&models.CommentMention{}).Where("comment_id = ?", middle.ID).Count(&mentions)
+	if images != 0 || mentions != 0 {
+		t.Errorf("placeholder kept %d images and %d mentions", images, mentions)
+	}
+
+	// Its last reply goes, and the placeholder with it.
+	if err := removeComment(leaf.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := load(t, leaf.ID); ok {
+		t.Error("reply without replies was not deleted")
+	}
+	if _, ok := load(t, middle.ID); ok {
+		t.Error("placeholder left without replies was not deleted")
+	}
+	got, ok = load(t, top.ID)
+	if !ok || got.Deleted || got.ReplyCount != 1 {
+		t.Errorf("top comment = %+v (found %v), want it kept with 1 reply", got, ok)
+	}
+
+	// The top comment still has a reply, so it stays until that goes too.
+	if err := removeComment(top.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := load(t, top.ID); !ok || !got.Deleted {
+		t.Errorf("top comment = %+v (found %v), want a placeholder", got, ok)
+	}
+	if err := removeComment(sibling.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := load(t, top.ID); ok {
+		t.Error("top placeholder left without replies was not deleted")
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	many := make([]string, maxMentions+2)
+	for i := range many {
+		many[i] = fmt.Sprintf("@user%d", i)
+	}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"no mentions here", nil},
+		{"@alice look", []string{"alice"}},
+		{"thanks @Bob, and @bob again", []string{"bob"}},
+		{"ask @carol.smith.", []string{"carol.smith"}},
+		{"(@dave) and @erin!", []string{"dave", "erin"}},
+		{"mail me at me@example.com", nil},
+		{"@@frank", nil},
+		{strings.Join(many, " "), []string{"user0", "user1", "user2", "user3", "user4",
+			"user5", "user6", "user7", "user8", "user9"}},
+	}
+	for _, tt := range tests {
+		if got := parseMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMentions(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
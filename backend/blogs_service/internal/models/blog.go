@@ -1,9 +1,34 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Publishing statuses of blogs. Drafts and scheduled posts are seen by
+// their authors only; a scheduled post is published at its PublishAt.
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+)
+
+// Kinds of items a post can embed as a card.
+const (
+	EmbedAttraction = "attraction"
+	EmbedPlace      = "place"
+	EmbedPlan       = "plan"
+)
+
+// Moderation statuses of blogs and comments. Only visible ones are shown to
+// everyone; held ones wait for a moderator, hidden ones were taken down.
+const (
+	StatusVisible = "visible"
+	StatusHeld    = "held"
+	StatusHidden  = "hidden"
+)
+
 type Blog struct {
 	gorm.Model
 	Title    string      `json:"title"`
@@ -14,12 +39,54 @@ type Blog struct {
 	Category string      `json:"category"`
 	Comments []Comment   `gorm:"foreignKey:BlogID" json:"comments"`
 	Images   []BlogImage `json:"images" gorm:"foreignKey:BlogID"`
+
+	// ModerationStatus is set by moderation-service.
+	ModerationStatus string `json:"moderation_status" gorm:"size:16;not null;default:visible;index"`
+
+	Status      string     `json:"status" gorm:"size:16;not null;default:published;index"`
+	PublishAt   *time.Time `json:"publish_at,omitempty" gorm:"index"`
+	PublishedAt *time.Time `json:"published_at,omitempty" gorm:"index"`
+
+	// Content is Markdown. ContentHTML is rendered from it when the post
+	// is saved, sanitized and with embeds replaced by cards.
+	Slug           string      `json:"slug" gorm:"size:200;uniqueIndex"`
+	ContentHTML    string      `json:"content_html"`
+	CoverImage     string      `json:"cover_image"`
+	ReadingMinutes int         `json:"reading_minutes"`
+	Tags           []Tag       `json:"tags" gorm:"many2many:blog_tags;"`
+	Embeds         []BlogEmbed `json:"embeds" gorm:"foreignKey:BlogID"`
+}
+
+type Tag struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"size:32;not null;uniqueIndex"`
+}
+
+// BlogEmbed is an item embedded in a post, with its card as it was when
+// the post was last saved.
+type BlogEmbed struct {
+	ID       uint   `json:"-" gorm:"primaryKey"`
+	BlogID   uint   `json:"-" gorm:"not null;index"`
+	Position int    `json:"-" gorm:"not null;default:0"`
+	ItemType string `json:"item_type" gorm:"size:16;not null"`
+	ItemID   uint   `json:"item_id" gorm:"not null"`
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	ImageURL string `json:"image_url"`
+	URL      string `json:"url"`
 }
 
 type BlogImage struct {
-	ID     uint   `json:"id" gorm:"primaryKey"`
-	BlogID uint   `json:"blog_id"`
-	URL    string `json:"url"`
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	BlogID  uint   `json:"blog_id"`
+	URL     string `json:"url"`
+	MediaID *uint  `json:"media_id,omitempty" gorm:"index"`
+}
+
+// BeforeCreate records the media-service ID behind the image URL.
+func (i *BlogImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
 }
 
 type Comment struct {
@@ -29,12 +96,38 @@ type Comment struct {
 	UserID   uint           `json:"user_id"`
 	Username string         `json:"username"`
 	Images   []CommentImage `json:"images" gorm:"foreignKey:CommentID"`
+
+	ModerationStatus string `json:"moderation_status" gorm:"size:16;not null;default:visible;index"`
+
+	// ParentID is the comment this one replies to; top-level comments have
+	// none and a Depth of 0. ReplyCount counts the replies still in the
+	// thread. A deleted comment that has replies stays in the thread as a
+	// placeholder: Deleted, without content, images or mentions.
+	ParentID   *uint            `json:"parent_id" gorm:"index"`
+	Depth      int              `json:"depth" gorm:"not null;default:0"`
+	ReplyCount int              `json:"reply_count" gorm:"not null;default:0"`
+	Deleted    bool             `json:"deleted" gorm:"not null;default:false"`
+	Mentions   []CommentMention `json:"mentions" gorm:"foreignKey:CommentID"`
+	Replies    []Comment        `json:"replies,omitempty" gorm:"-"`
+}
+
+// CommentMention is a user mentioned by @username in a comment.
+type CommentMention struct {
+	CommentID uint   `json:"-" gorm:"primaryKey"`
+	UserID    uint   `json:"user_id" gorm:"primaryKey"`
+	Username  string `json:"username"`
 }
 
 type CommentImage struct {
 	ID        uint   `json:"id" gorm:"primaryKey"`
 	CommentID uint   `json:"comment_id"`
 	URL       string `json:"url"`
+	MediaID   *uint  `json:"media_id,omitempty" gorm:"index"`
+}
+
+func (i *CommentImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
 }
 
 type BlogLike struct {
@@ -0,0 +1,40 @@
+// Package publishing publishes scheduled blog posts once their time has
+// come.
+package publishing
+
+import (
+	"diplomaPorject/backend/blogs_service/internal/models"
+	"diplomaPorject/backend/blogs_service/utils/db"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Run publishes the scheduled posts that are due, dated when they were
+// due rather than when this run got to them.
+func Run() (int64, error) {
+	result := db.DB.Model(&models.Blog{}).
+		Where("status = ? AND publish_at <= ?", models.PostScheduled, time.Now()).
+		Updates(map[string]interface{}{
+			"status":       models.PostPublished,
+			"published_at": gorm.Expr("publish_at"),
+			"publish_at":   nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Start runs Run right away and then on every tick.
+func Start(interval time.Duration) {
+	go func() {
+		for {
+			published, err := Run()
+			if err != nil {
+				log.Printf("Scheduled publishing error: %v", err)
+			} else if published > 0 {
+				log.Printf("Published %d scheduled posts", published)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
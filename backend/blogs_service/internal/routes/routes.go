@@ -4,31 +4,36 @@ import (
 	"diplomaPorject/backend/blogs_service/internal/controllers"
 	"diplomaPorject/backend/blogs_service/internal/middleware"
 	"github.com/gorilla/mux"
-	"log"
-	"net/http"
 )
 
 func RegisterBlogRoutes(r *mux.Router) {
-	uploadDir := "/app/uploads"
-	log.Printf("Serving blog uploads from: %s", uploadDir)
-	r.PathPrefix("/uploads/").Handler(
-		http.StripPrefix("/uploads/", http.FileServer(http.Dir(uploadDir))),
-	).Methods("GET")
-
 	r.HandleFunc("/blogs", controllers.GetBlogs).Methods("GET")
 	r.HandleFunc("/blogs/{id:[0-9]+}", controllers.GetBlog).Methods("GET")
+	r.HandleFunc("/blogs/slug/{slug}", controllers.GetBlogBySlug).Methods("GET")
 	r.HandleFunc("/blogs/{id:[0-9]+}/comments", controllers.GetComments).Methods("GET")
-	r.HandleFunc("/internal/sync-username",
+
+	// Called by other services only; not routed by the gateway.
+	internal := r.PathPrefix("/internal").Subrouter()
+	internal.Use(middleware.RequireServiceToken)
+	internal.HandleFunc("/sync-username",
 		controllers.SyncUsername).Methods("POST")
 
+	// Decisions of moderation-service.
+	internal.HandleFunc("/blogs/{id:[0-9]+}/moderation", controllers.SetBlogModeration).Methods("PUT")
+	internal.HandleFunc("/blogs/{id:[0-9]+}", controllers.InternalDeleteBlog).Methods("DELETE")
+	internal.HandleFunc("/comments/{comment_id:[0-9]+}/moderation", controllers.SetCommentModeration).Methods("PUT")
+	internal.HandleFunc("/comments/{comment_id:[0-9]+}", controllers.InternalDeleteComment).Methods("DELETE")
+
 	blogs := r.PathPrefix("/blogs").Subrouter()
 	blogs.Use(middleware.BlogsAuthMiddleware)
 
 	blogs.HandleFunc("", controllers.CreateBlog).Methods("POST")
+	blogs.HandleFunc("/mine", controllers.GetMyBlogs).Methods("GET")
 	blogs.HandleFunc("/{id:[0-9]+}", controllers.UpdateBlog).Methods("PUT")
 	blogs.HandleFunc("/{id:[0-9]+}", controllers.DeleteBlog).Methods("DELETE")
 	blogs.HandleFunc("/{id:[0-9]+}/like", controllers.LikeBlog).Methods("POST")
 	blogs.HandleFunc("/{id:[0-9]+}/unlike", controllers.UnlikeBlog).Methods("POST")
+	blogs.HandleFunc("/{id:[0-9]+}/report", controllers.ReportBlog).Methods("POST")
 
 	blogs.HandleFunc("/{id:[0-9]+}/comments", controllers.AddComment).Methods("POST")
 
@@ -36,4 +41,5 @@ func RegisterBlogRoutes(r *mux.Router) {
 	comments.Use(middleware.BlogsAuthMiddleware)
 	comments.HandleFunc("/{comment_id:[0-9]+}", controllers.UpdateComment).Methods("PUT")
 	comments.HandleFunc("/{comment_id:[0-9]+}", controllers.DeleteComment).Methods("DELETE")
+	comments.HandleFunc("/{comment_id:[0-9]+}/report", controllers.ReportComment).Methods("POST")
 }
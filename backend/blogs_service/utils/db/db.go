@@ -19,10 +19,15 @@ func ConnectDB() {
 
 	DB = dbInstance
 
-	err = DB.AutoMigrate(&models.Blog{}, &models.Comment{}, &models.BlogLike{}, &models.BlogImage{}, &models.CommentImage{})
+	err = DB.AutoMigrate(&models.Blog{}, &models.Comment{}, &models.BlogLike{}, &models.BlogImage{}, &models.CommentImage{},
+		&models.Tag{}, &models.BlogEmbed{}, &models.CommentMention{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	if err := prepareLegacyPosts(); err != nil {
+		log.Fatalf("Failed to prepare existing posts: %v", err)
+	}
+
 	fmt.Println("Connected to PostgreSQL database!")
 }
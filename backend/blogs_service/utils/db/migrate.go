@@ -0,0 +1,52 @@
+package db
+
+import (
+	"diplomaPorject/backend/blogs_service/internal/content"
+	"diplomaPorject/backend/blogs_service/internal/models"
+	"fmt"
+	"log"
+)
+
+// prepareLegacyPosts fills in what posts written before Markdown and
+// publishing statuses lack: a slug, the rendering of their text, the
+// reading time and the publishing date. Their slugs end in their ID, so
+// they cannot collide.
+func prepareLegacyPosts() error {
+	var blogs []models.Blog
+	if err := DB.Unscoped().Where("slug IS NULL OR slug = ''").Find(&blogs).Error; err != nil {
+		return err
+	}
+
+	for _, blog := range blogs {
+		slug := fmt.Sprintf("post-%d", blog.ID)
+		if base := content.Slug(blog.Title); base != "" {
+			slug = fmt.Sprintf("%s-%d", base, blog.ID)
+		}
+		rendered, embeds, err := content.Render(DB, blog.Content)
+		if err != nil {
+			log.Printf("Blog %d keeps no rendering: %v", blog.ID, err)
+		}
+		for i := range embeds {
+			embeds[i].BlogID = blog.ID
+		}
+		if len(embeds) > 0 {
+			if err := DB.Create(&embeds).Error; err != nil {
+				return err
+			}
+		}
+
+		err = DB.Unscoped().Model(&models.Blog{}).Where("id = ?", blog.ID).UpdateColumns(map[string]interface{}{
+			"slug":            slug,
+			"content_html":    rendered,
+			"reading_minutes": content.ReadingMinutes(blog.Content),
+			"published_at":    blog.CreatedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(blogs) > 0 {
+		log.Printf("Prepared %d posts written before Markdown", len(blogs))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+func getProfileServiceURL() string {
+	if u := os.Getenv("PROFILE_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://profile-service:8084"
+}
+
+var profileClient = &http.Client{Timeout: 5 * time.Second}
+
+// Profile is a user as profile-service knows them.
+type Profile struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Notification is sent to profile-service, which keeps it for UserID.
+type Notification struct {
+	UserID        uint   `json:"user_id"`
+	Type          string `json:"type"`
+	ActorID       uint   `json:"actor_id"`
+	ActorUsername string `json:"actor_username"`
+	Excerpt       string `json:"excerpt"`
+	URL           string `json:"url"`
+}
+
+func postProfiles(path string, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, getProfileServiceURL()+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+	resp, err := profileClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("profile-service %s: %s", path, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// ResolveUsernames looks up the users with the usernames; unknown ones are
+// left out.
+func ResolveUsernames(usernames []string) ([]Profile, error) {
+	var profiles []Profile
+	err := postProfiles("/internal/profiles/resolve", map[string][]string{"usernames": usernames}, &profiles)
+	return profiles, err
+}
+
+// Notify has profile-service store notifications for their users.
+func Notify(notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return postProfiles("/internal/notifications", map[string][]Notification{"notifications": notifications}, nil)
+}
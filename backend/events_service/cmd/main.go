@@ -1,14 +1,19 @@
 package main
 
 import (
+	"diplomaPorject/backend/events_service/internal/registrations"
 	routes1 "diplomaPorject/backend/events_service/internal/routes"
 	"diplomaPorject/backend/events_service/utils/db"
 	"log"
 	"net/http"
+	"time"
 )
 
 func main() {
 	db.ConnectDB()
+
+	registrations.StartExpiry(time.Minute)
+
 	router := routes1.SetupRoutes()
 	log.Println("Events service running on port 8083...")
 	log.Fatal(http.ListenAndServe(":8083", router))
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/ical"
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/occurrences"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// maxFeedEvents bounds the iCal feed, which is not paginated.
+const maxFeedEvents = 1000
+
+// EventsCalendar serves the events matching the ListPublishedEvents
+// filters as an iCal feed calendar apps can subscribe to.
+func EventsCalendar(w http.ResponseWriter, r *http.Request) {
+	filter, errMsg := parseEventFilter(r)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	list, err := filter.find()
+	if err != nil {
+		log.Printf("Failed to build calendar: %v", err)
+		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
+		return
+	}
+	if len(list) > maxFeedEvents {
+		list = list[:maxFeedEvents]
+	}
+
+	entries := make([]ical.Event, 0, len(list))
+	for i := range list {
+		entries = append(entries, calendarEntry(&list[i]))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="events.ics"`)
+	w.Write(ical.Calendar("Steppe Way events", entries))
+}
+
+func calendarEntry(occurrence *occurrences.Occurrence) ical.Event {
+	// Each occurrence of a series is its own entry, keyed by its original
+	// start so moving it updates the entry in place.
+	uid := fmt.Sprintf("event-%d@steppeway", occurrence.ID)
+	if occurrence.OccurrenceStart != nil {
+		uid = fmt.Sprintf("event-%d-%s@steppeway", occurrence.ID,
+			occurrence.OccurrenceStart.UTC().Format("20060102T150405Z"))
+	}
+
+	location := occurrence.Address
+	if location == "" {
+		location = occurrence.FormattedAddress
+	}
+
+	entry := ical.Event{
+		UID:         uid,
+		Start:       occurrence.StartDate,
+		End:         occurrence.EndDate,
+		Summary:     occurrence.Title,
+		Description: occurrence.Description,
+		Location:    location,
+		URL:         occurrence.Link,
+		Categories:  occurrence.Category,
+		Cancelled:   occurrence.Cancelled,
+		Modified:    occurrence.UpdatedAt,
+	}
+	if point, err := models.ParseGeoPoint(occurrence.Location); err == nil {
+		entry.Geo = strconv.FormatFloat(point.Lat, 'f', 6, 64) + ";" + strconv.FormatFloat(point.Lng, 'f', 6, 64)
+	}
+	return entry
+}
@@ -2,17 +2,16 @@ package controllers
 
 import (
 	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/registrations"
 	"diplomaPorject/backend/events_service/utils/db"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
-	"io"
 	"log"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,37 +23,13 @@ type EventRequest struct {
 	Location    string `json:"location"`
 	Address     string `json:"address"`
 	Link        string `json:"link"`
-	Capacity    int    `json:"capacity"`
-	Category    string `json:"category"`
-	ImageURL    string `json:"image_url"`
-}
-
-func generateRandomFilename(originalFilename string) string {
-	timestamp := time.Now().UnixNano()
-	return fmt.Sprintf("%d_%s", timestamp, filepath.Base(originalFilename))
-}
-
-func uploadImage(file io.Reader, filename string) (string, error) {
-	uploadDir := "/app/uploads/events"
-
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %v", err)
-	}
-
-	randomFilename := generateRandomFilename(filename)
-	filePath := filepath.Join(uploadDir, randomFilename)
-
-	dst, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %v", err)
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to save file: %v", err)
-	}
-
-	return fmt.Sprintf("/uploads/events/%s", randomFilename), nil
+	Capacity    int     `json:"capacity"`
+	Price       float64 `json:"price"`
+	Currency    string  `json:"currency"`
+	Category    string  `json:"category"`
+	RRule       string   `json:"rrule"`
+	ExDates     []string `json:"exdates"`
+	Timezone    string   `json:"timezone"`
 }
 
 func CreateEvent(w http.ResponseWriter, r *http.Request) {
@@ -78,18 +53,15 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var imageURL string
-	file, header, err := r.FormFile("image")
-	if err == nil {
-		defer file.Close()
-		imageURL, err = uploadImage(file, header.Filename)
-		if err != nil {
-			log.Printf("Failed to upload image: %v", err)
-			http.Error(w, "Failed to upload image", http.StatusInternalServerError)
-			return
-		}
-	} else {
-		log.Println("No image uploaded. Proceeding without image.")
+	imageURLs, err := uploadGalleryFiles(r)
+	if err != nil {
+		log.Printf("Failed to upload image: %v", err)
+		http.Error(w, "Failed to upload image", uploadErrorStatus(err))
+		return
+	}
+	if len(imageURLs) > maxGalleryImages {
+		http.Error(w, errGalleryFull.Error(), http.StatusBadRequest)
+		return
 	}
 
 	title := r.FormValue("title")
@@ -123,6 +95,19 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var price float64
+	if priceStr := r.FormValue("price"); priceStr != "" {
+		price, err = strconv.ParseFloat(priceStr, 64)
+		if err != nil || price < 0 {
+			http.Error(w, "Invalid price value", http.StatusBadRequest)
+			return
+		}
+	}
+	currency := strings.ToUpper(strings.TrimSpace(r.FormValue("currency")))
+	if currency == "" {
+		currency = "KZT"
+	}
+
 	event := models.Event{
 		Title:       title,
 		Description: description,
@@ -132,16 +117,41 @@ func CreateEvent(w http.ResponseWriter, r *http.Request) {
 		Address:     address,
 		Link:        link,
 		Capacity:    capacity,
+		Price:       price,
+		Currency:    currency,
 		Category:    category,
-		ImageURL:    imageURL,
 		AdminID:     adminID,
 	}
+	if check := checkAddress(event.Address, "", event.Location); check != nil {
+		event.Address = check.Address
+		event.Location = check.Location
+		event.FormattedAddress = check.FormattedAddress
+		event.LocationMismatch = check.Mismatch
+	}
+
+	event.CityID, _ = resolveCity("", event.Location)
+
+	// Series take an RRULE and comma-separated EXDATEs.
+	var exdates []string
+	if value := r.FormValue("exdates"); value != "" {
+		exdates = strings.Split(value, ",")
+	}
+	if err := applyRecurrence(&event, r.FormValue("rrule"), exdates, r.FormValue("timezone")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if err := db.DB.Create(&event).Error; err != nil {
 		log.Printf("Failed to create event: %v", err)
 		http.Error(w, "Failed to create event", http.StatusInternalServerError)
 		return
 	}
+	if len(imageURLs) > 0 {
+		if _, err := addImages(event.ID, imageURLs, r.MultipartForm.Value["captions"]); err != nil {
+			log.Printf("Failed to save images of event %d: %v", event.ID, err)
+		}
+		db.DB.Preload("Images", orderedImages).First(&event, event.ID)
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(event)
@@ -152,7 +162,7 @@ func GetEvent(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	var event models.Event
-	if err := db.DB.First(&event, id).Error; err != nil {
+	if err := db.DB.Preload("Images", orderedImages).First(&event, id).Error; err != nil {
 		http.Error(w, "Not found event", http.StatusNotFound)
 		return
 	}
@@ -187,17 +197,46 @@ func UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	event.Description = req.Description
 	event.StartDate = startDate
 	event.EndDate = endDate
+	if req.Address != event.Address || req.Location != event.Location {
+		event.FormattedAddress = ""
+		event.LocationMismatch = false
+		if check := checkAddress(req.Address, "", req.Location); check != nil {
+			req.Address = check.Address
+			req.Location = check.Location
+			event.FormattedAddress = check.FormattedAddress
+			event.LocationMismatch = check.Mismatch
+		}
+	}
+	if req.Location != event.Location {
+		event.CityID, _ = resolveCity("", req.Location)
+	}
 	event.Location = req.Location
+	capacityRaised := event.Capacity > 0 && (req.Capacity <= 0 || req.Capacity > event.Capacity)
 	event.Capacity = req.Capacity
+	event.Price = req.Price
+	if req.Currency != "" {
+		event.Currency = strings.ToUpper(req.Currency)
+	}
 	event.Category = req.Category
-	event.ImageURL = req.ImageURL
 	event.Address = req.Address
 	event.Link = req.Link
+	if err := applyRecurrence(&event, req.RRule, req.ExDates, req.Timezone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	
-	if err := db.DB.Save(&event).Error; err != nil {
+	// current_count belongs to registrations and may have moved since the
+	// event was read.
+	if err := db.DB.Omit("current_count").Save(&event).Error; err != nil {
 		http.Error(w, "Cant update event", http.StatusBadRequest)
 		return
 	}
+	if capacityRaised {
+		if _, err := registrations.PromoteWaitlist(event.ID); err != nil {
+			log.Printf("Failed to promote waitlist for event %d: %v", event.ID, err)
+		}
+		db.DB.First(&event, event.ID)
+	}
 	json.NewEncoder(w).Encode(event)
 }
 
@@ -213,7 +252,7 @@ func DeleteEvent(w http.ResponseWriter, r *http.Request) {
 
 func ListEvents(w http.ResponseWriter, r *http.Request) {
 	var events []models.Event
-	query := db.DB
+	query := db.DB.Preload("Images", orderedImages)
 	if category := r.URL.Query().Get("category"); category != "" {
 		query = query.Where("category = ?", category)
 	}
@@ -249,50 +288,55 @@ func UnpublishEvent(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// ListPublishedEvents lists published events one occurrence at a time, so
+// a series shows up once per date. See eventFilter for the parameters.
 func ListPublishedEvents(w http.ResponseWriter, r *http.Request) {
-	var events []models.Event
-	query := db.DB.Where("is_published = ?", true)
-
-	if category := r.URL.Query().Get("category"); category != "" {
-		query = query.Where("category = ?", category)
+	filter, errMsg := parseEventFilter(r)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
 	}
 
 	page := 1
-	pageSize := 10
 	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
 		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
 			page = p
 		}
 	}
+	pageSize := defaultPageSize
+	if sizeParam := r.URL.Query().Get("page_size"); sizeParam != "" {
+		size, err := strconv.Atoi(sizeParam)
+		if err != nil || size < 1 || size > maxPageSize {
+			http.Error(w, fmt.Sprintf("Invalid page_size - must be between 1 and %d", maxPageSize), http.StatusBadRequest)
+			return
+		}
+		pageSize = size
+	}
 
-	offset := (page - 1) * pageSize
-
-	var totalCount int64
-	query.Model(&models.Event{}).Count(&totalCount)
-
-	if err := query.
-		Order("start_date ASC"). // Sort by start date
-		Offset(offset).
-		Limit(pageSize).
-		Find(&events).Error; err != nil {
+	list, err := filter.find()
+	if err != nil {
+		log.Printf("Failed to list events: %v", err)
 		http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
 		return
 	}
 
-	response := struct {
-		Events     []models.Event `json:"events"`
-		Total      int64          `json:"total"`
-		Page       int            `json:"page"`
-		PageSize   int            `json:"page_size"`
-		TotalPages int            `json:"total_pages"`
-	}{
-		Events:     events,
-		Total:      totalCount,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalPages: int(math.Ceil(float64(totalCount) / float64(pageSize))),
+	total := len(list)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"events":      list[start:end],
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+		"total_pages": int(math.Ceil(float64(total) / float64(pageSize))),
+		"from":        filter.From,
+		"to":          filter.To,
+	})
 }
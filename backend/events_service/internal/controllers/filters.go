@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/occurrences"
+	"diplomaPorject/backend/events_service/utils/db"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+	maxSearchTerms  = 10
+)
+
+// eventSorts maps the sort parameter to an ordering of occurrences.
+var eventSorts = map[string]func(a, b *occurrences.Occurrence) bool{
+	"date":    func(a, b *occurrences.Occurrence) bool { return a.StartDate.Before(b.StartDate) },
+	"-date":   func(a, b *occurrences.Occurrence) bool { return a.StartDate.After(b.StartDate) },
+	"title":   func(a, b *occurrences.Occurrence) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) },
+	"price":   func(a, b *occurrences.Occurrence) bool { return a.Price < b.Price },
+	"-price":  func(a, b *occurrences.Occurrence) bool { return a.Price > b.Price },
+	"newest":  func(a, b *occurrences.Occurrence) bool { return a.CreatedAt.After(b.CreatedAt) },
+	"popular": func(a, b *occurrences.Occurrence) bool { return a.CurrentCount > b.CurrentCount },
+	"distance": func(a, b *occurrences.Occurrence) bool {
+		return a.Distance != nil && (b.Distance == nil || *a.Distance < *b.Distance)
+	},
+}
+
+// eventFilter holds the public event list parameters:
+//
+//	from, to   date range (RFC3339 or YYYY-MM-DD); occurrences overlapping it are listed
+//	status     upcoming, ongoing, past or all; by default events that have not ended
+//	q          words that must each appear in the title or description
+//	category, city_id
+//	lat, lng, distance   radius around a point in km, as for accommodations
+//	sort       date, -date, title, price, -price, newest, popular or distance
+type eventFilter struct {
+	From     time.Time
+	To       time.Time
+	Status   string
+	Terms    []string
+	Category string
+	CityID   *uint
+	Nearby   *nearbyFilter
+	Sort     string
+	now      time.Time
+}
+
+func parseEventFilter(r *http.Request) (*eventFilter, string) {
+	query := r.URL.Query()
+	filter := &eventFilter{
+		Status:   query.Get("status"),
+		Category: query.Get("category"),
+		Sort:     query.Get("sort"),
+		now:      time.Now(),
+	}
+
+	span := maxRangeDays * 24 * time.Hour
+	defaultFrom, defaultTo := filter.now, filter.now.Add(span)
+	switch filter.Status {
+	case "", occurrences.StatusUpcoming, occurrences.StatusOngoing, "all":
+	case occurrences.StatusPast:
+		defaultFrom, defaultTo = filter.now.Add(-span), filter.now
+	default:
+		return nil, "Invalid status - must be upcoming, ongoing, past or all"
+	}
+
+	var err error
+	filter.From, filter.To, err = parseRange(r, defaultFrom, defaultTo)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	if cityIDParam := query.Get("city_id"); cityIDParam != "" {
+		cityID, err := strconv.ParseUint(cityIDParam, 10, 32)
+		if err != nil {
+			return nil, "Invalid city_id"
+		}
+		id := uint(cityID)
+		filter.CityID = &id
+	}
+
+	filter.Terms = strings.Fields(query.Get("q"))
+	if len(filter.Terms) > maxSearchTerms {
+		filter.Terms = filter.Terms[:maxSearchTerms]
+	}
+
+	var errMsg string
+	if filter.Nearby, errMsg = parseNearby(r); errMsg != "" {
+		return nil, errMsg
+	}
+
+	if filter.Sort == "" {
+		filter.Sort = "date"
+		if filter.Status == occurrences.StatusPast {
+			filter.Sort = "-date"
+		}
+	}
+	if _, ok := eventSorts[filter.Sort]; !ok {
+		return nil, "Invalid sort - must be date, -date, title, price, -price, newest, popular or distance"
+	}
+	if filter.Sort == "distance" && filter.Nearby == nil {
+		return nil, "sort=distance needs lat and lng"
+	}
+
+	return filter, ""
+}
+
+// matchesStatus applies the status parameter; without one, events that
+// have ended are left out.
+func (f *eventFilter) matchesStatus(status string) bool {
+	switch f.Status {
+	case "":
+		return status != occurrences.StatusPast
+	case "all":
+		return true
+	default:
+		return status == f.Status
+	}
+}
+
+// find returns the matching occurrences of published events, sorted.
+func (f *eventFilter) find() ([]occurrences.Occurrence, error) {
+	query := db.DB.Model(&models.Event{}).Where("is_published = ?", true)
+	if f.Category != "" {
+		query = query.Where("category = ?", f.Category)
+	}
+	if f.CityID != nil {
+		query = query.Where("city_id = ?", *f.CityID)
+	}
+	for _, term := range f.Terms {
+		pattern := "%" + term + "%"
+		query = query.Where("(title ILIKE ? OR description ILIKE ?)", pattern, pattern)
+	}
+	if f.Nearby != nil {
+		query = f.Nearby.apply(query, "events")
+	}
+
+	var events []models.Event
+	if err := inRange(query, f.From, f.To).Preload("Images", orderedImages).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	expanded, err := expandEvents(events, f.From, f.To)
+	if err != nil {
+		return nil, err
+	}
+
+	list := expanded[:0]
+	for _, occurrence := range expanded {
+		occurrence.Status = occurrence.StatusAt(f.now)
+		if !f.matchesStatus(occurrence.Status) {
+			continue
+		}
+		if f.Nearby != nil {
+			// Overrides may move a single occurrence elsewhere.
+			distance, ok := f.Nearby.distance(occurrence.Location)
+			if !ok || distance > f.Nearby.RadiusKm {
+				continue
+			}
+			distance = math.Round(distance*10) / 10
+			occurrence.Distance = &distance
+		}
+		list = append(list, occurrence)
+	}
+
+	less := eventSorts[f.Sort]
+	sort.SliceStable(list, func(i, j int) bool { return less(&list[i], &list[j]) })
+	return list, nil
+}
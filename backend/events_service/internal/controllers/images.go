@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxGalleryImages bounds the number of images per event.
+const maxGalleryImages = 30
+
+var (
+	errGalleryFull   = errors.New("Gallery is full")
+	errImageNotFound = errors.New("Image not found")
+	errInvalidOrder  = errors.New("image_ids must list every image of the event once")
+)
+
+type ImageUpdateRequest struct {
+	Caption *string `json:"caption"`
+	IsCover bool    `json:"is_cover"`
+}
+
+type ImageOrderRequest struct {
+	ImageIDs []uint `json:"image_ids"`
+}
+
+// orderedImages preloads a gallery in display order.
+func orderedImages(query *gorm.DB) *gorm.DB {
+	return query.Order("position ASC, id ASC")
+}
+
+func writeGalleryError(w http.ResponseWriter, err error) {
+	switch err {
+	case errImageNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errGalleryFull, errInvalidOrder:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Gallery error: %v", err)
+		http.Error(w, "Failed to update gallery", http.StatusInternalServerError)
+	}
+}
+
+// lockGallery locks the event row so gallery changes of one event run one
+// at a time, and returns its images in display order.
+func lockGallery(tx *gorm.DB, eventID uint) ([]models.EventImage, error) {
+	var event models.Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&event, eventID).Error; err != nil {
+		return nil, err
+	}
+	var images []models.EventImage
+	err := orderedImages(tx.Where("event_id = ?", eventID)).Find(&images).Error
+	return images, err
+}
+
+// syncCover makes sure the gallery has exactly one cover, falling back to
+// the first image, and mirrors it into Event.ImageURL.
+func syncCover(tx *gorm.DB, eventID uint) error {
+	var images []models.EventImage
+	if err := orderedImages(tx.Where("event_id = ?", eventID)).Find(&images).Error; err != nil {
+		return err
+	}
+
+	cover := -1
+	for i, image := range images {
+		if image.IsCover && cover < 0 {
+			cover = i
+		}
+	}
+	if cover < 0 && len(images) > 0 {
+		cover = 0
+	}
+
+	url := ""
+	for i, image := range images {
+		isCover := i == cover
+		if isCover {
+			url = image.URL
+		}
+		if image.IsCover != isCover {
+			if err := tx.Model(&models.EventImage{}).Where("id = ?", image.ID).Update("is_cover", isCover).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Model(&models.Event{}).Where("id = ?", eventID).UpdateColumn("image_url", url).Error
+}
+
+// addImages appends uploaded images to the end of the event's gallery.
+func addImages(eventID uint, urls, captions []string) ([]models.EventImage, error) {
+	added := []models.EventImage{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := lockGallery(tx, eventID)
+		if err != nil {
+			return err
+		}
+		if len(existing)+len(urls) > maxGalleryImages {
+			return errGalleryFull
+		}
+
+		position := 0
+		if len(existing) > 0 {
+			position = existing[len(existing)-1].Position + 1
+		}
+		for i, url := range urls {
+			image := models.EventImage{EventID: eventID, URL: url, Position: position + i}
+			if i < len(captions) {
+				image.Caption = captions[i]
+			}
+			if err := tx.Create(&image).Error; err != nil {
+				return err
+			}
+			added = append(added, image)
+		}
+		return syncCover(tx, eventID)
+	})
+	return added, err
+}
+
+// uploadGalleryFiles saves the "images" files of a multipart request, plus
+// the legacy single "image" field.
+func uploadGalleryFiles(r *http.Request) ([]string, error) {
+	var urls []string
+	if r.MultipartForm == nil {
+		return urls, nil
+	}
+	files := append(r.MultipartForm.File["image"], r.MultipartForm.File["images"]...)
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		url, err := storeMedia(file, header.Filename)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+func ListEventImages(w http.ResponseWriter, r *http.Request) {
+	var images []models.EventImage
+	err := orderedImages(db.DB.Where("event_id = ?", mux.Vars(r)["id"])).Find(&images).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch images", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"images": images})
+}
+
+// UploadEventImages adds images to the gallery; "captions" form values
+// label them in upload order.
+func UploadEventImages(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	urls, err := uploadGalleryFiles(r)
+	if err != nil {
+		log.Printf("Failed to upload image: %v", err)
+		http.Error(w, "Failed to upload image", uploadErrorStatus(err))
+		return
+	}
+	if len(urls) == 0 {
+		http.Error(w, "No image files provided", http.StatusBadRequest)
+		return
+	}
+
+	added, err := addImages(event.ID, urls, r.MultipartForm.Value["captions"])
+	if err != nil {
+		writeGalleryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"images": added})
+}
+
+// UpdateEventImage changes an image's caption or makes it the cover.
+func UpdateEventImage(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req ImageUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var image models.EventImage
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockGallery(tx, event.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND event_id = ?", mux.Vars(r)["image_id"], event.ID).First(&image).Error; err != nil {
+			return errImageNotFound
+		}
+		if req.Caption != nil {
+			image.Caption = *req.Caption
+		}
+		if req.IsCover {
+			if err := tx.Model(&models.EventImage{}).Where("event_id = ?", event.ID).Update("is_cover", false).Error; err != nil {
+				return err
+			}
+			image.IsCover = true
+		}
+		if err := tx.Save(&image).Error; err != nil {
+			return err
+		}
+		return syncCover(tx, event.ID)
+	})
+	if err != nil {
+		writeGalleryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, image)
+}
+
+// ReorderEventImages sets the gallery order from a full list of image IDs.
+func ReorderEventImages(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req ImageOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var images []models.EventImage
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := lockGallery(tx, event.ID)
+		if err != nil {
+			return err
+		}
+		if len(req.ImageIDs) != len(existing) {
+			return errInvalidOrder
+		}
+		known := map[uint]bool{}
+		for _, image := range existing {
+			known[image.ID] = true
+		}
+		for position, id := range req.ImageIDs {
+			if !known[id] {
+				return errInvalidOrder
+			}
+			delete(known, id)
+			if err := tx.Model(&models.EventImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		if err := syncCover(tx, event.ID); err != nil {
+			return err
+		}
+		return orderedImages(tx.Where("event_id = ?", event.ID)).Find(&images).Error
+	})
+	if err != nil {
+		writeGalleryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"images": images})
+}
+
+// DeleteEventImage removes one image; deleting the cover promotes the next
+// image in order.
+func DeleteEventImage(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockGallery(tx, event.ID); err != nil {
+			return err
+		}
+		result := tx.Where("id = ? AND event_id = ?", mux.Vars(r)["image_id"], event.ID).Delete(&models.EventImage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errImageNotFound
+		}
+		return syncCover(tx, event.ID)
+	})
+	if err != nil {
+		writeGalleryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+func getLocationServiceURL() string {
+	if u := os.Getenv("LOCATION_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://location-service:8092"
+}
+
+// resolveCity asks location-service for the canonical city matching a
+// free-text name or a "lat,lng" location. It returns nil when the city is
+// unknown or the service is unreachable; location-service backfills such
+// rows later.
+func resolveCity(name, location string) (*uint, string) {
+	params := url.Values{}
+	if name != "" {
+		params.Set("name", name)
+	}
+	if location != "" {
+		params.Set("location", location)
+	}
+	if len(params) == 0 {
+		return nil, ""
+	}
+
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(getLocationServiceURL() + "/cities/resolve?" + params.Encode())
+	if err != nil {
+		log.Printf("Error resolving city %q: %v", name, err)
+		return nil, ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ""
+	}
+
+	var city struct {
+		ID   uint   `json:"ID"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&city); err != nil || city.ID == 0 {
+		return nil, ""
+	}
+
+	return &city.ID, city.Name
+}
+
+type addressCheck struct {
+	Address          string `json:"address"`
+	Location         string `json:"location"`
+	FormattedAddress string `json:"formatted_address"`
+	Mismatch         bool   `json:"mismatch"`
+}
+
+// checkAddress asks location-service to fill in whichever of address and
+// location is missing and to flag the pair when they point to different
+// places. It returns nil when the service is unreachable, in which case the
+// record is saved as entered.
+func checkAddress(address, city, location string) *addressCheck {
+	params := url.Values{}
+	if address != "" {
+		params.Set("address", address)
+	}
+	if location != "" {
+		params.Set("location", location)
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	if city != "" {
+		params.Set("city", city)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(getLocationServiceURL() + "/geocode/normalize?" + params.Encode())
+	if err != nil {
+		log.Printf("Error checking address %q: %v", address, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var check addressCheck
+	if err := json.NewDecoder(resp.Body).Decode(&check); err != nil {
+		return nil
+	}
+	return &check
+}
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/occurrences"
+	"diplomaPorject/backend/events_service/internal/registrations"
+	"diplomaPorject/backend/events_service/utils/db"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// maxRangeDays limits how far a single request expands series.
+const maxRangeDays = 366
+
+type OccurrenceOverrideRequest struct {
+	OccurrenceStart time.Time  `json:"occurrence_start"`
+	Cancelled       bool       `json:"cancelled"`
+	StartDate       *time.Time `json:"start_date"`
+	EndDate         *time.Time `json:"end_date"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Location        string     `json:"location"`
+	Address         string     `json:"address"`
+	Capacity        *int       `json:"capacity"`
+}
+
+// applyRecurrence sets the series fields of an event and validates them.
+// EXDATEs are RFC3339 instants matching occurrence starts.
+func applyRecurrence(event *models.Event, rrule string, exdates []string, timezone string) error {
+	event.RRule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rrule)), "RRULE:")
+	event.Timezone = strings.TrimSpace(timezone)
+	event.ExDates = nil
+	for _, value := range exdates {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		exdate, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("Invalid exdate format (expected RFC3339)")
+		}
+		event.ExDates = append(event.ExDates, exdate.UTC())
+	}
+
+	return occurrences.Validate(event)
+}
+
+// parseRangeTime reads a from/to parameter, either RFC3339 or a date in
+// the default timezone. A date used as the end of a range includes the
+// whole day.
+func parseRangeTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, occurrences.DefaultLocation())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseRange reads the from/to query parameters. Missing ends fall back
+// to defaultFrom and defaultTo; when only one end is given the default
+// span is kept.
+func parseRange(r *http.Request, defaultFrom, defaultTo time.Time) (time.Time, time.Time, error) {
+	from, to := defaultFrom, defaultTo
+	fromValue, toValue := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromValue != "" {
+		t, err := parseRangeTime(fromValue, false)
+		if err != nil {
+			return from, to, errors.New("Invalid from (expected RFC3339 or YYYY-MM-DD)")
+		}
+		from = t
+	}
+	if toValue != "" {
+		t, err := parseRangeTime(toValue, true)
+		if err != nil {
+			return from, to, errors.New("Invalid to (expected RFC3339 or YYYY-MM-DD)")
+		}
+		to = t
+	}
+	span := defaultTo.Sub(defaultFrom)
+	if fromValue != "" && toValue == "" {
+		to = from.Add(span)
+	} else if toValue != "" && fromValue == "" {
+		from = to.Add(-span)
+	}
+
+	if !to.After(from) {
+		return from, to, errors.New("to must be after from")
+	}
+	if to.Sub(from) > maxRangeDays*24*time.Hour {
+		return from, to, errors.New("Date range is limited to 366 days")
+	}
+	return from, to, nil
+}
+
+// inRange limits an event query to events with occurrences that may
+// overlap [from, to): plain events by their dates, series by their first
+// start and RecurrenceEnd.
+func inRange(query *gorm.DB, from, to time.Time) *gorm.DB {
+	return query.Where(`start_date < ? AND (
+			(COALESCE(rrule, '') = '' AND end_date > ?) OR
+			(COALESCE(rrule, '') <> '' AND (recurrence_end IS NULL OR recurrence_end > ?)))`,
+		to, from, from)
+}
+
+// expandEvents expands the events into their occurrences within [from,
+// to), sorted by start, with per-occurrence counts.
+func expandEvents(events []models.Event, from, to time.Time) ([]occurrences.Occurrence, error) {
+	var seriesIDs []uint
+	for _, event := range events {
+		if event.RRule != "" {
+			seriesIDs = append(seriesIDs, event.ID)
+		}
+	}
+	overrides, err := occurrences.LoadOverrides(db.DB, seriesIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []occurrences.Occurrence{}
+	for i := range events {
+		expanded, err := occurrences.Expand(&events[i], overrides[events[i].ID], from, to)
+		if err != nil {
+			log.Printf("Skipping event %d with an invalid rule: %v", events[i].ID, err)
+			continue
+		}
+		list = append(list, expanded...)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].StartDate.Before(list[j].StartDate)
+	})
+
+	if err := occurrences.FillCounts(db.DB, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListEventOccurrences lists the occurrences of one published event within
+// from/to, including cancelled ones.
+func ListEventOccurrences(w http.ResponseWriter, r *http.Request) {
+	var event models.Event
+	if err := db.DB.Where("is_published = ?", true).First(&event, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return
+	}
+	writeOccurrences(w, r, &event)
+}
+
+// ListAdminEventOccurrences is ListEventOccurrences for the event's owner,
+// published or not.
+func ListAdminEventOccurrences(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+	writeOccurrences(w, r, event)
+}
+
+func writeOccurrences(w http.ResponseWriter, r *http.Request, event *models.Event) {
+	now := time.Now()
+	from, to, err := parseRange(r, now, now.AddDate(0, 0, 30))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := expandEvents([]models.Event{*event}, from, to)
+	if err != nil {
+		http.Error(w, "Failed to fetch occurrences", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"event_id":    event.ID,
+		"from":        from,
+		"to":          to,
+		"occurrences": list,
+	})
+}
+
+// SaveOccurrenceOverride creates or replaces the override of one occurrence
+// of the admin's series, e.g. to move, rename or cancel it.
+func SaveOccurrenceOverride(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req OccurrenceOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OccurrenceStart.IsZero() {
+		http.Error(w, "occurrence_start is required (RFC3339)", http.StatusBadRequest)
+		return
+	}
+	if req.StartDate != nil && req.EndDate != nil && req.EndDate.Before(*req.StartDate) {
+		http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+		return
+	}
+	if _, err := occurrences.Find(db.DB, event, req.OccurrenceStart); err != nil {
+		http.Error(w, registrations.ErrNoOccurrence.Error(), http.StatusBadRequest)
+		return
+	}
+
+	override := models.EventOccurrenceOverride{
+		EventID:         event.ID,
+		OccurrenceStart: req.OccurrenceStart.UTC(),
+	}
+	err := db.DB.Where("event_id = ? AND occurrence_start = ?", event.ID, override.OccurrenceStart).
+		FirstOrInit(&override).Error
+	if err != nil {
+		http.Error(w, "Failed to save occurrence", http.StatusInternalServerError)
+		return
+	}
+	override.Cancelled = req.Cancelled
+	override.StartDate = req.StartDate
+	override.EndDate = req.EndDate
+	override.Title = req.Title
+	override.Description = req.Description
+	override.Location = req.Location
+	override.Address = req.Address
+	override.Capacity = req.Capacity
+	if err := db.DB.Save(&override).Error; err != nil {
+		log.Printf("Failed to save occurrence override: %v", err)
+		http.Error(w, "Failed to save occurrence", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Capacity != nil {
+		if _, err := registrations.PromoteWaitlist(event.ID); err != nil {
+			log.Printf("Failed to promote waitlist for event %d: %v", event.ID, err)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, override)
+}
+
+// DeleteOccurrenceOverride restores an occurrence to the series' values.
+func DeleteOccurrenceOverride(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, r.URL.Query().Get("occurrence_start"))
+	if err != nil {
+		http.Error(w, "occurrence_start is required (RFC3339)", http.StatusBadRequest)
+		return
+	}
+
+	result := db.DB.Where("event_id = ? AND occurrence_start = ?", event.ID, start).
+		Delete(&models.EventOccurrenceOverride{})
+	if result.Error != nil {
+		http.Error(w, "Failed to delete occurrence override", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Occurrence override not found", http.StatusNotFound)
+		return
+	}
+	if _, err := registrations.PromoteWaitlist(event.ID); err != nil {
+		log.Printf("Failed to promote waitlist for event %d: %v", event.ID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"bytes"
+	"diplomaPorject/backend/events_service/internal/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func getPaymentServiceURL() string {
+	if u := os.Getenv("PAYMENT_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://payment-service:8093"
+}
+
+// refundTicket has payment_service refund a paid ticket that was just
+// cancelled. Refunds it misses are made by payment_service's sweep.
+func refundTicket(registration *models.EventRegistration) {
+	if registration.PaymentStatus != "captured" {
+		return
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"subject_type": "ticket", "subject_id": registration.ID})
+	req, err := http.NewRequest(http.MethodPost, getPaymentServiceURL()+"/internal/payments/refunds", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error requesting refund of ticket %d: %v", registration.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("Error requesting refund of ticket %d: %v", registration.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Refund of ticket %d returned %s", registration.ID, resp.Status)
+	}
+}
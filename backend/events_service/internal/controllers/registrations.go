@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/registrations"
+	"diplomaPorject/backend/events_service/utils/db"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// RegisterRequest picks the occurrence of a recurring event by its
+// original start; plain events need no body.
+type RegisterRequest struct {
+	OccurrenceStart *time.Time `json:"occurrence_start"`
+}
+
+type CheckInRequest struct {
+	Code string `json:"code"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeRegistrationError maps registration errors to HTTP statuses.
+func writeRegistrationError(w http.ResponseWriter, err error, notFound string) {
+	switch err {
+	case registrations.ErrNotFound:
+		http.Error(w, notFound, http.StatusNotFound)
+	case registrations.ErrInvalidTicket, registrations.ErrOccurrenceRequired, registrations.ErrNoOccurrence:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case registrations.ErrNotOwner:
+		http.Error(w, "Unauthorized - not the event owner", http.StatusUnauthorized)
+	case registrations.ErrClosed, registrations.ErrAlreadyRegistered, registrations.ErrInvalidState,
+		registrations.ErrAlreadyCheckedIn:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Registration error: %v", err)
+		http.Error(w, "Failed to process registration", http.StatusInternalServerError)
+	}
+}
+
+func contextUserID(r *http.Request) (uint, bool) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	return userID, ok
+}
+
+func RegisterForEvent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	eventID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RegisterRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	registration, err := registrations.Register(uint(eventID), userID, req.OccurrenceStart)
+	if err != nil {
+		writeRegistrationError(w, err, "Event not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, registration)
+}
+
+func ListMyRegistrations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	query := db.DB.Preload("Event").Where("user_id = ?", userID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	list := []models.EventRegistration{}
+	if err := query.Order("created_at DESC").Find(&list).Error; err != nil {
+		http.Error(w, "Failed to fetch registrations", http.StatusInternalServerError)
+		return
+	}
+	for i := range list {
+		registrations.SetWaitlistPosition(db.DB, &list[i])
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"registrations": list})
+}
+
+// GetMyRegistration returns one registration with its ticket code, which
+// the app renders as a QR code.
+func GetMyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	var registration models.EventRegistration
+	err := db.DB.Preload("Event").
+		Where("id = ? AND user_id = ?", mux.Vars(r)["registration_id"], userID).
+		First(&registration).Error
+	if err != nil {
+		http.Error(w, "Registration not found", http.StatusNotFound)
+		return
+	}
+	registrations.SetWaitlistPosition(db.DB, &registration)
+
+	writeJSON(w, http.StatusOK, registration)
+}
+
+func CancelMyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	registrationID, err := strconv.ParseUint(mux.Vars(r)["registration_id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid registration ID", http.StatusBadRequest)
+		return
+	}
+
+	registration, _, err := registrations.Cancel(uint(registrationID), userID)
+	if err != nil {
+		writeRegistrationError(w, err, "Registration not found")
+		return
+	}
+	refundTicket(registration)
+
+	writeJSON(w, http.StatusOK, registration)
+}
+
+// ownedEvent loads the event in the {id} route variable and checks that it
+// belongs to the calling admin.
+func ownedEvent(w http.ResponseWriter, r *http.Request) (*models.Event, bool) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - Admin ID missing", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var event models.Event
+	if err := db.DB.First(&event, mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "Not found event", http.StatusNotFound)
+		return nil, false
+	}
+	if event.AdminID != adminID {
+		http.Error(w, "Unauthorized - not the event owner", http.StatusUnauthorized)
+		return nil, false
+	}
+	return &event, true
+}
+
+func ListEventRegistrations(w http.ResponseWriter, r *http.Request) {
+	event, ok := ownedEvent(w, r)
+	if !ok {
+		return
+	}
+
+	// For a series, occurrence_start narrows the list and the counts to one
+	// occurrence.
+	scope := func() *gorm.DB {
+		return db.DB.Model(&models.EventRegistration{}).Where("event_id = ?", event.ID)
+	}
+	capacity, currentCount := event.Capacity, event.CurrentCount
+	if value := r.URL.Query().Get("occurrence_start"); value != "" {
+		start, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "Invalid occurrence_start (expected RFC3339)", http.StatusBadRequest)
+			return
+		}
+		scope = func() *gorm.DB {
+			return db.DB.Model(&models.EventRegistration{}).
+				Where("event_id = ? AND occurrence_start = ?", event.ID, start)
+		}
+
+		currentCount = 0
+		var counter models.EventOccurrence
+		if db.DB.Where("event_id = ? AND occurrence_start = ?", event.ID, start).First(&counter).Error == nil {
+			currentCount = counter.CurrentCount
+		}
+		var override models.EventOccurrenceOverride
+		if db.DB.Where("event_id = ? AND occurrence_start = ?", event.ID, start).First(&override).Error == nil &&
+			override.Capacity != nil {
+			capacity = *override.Capacity
+		}
+	}
+
+	query := scope()
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	list := []models.EventRegistration{}
+	if err := query.Order("id").Find(&list).Error; err != nil {
+		http.Error(w, "Failed to fetch registrations", http.StatusInternalServerError)
+		return
+	}
+
+	var held, waitlisted, checkedIn int64
+	scope().Where("status = ?", models.RegistrationHeld).Count(&held)
+	scope().Where("status = ?", models.RegistrationWaitlisted).Count(&waitlisted)
+	scope().Where("status = ? AND checked_in_at IS NOT NULL", models.RegistrationConfirmed).Count(&checkedIn)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"event_id":      event.ID,
+		"capacity":      capacity,
+		"current_count": currentCount,
+		"held":          held,
+		"waitlisted":    waitlisted,
+		"checked_in":    checkedIn,
+		"registrations": list,
+	})
+}
+
+// CheckInTicket validates a scanned ticket code for the event and marks the
+// registration as checked in.
+func CheckInTicket(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - Admin ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	eventID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	registration, err := registrations.CheckIn(uint(eventID), adminID, req.Code)
+	if err == registrations.ErrAlreadyCheckedIn {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":        err.Error(),
+			"registration": registration,
+		})
+		return
+	}
+	if err != nil {
+		writeRegistrationError(w, err, "Not found event")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, registration)
+}
@@ -0,0 +1,107 @@
+// Package ical writes RFC 5545 calendars for calendar apps to subscribe to.
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const utcLayout = "20060102T150405Z"
+
+// Event is one VEVENT. UID must stay the same across feed refreshes.
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Categories  string
+	Geo         string // "lat;lng"
+	Cancelled   bool
+	Modified    time.Time
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// Escape escapes a TEXT value.
+func Escape(value string) string {
+	return escaper.Replace(value)
+}
+
+// fold splits content lines longer than 75 octets without breaking UTF-8
+// sequences.
+func fold(line string) string {
+	if len(line) <= 75 {
+		return line + "\r\n"
+	}
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines lose one octet to the leading space.
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// Calendar renders a VCALENDAR with the given name and events.
+func Calendar(name string, events []Event) []byte {
+	var b bytes.Buffer
+	write := func(line string) { b.WriteString(fold(line)) }
+	now := time.Now().UTC().Format(utcLayout)
+
+	write("BEGIN:VCALENDAR")
+	write("VERSION:2.0")
+	write("PRODID:-//Steppe Way//Events//EN")
+	write("CALSCALE:GREGORIAN")
+	write("METHOD:PUBLISH")
+	write("X-WR-CALNAME:" + Escape(name))
+	for _, event := range events {
+		write("BEGIN:VEVENT")
+		write("UID:" + event.UID)
+		write("DTSTAMP:" + now)
+		write("DTSTART:" + event.Start.UTC().Format(utcLayout))
+		if !event.End.IsZero() && event.End.After(event.Start) {
+			write("DTEND:" + event.End.UTC().Format(utcLayout))
+		}
+		if !event.Modified.IsZero() {
+			write("LAST-MODIFIED:" + event.Modified.UTC().Format(utcLayout))
+		}
+		write("SUMMARY:" + Escape(event.Summary))
+		if event.Description != "" {
+			write("DESCRIPTION:" + Escape(event.Description))
+		}
+		if event.Location != "" {
+			write("LOCATION:" + Escape(event.Location))
+		}
+		if event.Geo != "" {
+			write("GEO:" + event.Geo)
+		}
+		if event.URL != "" {
+			write("URL:" + event.URL)
+		}
+		if event.Categories != "" {
+			write("CATEGORIES:" + Escape(event.Categories))
+		}
+		if event.Cancelled {
+			write("STATUS:CANCELLED")
+		} else {
+			write("STATUS:CONFIRMED")
+		}
+		write("END:VEVENT")
+	}
+	write("END:VCALENDAR")
+	return b.Bytes()
+}
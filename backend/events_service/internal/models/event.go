@@ -1,23 +1,168 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"gorm.io/gorm"
 	"time"
 )
 
 type Event struct {
 	gorm.Model
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	StartDate    time.Time `json:"start_date"`
-	EndDate      time.Time `json:"end_date"`
-	Location     string    `json:"location"`
-	Address      string    `json:"address"`
-	Link         string    `json:"link"`
-	Capacity     int       `json:"capacity"`
-	IsPublished  bool      `json:"is_published" gorm:"default:false"`
-	AdminID      uint      `json:"admin_id"`
-	CurrentCount int       `json:"current_count" gorm:"default:0"`
-	ImageURL     string    `json:"image_url"`
-	Category     string    `json:"category"`
+	Title            string    `json:"title"`
+	Description      string    `json:"description"`
+	StartDate        time.Time `json:"start_date"`
+	EndDate          time.Time `json:"end_date"`
+	Location         string    `json:"location"`
+	FormattedAddress string    `json:"formatted_address"`
+	LocationMismatch bool      `json:"location_mismatch" gorm:"default:false"`
+	Latitude         *float64  `json:"-"`
+	Longitude        *float64  `json:"-"`
+	Geohash          string    `json:"-" gorm:"size:12"`
+	CityID           *uint     `json:"city_id" gorm:"index"`
+	Address          string    `json:"address"`
+	Link             string    `json:"link"`
+	Capacity         int       `json:"capacity"`
+	Price            float64   `json:"price"`
+	Currency         string    `json:"currency" gorm:"size:3;default:'KZT'"`
+	IsPublished      bool      `json:"is_published" gorm:"default:false"`
+	AdminID          uint      `json:"admin_id"`
+	CurrentCount     int       `json:"current_count" gorm:"default:0"`
+	// ImageURL mirrors the gallery's cover image.
+	ImageURL string `json:"image_url"`
+	Category string `json:"category"`
+
+	// RRule makes the event a series (RFC 5545, e.g.
+	// "FREQ=WEEKLY;BYDAY=SA"); StartDate and EndDate are then the first
+	// occurrence. ExDates lists occurrence starts that are skipped and
+	// Timezone is the zone the rule is expanded in.
+	RRule    string   `json:"rrule,omitempty"`
+	ExDates  TimeList `json:"exdates,omitempty" gorm:"type:json"`
+	Timezone string   `json:"timezone,omitempty"`
+	// RecurrenceEnd is when the last occurrence ends; nil for series
+	// without COUNT or UNTIL.
+	RecurrenceEnd *time.Time `json:"recurrence_end,omitempty" gorm:"index"`
+
+	Images []EventImage `json:"images,omitempty" gorm:"foreignKey:EventID;constraint:OnDelete:CASCADE;"`
+}
+
+// EventImage is one picture of an event's gallery, shown in Position
+// order. One image per event is the cover.
+type EventImage struct {
+	gorm.Model
+	EventID  uint   `json:"event_id" gorm:"index;not null"`
+	URL      string `json:"url" gorm:"not null"`
+	MediaID  *uint  `json:"media_id,omitempty" gorm:"index"`
+	Caption  string `json:"caption"`
+	Position int    `json:"position" gorm:"not null;default:0"`
+	IsCover  bool   `json:"is_cover" gorm:"default:false"`
+}
+
+// BeforeCreate records the media-service ID behind the image URL.
+func (i *EventImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
+}
+
+// BeforeSave keeps the indexed coordinate columns in sync with Location.
+func (e *Event) BeforeSave(tx *gorm.DB) error {
+	e.Latitude, e.Longitude, e.Geohash = GeoColumns(e.Location)
+	return nil
+}
+
+// TimeList stores a list of instants as JSON.
+type TimeList []time.Time
+
+func (l TimeList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *TimeList) Scan(value interface{}) error {
+	if value == nil {
+		*l = TimeList{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		return errors.New("invalid scan source for TimeList")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
+
+// EventOccurrenceOverride changes one occurrence of a series, identified by
+// its original start. Empty fields keep the series' values.
+type EventOccurrenceOverride struct {
+	ID              uint       `json:"id" gorm:"primarykey"`
+	EventID         uint       `json:"event_id" gorm:"not null;uniqueIndex:idx_event_occurrence_override"`
+	OccurrenceStart time.Time  `json:"occurrence_start" gorm:"not null;uniqueIndex:idx_event_occurrence_override"`
+	Cancelled       bool       `json:"cancelled" gorm:"default:false"`
+	StartDate       *time.Time `json:"start_date,omitempty"`
+	EndDate         *time.Time `json:"end_date,omitempty"`
+	Title           string     `json:"title,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	Location        string     `json:"location,omitempty"`
+	Address         string     `json:"address,omitempty"`
+	Capacity        *int       `json:"capacity,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// EventOccurrence counts confirmed registrations of one occurrence of a
+// series. Rows are created when the first guest registers.
+type EventOccurrence struct {
+	ID              uint      `json:"id" gorm:"primarykey"`
+	EventID         uint      `json:"event_id" gorm:"not null;uniqueIndex:idx_event_occurrence"`
+	OccurrenceStart time.Time `json:"occurrence_start" gorm:"not null;uniqueIndex:idx_event_occurrence"`
+	CurrentCount    int       `json:"current_count" gorm:"default:0"`
+}
+
+const (
+	RegistrationHeld       = "held"
+	RegistrationConfirmed  = "confirmed"
+	RegistrationWaitlisted = "waitlisted"
+	RegistrationCancelled  = "cancelled"
+	RegistrationExpired    = "expired"
+)
+
+// EventRegistration is a user's place at an event. Held and confirmed
+// registrations count towards Event.CurrentCount (EventOccurrence.CurrentCount
+// for a series) and carry a signed ticket code; only confirmed tickets pass
+// check-in. A place at a priced event is held until HoldExpiresAt and
+// confirmed once its payment is captured. Waitlisted registrations are
+// promoted in sign-up order when a place frees up.
+type EventRegistration struct {
+	gorm.Model
+	EventID     uint       `json:"event_id" gorm:"index;not null"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Status      string     `json:"status" gorm:"index;not null"`
+	TicketCode  *string    `json:"ticket_code,omitempty" gorm:"uniqueIndex"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty"`
+	CheckedInBy *uint      `json:"checked_in_by,omitempty"`
+	// HoldExpiresAt is when a held place is given up unless paid for.
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty" gorm:"index"`
+	// PaymentID and PaymentStatus are kept up to date by payment_service
+	// for events with a price.
+	PaymentID     *uint  `json:"payment_id,omitempty"`
+	PaymentStatus string `json:"payment_status,omitempty"`
+	// OccurrenceStart is the original start of the occurrence booked when
+	// the event is a series.
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty" gorm:"index"`
+
+	Event *Event `json:"event,omitempty" gorm:"foreignKey:EventID"`
+
+	// WaitlistPosition is computed for waitlisted registrations.
+	WaitlistPosition int `json:"waitlist_position,omitempty" gorm:"-"`
 }
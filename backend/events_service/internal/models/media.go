@@ -0,0 +1,21 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MediaIDFromURL returns the media-service ID of a "/media/{id}" URL, or
+// nil for files uploaded before media-service existed.
+func MediaIDFromURL(url string) *uint {
+	value, ok := strings.CutPrefix(url, "/media/")
+	if !ok {
+		return nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil
+	}
+	mediaID := uint(id)
+	return &mediaID
+}
@@ -0,0 +1,315 @@
+// Package occurrences turns events into dated occurrences. A plain event
+// has one occurrence; a series has one per RRULE date, minus its EXDATEs,
+// with per-occurrence overrides applied.
+package occurrences
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/recurrence"
+	"errors"
+	"sort"
+	"time"
+	// The runtime image has no zoneinfo, so the database is embedded.
+	_ "time/tzdata"
+
+	"gorm.io/gorm"
+)
+
+const DefaultTimezone = "Asia/Almaty"
+
+// MaxPerEvent bounds how many occurrences of one series are expanded per
+// request, and how far a finite series is walked to find its end.
+const MaxPerEvent = 1000
+
+// Occurrence statuses relative to the current time.
+const (
+	StatusUpcoming = "upcoming"
+	StatusOngoing  = "ongoing"
+	StatusPast     = "past"
+)
+
+var (
+	ErrInvalidTimezone = errors.New("unknown timezone")
+	ErrNotOccurrence   = errors.New("not an occurrence of this event")
+)
+
+// Occurrence is an event as it happens on one date. For a series the
+// embedded event carries the occurrence's dates and overridden fields, and
+// OccurrenceStart is the original start that identifies the occurrence.
+type Occurrence struct {
+	models.Event
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+	Cancelled       bool       `json:"cancelled,omitempty"`
+	// Status and Distance (in km, when a list is filtered by radius) are
+	// set by listings.
+	Status   string   `json:"status,omitempty"`
+	Distance *float64 `json:"distance,omitempty"`
+}
+
+// StatusAt reports whether the occurrence is upcoming, ongoing or past at
+// now. An occurrence without a duration is past once it has started.
+func (o *Occurrence) StatusAt(now time.Time) string {
+	switch {
+	case o.StartDate.After(now):
+		return StatusUpcoming
+	case o.EndDate.After(now):
+		return StatusOngoing
+	default:
+		return StatusPast
+	}
+}
+
+// DefaultLocation is the zone used for events without a timezone and for
+// date-only query parameters.
+func DefaultLocation() *time.Location {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Location is the zone the event's rule is expanded in.
+func Location(event *models.Event) *time.Location {
+	if event.Timezone != "" {
+		if loc, err := time.LoadLocation(event.Timezone); err == nil {
+			return loc
+		}
+	}
+	return DefaultLocation()
+}
+
+// Rule parses the event's RRULE; it returns nil for a plain event.
+func Rule(event *models.Event) (*recurrence.Rule, error) {
+	if event.RRule == "" {
+		return nil, nil
+	}
+	return recurrence.Parse(event.RRule)
+}
+
+// Validate checks the recurrence fields and fills in RecurrenceEnd.
+func Validate(event *models.Event) error {
+	if event.Timezone != "" {
+		if _, err := time.LoadLocation(event.Timezone); err != nil {
+			return ErrInvalidTimezone
+		}
+	}
+	rule, err := Rule(event)
+	if err != nil {
+		return err
+	}
+
+	event.RecurrenceEnd = nil
+	if rule == nil {
+		event.ExDates = nil
+		return nil
+	}
+	if err := rule.Check(event.StartDate.In(Location(event))); err != nil {
+		return err
+	}
+	if last, ok := rule.Last(event.StartDate.In(Location(event)), MaxPerEvent); ok {
+		end := last.Add(duration(event))
+		event.RecurrenceEnd = &end
+	}
+	return nil
+}
+
+func duration(event *models.Event) time.Duration {
+	if d := event.EndDate.Sub(event.StartDate); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func excluded(event *models.Event, start time.Time) bool {
+	for _, ex := range event.ExDates {
+		if ex.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+func isOccurrence(event *models.Event, rule *recurrence.Rule, start time.Time) bool {
+	dtstart := event.StartDate.In(Location(event))
+	starts := rule.Between(dtstart, start, start.Add(time.Nanosecond), 1)
+	return len(starts) == 1 && !excluded(event, start)
+}
+
+// apply builds the occurrence starting at start, with its override if any.
+func apply(event *models.Event, start time.Time, override *models.EventOccurrenceOverride) Occurrence {
+	occurrence := Occurrence{Event: *event}
+	original := start.UTC()
+	occurrence.OccurrenceStart = &original
+	occurrence.StartDate = start
+	occurrence.EndDate = start.Add(duration(event))
+	occurrence.CurrentCount = 0
+
+	if override == nil {
+		return occurrence
+	}
+	occurrence.Cancelled = override.Cancelled
+	if override.StartDate != nil {
+		occurrence.StartDate = *override.StartDate
+		occurrence.EndDate = override.StartDate.Add(duration(event))
+	}
+	if override.EndDate != nil {
+		occurrence.EndDate = *override.EndDate
+	}
+	if override.Title != "" {
+		occurrence.Title = override.Title
+	}
+	if override.Description != "" {
+		occurrence.Description = override.Description
+	}
+	if override.Location != "" {
+		occurrence.Location = override.Location
+	}
+	if override.Address != "" {
+		occurrence.Address = override.Address
+	}
+	if override.Capacity != nil {
+		occurrence.Capacity = *override.Capacity
+	}
+	return occurrence
+}
+
+func overlaps(occurrence *Occurrence, from, to time.Time) bool {
+	return occurrence.StartDate.Before(to) &&
+		(occurrence.EndDate.After(from) || !occurrence.StartDate.Before(from))
+}
+
+// Expand lists the occurrences of an event that overlap [from, to), in
+// start order. Cancelled occurrences are included and flagged.
+func Expand(event *models.Event, overrides []models.EventOccurrenceOverride, from, to time.Time) ([]Occurrence, error) {
+	rule, err := Rule(event)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		occurrence := Occurrence{Event: *event}
+		if !overlaps(&occurrence, from, to) {
+			return nil, nil
+		}
+		return []Occurrence{occurrence}, nil
+	}
+
+	byStart := map[int64]*models.EventOccurrenceOverride{}
+	for i := range overrides {
+		byStart[overrides[i].OccurrenceStart.UnixNano()] = &overrides[i]
+	}
+
+	dtstart := event.StartDate.In(Location(event))
+	starts := rule.Between(dtstart, from.Add(-duration(event)), to, MaxPerEvent)
+
+	result := []Occurrence{}
+	seen := map[int64]bool{}
+	for _, start := range starts {
+		if excluded(event, start) {
+			continue
+		}
+		seen[start.UnixNano()] = true
+		occurrence := apply(event, start, byStart[start.UnixNano()])
+		if overlaps(&occurrence, from, to) {
+			result = append(result, occurrence)
+		}
+	}
+
+	// Occurrences moved into the range from outside it.
+	for i := range overrides {
+		override := &overrides[i]
+		if override.StartDate == nil || seen[override.OccurrenceStart.UnixNano()] {
+			continue
+		}
+		occurrence := apply(event, override.OccurrenceStart.In(dtstart.Location()), override)
+		if overlaps(&occurrence, from, to) && isOccurrence(event, rule, override.OccurrenceStart) {
+			result = append(result, occurrence)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartDate.Before(result[j].StartDate)
+	})
+	return result, nil
+}
+
+// LoadOverrides reads the overrides of the given events.
+func LoadOverrides(tx *gorm.DB, eventIDs ...uint) (map[uint][]models.EventOccurrenceOverride, error) {
+	result := map[uint][]models.EventOccurrenceOverride{}
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+	var list []models.EventOccurrenceOverride
+	if err := tx.Where("event_id IN ?", eventIDs).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, override := range list {
+		result[override.EventID] = append(result[override.EventID], override)
+	}
+	return result, nil
+}
+
+// Find returns the occurrence of the event that originally starts at start.
+func Find(tx *gorm.DB, event *models.Event, start time.Time) (*Occurrence, error) {
+	rule, err := Rule(event)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil || !isOccurrence(event, rule, start) {
+		return nil, ErrNotOccurrence
+	}
+
+	var override *models.EventOccurrenceOverride
+	var found models.EventOccurrenceOverride
+	err = tx.Where("event_id = ? AND occurrence_start = ?", event.ID, start).First(&found).Error
+	if err == nil {
+		override = &found
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	occurrence := apply(event, start.In(Location(event)), override)
+	return &occurrence, nil
+}
+
+// FillCounts sets CurrentCount on occurrences of series from the
+// per-occurrence counters.
+func FillCounts(tx *gorm.DB, list []Occurrence) error {
+	var eventIDs []uint
+	var first, last time.Time
+	for _, occurrence := range list {
+		if occurrence.OccurrenceStart == nil {
+			continue
+		}
+		eventIDs = append(eventIDs, occurrence.ID)
+		if first.IsZero() || occurrence.OccurrenceStart.Before(first) {
+			first = *occurrence.OccurrenceStart
+		}
+		if occurrence.OccurrenceStart.After(last) {
+			last = *occurrence.OccurrenceStart
+		}
+	}
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	var counts []models.EventOccurrence
+	err := tx.Where("event_id IN ? AND occurrence_start BETWEEN ? AND ? AND current_count > 0", eventIDs, first, last).
+		Find(&counts).Error
+	if err != nil {
+		return err
+	}
+	byKey := map[uint]map[int64]int{}
+	for _, count := range counts {
+		if byKey[count.EventID] == nil {
+			byKey[count.EventID] = map[int64]int{}
+		}
+		byKey[count.EventID][count.OccurrenceStart.UnixNano()] = count.CurrentCount
+	}
+	for i := range list {
+		if list[i].OccurrenceStart != nil {
+			list[i].CurrentCount = byKey[list[i].ID][list[i].OccurrenceStart.UnixNano()]
+		}
+	}
+	return nil
+}
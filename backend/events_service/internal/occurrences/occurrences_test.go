@@ -0,0 +1,80 @@
+package occurrences
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func series(rrule string, exdates ...time.Time) *models.Event {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	return &models.Event{
+		Title:     "Market",
+		StartDate: start,
+		EndDate:   start.Add(2 * time.Hour),
+		RRule:     rrule,
+		ExDates:   exdates,
+		Timezone:  "UTC",
+	}
+}
+
+func starts(occurrences []Occurrence) []string {
+	var result []string
+	for _, o := range occurrences {
+		result = append(result, o.StartDate.UTC().Format("2006-01-02"))
+	}
+	return result
+}
+
+func TestExpandSkipsExDates(t *testing.T) {
+	event := series("FREQ=WEEKLY;COUNT=4", time.Date(2025, 3, 8, 10, 0, 0, 0, time.UTC))
+
+	got, err := Expand(event, nil, event.StartDate, event.StartDate.AddDate(0, 2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2025-03-01", "2025-03-15", "2025-03-22"}
+	if dates := starts(got); !reflect.DeepEqual(dates, want) {
+		t.Fatalf("got %v, want %v; an EXDATE still counts towards COUNT", dates, want)
+	}
+}
+
+func TestExpandAppliesOverrides(t *testing.T) {
+	event := series("FREQ=WEEKLY;COUNT=3")
+	original := time.Date(2025, 3, 8, 10, 0, 0, 0, time.UTC)
+	moved := time.Date(2025, 3, 9, 12, 0, 0, 0, time.UTC)
+	overrides := []models.EventOccurrenceOverride{
+		{OccurrenceStart: original, StartDate: &moved, Title: "Sunday market"},
+		{OccurrenceStart: time.Date(2025, 3, 15, 10, 0, 0, 0, time.UTC), Cancelled: true},
+	}
+
+	got, err := Expand(event, overrides, event.StartDate, event.StartDate.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d occurrences, want 3", len(got))
+	}
+	if !got[1].StartDate.Equal(moved) || got[1].Title != "Sunday market" || !got[1].OccurrenceStart.Equal(original) {
+		t.Errorf("moved occurrence = %+v", got[1])
+	}
+	if !got[2].Cancelled {
+		t.Error("cancelled occurrence is not flagged")
+	}
+}
+
+func TestValidateRejectsRulesThatNeverRepeat(t *testing.T) {
+	event := series("FREQ=DAILY;INTERVAL=7;BYDAY=TU")
+	if err := Validate(event); err == nil {
+		t.Fatal("a weekly-interval rule on another weekday never repeats")
+	}
+
+	event = series("FREQ=WEEKLY;COUNT=3")
+	if err := Validate(event); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC); event.RecurrenceEnd == nil || !event.RecurrenceEnd.Equal(want) {
+		t.Fatalf("RecurrenceEnd = %v, want %v", event.RecurrenceEnd, want)
+	}
+}
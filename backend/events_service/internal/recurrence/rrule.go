@@ -0,0 +1,511 @@
+// Package recurrence expands RFC 5545 recurrence rules. It covers the parts
+// event organisers use: FREQ, INTERVAL, COUNT, UNTIL, BYDAY (with ordinals
+// for monthly and yearly rules), BYMONTHDAY, BYMONTH and WKST.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+// maxPeriods bounds how many days, weeks, months or years are walked while
+// expanding a rule.
+const maxPeriods = 100000
+
+var ErrInvalidRule = errors.New("invalid RRULE")
+
+var frequencies = map[string]Frequency{
+	"DAILY":   Daily,
+	"WEEKLY":  Weekly,
+	"MONTHLY": Monthly,
+	"YEARLY":  Yearly,
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// WeekdayNum is a BYDAY entry; N is the ordinal within the month or year
+// (negative counts from the end) and zero means every such weekday.
+type WeekdayNum struct {
+	Day time.Weekday
+	N   int
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes the whole day.
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, invalid("UNTIL must look like 20250131T235959Z")
+}
+
+// Parse reads an RRULE value, with or without the "RRULE:" prefix.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	hasFreq := false
+
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, invalid("malformed part %q", part)
+		}
+
+		switch key {
+		case "FREQ":
+			freq, ok := frequencies[val]
+			if !ok {
+				return nil, invalid("FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+			rule.Freq = freq
+			hasFreq = true
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, invalid("INTERVAL must be a positive number")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, invalid("COUNT must be a positive number")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				if len(item) < 2 {
+					return nil, invalid("bad BYDAY value %q", item)
+				}
+				day, ok := weekdays[item[len(item)-2:]]
+				if !ok {
+					return nil, invalid("bad BYDAY value %q", item)
+				}
+				n := 0
+				if prefix := item[:len(item)-2]; prefix != "" {
+					var err error
+					n, err = strconv.Atoi(prefix)
+					if err != nil || n == 0 || n < -53 || n > 53 {
+						return nil, invalid("bad BYDAY value %q", item)
+					}
+				}
+				rule.ByDay = append(rule.ByDay, WeekdayNum{Day: day, N: n})
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, invalid("bad BYMONTHDAY value %q", item)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n < 1 || n > 12 {
+					return nil, invalid("bad BYMONTH value %q", item)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			day, ok := weekdays[val]
+			if !ok {
+				return nil, invalid("bad WKST value %q", val)
+			}
+			rule.WeekStart = day
+		default:
+			return nil, invalid("%s is not supported", key)
+		}
+	}
+
+	if !hasFreq {
+		return nil, invalid("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, invalid("COUNT and UNTIL cannot be combined")
+	}
+	if rule.Freq == Daily || rule.Freq == Weekly {
+		for _, day := range rule.ByDay {
+			if day.N != 0 {
+				return nil, invalid("BYDAY ordinals need a MONTHLY or YEARLY rule")
+			}
+		}
+	}
+	if rule.Freq == Weekly && len(rule.ByMonthDay) > 0 {
+		return nil, invalid("BYMONTHDAY cannot be used with a WEEKLY rule")
+	}
+	if rule.Freq == Monthly || rule.Freq == Yearly && len(rule.ByMonth) > 0 {
+		for _, day := range rule.ByDay {
+			if day.N < -5 || day.N > 5 {
+				return nil, invalid("a month has at most 5 of each weekday")
+			}
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && !rule.monthDayFits() {
+		return nil, invalid("no month in the rule has a day in BYMONTHDAY")
+	}
+	return rule, nil
+}
+
+// monthDayFits reports whether some BYMONTHDAY exists in some allowed
+// month, counting February as 29 days.
+func (r *Rule) monthDayFits() bool {
+	for month := time.January; month <= time.December; month++ {
+		if !r.monthAllowed(month) {
+			continue
+		}
+		last := daysIn(2024, month)
+		for _, md := range r.ByMonthDay {
+			if md <= last && -md <= last {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check reports rules that parse but never repeat from dtstart, such as a
+// leap day every fourth year starting in a common year.
+func (r *Rule) Check(dtstart time.Time) error {
+	if r.Count == 1 {
+		return nil
+	}
+	far := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	if len(r.Between(dtstart, dtstart.Add(time.Nanosecond), far, 1)) == 0 {
+		return invalid("the rule has no occurrence after the start")
+	}
+	return nil
+}
+
+// Finite reports whether the rule ends through COUNT or UNTIL.
+func (r *Rule) Finite() bool {
+	return r.Count > 0 || !r.Until.IsZero()
+}
+
+func (r *Rule) monthAllowed(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) weekdayAllowed(day time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) monthDayAllowed(date time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := daysIn(date.Year(), date.Month())
+	for _, md := range r.ByMonthDay {
+		if md == date.Day() || md < 0 && last+md+1 == date.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// nthWeekday finds the n-th given weekday between first and last day
+// (inclusive, 1-based); negative n counts back from last.
+func nthWeekday(year int, month time.Month, firstDay, lastDay int, day time.Weekday, n int, loc *time.Location) (int, bool) {
+	var matches []int
+	for d := firstDay; d <= lastDay; d++ {
+		if time.Date(year, month, d, 0, 0, 0, 0, loc).Weekday() == day {
+			matches = append(matches, d)
+		}
+	}
+	if n > 0 && n <= len(matches) {
+		return matches[n-1], true
+	}
+	if n < 0 && -n <= len(matches) {
+		return matches[len(matches)+n], true
+	}
+	return 0, false
+}
+
+// monthDays lists the matching days of one month, in order.
+func (r *Rule) monthDays(year int, month time.Month, dtstart time.Time) []int {
+	last := daysIn(year, month)
+	loc := dtstart.Location()
+	set := map[int]bool{}
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			day := md
+			if md < 0 {
+				day = last + md + 1
+			}
+			if day < 1 || day > last {
+				continue
+			}
+			if r.weekdayAllowed(time.Date(year, month, day, 0, 0, 0, 0, loc).Weekday()) {
+				set[day] = true
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			if wd.N == 0 {
+				for d := 1; d <= last; d++ {
+					if time.Date(year, month, d, 0, 0, 0, 0, loc).Weekday() == wd.Day {
+						set[d] = true
+					}
+				}
+			} else if d, ok := nthWeekday(year, month, 1, last, wd.Day, wd.N, loc); ok {
+				set[d] = true
+			}
+		}
+	default:
+		// Months without the start day, e.g. the 31st, are skipped.
+		if dtstart.Day() <= last {
+			set[dtstart.Day()] = true
+		}
+	}
+
+	days := make([]int, 0, len(set))
+	for d := range set {
+		days = append(days, d)
+	}
+	sort.Ints(days)
+	return days
+}
+
+// yearDays handles yearly rules with BYDAY but no BYMONTH, where ordinals
+// count within the whole year ("20MO" is the 20th Monday).
+func (r *Rule) yearDays(year int, loc *time.Location) []time.Time {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc)
+	set := map[time.Time]bool{}
+
+	for _, wd := range r.ByDay {
+		var matches []time.Time
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			if d.Weekday() == wd.Day {
+				matches = append(matches, d)
+			}
+		}
+		switch {
+		case wd.N == 0:
+			for _, m := range matches {
+				set[m] = true
+			}
+		case wd.N > 0 && wd.N <= len(matches):
+			set[matches[wd.N-1]] = true
+		case wd.N < 0 && -wd.N <= len(matches):
+			set[matches[len(matches)+wd.N]] = true
+		}
+	}
+
+	dates := make([]time.Time, 0, len(set))
+	for d := range set {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// periodStart is the first day (at midnight) of the k-th period.
+func (r *Rule) periodStart(k int, dtstart time.Time) time.Time {
+	loc := dtstart.Location()
+	base := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, loc)
+	switch r.Freq {
+	case Weekly:
+		offset := (int(base.Weekday()) - int(r.WeekStart) + 7) % 7
+		return base.AddDate(0, 0, -offset+7*k*r.Interval)
+	case Monthly:
+		return time.Date(base.Year(), base.Month()+time.Month(k*r.Interval), 1, 0, 0, 0, 0, loc)
+	case Yearly:
+		return time.Date(base.Year()+k*r.Interval, time.January, 1, 0, 0, 0, 0, loc)
+	}
+	return base.AddDate(0, 0, k*r.Interval)
+}
+
+// period returns the candidate dates (at midnight) of the k-th period.
+func (r *Rule) period(k int, dtstart time.Time) []time.Time {
+	loc := dtstart.Location()
+	base := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, loc)
+	var dates []time.Time
+
+	switch r.Freq {
+	case Daily:
+		day := base.AddDate(0, 0, k*r.Interval)
+		if r.monthAllowed(day.Month()) && r.weekdayAllowed(day.Weekday()) && r.monthDayAllowed(day) {
+			dates = append(dates, day)
+		}
+
+	case Weekly:
+		offset := (int(base.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := base.AddDate(0, 0, -offset+7*k*r.Interval)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			matches := day.Weekday() == dtstart.Weekday()
+			if len(r.ByDay) > 0 {
+				matches = r.weekdayAllowed(day.Weekday())
+			}
+			if matches && r.monthAllowed(day.Month()) {
+				dates = append(dates, day)
+			}
+		}
+
+	case Monthly:
+		first := time.Date(base.Year(), base.Month()+time.Month(k*r.Interval), 1, 0, 0, 0, 0, loc)
+		if r.monthAllowed(first.Month()) {
+			for _, d := range r.monthDays(first.Year(), first.Month(), dtstart) {
+				dates = append(dates, time.Date(first.Year(), first.Month(), d, 0, 0, 0, 0, loc))
+			}
+		}
+
+	case Yearly:
+		year := base.Year() + k*r.Interval
+		if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) > 0 {
+			return r.yearDays(year, loc)
+		}
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{dtstart.Month()}
+		}
+		sorted := append([]time.Month(nil), months...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		for _, month := range sorted {
+			for _, d := range r.monthDays(year, month, dtstart) {
+				dates = append(dates, time.Date(year, month, d, 0, 0, 0, 0, loc))
+			}
+		}
+	}
+	return dates
+}
+
+// Between returns the occurrence starts s with after <= s < before, at most
+// limit of them. dtstart is always the first occurrence and its clock time
+// and location apply to every occurrence.
+func (r *Rule) Between(dtstart, after, before time.Time, limit int) []time.Time {
+	var result []time.Time
+	emit := func(t time.Time) bool {
+		if !t.Before(after) && t.Before(before) {
+			result = append(result, t)
+		}
+		return limit <= 0 || len(result) < limit
+	}
+
+	count := 1
+	if !emit(dtstart) {
+		return result
+	}
+	if r.Count == 1 {
+		return result
+	}
+
+	hour, minute, second := dtstart.Clock()
+	for k := 0; k < maxPeriods; k++ {
+		// Periods that start past the range or UNTIL cannot add anything,
+		// even when the ones before had no dates at all.
+		start := r.periodStart(k, dtstart)
+		if !start.Before(before) || !r.Until.IsZero() && start.After(r.Until) {
+			break
+		}
+		dates := r.period(k, dtstart)
+		for _, date := range dates {
+			t := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, second, dtstart.Nanosecond(), dtstart.Location())
+			if !t.After(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return result
+			}
+			if !t.Before(before) {
+				return result
+			}
+			count++
+			if !emit(t) {
+				return result
+			}
+			if r.Count > 0 && count >= r.Count {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// Last returns the final occurrence start of a finite rule, looking no
+// further than limit occurrences.
+func (r *Rule) Last(dtstart time.Time, limit int) (time.Time, bool) {
+	if !r.Finite() {
+		return time.Time{}, false
+	}
+	before := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !r.Until.IsZero() {
+		before = r.Until.Add(time.Second)
+	}
+	starts := r.Between(dtstart, dtstart, before, limit)
+	if len(starts) == 0 {
+		return dtstart, true
+	}
+	return starts[len(starts)-1], true
+}
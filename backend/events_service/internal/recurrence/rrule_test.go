@@ -0,0 +1,240 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"no frequency", "INTERVAL=2"},
+		{"unknown frequency", "FREQ=HOURLY"},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0"},
+		{"count and until", "FREQ=DAILY;COUNT=3;UNTIL=20250101"},
+		{"bad until", "FREQ=DAILY;UNTIL=tomorrow"},
+		{"unsupported BYSETPOS", "FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1"},
+		{"ordinal in a weekly rule", "FREQ=WEEKLY;BYDAY=2MO"},
+		{"sixth weekday of a month", "FREQ=MONTHLY;BYDAY=6MO"},
+		{"BYMONTHDAY in a weekly rule", "FREQ=WEEKLY;BYMONTHDAY=1"},
+		{"30 February", "FREQ=MONTHLY;BYMONTH=2;BYMONTHDAY=30"},
+		{"31st of short months", "FREQ=YEARLY;BYMONTH=4,6,9,11;BYMONTHDAY=31,-31"},
+		{"malformed part", "FREQ=DAILY;COUNT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.rule); !errors.Is(err, ErrInvalidRule) {
+				t.Fatalf("Parse(%q) = %v, want ErrInvalidRule", tt.rule, err)
+			}
+		})
+	}
+}
+
+func TestParseAccepts(t *testing.T) {
+	for _, rule := range []string{
+		"RRULE:FREQ=WEEKLY;BYDAY=SA,SU",
+		"FREQ=MONTHLY;BYMONTH=2;BYMONTHDAY=29",
+		"FREQ=MONTHLY;BYDAY=-1FR",
+		"FREQ=YEARLY;BYDAY=20MO",
+		"freq=daily;until=20250131T235959Z",
+	} {
+		if _, err := Parse(rule); err != nil {
+			t.Errorf("Parse(%q) = %v", rule, err)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart string
+		after   string
+		before  string
+		limit   int
+		want    []string
+	}{
+		{
+			name:    "count includes dtstart",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: "2025-03-01T10:00:00Z",
+			after:   "2025-01-01T00:00:00Z",
+			before:  "2026-01-01T00:00:00Z",
+			want:    []string{"2025-03-01T10:00:00Z", "2025-03-02T10:00:00Z", "2025-03-03T10:00:00Z"},
+		},
+		{
+			name:    "count is counted from dtstart, not from the range",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: "2025-03-01T10:00:00Z",
+			after:   "2025-03-02T00:00:00Z",
+			before:  "2026-01-01T00:00:00Z",
+			want:    []string{"2025-03-02T10:00:00Z", "2025-03-03T10:00:00Z"},
+		},
+		{
+			name:    "until is inclusive",
+			rule:    "FREQ=WEEKLY;UNTIL=20250315T100000Z",
+			dtstart: "2025-03-01T10:00:00Z",
+			after:   "2025-01-01T00:00:00Z",
+			before:  "2026-01-01T00:00:00Z",
+			want:    []string{"2025-03-01T10:00:00Z", "2025-03-08T10:00:00Z", "2025-03-15T10:00:00Z"},
+		},
+		{
+			name:    "date-only until covers the day",
+			rule:    "FREQ=DAILY;UNTIL=20250302",
+			dtstart: "2025-03-01T20:00:00Z",
+			after:   "2025-01-01T00:00:00Z",
+			before:  "2026-01-01T00:00:00Z",
+			want:    []string{"2025-03-01T20:00:00Z", "2025-03-02T20:00:00Z"},
+		},
+		{
+			name:    "weekly by day",
+			rule:    "FREQ=WEEKLY;BYDAY=SA,SU",
+			dtstart: "2025-03-01T09:00:00Z",
+			after:   "2025-03-01T00:00:00Z",
+			before:  "2025-03-10T00:00:00Z",
+			want: []string{"2025-03-01T09:00:00Z", "2025-03-02T09:00:00Z",
+				"2025-03-08T09:00:00Z", "2025-03-09T09:00:00Z"},
+		},
+		{
+			name:    "last friday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			dtstart: "2025-01-31T18:00:00Z",
+			after:   "2025-01-01T00:00:00Z",
+			before:  "2026-01-01T00:00:00Z",
+			want:    []string{"2025-01-31T18:00:00Z", "2025-02-28T18:00:00Z", "2025-03-28T18:00:00Z"},
+		},
+		{
+			name:    "months without the 31st are skipped",
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: "2025-01-31T12:00:00Z",
+			after:   "2025-01-01T00:00:00Z",
+			before:  "2026-01-01T00:00:00Z",
+			want:    []string{"2025-01-31T12:00:00Z", "2025-03-31T12:00:00Z", "2025-05-31T12:00:00Z"},
+		},
+		{
+			name:    "leap days only",
+			rule:    "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29",
+			dtstart: "2024-02-29T08:00:00Z",
+			after:   "2024-01-01T00:00:00Z",
+			before:  "2033-01-01T00:00:00Z",
+			want:    []string{"2024-02-29T08:00:00Z", "2028-02-29T08:00:00Z", "2032-02-29T08:00:00Z"},
+		},
+		{
+			name:    "limit",
+			rule:    "FREQ=DAILY",
+			dtstart: "2025-03-01T10:00:00Z",
+			after:   "2025-03-01T00:00:00Z",
+			before:  "2026-01-01T00:00:00Z",
+			limit:   2,
+			want:    []string{"2025-03-01T10:00:00Z", "2025-03-02T10:00:00Z"},
+		},
+		{
+			name:    "interval",
+			rule:    "FREQ=DAILY;INTERVAL=10",
+			dtstart: "2025-03-01T10:00:00Z",
+			after:   "2025-03-05T00:00:00Z",
+			before:  "2025-03-25T00:00:00Z",
+			want:    []string{"2025-03-11T10:00:00Z", "2025-03-21T10:00:00Z"},
+		},
+		{
+			name:    "range before dtstart",
+			rule:    "FREQ=DAILY",
+			dtstart: "2025-03-01T10:00:00Z",
+			after:   "2025-01-01T00:00:00Z",
+			before:  "2025-02-01T00:00:00Z",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := rule.Between(date(tt.dtstart), date(tt.after), date(tt.before), tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(date(tt.want[i])) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// Rules whose periods are all empty must stop at the end of the range
+// instead of walking every period.
+func TestBetweenEmptyPeriodsStop(t *testing.T) {
+	dtstart := date("2025-01-06T10:00:00Z") // a Monday
+	for _, value := range []string{
+		"FREQ=DAILY;INTERVAL=7;BYDAY=TU",
+		"FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29",
+		"FREQ=MONTHLY;BYMONTHDAY=13;BYDAY=FR;BYMONTH=1",
+	} {
+		rule, err := Parse(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			rule.Between(dtstart, dtstart, dtstart.AddDate(0, 1, 0), 0)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: 100 one-month expansions took %s", value, elapsed)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		rule    string
+		dtstart string
+		ok      bool
+	}{
+		{"FREQ=DAILY", "2025-01-06T10:00:00Z", true},
+		{"FREQ=DAILY;COUNT=1", "2025-01-06T10:00:00Z", true},
+		{"FREQ=DAILY;INTERVAL=7;BYDAY=TU", "2025-01-06T10:00:00Z", false},
+		{"FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29", "2025-02-01T10:00:00Z", false},
+		{"FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29", "2024-02-29T10:00:00Z", true},
+		{"FREQ=DAILY;UNTIL=20250101", "2025-01-06T10:00:00Z", false},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = rule.Check(date(tt.dtstart))
+		if (err == nil) != tt.ok {
+			t.Errorf("Check(%s from %s) = %v, want ok %v", tt.rule, tt.dtstart, err, tt.ok)
+		}
+	}
+}
+
+func TestLast(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;COUNT=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, ok := rule.Last(date("2025-03-01T10:00:00Z"), 1000)
+	if !ok || !last.Equal(date("2025-03-22T10:00:00Z")) {
+		t.Fatalf("Last = %v, %v", last, ok)
+	}
+
+	rule, _ = Parse("FREQ=WEEKLY")
+	if _, ok := rule.Last(date("2025-03-01T10:00:00Z"), 1000); ok {
+		t.Fatal("an endless rule has no last occurrence")
+	}
+}
@@ -0,0 +1,459 @@
+package registrations
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/internal/occurrences"
+	"diplomaPorject/backend/events_service/internal/tickets"
+	"diplomaPorject/backend/events_service/utils/db"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrClosed             = errors.New("registration is closed for this event")
+	ErrAlreadyRegistered  = errors.New("already registered for this event")
+	ErrInvalidState       = errors.New("registration cannot change from its current status")
+	ErrInvalidTicket      = errors.New("ticket is not valid for this event")
+	ErrAlreadyCheckedIn   = errors.New("ticket has already been checked in")
+	ErrNotOwner           = errors.New("not the event owner")
+	ErrOccurrenceRequired = errors.New("occurrence_start is required for a recurring event")
+	ErrNoOccurrence       = errors.New("occurrence_start does not match an occurrence of this event")
+)
+
+// HoldDuration is how long a place at a priced event is held for payment.
+// TICKET_HOLD_MINUTES overrides the default.
+func HoldDuration() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("TICKET_HOLD_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// slot is what a registration takes a place in: a plain event, or one
+// occurrence of a series with its own counter.
+type slot struct {
+	event    *models.Event
+	start    *time.Time
+	capacity int
+	counter  *models.EventOccurrence
+}
+
+func (s *slot) count() int {
+	if s.counter != nil {
+		return s.counter.CurrentCount
+	}
+	return s.event.CurrentCount
+}
+
+// hasRoom reports whether another registration can take a place. A
+// capacity of zero means the event has no limit.
+func (s *slot) hasRoom() bool {
+	return s.capacity <= 0 || s.count() < s.capacity
+}
+
+func (s *slot) adjustCount(tx *gorm.DB, delta int) error {
+	if s.counter != nil {
+		s.counter.CurrentCount += delta
+		return tx.Model(s.counter).
+			Update("current_count", gorm.Expr("GREATEST(current_count + ?, 0)", delta)).Error
+	}
+	s.event.CurrentCount += delta
+	return tx.Model(&models.Event{}).Where("id = ?", s.event.ID).
+		Update("current_count", gorm.Expr("GREATEST(current_count + ?, 0)", delta)).Error
+}
+
+// scope limits a registration query to the slot.
+func (s *slot) scope(query *gorm.DB) *gorm.DB {
+	query = query.Where("event_id = ?", s.event.ID)
+	if s.start == nil {
+		return query.Where("occurrence_start IS NULL")
+	}
+	return query.Where("occurrence_start = ?", *s.start)
+}
+
+// lockEvent locks the event row. Every change to an event's registrations
+// takes this lock first, which serializes them per event and keeps
+// CurrentCount exact.
+func lockEvent(tx *gorm.DB, eventID uint) (*models.Event, error) {
+	var event models.Event
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, eventID).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	return &event, nil
+}
+
+// slotFor returns the slot of a locked event. For a series the occurrence
+// counter is created on first use and the capacity comes from the
+// occurrence's override when it has one.
+func slotFor(tx *gorm.DB, event *models.Event, start *time.Time) (*slot, error) {
+	s := &slot{event: event, capacity: event.Capacity}
+	if start == nil {
+		return s, nil
+	}
+
+	original := start.UTC()
+	s.start = &original
+	s.counter = &models.EventOccurrence{}
+	err := tx.Where(models.EventOccurrence{EventID: event.ID, OccurrenceStart: original}).
+		FirstOrCreate(s.counter).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var override models.EventOccurrenceOverride
+	err = tx.Where("event_id = ? AND occurrence_start = ?", event.ID, original).First(&override).Error
+	if err == nil && override.Capacity != nil {
+		s.capacity = *override.Capacity
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return s, nil
+}
+
+// take gives the registration a place and issues its ticket. The place is
+// confirmed at a free event; at a priced one it is held until HoldDuration
+// passes, and payment_service confirms it once the payment is captured.
+func take(tx *gorm.DB, s *slot, registration *models.EventRegistration) error {
+	now := time.Now()
+	if s.event.Price > 0 {
+		expiresAt := now.Add(HoldDuration())
+		registration.Status = models.RegistrationHeld
+		registration.HoldExpiresAt = &expiresAt
+	} else {
+		registration.Status = models.RegistrationConfirmed
+		registration.ConfirmedAt = &now
+	}
+	if err := tx.Save(registration).Error; err != nil {
+		return err
+	}
+
+	code := tickets.Code(registration.ID, s.event.ID)
+	registration.TicketCode = &code
+	if err := tx.Model(registration).Update("ticket_code", code).Error; err != nil {
+		return err
+	}
+	return s.adjustCount(tx, 1)
+}
+
+// promote gives places to waitlisted registrations in sign-up order while
+// the slot has room.
+func promote(tx *gorm.DB, s *slot) ([]models.EventRegistration, error) {
+	promoted := []models.EventRegistration{}
+	for s.hasRoom() {
+		var next models.EventRegistration
+		err := s.scope(tx.Clauses(clause.Locking{Strength: "UPDATE"})).
+			Where("status = ?", models.RegistrationWaitlisted).
+			Order("id").First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := take(tx, s, &next); err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, next)
+	}
+	return promoted, nil
+}
+
+// SetWaitlistPosition fills in the 1-based waitlist position within the
+// event or occurrence.
+func SetWaitlistPosition(tx *gorm.DB, registration *models.EventRegistration) error {
+	if registration.Status != models.RegistrationWaitlisted {
+		return nil
+	}
+	s := &slot{event: &models.Event{}, start: registration.OccurrenceStart}
+	s.event.ID = registration.EventID
+
+	var ahead int64
+	err := s.scope(tx.Model(&models.EventRegistration{})).
+		Where("status = ? AND id < ?", models.RegistrationWaitlisted, registration.ID).
+		Count(&ahead).Error
+	registration.WaitlistPosition = int(ahead) + 1
+	return err
+}
+
+// Register signs a user up for a published event that has not ended. A
+// series needs the original start of the chosen occurrence. The
+// registration takes a place while places are left, held until paid for
+// at a priced event, and is waitlisted after.
+func Register(eventID, userID uint, occurrenceStart *time.Time) (*models.EventRegistration, error) {
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, eventID)
+	if err != nil || !event.IsPublished {
+		return nil, ErrNotFound
+	}
+
+	endDate := event.EndDate
+	if event.RRule == "" {
+		occurrenceStart = nil
+	} else {
+		if occurrenceStart == nil {
+			return nil, ErrOccurrenceRequired
+		}
+		occurrence, err := occurrences.Find(tx, event, *occurrenceStart)
+		if err == occurrences.ErrNotOccurrence {
+			return nil, ErrNoOccurrence
+		}
+		if err != nil {
+			return nil, err
+		}
+		if occurrence.Cancelled {
+			return nil, ErrClosed
+		}
+		endDate = occurrence.EndDate
+	}
+	if !endDate.IsZero() && endDate.Before(time.Now()) {
+		return nil, ErrClosed
+	}
+
+	s, err := slotFor(tx, event, occurrenceStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	err = s.scope(tx.Model(&models.EventRegistration{})).
+		Where("user_id = ? AND status IN ?", userID,
+			[]string{models.RegistrationHeld, models.RegistrationConfirmed, models.RegistrationWaitlisted}).
+		Count(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrAlreadyRegistered
+	}
+
+	registration := &models.EventRegistration{
+		EventID:         eventID,
+		UserID:          userID,
+		Status:          models.RegistrationWaitlisted,
+		OccurrenceStart: s.start,
+	}
+	if err := tx.Create(registration).Error; err != nil {
+		return nil, err
+	}
+	if s.hasRoom() {
+		if err := take(tx, s, registration); err != nil {
+			return nil, err
+		}
+	} else if err := SetWaitlistPosition(tx, registration); err != nil {
+		return nil, err
+	}
+
+	return registration, tx.Commit().Error
+}
+
+// Cancel gives up a registration. Guests pass their user ID; admins pass
+// zero. A freed place goes to the head of the waitlist, and the promoted
+// registrations are returned.
+func Cancel(registrationID, userID uint) (*models.EventRegistration, []models.EventRegistration, error) {
+	query := db.DB.Where("id = ?", registrationID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var found models.EventRegistration
+	if err := query.First(&found).Error; err != nil {
+		return nil, nil, ErrNotFound
+	}
+
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, found.EventID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var registration models.EventRegistration
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&registration, registrationID).Error; err != nil {
+		return nil, nil, ErrNotFound
+	}
+	if registration.Status == models.RegistrationCancelled || registration.Status == models.RegistrationExpired ||
+		registration.CheckedInAt != nil {
+		return nil, nil, ErrInvalidState
+	}
+
+	hadPlace := registration.Status == models.RegistrationHeld || registration.Status == models.RegistrationConfirmed
+	now := time.Now()
+	registration.Status = models.RegistrationCancelled
+	registration.CancelledAt = &now
+	if err := tx.Save(&registration).Error; err != nil {
+		return nil, nil, err
+	}
+
+	promoted := []models.EventRegistration{}
+	if hadPlace {
+		s, err := slotFor(tx, event, registration.OccurrenceStart)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.adjustCount(tx, -1); err != nil {
+			return nil, nil, err
+		}
+		if promoted, err = promote(tx, s); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &registration, promoted, tx.Commit().Error
+}
+
+// ExpireHolds gives up the places held for payment that were not paid for
+// in time and passes them on to the waitlist.
+func ExpireHolds() (int, error) {
+	var found []models.EventRegistration
+	err := db.DB.Select("id", "event_id").
+		Where("status = ? AND hold_expires_at < ?", models.RegistrationHeld, time.Now()).
+		Find(&found).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, row := range found {
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			event, err := lockEvent(tx, row.EventID)
+			if err != nil {
+				return err
+			}
+			var registration models.EventRegistration
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&registration, row.ID).Error; err != nil {
+				return err
+			}
+			// Paid for or cancelled since the IDs were read.
+			if registration.Status != models.RegistrationHeld ||
+				registration.HoldExpiresAt == nil || registration.HoldExpiresAt.After(time.Now()) {
+				return nil
+			}
+
+			registration.Status = models.RegistrationExpired
+			if err := tx.Save(&registration).Error; err != nil {
+				return err
+			}
+			s, err := slotFor(tx, event, registration.OccurrenceStart)
+			if err != nil {
+				return err
+			}
+			if err := s.adjustCount(tx, -1); err != nil {
+				return err
+			}
+			if _, err := promote(tx, s); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// StartExpiry runs ExpireHolds in the background.
+func StartExpiry(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if count, err := ExpireHolds(); err != nil {
+				log.Printf("Failed to expire ticket holds: %v", err)
+			} else if count > 0 {
+				log.Printf("Expired %d ticket holds", count)
+			}
+		}
+	}()
+}
+
+// PromoteWaitlist fills places freed by a capacity increase, on the event
+// itself or on each occurrence of a series that has a waitlist.
+func PromoteWaitlist(eventID uint) ([]models.EventRegistration, error) {
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	event, err := lockEvent(tx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	starts := []*time.Time{nil}
+	if event.RRule != "" {
+		var waiting []time.Time
+		err := tx.Model(&models.EventRegistration{}).
+			Where("event_id = ? AND status = ? AND occurrence_start IS NOT NULL", eventID, models.RegistrationWaitlisted).
+			Distinct().Pluck("occurrence_start", &waiting).Error
+		if err != nil {
+			return nil, err
+		}
+		starts = starts[:0]
+		for i := range waiting {
+			starts = append(starts, &waiting[i])
+		}
+	}
+
+	promoted := []models.EventRegistration{}
+	for _, start := range starts {
+		s, err := slotFor(tx, event, start)
+		if err != nil {
+			return nil, err
+		}
+		list, err := promote(tx, s)
+		if err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, list...)
+	}
+	return promoted, tx.Commit().Error
+}
+
+// CheckIn validates a scanned ticket at the door of the admin's event and
+// marks it used. Only confirmed tickets pass; at a priced event a ticket
+// is confirmed once it is paid for.
+func CheckIn(eventID, adminID uint, code string) (*models.EventRegistration, error) {
+	registrationID, ticketEventID, err := tickets.Parse(code)
+	if err != nil || ticketEventID != eventID {
+		return nil, ErrInvalidTicket
+	}
+
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	var event models.Event
+	if err := tx.First(&event, eventID).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	if event.AdminID != adminID {
+		return nil, ErrNotOwner
+	}
+
+	var registration models.EventRegistration
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND event_id = ?", registrationID, eventID).First(&registration).Error
+	if err != nil || registration.Status != models.RegistrationConfirmed {
+		return nil, ErrInvalidTicket
+	}
+	if registration.CheckedInAt != nil {
+		return &registration, ErrAlreadyCheckedIn
+	}
+	now := time.Now()
+	registration.CheckedInAt = &now
+	registration.CheckedInBy = &adminID
+	if err := tx.Save(&registration).Error; err != nil {
+		return nil, err
+	}
+
+	return &registration, tx.Commit().Error
+}
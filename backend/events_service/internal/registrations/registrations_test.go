@@ -0,0 +1,265 @@
+package registrations
+
+import (
+	"diplomaPorject/backend/events_service/internal/models"
+	"diplomaPorject/backend/events_service/utils/db"
+	"diplomaPorject/backend/events_service/utils/testdb"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useTestDB points db.DB at a schema of its own. Registrations rely on row
+// locks, so they are tested against PostgreSQL only.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	conn := testdb.Open(t)
+	err := conn.AutoMigrate(&models.Event{}, &models.EventRegistration{},
+		&models.EventOccurrenceOverride{}, &models.EventOccurrence{}, &models.EventImage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+}
+
+// seedEvent adds a published event of admin 7 next week.
+func seedEvent(t *testing.T, capacity int, price float64) models.Event {
+	t.Helper()
+	start := time.Now().Add(7 * 24 * time.Hour)
+	event := models.Event{Title: "Concert", StartDate: start, EndDate: start.Add(3 * time.Hour),
+		Capacity: capacity, Price: price, Currency: "KZT", IsPublished: true, AdminID: 7}
+	if err := db.DB.Create(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func register(t *testing.T, eventID, userID uint) *models.EventRegistration {
+	t.Helper()
+	registration, err := Register(eventID, userID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registration
+}
+
+func reload(t *testing.T, id uint) models.EventRegistration {
+	t.Helper()
+	var registration models.EventRegistration
+	if err := db.DB.First(&registration, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return registration
+}
+
+func currentCount(t *testing.T, eventID uint) int {
+	t.Helper()
+	var event models.Event
+	if err := db.DB.First(&event, eventID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return event.CurrentCount
+}
+
+// pay confirms a held place the way payment_service does once the payment
+// is captured.
+func pay(t *testing.T, id uint) {
+	t.Helper()
+	result := db.DB.Exec(`UPDATE event_registrations
+		SET status = 'confirmed', confirmed_at = NOW(), hold_expires_at = NULL,
+			payment_status = 'captured', updated_at = NOW()
+		WHERE id = ? AND status = 'held' AND hold_expires_at > NOW()`, id)
+	if result.Error != nil || result.RowsAffected != 1 {
+		t.Fatalf("paying registration %d: %v, %d rows", id, result.Error, result.RowsAffected)
+	}
+}
+
+// expire moves a hold into the past.
+func expire(t *testing.T, id uint) {
+	t.Helper()
+	err := db.DB.Model(&models.EventRegistration{}).Where("id = ?", id).
+		UpdateColumn("hold_expires_at", time.Now().Add(-time.Minute)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterTakesThePlace(t *testing.T) {
+	tests := []struct {
+		name       string
+		price      float64
+		wantStatus string
+		wantHold   bool
+	}{
+		{"free event", 0, models.RegistrationConfirmed, false},
+		{"priced event", 5000, models.RegistrationHeld, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDB(t)
+			event := seedEvent(t, 1, tt.price)
+
+			first := register(t, event.ID, 1)
+			if first.Status != tt.wantStatus || first.TicketCode == nil {
+				t.Fatalf("first registration = %s with ticket %v, want %s with a ticket",
+					first.Status, first.TicketCode, tt.wantStatus)
+			}
+			if tt.wantHold != (first.HoldExpiresAt != nil) {
+				t.Errorf("hold expires at %v, want a hold %v", first.HoldExpiresAt, tt.wantHold)
+			}
+			if tt.wantHold && first.HoldExpiresAt.Before(time.Now().Add(HoldDuration()-time.Minute)) {
+				t.Errorf("hold expires at %v, want about %s from now", first.HoldExpiresAt, HoldDuration())
+			}
+			if tt.wantHold != (first.ConfirmedAt == nil) {
+				t.Errorf("confirmed at %v", first.ConfirmedAt)
+			}
+
+			second := register(t, event.ID, 2)
+			if second.Status != models.RegistrationWaitlisted || second.WaitlistPosition != 1 {
+				t.Errorf("second registration = %s at position %d, want waitlisted first",
+					second.Status, second.WaitlistPosition)
+			}
+			if _, err := Register(event.ID, 1, nil); err != ErrAlreadyRegistered {
+				t.Errorf("registering again: err = %v, want ErrAlreadyRegistered", err)
+			}
+			if count := currentCount(t, event.ID); count != 1 {
+				t.Errorf("current count = %d, want 1", count)
+			}
+		})
+	}
+}
+
+// However many users race for the last place, exactly one gets it and the
+// others are waitlisted.
+func TestConcurrentRegistrationsForTheLastPlace(t *testing.T) {
+	for _, price := range []float64{0, 5000} {
+		t.Run(fmt.Sprintf("price %v", price), func(t *testing.T) {
+			useTestDB(t)
+			event := seedEvent(t, 3, price)
+			register(t, event.ID, 100)
+			register(t, event.ID, 101)
+
+			const users = 10
+			var wg sync.WaitGroup
+			results := make([]*models.EventRegistration, users)
+			errs := make([]error, users)
+			for i := 0; i < users; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i], errs[i] = Register(event.ID, uint(i+1), nil)
+				}(i)
+			}
+			wg.Wait()
+
+			placed, waitlisted := 0, 0
+			for i, registration := range results {
+				if errs[i] != nil {
+					t.Fatalf("user %d: %v", i+1, errs[i])
+				}
+				switch registration.Status {
+				case models.RegistrationWaitlisted:
+					waitlisted++
+				default:
+					placed++
+				}
+			}
+			if placed != 1 || waitlisted != users-1 {
+				t.Errorf("%d got the last place and %d were waitlisted, want 1 and %d", placed, waitlisted, users-1)
+			}
+			if count := currentCount(t, event.ID); count != 3 {
+				t.Errorf("current count = %d, want 3", count)
+			}
+		})
+	}
+}
+
+func TestExpireHoldsPromotesTheWaitlist(t *testing.T) {
+	useTestDB(t)
+	event := seedEvent(t, 1, 5000)
+	first := register(t, event.ID, 1)
+	second := register(t, event.ID, 2)
+
+	if expired, err := ExpireHolds(); err != nil || expired != 0 {
+		t.Fatalf("ExpireHolds before the hold ran out = %d, %v", expired, err)
+	}
+
+	expire(t, first.ID)
+	if expired, err := ExpireHolds(); err != nil || expired != 1 {
+		t.Fatalf("ExpireHolds = %d, %v, want 1", expired, err)
+	}
+	if got := reload(t, first.ID); got.Status != models.RegistrationExpired {
+		t.Errorf("lapsed registration = %s, want expired", got.Status)
+	}
+	got := reload(t, second.ID)
+	if got.Status != models.RegistrationHeld || got.HoldExpiresAt == nil || !got.HoldExpiresAt.After(time.Now()) {
+		t.Errorf("waitlisted registration = %s until %v, want a fresh hold", got.Status, got.HoldExpiresAt)
+	}
+	if count := currentCount(t, event.ID); count != 1 {
+		t.Errorf("current count = %d, want 1", count)
+	}
+
+	// The expired registration no longer blocks signing up again.
+	if again := register(t, event.ID, 1); again.Status != models.RegistrationWaitlisted {
+		t.Errorf("registering again = %s, want waitlisted", again.Status)
+	}
+}
+
+// A paid place is confirmed and no longer expires.
+func TestExpireHoldsKeepsPaidPlaces(t *testing.T) {
+	useTestDB(t)
+	event := seedEvent(t, 1, 5000)
+	first := register(t, event.ID, 1)
+	pay(t, first.ID)
+
+	if expired, err := ExpireHolds(); err != nil || expired != 0 {
+		t.Fatalf("ExpireHolds = %d, %v, want 0", expired, err)
+	}
+	if got := reload(t, first.ID); got.Status != models.RegistrationConfirmed {
+		t.Errorf("paid registration = %s, want confirmed", got.Status)
+	}
+}
+
+func TestCancelHeldPlace(t *testing.T) {
+	useTestDB(t)
+	event := seedEvent(t, 1, 5000)
+	first := register(t, event.ID, 1)
+	second := register(t, event.ID, 2)
+
+	_, promoted, err := Cancel(first.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promoted) != 1 || promoted[0].ID != second.ID || promoted[0].Status != models.RegistrationHeld {
+		t.Errorf("promoted = %+v, want the waitlisted registration held", promoted)
+	}
+	if count := currentCount(t, event.ID); count != 1 {
+		t.Errorf("current count = %d, want 1", count)
+	}
+}
+
+func TestCheckInNeedsAConfirmedTicket(t *testing.T) {
+	useTestDB(t)
+	event := seedEvent(t, 10, 5000)
+	registration := register(t, event.ID, 1)
+
+	if _, err := CheckIn(event.ID, event.AdminID, *registration.TicketCode); err != ErrInvalidTicket {
+		t.Fatalf("checking in a held ticket: err = %v, want ErrInvalidTicket", err)
+	}
+
+	pay(t, registration.ID)
+	checkedIn, err := CheckIn(event.ID, event.AdminID, *registration.TicketCode)
+	if err != nil || checkedIn.CheckedInAt == nil {
+		t.Fatalf("checking in a paid ticket = %+v, %v", checkedIn, err)
+	}
+	if _, err := CheckIn(event.ID, event.AdminID, *registration.TicketCode); err != ErrAlreadyCheckedIn {
+		t.Errorf("checking in twice: err = %v, want ErrAlreadyCheckedIn", err)
+	}
+	if _, err := CheckIn(event.ID, event.AdminID+1, *registration.TicketCode); err != ErrNotOwner {
+		t.Errorf("checking in at another admin's event: err = %v, want ErrNotOwner", err)
+	}
+}
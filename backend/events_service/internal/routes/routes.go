@@ -4,19 +4,10 @@ import (
 	"diplomaPorject/backend/events_service/internal/controllers"
 	"diplomaPorject/backend/events_service/internal/middleware"
 	"github.com/gorilla/mux"
-	"log"
-	"net/http"
 )
 
 func SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
-	uploadDir := "/app/uploads/events"
-
-	log.Printf("Serving static files from: %s", uploadDir)
-
-	r.PathPrefix("/uploads/events/").Handler(
-		http.StripPrefix("/uploads/events/", http.FileServer(http.Dir(uploadDir))),
-	).Methods("GET")
 
 	admin := r.PathPrefix("/admin/events").Subrouter()
 	admin.Use(middleware.AdminAuthMiddleware)
@@ -26,9 +17,28 @@ func SetupRoutes() *mux.Router {
 	admin.HandleFunc("/{id}", controllers.DeleteEvent).Methods("DELETE")
 	admin.HandleFunc("/{id}/publish", controllers.PublishEvent).Methods("POST")
 	admin.HandleFunc("/{id}/unpublish", controllers.UnpublishEvent).Methods("POST")
+	admin.HandleFunc("/{id}/registrations", controllers.ListEventRegistrations).Methods("GET")
+	admin.HandleFunc("/{id}/check-in", controllers.CheckInTicket).Methods("POST")
+	admin.HandleFunc("/{id}/images", controllers.UploadEventImages).Methods("POST")
+	admin.HandleFunc("/{id}/images/order", controllers.ReorderEventImages).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{image_id:[0-9]+}", controllers.UpdateEventImage).Methods("PUT")
+	admin.HandleFunc("/{id}/images/{image_id:[0-9]+}", controllers.DeleteEventImage).Methods("DELETE")
+	admin.HandleFunc("/{id}/occurrences", controllers.ListAdminEventOccurrences).Methods("GET")
+	admin.HandleFunc("/{id}/occurrences", controllers.SaveOccurrenceOverride).Methods("PUT")
+	admin.HandleFunc("/{id}/occurrences", controllers.DeleteOccurrenceOverride).Methods("DELETE")
 
 	r.HandleFunc("/events", controllers.ListPublishedEvents).Methods("GET")
+	r.HandleFunc("/events.ics", controllers.EventsCalendar).Methods("GET")
 	r.HandleFunc("/events/{id}", controllers.GetEvent).Methods("GET")
+	r.HandleFunc("/events/{id}/occurrences", controllers.ListEventOccurrences).Methods("GET")
+	r.HandleFunc("/events/{id}/images", controllers.ListEventImages).Methods("GET")
+
+	user := r.PathPrefix("/user/events").Subrouter()
+	user.Use(middleware.AuthMiddleware)
+	user.HandleFunc("/{id:[0-9]+}/register", controllers.RegisterForEvent).Methods("POST")
+	user.HandleFunc("/registrations", controllers.ListMyRegistrations).Methods("GET")
+	user.HandleFunc("/registrations/{registration_id:[0-9]+}", controllers.GetMyRegistration).Methods("GET")
+	user.HandleFunc("/registrations/{registration_id:[0-9]+}/cancel", controllers.CancelMyRegistration).Methods("POST")
 
 	return r
 }
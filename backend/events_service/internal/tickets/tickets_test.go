@@ -0,0 +1,83 @@
+package tickets
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useSecret makes the next codes sign with value.
+func useSecret(t *testing.T, value string) {
+	t.Helper()
+	t.Setenv("TICKET_SECRET", value)
+	secretOnce = sync.Once{}
+	t.Cleanup(func() { secretOnce = sync.Once{} })
+}
+
+func TestCodeRoundTrip(t *testing.T) {
+	useSecret(t, "test-secret")
+	alphanumeric := regexp.MustCompile(`^[0-9A-Z-]+$`)
+
+	for _, ids := range [][2]uint{{1, 1}, {12345, 67}, {4294967295, 4294967295}} {
+		code := Code(ids[0], ids[1])
+		if !alphanumeric.MatchString(code) {
+			t.Errorf("Code(%d, %d) = %s does not fit QR alphanumeric mode", ids[0], ids[1], code)
+		}
+		registrationID, eventID, err := Parse(code)
+		if err != nil || registrationID != ids[0] || eventID != ids[1] {
+			t.Errorf("Parse(%s) = %d, %d, %v", code, registrationID, eventID, err)
+		}
+		// Scanners and people may hand codes back in lower case or
+		// with surrounding space.
+		if _, _, err := Parse(" " + strings.ToLower(code) + "\n"); err != nil {
+			t.Errorf("Parse of lower-case %s = %v", code, err)
+		}
+	}
+}
+
+func TestParseRejectsTampering(t *testing.T) {
+	useSecret(t, "test-secret")
+	code := Code(12345, 67)
+	parts := strings.Split(code, "-")
+	flip := func(s string) string {
+		if s[0] == 'A' {
+			return "B" + s[1:]
+		}
+		return "A" + s[1:]
+	}
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"other registration", strings.Join([]string{parts[0], "9IY", parts[2], parts[3]}, "-")},
+		{"other event", strings.Join([]string{parts[0], parts[1], "1W", parts[3]}, "-")},
+		{"changed signature", strings.Join([]string{parts[0], parts[1], parts[2], flip(parts[3])}, "-")},
+		{"truncated signature", code[:len(code)-1]},
+		{"missing signature", strings.Join(parts[:3], "-")},
+		{"other prefix", "EVT2" + code[len(prefix):]},
+		{"extra part", code + "-X"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Parse(tt.code); err != ErrInvalidCode {
+				t.Fatalf("Parse(%q) = %v, want ErrInvalidCode", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestCodesDependOnTheSecret(t *testing.T) {
+	useSecret(t, "first-secret")
+	code := Code(1, 2)
+
+	useSecret(t, "second-secret")
+	if _, _, err := Parse(code); err != ErrInvalidCode {
+		t.Fatalf("a code signed with another secret parsed: %v", err)
+	}
+	if Code(1, 2) == code {
+		t.Fatal("codes do not change with the secret")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
+	"time"
 )
 
 var DB *gorm.DB
@@ -23,10 +24,112 @@ func ConnectDB() {
 		log.Fatal("Database connection is nil after initialization!")
 	}
 
-	err = DB.AutoMigrate(&models.Event{})
+	// Registrations made before places at priced events were held until
+	// paid for are moved to a hold once, when the column is added.
+	holding := DB.Migrator().HasColumn(&models.EventRegistration{}, "hold_expires_at")
+
+	err = DB.AutoMigrate(&models.Event{}, &models.EventRegistration{},
+		&models.EventOccurrenceOverride{}, &models.EventOccurrence{}, &models.EventImage{})
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// One active registration per user and event, or per user and occurrence
+	// for a series; cancelled and expired ones are kept. The earlier indexes
+	// predate series and holds and are replaced.
+	indexes := []string{
+		`DROP INDEX IF EXISTS idx_event_registrations_active`,
+		`DROP INDEX IF EXISTS idx_event_registrations_active_single`,
+		`DROP INDEX IF EXISTS idx_event_registrations_active_occurrence`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_event_registrations_open_single
+			ON event_registrations (event_id, user_id)
+			WHERE status IN ('held', 'confirmed', 'waitlisted') AND deleted_at IS NULL AND occurrence_start IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_event_registrations_open_occurrence
+			ON event_registrations (event_id, user_id, occurrence_start)
+			WHERE status IN ('held', 'confirmed', 'waitlisted') AND deleted_at IS NULL AND occurrence_start IS NOT NULL`,
+	}
+	for _, statement := range indexes {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to create registration index: %v", err)
+		}
+	}
+
+	if !holding {
+		if err := holdUnpaidTickets(); err != nil {
+			log.Fatalf("Failed to hold unpaid tickets: %v", err)
+		}
+	}
+
+	if err := migrateImageURLs(); err != nil {
+		log.Printf("Failed to move image URLs into galleries: %v", err)
+	}
+
+	for _, statement := range geoIndexStatements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to create geo index: %v", err)
+		}
+	}
+	if err := backfillGeoColumns(); err != nil {
+		log.Printf("Failed to backfill coordinates: %v", err)
+	}
+
 	log.Println("Connected to PostgreSQL database successfully!")
 }
+
+// holdUnpaidTickets holds the confirmed but unpaid places at priced events,
+// which used to pass check-in once paid for, for a day in which their
+// owners can still pay.
+func holdUnpaidTickets() error {
+	return DB.Exec(`UPDATE event_registrations reg
+		SET status = 'held', hold_expires_at = ?, confirmed_at = NULL, updated_at = NOW()
+		FROM events e
+		WHERE e.id = reg.event_id AND e.price > 0
+			AND reg.status = 'confirmed' AND reg.checked_in_at IS NULL AND reg.deleted_at IS NULL
+			AND COALESCE(reg.payment_status, '') NOT IN ('captured', 'partially_refunded', 'refunded')`,
+		time.Now().Add(24*time.Hour)).Error
+}
+
+// migrateImageURLs turns the single image of events saved before galleries
+// existed into the cover of their gallery.
+func migrateImageURLs() error {
+	return DB.Exec(`INSERT INTO event_images (created_at, updated_at, event_id, url, position, is_cover)
+		SELECT NOW(), NOW(), e.id, e.image_url, 0, TRUE
+		FROM events e
+		WHERE e.image_url <> '' AND e.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM event_images i WHERE i.event_id = e.id)`).Error
+}
+
+// geoIndexStatements create the indexes behind radius queries. The geohash
+// index uses text_pattern_ops so that prefix LIKE lookups can use it.
+var geoIndexStatements = []string{
+	`CREATE INDEX IF NOT EXISTS idx_events_geohash ON events (geohash text_pattern_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_coordinates ON events (latitude, longitude)`,
+}
+
+// backfillGeoColumns fills the coordinate columns of rows saved before they
+// existed. New writes keep them in sync through the model's BeforeSave hook.
+func backfillGeoColumns() error {
+	var events []models.Event
+	err := DB.Unscoped().Select("id", "location").
+		Where("location <> '' AND (geohash IS NULL OR geohash = '')").
+		Find(&events).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range events {
+		lat, lng, geohash := models.GeoColumns(row.Location)
+		if geohash == "" {
+			continue
+		}
+		err := DB.Unscoped().Model(&models.Event{}).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{
+			"latitude":  lat,
+			"longitude": lng,
+			"geohash":   geohash,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
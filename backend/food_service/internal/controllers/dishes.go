@@ -89,10 +89,10 @@ func (c *FoodController) AddDish(w http.ResponseWriter, r *http.Request) {
 
 	files := r.MultipartForm.File["images"]
 	if len(files) > 0 {
-		imageURLs, err := uploadImages(files, "dishes")
+		imageURLs, err := uploadImages(files)
 		if err != nil {
 			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 			return
 		}
 
@@ -209,11 +209,11 @@ func (c *FoodController) UpdateDish(w http.ResponseWriter, r *http.Request) {
 
 	files := r.MultipartForm.File["images"]
 	if len(files) > 0 {
-		imageURLs, err := uploadImages(files, "dishes")
+		imageURLs, err := uploadImages(files)
 		if err != nil {
 			log.Printf("Error uploading images: %v", err)
 			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 			return
 		}
 
@@ -345,10 +345,10 @@ func (c *FoodController) UploadDishImages(w http.ResponseWriter, r *http.Request
 
 	files := r.MultipartForm.File["images"]
 	if len(files) > 0 {
-		imageURLs, err := uploadImages(files, "dishes")
+		imageURLs, err := uploadImages(files)
 		if err != nil {
 			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 			return
 		}
 
@@ -1,63 +1,35 @@
 package controllers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"food_service/internal/models"
 	"food_service/utils/db"
-	"io"
 	"log"
 	"math"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
-	"sort"
+	"net/url"
 	"strconv"
-	"strings"
 
 	"github.com/gorilla/mux"
 )
 
 type FoodController struct{}
 
-func generateRandomFilename(originalFilename string) string {
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes) + filepath.Ext(originalFilename)
-}
-
-func uploadImages(files []*multipart.FileHeader, subdir string) ([]string, error) {
+func uploadImages(files []*multipart.FileHeader) ([]string, error) {
 	var imageURLs []string
-	uploadDir := fmt.Sprintf("/app/uploads/food/%s", subdir)
-
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create upload directory: %v", err)
-	}
-
 	for _, fileHeader := range files {
 		file, err := fileHeader.Open()
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file: %v", err)
 		}
-		defer file.Close()
-
-		randomFilename := generateRandomFilename(fileHeader.Filename)
-		filePath := filepath.Join(uploadDir, randomFilename)
-
-		dst, err := os.Create(filePath)
+		url, err := storeMedia(file, fileHeader.Filename)
+		file.Close()
 		if err != nil {
-			return nil, fmt.Errorf("failed to create file: %v", err)
-		}
-		defer dst.Close()
-
-		if _, err := io.Copy(dst, file); err != nil {
-			return nil, fmt.Errorf("failed to save file: %v", err)
+			return nil, err
 		}
-
-		imageURLs = append(imageURLs, fmt.Sprintf("/uploads/food/%s/%s", subdir, randomFilename))
+		imageURLs = append(imageURLs, url)
 	}
 	return imageURLs, nil
 }
@@ -102,6 +74,18 @@ func (c *FoodController) CreatePlace(w http.ResponseWriter, r *http.Request) {
 		AdminID:     adminID,
 	}
 
+	if check := checkAddress(place.Address, place.City, place.Location); check != nil {
+		place.Address = check.Address
+		place.Location = check.Location
+		place.FormattedAddress = check.FormattedAddress
+		place.LocationMismatch = check.Mismatch
+	}
+
+	if cityID, cityName := resolveCity(place.City, place.Location); cityID != nil {
+		place.CityID = cityID
+		place.City = cityName
+	}
+
 	tx := db.DB.Begin()
 
 	if err := tx.Create(&place).Error; err != nil {
@@ -133,10 +117,10 @@ func (c *FoodController) CreatePlace(w http.ResponseWriter, r *http.Request) {
 
 	files := r.MultipartForm.File["images"]
 	if len(files) > 0 {
-		imageURLs, err := uploadImages(files, "places")
+		imageURLs, err := uploadImages(files)
 		if err != nil {
 			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 			return
 		}
 
@@ -173,7 +157,7 @@ func (c *FoodController) GetPlace(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	var place models.Place
-	if err := db.DB.Preload("Images").Preload("Cuisines").Preload("Dishes").Preload("Dishes.Images").Preload("Reviews").Preload("Reviews.Images").First(&place, id).Error; err != nil {
+	if err := db.DB.Preload("Images").Preload("Cuisines").Preload("Dishes").Preload("Dishes.Images").First(&place, id).Error; err != nil {
 		log.Printf("Error fetching place with ID %s: %v", id, err)
 		http.Error(w, "Place not found", http.StatusNotFound)
 		return
@@ -182,8 +166,12 @@ func (c *FoodController) GetPlace(w http.ResponseWriter, r *http.Request) {
 	if place.Dishes == nil {
 		place.Dishes = []models.Dish{}
 	}
-	if place.Reviews == nil {
-		place.Reviews = []models.FoodReview{}
+	place.AverageRating = placeRatings([]uint{place.ID})[place.ID]
+	place.Reviews = []models.FoodReview{}
+	if result, _, err := fetchPlaceReviews(r, id, url.Values{"page_size": {strconv.Itoa(placeReviewsLimit)}}); err == nil {
+		place.Reviews = foodReviews(result.Reviews)
+	} else {
+		log.Printf("Error fetching reviews of place %s: %v", id, err)
 	}
 	if place.Cuisines == nil {
 		place.Cuisines = []models.Cuisine{}
@@ -219,6 +207,8 @@ func (c *FoodController) UpdatePlace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	previousCity, previousAddress, previousLocation := place.City, place.Address, place.Location
+
 	if name := r.FormValue("name"); name != "" {
 		place.Name = name
 	}
@@ -247,6 +237,25 @@ func (c *FoodController) UpdatePlace(w http.ResponseWriter, r *http.Request) {
 		place.Location = location
 	}
 
+	if place.Address != previousAddress || place.Location != previousLocation {
+		place.FormattedAddress = ""
+		place.LocationMismatch = false
+		if check := checkAddress(place.Address, place.City, place.Location); check != nil {
+			place.Address = check.Address
+			place.Location = check.Location
+			place.FormattedAddress = check.FormattedAddress
+			place.LocationMismatch = check.Mismatch
+		}
+	}
+
+	if place.City != previousCity || place.Location != previousLocation {
+		place.CityID = nil
+		if cityID, cityName := resolveCity(place.City, place.Location); cityID != nil {
+			place.CityID = cityID
+			place.City = cityName
+		}
+	}
+
 	tx := db.DB.Begin()
 
 	if cuisineIDs := r.Form["cuisine_ids"]; len(cuisineIDs) > 0 {
@@ -287,10 +296,10 @@ func (c *FoodController) UpdatePlace(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		imageURLs, err := uploadImages(files, "places")
+		imageURLs, err := uploadImages(files)
 		if err != nil {
 			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), uploadErrorStatus(err))
 			return
 		}
 
@@ -358,9 +367,26 @@ func (c *FoodController) DeletePlace(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *FoodController) ListPlaces(w http.ResponseWriter, r *http.Request) {
+	nearby, errMsg := parseNearby(r)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
 	query := db.DB.Preload("Images").Preload("Cuisines")
+	rated := ratingsAvailable()
+	if rated {
+		query = joinRatings(query)
+	}
 
-	if city := r.URL.Query().Get("city"); city != "" {
+	if cityIDParam := r.URL.Query().Get("city_id"); cityIDParam != "" {
+		cityID, err := strconv.ParseUint(cityIDParam, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid city_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("city_id = ?", cityID)
+	} else if city := r.URL.Query().Get("city"); city != "" {
 		query = query.Where("city LIKE ?", "%"+city+"%")
 	}
 
@@ -376,8 +402,13 @@ func (c *FoodController) ListPlaces(w http.ResponseWriter, r *http.Request) {
 
 	if minRating := r.URL.Query().Get("min_rating"); minRating != "" {
 		rating, err := strconv.ParseFloat(minRating, 64)
-		if err == nil {
-			query = query.Where("average_rating >= ?", rating)
+		if err == nil && rating > 0 {
+			if rated {
+				query = query.Where("COALESCE(review_stats.average, 0) >= ?", rating)
+			} else {
+				// Without stats no place has been rated yet.
+				query = query.Where("FALSE")
+			}
 		}
 	}
 
@@ -385,61 +416,6 @@ func (c *FoodController) ListPlaces(w http.ResponseWriter, r *http.Request) {
 		query = query.Where("price_range = ?", priceRange)
 	}
 
-	var places []models.Place
-	if lat := r.URL.Query().Get("lat"); lat != "" {
-		if lng := r.URL.Query().Get("lng"); lng != "" {
-			latFloat, latErr := strconv.ParseFloat(lat, 64)
-			lngFloat, lngErr := strconv.ParseFloat(lng, 64)
-			if latErr == nil && lngErr == nil {
-				maxDistance := 10.0
-				if distance := r.URL.Query().Get("distance"); distance != "" {
-					if distFloat, err := strconv.ParseFloat(distance, 64); err == nil {
-						maxDistance = distFloat
-					}
-				}
-
-				var placesWithLocation []models.Place
-				if err := query.Where("location IS NOT NULL AND location != ''").Find(&placesWithLocation).Error; err != nil {
-					http.Error(w, "Failed to fetch places", http.StatusInternalServerError)
-					return
-				}
-
-				var placesWithDistance []models.PlaceWithDistance
-				for _, place := range placesWithLocation {
-					parts := strings.Split(place.Location, ",")
-					if len(parts) != 2 {
-						continue
-					}
-					placeLat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
-					placeLng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
-					if err1 != nil || err2 != nil {
-						continue
-					}
-
-					distance := calculateDistance(latFloat, lngFloat, placeLat, placeLng)
-					if distance <= maxDistance {
-						placesWithDistance = append(placesWithDistance, models.PlaceWithDistance{
-							Place:    place,
-							Distance: math.Round(distance*10) / 10,
-						})
-					}
-				}
-
-				sort.Slice(placesWithDistance, func(i, j int) bool {
-					return placesWithDistance[i].Distance < placesWithDistance[j].Distance
-				})
-
-				for _, pwd := range placesWithDistance {
-					places = append(places, pwd.Place)
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(places)
-				return
-			}
-		}
-	}
-
 	page := 1
 	pageSize := 20
 	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
@@ -453,20 +429,79 @@ func (c *FoodController) ListPlaces(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if nearby != nil {
+		rows, total, err := nearby.page(query.Model(&models.Place{}).Where("places.is_published = ?", true), "places", page, pageSize)
+		if err != nil {
+			http.Error(w, "Failed to fetch places", http.StatusInternalServerError)
+			return
+		}
+
+		ids := make([]uint, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+
+		var found []models.Place
+		if len(ids) > 0 {
+			if err := db.DB.Preload("Images").Preload("Cuisines").Where("id IN ?", ids).Find(&found).Error; err != nil {
+				http.Error(w, "Failed to fetch places", http.StatusInternalServerError)
+				return
+			}
+		}
+		byID := make(map[uint]models.Place, len(found))
+		fillRatings(found)
+		for _, place := range found {
+			byID[place.ID] = place
+		}
+
+		placesWithDistance := []models.PlaceWithDistance{}
+		for _, row := range rows {
+			if place, ok := byID[row.ID]; ok {
+				placesWithDistance = append(placesWithDistance, models.PlaceWithDistance{
+					Place:    place,
+					Distance: math.Round(row.Distance*10) / 10,
+				})
+			}
+		}
+
+		response := map[string]interface{}{
+			"places": placesWithDistance,
+			"pagination": map[string]interface{}{
+				"page":        page,
+				"page_size":   pageSize,
+				"total":       total,
+				"total_pages": int(math.Ceil(float64(total) / float64(pageSize))),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	var places []models.Place
 	var totalCount int64
 	query.Model(&models.Place{}).Where("is_published = ?", true).Count(&totalCount)
 
 	offset := (page - 1) * pageSize
 	query = query.Offset(offset).Limit(pageSize)
 
-	query = query.Order("average_rating DESC, name ASC")
+	// Places rank by the Bayesian score review-service keeps in its stats,
+	// so one 5-star review does not put a place first. Unrated places come
+	// last.
+	if rated {
+		query = query.Order("COALESCE(review_stats.score, 0) DESC, places.name ASC")
+	} else {
+		query = query.Order("places.name ASC")
+	}
 
-	query = query.Where("is_published = ?", true)
+	query = query.Where("places.is_published = ?", true)
 
 	if err := query.Find(&places).Error; err != nil {
 		http.Error(w, "Failed to fetch places", http.StatusInternalServerError)
 		return
 	}
+	fillRatings(places)
 
 	response := map[string]interface{}{
 		"places": places,
@@ -584,6 +619,7 @@ func (c *FoodController) ListAdminPlaces(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Failed to fetch places", http.StatusInternalServerError)
 		return
 	}
+	fillRatings(places)
 
 	response := map[string]interface{}{
 		"places": places,
@@ -609,12 +645,16 @@ func (c *FoodController) SearchPlaces(w http.ResponseWriter, r *http.Request) {
 	var places []models.Place
 	searchQuery := "%" + query + "%"
 
-	db.DB.Preload("Images").Preload("Cuisines").
-		Where("is_published = ? AND (name LIKE ? OR description LIKE ? OR city LIKE ?)",
-			true, searchQuery, searchQuery, searchQuery).
-		Order("average_rating DESC").
-		Limit(20).
-		Find(&places)
+	search := db.DB.Preload("Images").Preload("Cuisines").
+		Where("places.is_published = ? AND (places.name LIKE ? OR places.description LIKE ? OR places.city LIKE ?)",
+			true, searchQuery, searchQuery, searchQuery)
+	if ratingsAvailable() {
+		search = joinRatings(search).Order("COALESCE(review_stats.score, 0) DESC, places.name ASC")
+	} else {
+		search = search.Order("places.name ASC")
+	}
+	search.Limit(20).Find(&places)
+	fillRatings(places)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(places)
@@ -636,13 +676,16 @@ func (c *FoodController) SearchDishes(w http.ResponseWriter, r *http.Request) {
 
 	var results []DishWithPlace
 
-	rows, err := db.DB.Table("dishes").
+	search := db.DB.Table("dishes").
 		Select("dishes.*, places.id as place_id, places.name as place_name, places.city as place_city, places.address as place_address").
 		Joins("JOIN places ON dishes.place_id = places.id").
-		Where("dishes.name LIKE ? AND places.is_published = ?", searchQuery, true).
-		Order("places.average_rating DESC").
-		Limit(50).
-		Rows()
+		Where("dishes.name LIKE ? AND places.is_published = ?", searchQuery, true)
+	if ratingsAvailable() {
+		search = joinRatings(search).Order("COALESCE(review_stats.score, 0) DESC, places.name ASC")
+	} else {
+		search = search.Order("places.name ASC")
+	}
+	rows, err := search.Limit(50).Rows()
 
 	if err != nil {
 		http.Error(w, "Failed to search dishes", http.StatusInternalServerError)
@@ -714,25 +757,6 @@ func getUserProfileInfo(userID uint) (string, string, error) {
 	return profileData.Username, profileData.ProfileImg, nil
 }
 
-func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
-	const R = 6371
-
-	lat1Rad := lat1 * math.Pi / 180
-	lon1Rad := lon1 * math.Pi / 180
-	lat2Rad := lat2 * math.Pi / 180
-	lon2Rad := lon2 * math.Pi / 180
-
-	dLat := lat2Rad - lat1Rad
-	dLon := lon2Rad - lon1Rad
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1Rad)*math.Cos(lat2Rad)*
-			math.Sin(dLon/2)*math.Sin(dLon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	distance := R * c
-
-	return distance
-}
-
 func getUserID(r *http.Request) (uint, error) {
 	userIDStr := r.Header.Get("X-User-ID")
 	if userIDStr != "" {
@@ -755,35 +779,3 @@ func getUserID(r *http.Request) (uint, error) {
 
 	return userID, nil
 }
-
-func updateAverageRating(placeID uint) error {
-	var count int64
-	var sum float64
-
-	err := db.DB.Model(&models.FoodReview{}).
-		Where("place_id = ?", placeID).
-		Count(&count).Error
-	if err != nil {
-		return err
-	}
-
-	if count == 0 {
-		return db.DB.Model(&models.Place{}).
-			Where("id = ?", placeID).
-			Update("average_rating", 0).Error
-	}
-
-	err = db.DB.Model(&models.FoodReview{}).
-		Where("place_id = ?", placeID).
-		Select("COALESCE(SUM(rating), 0)").
-		Scan(&sum).Error
-	if err != nil {
-		return err
-	}
-
-	average := math.Round((sum/float64(count))*10) / 10
-
-	return db.DB.Model(&models.Place{}).
-		Where("id = ?", placeID).
-		Update("average_rating", average).Error
-}
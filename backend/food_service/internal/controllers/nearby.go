@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"food_service/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultNearbyRadiusKm = 10.0
+	maxNearbyRadiusKm     = 200.0
+)
+
+type nearbyFilter struct {
+	Origin   models.GeoPoint
+	RadiusKm float64
+}
+
+// parseNearby reads the lat, lng and distance (km) query parameters. It
+// returns nil without an error message when the request has no coordinates.
+func parseNearby(r *http.Request) (*nearbyFilter, string) {
+	lat := r.URL.Query().Get("lat")
+	lng := r.URL.Query().Get("lng")
+	if lat == "" && lng == "" {
+		return nil, ""
+	}
+
+	origin, err := models.ParseGeoPoint(lat + "," + lng)
+	if err != nil {
+		return nil, "Invalid lat/lng: " + err.Error()
+	}
+
+	filter := &nearbyFilter{Origin: origin, RadiusKm: defaultNearbyRadiusKm}
+	if distance := r.URL.Query().Get("distance"); distance != "" {
+		radius, err := strconv.ParseFloat(distance, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+			return nil, "Invalid distance - must be between 0 and 200 km"
+		}
+		filter.RadiusKm = radius
+	}
+
+	return filter, ""
+}
+
+// distanceExpr is the haversine distance in km from the origin to a row of
+// table. It takes the origin as lat, lng, lat arguments.
+func distanceExpr(table string) string {
+	return "(6371 * acos(LEAST(1, cos(radians(?)) * cos(radians(" + table + ".latitude)) * " +
+		"cos(radians(" + table + ".longitude) - radians(?)) + sin(radians(?)) * sin(radians(" + table + ".latitude)))))"
+}
+
+func (f *nearbyFilter) distanceArgs() []interface{} {
+	return []interface{}{f.Origin.Lat, f.Origin.Lng, f.Origin.Lat}
+}
+
+// apply keeps the rows of table within the radius. Geohash prefixes and the
+// bounding box let the indexes discard most rows before the exact distance
+// check runs.
+func (f *nearbyFilter) apply(query *gorm.DB, table string) *gorm.DB {
+	box := models.BoundingBoxAround(f.Origin, f.RadiusKm)
+
+	if cells := models.GeohashCover(box); len(cells) > 0 {
+		conditions := make([]string, len(cells))
+		args := make([]interface{}, len(cells))
+		for i, cell := range cells {
+			conditions[i] = table + ".geohash LIKE ?"
+			args[i] = cell + "%"
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	return query.
+		Where(table+".latitude BETWEEN ? AND ? AND "+table+".longitude BETWEEN ? AND ?",
+			box.MinLat, box.MaxLat, box.MinLng, box.MaxLng).
+		Where(distanceExpr(table)+" <= ?", append(f.distanceArgs(), f.RadiusKm)...)
+}
+
+type nearbyRow struct {
+	ID       uint
+	Distance float64
+}
+
+// page returns the IDs and distances of one page of matches, nearest first.
+func (f *nearbyFilter) page(query *gorm.DB, table string, page, pageSize int) ([]nearbyRow, int64, error) {
+	query = f.apply(query, table).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Distinct(table + ".id").Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []nearbyRow
+	err := query.
+		Select("DISTINCT "+table+".id AS id, "+distanceExpr(table)+" AS distance", f.distanceArgs()...).
+		Order("distance ASC, id ASC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rows, total, nil
+}
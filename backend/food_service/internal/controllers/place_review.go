@@ -7,17 +7,15 @@ import (
 	"food_service/utils/db"
 	"github.com/gorilla/mux"
 	"log"
-	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 )
 
-func (c *FoodController) AddReview(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
+// Place reviews are stored by review-service. These handlers keep the
+// original endpoints and response shapes working on top of it.
 
+func (c *FoodController) AddReview(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	placeIDStr := vars["id"]
 	placeID, err := strconv.ParseUint(placeIDStr, 10, 32)
@@ -32,99 +30,46 @@ func (c *FoodController) AddReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := getUserID(r)
-	if err != nil {
+	if _, err := getUserID(r); err != nil {
 		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
 		return
 	}
 
-	username, profileImg, err := getUserProfileInfo(userID)
+	query := url.Values{"item_type": {reviewItemType}, "item_id": {placeIDStr}}
+	resp, err := reviewRequest(r, http.MethodPost, "/reviews?"+query.Encode(), r.Body)
 	if err != nil {
-		log.Printf("Warning: couldn't get user profile info: %v", err)
-	}
-
-	ratingStr := r.FormValue("rating")
-	rating, err := strconv.Atoi(ratingStr)
-	if err != nil || rating < 1 || rating > 5 {
-		http.Error(w, "Invalid rating - must be between 1 and 5", http.StatusBadRequest)
+		log.Printf("Error creating review: %v", err)
+		http.Error(w, "Failed to create review", http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	comment := r.FormValue("comment")
-
-	review := models.FoodReview{
-		PlaceID:    uint(placeID),
-		UserID:     userID,
-		Username:   username,
-		ProfileImg: profileImg,
-		Rating:     rating,
-		Comment:    comment,
-	}
-
-	tx := db.DB.Begin()
-	if err := tx.Create(&review).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to create review", http.StatusInternalServerError)
+	if resp.StatusCode != http.StatusCreated {
+		relayReviewError(w, resp)
 		return
 	}
-
-	files := r.MultipartForm.File["images"]
-	if len(files) > 0 {
-		imageURLs, err := uploadImages(files, "reviews")
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		for _, url := range imageURLs {
-			image := models.FoodReviewImage{
-				ReviewID: review.ID,
-				URL:      url,
-			}
-			if err := tx.Create(&image).Error; err != nil {
-				tx.Rollback()
-				http.Error(w, "Failed to save review image", http.StatusInternalServerError)
-				return
-			}
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
-		return
-	}
-
-	if err := updateAverageRating(uint(placeID)); err != nil {
-		log.Printf("Warning: failed to update average rating: %v", err)
-	}
-
-	if err := db.DB.Preload("Images").First(&review, review.ID).Error; err != nil {
-		http.Error(w, "Failed to reload review", http.StatusInternalServerError)
+	var created review
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		http.Error(w, "Failed to read review", http.StatusBadGateway)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(review)
+	json.NewEncoder(w).Encode(created.foodReview())
 }
-func (c *FoodController) UpdateReview(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
-		return
-	}
 
+func (c *FoodController) UpdateReview(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	reviewIDStr := vars["review_id"]
-	reviewID, err := strconv.ParseUint(reviewIDStr, 10, 32)
+	reviewID, err := strconv.ParseUint(vars["review_id"], 10, 32)
 	if err != nil {
 		http.Error(w, "Invalid review ID", http.StatusBadRequest)
 		return
 	}
 
-	var review models.FoodReview
-	if err := db.DB.First(&review, reviewID).Error; err != nil {
-		http.Error(w, "Review not found", http.StatusNotFound)
+	existing, status, err := fetchPlaceReview(r, reviewID)
+	if err != nil {
+		writeReviewLookupError(w, status, err)
 		return
 	}
 
@@ -134,92 +79,44 @@ func (c *FoodController) UpdateReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if userID != review.UserID {
+	if userID != existing.UserID {
 		http.Error(w, "Unauthorized - not the review owner", http.StatusUnauthorized)
 		return
 	}
 
-	if ratingStr := r.FormValue("rating"); ratingStr != "" {
-		rating, err := strconv.Atoi(ratingStr)
-		if err != nil || rating < 1 || rating > 5 {
-			http.Error(w, "Invalid rating - must be between 1 and 5", http.StatusBadRequest)
-			return
-		}
-		review.Rating = rating
-	}
-
-	if comment := r.FormValue("comment"); comment != "" {
-		review.Comment = comment
-	}
-
-	tx := db.DB.Begin()
-
-	files := r.MultipartForm.File["images"]
-	if len(files) > 0 {
-		if deleteExisting := r.FormValue("delete_existing_images"); deleteExisting == "true" {
-			if err := tx.Where("review_id = ?", review.ID).Delete(&models.FoodReviewImage{}).Error; err != nil {
-				tx.Rollback()
-				http.Error(w, "Failed to delete existing images", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		imageURLs, err := uploadImages(files, "reviews")
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, fmt.Sprintf("Failed to upload images: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		for _, url := range imageURLs {
-			image := models.FoodReviewImage{
-				ReviewID: review.ID,
-				URL:      url,
-			}
-			if err := tx.Create(&image).Error; err != nil {
-				tx.Rollback()
-				http.Error(w, "Failed to save review image", http.StatusInternalServerError)
-				return
-			}
-		}
-	}
-
-	if err := tx.Save(&review).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "Failed to update review", http.StatusInternalServerError)
+	resp, err := reviewRequest(r, http.MethodPut, fmt.Sprintf("/reviews/%d", reviewID), r.Body)
+	if err != nil {
+		log.Printf("Error updating review %d: %v", reviewID, err)
+		http.Error(w, "Failed to update review", http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	if err := tx.Commit().Error; err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+	if resp.StatusCode != http.StatusOK {
+		relayReviewError(w, resp)
 		return
 	}
-
-	if err := updateAverageRating(review.PlaceID); err != nil {
-		log.Printf("Warning: failed to update average rating: %v", err)
-	}
-
-	if err := db.DB.Preload("Images").First(&review, review.ID).Error; err != nil {
-		http.Error(w, "Failed to reload review", http.StatusInternalServerError)
+	var updated review
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		http.Error(w, "Failed to read review", http.StatusBadGateway)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(review)
+	json.NewEncoder(w).Encode(updated.foodReview())
 }
 
 func (c *FoodController) DeleteReview(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	reviewIDStr := vars["review_id"]
-	reviewID, err := strconv.ParseUint(reviewIDStr, 10, 32)
+	reviewID, err := strconv.ParseUint(vars["review_id"], 10, 32)
 	if err != nil {
 		http.Error(w, "Invalid review ID", http.StatusBadRequest)
 		return
 	}
 
-	var review models.FoodReview
-	if err := db.DB.First(&review, reviewID).Error; err != nil {
-		http.Error(w, "Review not found", http.StatusNotFound)
+	existing, status, err := fetchPlaceReview(r, reviewID)
+	if err != nil {
+		writeReviewLookupError(w, status, err)
 		return
 	}
 
@@ -229,9 +126,10 @@ func (c *FoodController) DeleteReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if userID != review.UserID {
+	path := fmt.Sprintf("/reviews/%d", reviewID)
+	if userID != existing.UserID {
 		var place models.Place
-		if err := db.DB.First(&place, review.PlaceID).Error; err != nil {
+		if err := db.DB.First(&place, existing.ItemID).Error; err != nil {
 			http.Error(w, "Unauthorized - not the review owner", http.StatusUnauthorized)
 			return
 		}
@@ -246,17 +144,20 @@ func (c *FoodController) DeleteReview(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Unauthorized - not the review owner or place owner", http.StatusUnauthorized)
 			return
 		}
+		path = fmt.Sprintf("/internal/reviews/%d", reviewID)
 	}
 
-	placeID := review.PlaceID
-
-	if err := db.DB.Delete(&review).Error; err != nil {
-		http.Error(w, "Failed to delete review", http.StatusInternalServerError)
+	resp, err := reviewRequest(r, http.MethodDelete, path, nil)
+	if err != nil {
+		log.Printf("Error deleting review %d: %v", reviewID, err)
+		http.Error(w, "Failed to delete review", http.StatusBadGateway)
 		return
 	}
+	defer resp.Body.Close()
 
-	if err := updateAverageRating(placeID); err != nil {
-		log.Printf("Warning: failed to update average rating: %v", err)
+	if resp.StatusCode != http.StatusNoContent {
+		relayReviewError(w, resp)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -267,43 +168,17 @@ func (c *FoodController) GetPlaceReviews(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	var reviews []models.FoodReview
-	query := db.DB.Preload("Images").Where("place_id = ?", id)
-
-	page := 1
-	pageSize := 20
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if pageInt, err := strconv.Atoi(pageStr); err == nil && pageInt > 0 {
-			page = pageInt
-		}
-	}
-	if pageSizeStr := r.URL.Query().Get("page_size"); pageSizeStr != "" {
-		if pageSizeInt, err := strconv.Atoi(pageSizeStr); err == nil && pageSizeInt > 0 {
-			pageSize = pageSizeInt
-		}
-	}
-
-	var totalCount int64
-	query.Model(&models.FoodReview{}).Count(&totalCount)
-
-	offset := (page - 1) * pageSize
-	query = query.Offset(offset).Limit(pageSize)
-
-	query = query.Order("created_at desc")
-
-	if err := query.Find(&reviews).Error; err != nil {
-		http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+	result, status, err := fetchPlaceReviews(r, id, r.URL.Query())
+	if err != nil {
+		log.Printf("Error fetching reviews of place %s: %v", id, err)
+		http.Error(w, "Failed to fetch reviews", status)
 		return
 	}
 
 	response := map[string]interface{}{
-		"reviews": reviews,
-		"pagination": map[string]interface{}{
-			"page":        page,
-			"page_size":   pageSize,
-			"total":       totalCount,
-			"total_pages": int(math.Ceil(float64(totalCount) / float64(pageSize))),
-		},
+		"reviews":    foodReviews(result.Reviews),
+		"pagination": result.Pagination,
+		"stats":      result.Stats,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
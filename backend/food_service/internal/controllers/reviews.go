@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"food_service/internal/models"
+	"food_service/utils/db"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reviewItemType is what review-service calls food places.
+const reviewItemType = "place"
+
+// placeReviewsLimit caps the reviews embedded in a place's details; the
+// rest are paged through GetPlaceReviews.
+const placeReviewsLimit = 100
+
+func getReviewServiceURL() string {
+	if u := os.Getenv("REVIEW_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://review-service:8086"
+}
+
+// review is a review as review-service returns it.
+type review struct {
+	ID          uint      `json:"id"`
+	ItemType    string    `json:"item_type"`
+	ItemID      uint      `json:"item_id"`
+	UserID      uint      `json:"user_id"`
+	Username    string    `json:"username"`
+	ProfileImg  string    `json:"profile_img"`
+	Rating      int       `json:"rating"`
+	Comment     string    `json:"comment"`
+	Verified    bool      `json:"verified"`
+	VerifiedVia string    `json:"verified_via"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Images      []struct {
+		ID       uint   `json:"id"`
+		ReviewID uint   `json:"review_id"`
+		URL      string `json:"url"`
+		MediaID  *uint  `json:"media_id"`
+	} `json:"images"`
+	Aspects      []models.FoodReviewAspect `json:"aspects"`
+	HelpfulCount int                       `json:"helpful_count"`
+	VotedHelpful bool                      `json:"voted_helpful"`
+	Reply        *models.FoodReviewReply   `json:"reply"`
+}
+
+func (rv review) foodReview() models.FoodReview {
+	result := models.FoodReview{
+		ID:           rv.ID,
+		CreatedAt:    rv.CreatedAt,
+		UpdatedAt:    rv.UpdatedAt,
+		PlaceID:      rv.ItemID,
+		UserID:       rv.UserID,
+		Username:     rv.Username,
+		ProfileImg:   rv.ProfileImg,
+		Rating:       rv.Rating,
+		Comment:      rv.Comment,
+		Verified:     rv.Verified,
+		VerifiedVia:  rv.VerifiedVia,
+		Images:       []models.FoodReviewImage{},
+		Aspects:      rv.Aspects,
+		HelpfulCount: rv.HelpfulCount,
+		VotedHelpful: rv.VotedHelpful,
+		Reply:        rv.Reply,
+	}
+	if result.Aspects == nil {
+		result.Aspects = []models.FoodReviewAspect{}
+	}
+	for _, image := range rv.Images {
+		result.Images = append(result.Images, models.FoodReviewImage{
+			ID:       image.ID,
+			ReviewID: image.ReviewID,
+			URL:      image.URL,
+			MediaID:  image.MediaID,
+		})
+	}
+	return result
+}
+
+func foodReviews(reviews []review) []models.FoodReview {
+	result := make([]models.FoodReview, 0, len(reviews))
+	for _, rv := range reviews {
+		result = append(result, rv.foodReview())
+	}
+	return result
+}
+
+var reviewClient = &http.Client{Timeout: 60 * time.Second}
+
+// reviewRequest calls review-service on behalf of the user of r, passing
+// the body through with its content type. Calls to /internal routes carry the
+// service token.
+func reviewRequest(r *http.Request, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.Context(), method, getReviewServiceURL()+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+	}
+	req.Header.Set("X-User-ID", r.Header.Get("X-User-ID"))
+	req.Header.Set("X-Username", r.Header.Get("X-Username"))
+	if strings.HasPrefix(path, "/internal/") {
+		req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+	}
+	return reviewClient.Do(req)
+}
+
+// relayReviewError passes a failed review-service response on to the client.
+func relayReviewError(w http.ResponseWriter, resp *http.Response) {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	http.Error(w, strings.TrimSpace(string(message)), resp.StatusCode)
+}
+
+// fetchPlaceReview loads a review and makes sure it is about a food place.
+func fetchPlaceReview(r *http.Request, id uint64) (*review, int, error) {
+	resp, err := reviewRequest(r, http.MethodGet, fmt.Sprintf("/reviews/%d", id), nil)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("review service returned %d", resp.StatusCode)
+	}
+	var rv review
+	if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	if rv.ItemType != reviewItemType {
+		return nil, http.StatusNotFound, fmt.Errorf("review %d is not about a place", id)
+	}
+	return &rv, http.StatusOK, nil
+}
+
+func writeReviewLookupError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusNotFound {
+		http.Error(w, "Review not found", status)
+		return
+	}
+	log.Printf("Error loading review: %v", err)
+	http.Error(w, "Failed to load review", status)
+}
+
+// reviewListParams are the listing parameters passed on to review-service:
+// paging, ?verified=true and
+// ?sort=newest|verified|helpful|highest|lowest.
+var reviewListParams = []string{"page", "page_size", "verified", "sort"}
+
+type reviewPage struct {
+	Reviews    []review               `json:"reviews"`
+	Pagination map[string]interface{} `json:"pagination"`
+	// Stats are the item's rating stats, histogram and aspect averages
+	// included, passed through as they are.
+	Stats json.RawMessage `json:"stats"`
+}
+
+// fetchPlaceReviews loads one page of a place's reviews with the listing
+// parameters of params.
+func fetchPlaceReviews(r *http.Request, placeID string, params url.Values) (*reviewPage, int, error) {
+	query := url.Values{"item_type": {reviewItemType}, "item_id": {placeID}}
+	for _, name := range reviewListParams {
+		if value := params.Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	resp, err := reviewRequest(r, http.MethodGet, "/reviews?"+query.Encode(), nil)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("review service returned %d", resp.StatusCode)
+	}
+	var result reviewPage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return &result, http.StatusOK, nil
+}
+
+// ratingsRecheck is how long a missing review_stats table is trusted
+// before it is looked up again.
+const ratingsRecheck = time.Minute
+
+var ratings struct {
+	sync.Mutex
+	available bool
+	checked   time.Time
+}
+
+// ratingsAvailable reports whether the rating stats review-service keeps
+// in the shared database exist; they do once it has run. The table never
+// goes away once created, so a hit is kept for good and a miss is looked
+// up again after ratingsRecheck.
+func ratingsAvailable() bool {
+	ratings.Lock()
+	defer ratings.Unlock()
+	if ratings.available || time.Since(ratings.checked) < ratingsRecheck {
+		return ratings.available
+	}
+	ratings.available = db.DB.Migrator().HasTable("review_stats")
+	ratings.checked = time.Now()
+	return ratings.available
+}
+
+// joinRatings joins each place's rating stats, leaving unrated places
+// with NULL columns.
+func joinRatings(query *gorm.DB) *gorm.DB {
+	return query.Joins("LEFT JOIN review_stats ON review_stats.item_type = ? AND review_stats.item_id = places.id", reviewItemType)
+}
+
+// placeRatings returns the average rating of the rated places among ids,
+// rounded to one decimal. The stats are read as review-service keeps them,
+// so hidden and deleted reviews never linger in a copy.
+func placeRatings(ids []uint) map[uint]float64 {
+	ratings := make(map[uint]float64, len(ids))
+	if len(ids) == 0 || !ratingsAvailable() {
+		return ratings
+	}
+	var rows []struct {
+		ItemID  uint
+		Average float64
+	}
+	err := db.DB.Table("review_stats").Select("item_id, average").
+		Where("item_type = ? AND item_id IN ?", reviewItemType, ids).
+		Scan(&rows).Error
+	if err != nil {
+		log.Printf("Error loading place ratings: %v", err)
+		return ratings
+	}
+	for _, row := range rows {
+		ratings[row.ItemID] = math.Round(row.Average*10) / 10
+	}
+	return ratings
+}
+
+// fillRatings sets the average rating of each place.
+func fillRatings(places []models.Place) {
+	ids := make([]uint, len(places))
+	for i, place := range places {
+		ids[i] = place.ID
+	}
+	ratings := placeRatings(ids)
+	for i := range places {
+		places[i].AverageRating = ratings[places[i].ID]
+	}
+}
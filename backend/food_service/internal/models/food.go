@@ -1,34 +1,55 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Place struct {
 	gorm.Model
-	Name          string  `json:"name" gorm:"not null"`
-	Description   string  `json:"description"`
-	City          string  `json:"city" gorm:"index;not null"`
-	Address       string  `json:"address" gorm:"not null"`
-	Location      string  `json:"location"`
-	Type          string  `json:"type" gorm:"index;not null"`
-	PriceRange    string  `json:"price_range"`
-	Website       string  `json:"website"`
-	Phone         string  `json:"phone"`
-	IsPublished   bool    `json:"is_published" gorm:"default:false"`
-	AdminID       uint    `json:"admin_id" gorm:"index;not null"`
-	AverageRating float64 `json:"average_rating" gorm:"default:0"`
+	Name             string   `json:"name" gorm:"not null"`
+	Description      string   `json:"description"`
+	City             string   `json:"city" gorm:"index;not null"`
+	CityID           *uint    `json:"city_id" gorm:"index"`
+	Address          string   `json:"address" gorm:"not null"`
+	Location         string   `json:"location"`
+	FormattedAddress string   `json:"formatted_address"`
+	LocationMismatch bool     `json:"location_mismatch" gorm:"default:false"`
+	Latitude         *float64 `json:"-"`
+	Longitude        *float64 `json:"-"`
+	Geohash          string   `json:"-" gorm:"size:12"`
+	Type             string   `json:"type" gorm:"index;not null"`
+	PriceRange       string   `json:"price_range"`
+	Website          string   `json:"website"`
+	Phone            string   `json:"phone"`
+	IsPublished      bool     `json:"is_published" gorm:"default:false"`
+	AdminID          uint     `json:"admin_id" gorm:"index;not null"`
+	AverageRating    float64  `json:"average_rating" gorm:"-"`
 
 	Cuisines []Cuisine    `json:"cuisines" gorm:"many2many:place_cuisines;"`
 	Images   []PlaceImage `json:"images" gorm:"foreignKey:PlaceID;constraint:OnDelete:CASCADE;"`
 	Dishes   []Dish       `json:"dishes" gorm:"foreignKey:PlaceID;constraint:OnDelete:CASCADE;"`
-	Reviews  []FoodReview `json:"reviews" gorm:"foreignKey:PlaceID;constraint:OnDelete:CASCADE;"`
+	Reviews  []FoodReview `json:"reviews" gorm:"-"`
+}
+
+// BeforeSave keeps the indexed coordinate columns in sync with Location.
+func (p *Place) BeforeSave(tx *gorm.DB) error {
+	p.Latitude, p.Longitude, p.Geohash = GeoColumns(p.Location)
+	return nil
 }
 
 type PlaceImage struct {
 	gorm.Model
 	PlaceID uint   `json:"place_id" gorm:"index;not null"`
 	URL     string `json:"url" gorm:"not null"`
+	MediaID *uint  `json:"media_id,omitempty" gorm:"index"`
+}
+
+// BeforeCreate records the media-service ID behind the image URL.
+func (i *PlaceImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
 }
 
 type Cuisine struct {
@@ -54,26 +75,60 @@ type Dish struct {
 
 type DishImage struct {
 	gorm.Model
-	DishID uint   `json:"dish_id" gorm:"index;not null"`
-	URL    string `json:"url" gorm:"not null"`
+	DishID  uint   `json:"dish_id" gorm:"index;not null"`
+	URL     string `json:"url" gorm:"not null"`
+	MediaID *uint  `json:"media_id,omitempty" gorm:"index"`
+}
+
+func (i *DishImage) BeforeCreate(tx *gorm.DB) error {
+	i.MediaID = MediaIDFromURL(i.URL)
+	return nil
 }
 
+// FoodReview is a place review in the shape this service has always
+// returned. Reviews are stored by review-service.
 type FoodReview struct {
-	gorm.Model
-	PlaceID    uint   `json:"place_id" gorm:"index;not null"`
-	UserID     uint   `json:"user_id" gorm:"index;not null"`
-	Username   string `json:"username"`
-	ProfileImg string `json:"profile_img"`
-	Rating     int    `json:"rating" gorm:"not null;check:rating >= 1 AND rating <= 5"`
-	Comment    string `json:"comment"`
-
-	Images []FoodReviewImage `json:"images" gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE;"`
+	ID         uint      `json:"ID"`
+	CreatedAt  time.Time `json:"CreatedAt"`
+	UpdatedAt  time.Time `json:"UpdatedAt"`
+	PlaceID    uint      `json:"place_id"`
+	UserID     uint      `json:"user_id"`
+	Username   string    `json:"username"`
+	ProfileImg string    `json:"profile_img"`
+	Rating     int       `json:"rating"`
+	Comment    string    `json:"comment"`
+	// Verified marks a verified visit; VerifiedVia is "plan" for food
+	// places.
+	Verified    bool   `json:"verified"`
+	VerifiedVia string `json:"verified_via,omitempty"`
+
+	Images []FoodReviewImage `json:"images"`
+
+	// Aspects rate food_quality, service and value; Reply is the answer
+	// of the admin who owns the place.
+	Aspects      []FoodReviewAspect `json:"aspects"`
+	HelpfulCount int                `json:"helpful_count"`
+	VotedHelpful bool               `json:"voted_helpful"`
+	Reply        *FoodReviewReply   `json:"reply,omitempty"`
+}
+
+type FoodReviewAspect struct {
+	Aspect string `json:"aspect"`
+	Rating int    `json:"rating"`
+}
+
+type FoodReviewReply struct {
+	AdminID   uint      `json:"admin_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type FoodReviewImage struct {
-	gorm.Model
-	ReviewID uint   `json:"review_id" gorm:"index;not null"`
-	URL      string `json:"url" gorm:"not null"`
+	ID       uint   `json:"ID"`
+	ReviewID uint   `json:"review_id"`
+	URL      string `json:"url"`
+	MediaID  *uint  `json:"media_id,omitempty"`
 }
 
 type PlaceWithDistance struct {
@@ -3,8 +3,6 @@ package routes
 import (
 	"food_service/internal/controllers"
 	"food_service/internal/middleware"
-	"log"
-	"net/http"
 
 	"github.com/gorilla/mux"
 )
@@ -12,12 +10,6 @@ import (
 func SetupRoutes() *mux.Router {
 	r := mux.NewRouter()
 
-	uploadDir := "/app/uploads/food"
-	log.Printf("Serving static files from: %s", uploadDir)
-	r.PathPrefix("/uploads/food/").Handler(
-		http.StripPrefix("/uploads/food/", http.FileServer(http.Dir(uploadDir))),
-	).Methods("GET")
-
 	foodController := controllers.FoodController{}
 
 	r.HandleFunc("/places", foodController.ListPlaces).Methods("GET")
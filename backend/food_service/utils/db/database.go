@@ -31,12 +31,62 @@ func ConnectDB() {
 		&models.Cuisine{},
 		&models.Dish{},
 		&models.DishImage{},
-		&models.FoodReview{},
-		&models.FoodReviewImage{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database schemas: %v", err)
 	}
 
+	// Places used to keep a copy of their average rating, which missed
+	// reviews hidden by moderation; ratings now come from review_stats.
+	if DB.Migrator().HasColumn(&models.Place{}, "average_rating") {
+		if err := DB.Migrator().DropColumn(&models.Place{}, "average_rating"); err != nil {
+			log.Printf("Failed to drop average_rating: %v", err)
+		}
+	}
+
+	for _, statement := range geoIndexStatements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to create geo index: %v", err)
+		}
+	}
+	if err := backfillGeoColumns(); err != nil {
+		log.Printf("Failed to backfill coordinates: %v", err)
+	}
+
 	log.Println("Database migration completed")
 }
+
+// geoIndexStatements create the indexes behind "near me" queries. The geohash
+// index uses text_pattern_ops so that prefix LIKE lookups can use it.
+var geoIndexStatements = []string{
+	`CREATE INDEX IF NOT EXISTS idx_places_geohash ON places (geohash text_pattern_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_places_coordinates ON places (latitude, longitude)`,
+}
+
+// backfillGeoColumns fills the coordinate columns of rows saved before they
+// existed. New writes keep them in sync through the model's BeforeSave hook.
+func backfillGeoColumns() error {
+	var places []models.Place
+	err := DB.Unscoped().Select("id", "location").
+		Where("location <> '' AND (geohash IS NULL OR geohash = '')").
+		Find(&places).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range places {
+		lat, lng, geohash := models.GeoColumns(row.Location)
+		if geohash == "" {
+			continue
+		}
+		err := DB.Unscoped().Model(&models.Place{}).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{
+			"latitude":  lat,
+			"longitude": lng,
+			"geohash":   geohash,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
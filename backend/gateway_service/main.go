@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sort"
 	"strings"
 
 	middlewares "gateway_service/middleware"
@@ -43,7 +44,7 @@ var services = map[string]ServiceConfig{
 		Paths: []string{
 			"/admin/events",
 			"/events",
-			"/uploads/events",
+			"/user/events",
 		},
 		Auth: false,
 	},
@@ -53,6 +54,7 @@ var services = map[string]ServiceConfig{
 			"/user/profiles",
 			"/user/profiles/{user_id}",
 			"/user/profiles/{user_id}/follow",
+			"/user/notifications",
 		},
 		Auth: true,
 	},
@@ -62,7 +64,6 @@ var services = map[string]ServiceConfig{
 		Paths: []string{
 			"/admin/attractions",
 			"/attractions",
-			"/uploads",
 		},
 		Auth: false,
 	},
@@ -73,11 +74,6 @@ var services = map[string]ServiceConfig{
 		},
 		Auth: true,
 	},
-	"uploads": {
-		URL:   "http://profile-service:8084",
-		Paths: []string{"/uploads"},
-		Auth:  false,
-	},
 	"plans": {
 		URL: "http://plan-service:8087",
 		Paths: []string{
@@ -88,6 +84,58 @@ var services = map[string]ServiceConfig{
 		},
 		Auth: true,
 	},
+	"accommodations": {
+		URL: "http://accommodation-service:8089",
+		Paths: []string{
+			"/accommodations",
+			"/admin/accommodations",
+			"/user/accommodations",
+		},
+		Auth: false,
+	},
+	"search": {
+		URL: "http://search-service:8091",
+		Paths: []string{
+			"/search",
+			"/admin/search",
+			"/map",
+		},
+		Auth: false,
+	},
+	"payments": {
+		URL: "http://payment-service:8093",
+		Paths: []string{
+			"/payments",
+			"/user/payments",
+			"/admin/payments",
+		},
+		Auth: false,
+	},
+	"media": {
+		URL: "http://media-service:8094",
+		Paths: []string{
+			"/media",
+			"/admin/media",
+			"/uploads",
+		},
+		Auth: false,
+	},
+	"moderation": {
+		URL: "http://moderation-service:8095",
+		Paths: []string{
+			"/admin/moderation",
+		},
+		Auth: false,
+	},
+	"locations": {
+		URL: "http://location-service:8092",
+		Paths: []string{
+			"/cities",
+			"/admin/cities",
+			"/geocode",
+		},
+		Auth: false,
+	},
 }
 
 var pathAuthOverrides = map[string]bool{
@@ -110,29 +158,58 @@ func main() {
 	log.Fatal(http.ListenAndServe(":8080", handler))
 }
 
-func setupRoutes(r *mux.Router) {
-	for _, config := range services {
-		for _, path := range config.Paths {
-			handler := createProxyHandler(config.URL)
+type route struct {
+	path   string
+	config ServiceConfig
+}
 
-			requiresAuth := config.Auth
-			if override, exists := pathAuthOverrides[path]; exists {
-				requiresAuth = override
+// sortedRoutes lists every configured path, longest first, so that a
+// prefix such as "/events" never shadows "/events/..." routes of another
+// service regardless of map order. A path claimed by two services is a
+// configuration error.
+func sortedRoutes() []route {
+	owners := map[string]string{}
+	var routes []route
+	for name, config := range services {
+		for _, path := range config.Paths {
+			if owner, exists := owners[path]; exists {
+				log.Fatalf("Path %s is configured for both %s and %s", path, owner, name)
 			}
+			owners[path] = name
+			routes = append(routes, route{path: path, config: config})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if len(routes[i].path) != len(routes[j].path) {
+			return len(routes[i].path) > len(routes[j].path)
+		}
+		return routes[i].path < routes[j].path
+	})
+	return routes
+}
 
-			if requiresAuth {
-				handler = middlewares.AuthMiddleware(handler)
-			}
+func setupRoutes(r *mux.Router) {
+	for _, route := range sortedRoutes() {
+		path := route.path
+		handler := createProxyHandler(route.config.URL)
 
-			if path == "/profiles/{user_id}" ||
-				path == "/profiles/{user_id}/follow" ||
-				path == "/profiles/{user_id}/unfollow" ||
-				path == "/profiles/{user_id}/followers" ||
-				path == "/profiles/{user_id}/following" {
-				r.Handle(path, handler).Methods("PATCH", "POST", "DELETE", "GET")
-			} else {
-				r.PathPrefix(path).Handler(handler)
-			}
+		requiresAuth := route.config.Auth
+		if override, exists := pathAuthOverrides[path]; exists {
+			requiresAuth = override
+		}
+
+		if requiresAuth {
+			handler = middlewares.AuthMiddleware(handler)
+		}
+
+		if path == "/profiles/{user_id}" ||
+			path == "/profiles/{user_id}/follow" ||
+			path == "/profiles/{user_id}/unfollow" ||
+			path == "/profiles/{user_id}/followers" ||
+			path == "/profiles/{user_id}/following" {
+			r.Handle(path, handler).Methods("PATCH", "POST", "DELETE", "GET")
+		} else {
+			r.PathPrefix(path).Handler(handler)
 		}
 	}
 }
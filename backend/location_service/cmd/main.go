@@ -0,0 +1,28 @@
+package main
+
+import (
+	"location_service/internal/backfill"
+	"location_service/routes"
+	"location_service/utils/db"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	db.ConnectDB()
+
+	backfill.Start(time.Hour)
+
+	router := routes.SetupRoutes()
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8092"
+	}
+
+	log.Printf("Location service running on port %s", port)
+
+	log.Fatal(http.ListenAndServe(":"+port, router))
+}
@@ -0,0 +1,136 @@
+package backfill
+
+import (
+	"fmt"
+	"location_service/internal/cities"
+	"location_service/internal/models"
+	"location_service/utils/db"
+	"log"
+	"time"
+)
+
+// target is a table in another service that carries a city_id column.
+// Rows are matched by their free-text city first and by location second.
+type target struct {
+	Table       string
+	HasName     bool
+	HasLocation bool
+}
+
+var targets = []target{
+	{Table: "attractions", HasName: true, HasLocation: true},
+	{Table: "places", HasName: true, HasLocation: true},
+	{Table: "accommodations", HasName: true, HasLocation: true},
+	{Table: "events", HasLocation: true},
+	{Table: "plans", HasName: true},
+	{Table: "plan_templates", HasName: true},
+}
+
+// Start fills missing city IDs right away and then on every tick, so rows
+// created while the location service was unreachable get linked later.
+func Start(interval time.Duration) {
+	go func() {
+		for {
+			updated, errs := Run()
+			for _, err := range errs {
+				log.Printf("City backfill error: %v", err)
+			}
+			if len(updated) > 0 {
+				log.Printf("City backfill linked rows: %v", updated)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func Run() (map[string]int64, []error) {
+	updated := make(map[string]int64)
+	var errs []error
+
+	for _, t := range targets {
+		if !db.DB.Migrator().HasTable(t.Table) || !db.DB.Migrator().HasColumn(t.Table, "city_id") {
+			continue
+		}
+
+		var count int64
+		if t.HasName {
+			n, err := backfillByName(t.Table)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", t.Table, err))
+				continue
+			}
+			count += n
+		}
+		if t.HasLocation {
+			n, err := backfillByLocation(t.Table)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", t.Table, err))
+				continue
+			}
+			count += n
+		}
+		if count > 0 {
+			updated[t.Table] = count
+		}
+	}
+
+	return updated, errs
+}
+
+func backfillByName(table string) (int64, error) {
+	var names []string
+	err := db.DB.Table(table).
+		Where("city_id IS NULL AND city IS NOT NULL AND city <> ''").
+		Distinct().Pluck("city", &names).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, name := range names {
+		city, err := cities.FindByName(name)
+		if err != nil {
+			continue
+		}
+		result := db.DB.Table(table).
+			Where("city_id IS NULL AND city = ?", name).
+			Update("city_id", city.ID)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+	}
+	return total, nil
+}
+
+func backfillByLocation(table string) (int64, error) {
+	var rows []struct {
+		ID       uint
+		Location string
+	}
+	err := db.DB.Table(table).
+		Select("id, location").
+		Where("city_id IS NULL AND location IS NOT NULL AND location <> ''").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, row := range rows {
+		point, err := models.ParseGeoPoint(row.Location)
+		if err != nil {
+			continue
+		}
+		city, err := cities.FindByPoint(point)
+		if err != nil {
+			continue
+		}
+		result := db.DB.Table(table).Where("id = ?", row.ID).Update("city_id", city.ID)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+	}
+	return total, nil
+}
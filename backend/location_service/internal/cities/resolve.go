@@ -0,0 +1,73 @@
+package cities
+
+import (
+	"location_service/internal/models"
+	"location_service/utils/db"
+
+	"gorm.io/gorm"
+)
+
+// FindByName matches free-text input against every known spelling of a city.
+func FindByName(name string) (*models.City, error) {
+	key := models.NameKey(name)
+	if key == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var cityName models.CityName
+	if err := db.DB.Where("name_key = ?", key).Order("city_id").First(&cityName).Error; err != nil {
+		return nil, err
+	}
+
+	return Get(cityName.CityID)
+}
+
+// FindByPoint returns the city whose bounding box contains the point. When
+// boxes overlap the city with the closest center wins.
+func FindByPoint(point models.GeoPoint) (*models.City, error) {
+	var candidates []models.City
+	err := db.DB.Preload("Names").
+		Where("bbox_min_lat <= ? AND bbox_max_lat >= ? AND bbox_min_lng <= ? AND bbox_max_lng >= ?",
+			point.Lat, point.Lat, point.Lng, point.Lng).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	best := &candidates[0]
+	for i := range candidates[1:] {
+		candidate := &candidates[i+1]
+		if point.DistanceKm(candidate.Center) < point.DistanceKm(best.Center) {
+			best = candidate
+		}
+	}
+	return best, nil
+}
+
+// Resolve prefers the name and falls back to the point.
+func Resolve(name string, point *models.GeoPoint) (*models.City, error) {
+	if name != "" {
+		city, err := FindByName(name)
+		if err == nil {
+			return city, nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+	}
+	if point != nil {
+		return FindByPoint(*point)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func Get(id uint) (*models.City, error) {
+	var city models.City
+	if err := db.DB.Preload("Names").First(&city, id).Error; err != nil {
+		return nil, err
+	}
+	return &city, nil
+}
@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"encoding/json"
+	"location_service/internal/backfill"
+	"location_service/internal/cities"
+	"location_service/internal/models"
+	"location_service/utils/db"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type CityController struct{}
+
+type cityRequest struct {
+	Slug     string              `json:"slug"`
+	Country  string              `json:"country"`
+	Timezone string              `json:"timezone"`
+	Center   models.GeoPoint     `json:"center"`
+	BBox     *models.BoundingBox `json:"bbox"`
+	RadiusKm float64             `json:"radius_km"`
+	Names    []struct {
+		Locale string `json:"locale"`
+		Name   string `json:"name"`
+	} `json:"names"`
+}
+
+func getLocale(r *http.Request) string {
+	if locale := r.URL.Query().Get("locale"); locale != "" {
+		return locale
+	}
+	return models.DefaultLocale
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (c *CityController) ListCities(w http.ResponseWriter, r *http.Request) {
+	query := db.DB.Preload("Names")
+
+	if country := r.URL.Query().Get("country"); country != "" {
+		query = query.Where("country = ?", strings.ToUpper(country))
+	}
+
+	if q := models.NameKey(r.URL.Query().Get("q")); q != "" {
+		query = query.Where("id IN (?)",
+			db.DB.Model(&models.CityName{}).Select("city_id").Where("name_key LIKE ?", q+"%"))
+	}
+
+	var result []models.City
+	if err := query.Order("slug ASC").Find(&result).Error; err != nil {
+		http.Error(w, "Failed to fetch cities", http.StatusInternalServerError)
+		return
+	}
+
+	locale := getLocale(r)
+	for i := range result {
+		result[i].LocalizeName(locale)
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (c *CityController) GetCity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid city ID", http.StatusBadRequest)
+		return
+	}
+
+	city, err := cities.Get(uint(id))
+	if err != nil {
+		http.Error(w, "City not found", http.StatusNotFound)
+		return
+	}
+
+	city.LocalizeName(getLocale(r))
+	writeJSON(w, http.StatusOK, city)
+}
+
+// ResolveCity maps a free-text name and/or a "lat,lng" location to a
+// canonical city. Services call it when saving records.
+func (c *CityController) ResolveCity(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	var point *models.GeoPoint
+	if location := r.URL.Query().Get("location"); location != "" {
+		parsed, err := models.ParseGeoPoint(location)
+		if err != nil {
+			http.Error(w, "Invalid location: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		point = &parsed
+	}
+
+	if name == "" && point == nil {
+		http.Error(w, "name or location is required", http.StatusBadRequest)
+		return
+	}
+
+	city, err := cities.Resolve(name, point)
+	if err == gorm.ErrRecordNotFound {
+		http.Error(w, "City not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to resolve city", http.StatusInternalServerError)
+		return
+	}
+
+	city.LocalizeName(getLocale(r))
+	writeJSON(w, http.StatusOK, city)
+}
+
+func (req *cityRequest) toCity() (*models.City, string) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if slug == "" {
+		return nil, "Slug is required"
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(req.Country))
+	if len(country) != 2 {
+		return nil, "Country must be an ISO 3166-1 alpha-2 code"
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil || req.Timezone == "" {
+		return nil, "Invalid timezone"
+	}
+
+	if !req.Center.Valid() {
+		return nil, "Invalid center coordinates"
+	}
+
+	var bbox models.BoundingBox
+	if req.BBox != nil {
+		bbox = *req.BBox
+	} else {
+		radius := req.RadiusKm
+		if radius <= 0 {
+			radius = 20
+		}
+		bbox = db.BoundingBoxAround(req.Center, radius)
+	}
+	if !bbox.Valid() || !bbox.Contains(req.Center) {
+		return nil, "Bounding box must be valid and contain the center"
+	}
+
+	if len(req.Names) == 0 {
+		return nil, "At least one name is required"
+	}
+
+	city := &models.City{
+		Slug:     slug,
+		Country:  country,
+		Timezone: req.Timezone,
+		Center:   req.Center,
+		BBox:     bbox,
+	}
+	for _, name := range req.Names {
+		key := models.NameKey(name.Name)
+		if name.Locale == "" || key == "" {
+			return nil, "Every name needs a locale and a name"
+		}
+		city.Names = append(city.Names, models.CityName{
+			Locale:  name.Locale,
+			Name:    strings.TrimSpace(name.Name),
+			NameKey: key,
+		})
+	}
+
+	return city, ""
+}
+
+func (c *CityController) CreateCity(w http.ResponseWriter, r *http.Request) {
+	var req cityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	city, errMsg := req.toCity()
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	if err := db.DB.Create(city).Error; err != nil {
+		http.Error(w, "Failed to create city: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	city.LocalizeName(models.DefaultLocale)
+	writeJSON(w, http.StatusCreated, city)
+}
+
+func (c *CityController) UpdateCity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid city ID", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := cities.Get(uint(id))
+	if err != nil {
+		http.Error(w, "City not found", http.StatusNotFound)
+		return
+	}
+
+	var req cityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	city, errMsg := req.toCity()
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+	city.ID = existing.ID
+	city.CreatedAt = existing.CreatedAt
+
+	tx := db.DB.Begin()
+	if err := tx.Where("city_id = ?", city.ID).Delete(&models.CityName{}).Error; err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to update city names", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Save(city).Error; err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to update city: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		http.Error(w, "Failed to update city", http.StatusInternalServerError)
+		return
+	}
+
+	city.LocalizeName(models.DefaultLocale)
+	writeJSON(w, http.StatusOK, city)
+}
+
+func (c *CityController) DeleteCity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid city ID", http.StatusBadRequest)
+		return
+	}
+
+	tx := db.DB.Begin()
+	if err := tx.Where("city_id = ?", id).Delete(&models.CityName{}).Error; err != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to delete city", http.StatusInternalServerError)
+		return
+	}
+	result := tx.Delete(&models.City{}, id)
+	if result.Error != nil {
+		tx.Rollback()
+		http.Error(w, "Failed to delete city", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		http.Error(w, "City not found", http.StatusNotFound)
+		return
+	}
+	tx.Commit()
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "City deleted successfully"})
+}
+
+// RunBackfill links rows in other services to cities without waiting for
+// the next scheduled run.
+func (c *CityController) RunBackfill(w http.ResponseWriter, r *http.Request) {
+	updated, errs := backfill.Run()
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"updated": updated,
+		"errors":  messages,
+	})
+}
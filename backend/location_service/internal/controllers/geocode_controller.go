@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"location_service/internal/geocoding"
+	"location_service/internal/models"
+	"log"
+	"net/http"
+)
+
+type GeocodeController struct {
+	Geocoder geocoding.Geocoder
+}
+
+func (c *GeocodeController) Geocode(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "address is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.Geocoder.Geocode(address)
+	if err == geocoding.ErrNotFound {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Geocoding %q failed: %v", address, err)
+		http.Error(w, "Geocoding failed", http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (c *GeocodeController) Reverse(w http.ResponseWriter, r *http.Request) {
+	point, err := models.ParseGeoPoint(r.URL.Query().Get("location"))
+	if err != nil {
+		http.Error(w, "Invalid location: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := c.Geocoder.Reverse(point)
+	if err == geocoding.ErrNotFound {
+		http.Error(w, "No address found for location", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Reverse geocoding %s failed: %v", point, err)
+		http.Error(w, "Geocoding failed", http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Normalize checks an address and a "lat,lng" location against each other.
+// Catalog services call it when records are saved.
+func (c *GeocodeController) Normalize(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	location := r.URL.Query().Get("location")
+	if address == "" && location == "" {
+		http.Error(w, "address or location is required", http.StatusBadRequest)
+		return
+	}
+
+	if location != "" {
+		if _, err := models.ParseGeoPoint(location); err != nil {
+			http.Error(w, "Invalid location: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	city := r.URL.Query().Get("city")
+	normalized, err := geocoding.Normalize(c.Geocoder, address, city, location, geocoding.MismatchThresholdKm())
+	if err != nil {
+		log.Printf("Normalizing %q / %q failed: %v", address, location, err)
+		http.Error(w, "Geocoding failed", http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, normalized)
+}
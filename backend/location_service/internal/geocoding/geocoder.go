@@ -0,0 +1,134 @@
+package geocoding
+
+import (
+	"errors"
+	"location_service/internal/models"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrNotFound = errors.New("no geocoding result")
+
+// Result is a geocoded point. RadiusKm is how far the real place may be from
+// Point: a street address is precise, a bare city name is not.
+type Result struct {
+	Point    models.GeoPoint `json:"point"`
+	Address  string          `json:"address"`
+	RadiusKm float64         `json:"radius_km"`
+}
+
+// Geocoder turns addresses into coordinates and coordinates into addresses.
+type Geocoder interface {
+	Geocode(address string) (*Result, error)
+	Reverse(point models.GeoPoint) (*Result, error)
+}
+
+// FromEnv picks the implementation named by GEOCODER: "nominatim" (the
+// default) or "stub" for offline development.
+func FromEnv() Geocoder {
+	if strings.EqualFold(os.Getenv("GEOCODER"), "stub") {
+		return Stub{}
+	}
+
+	baseURL := os.Getenv("GEOCODER_URL")
+	if baseURL == "" {
+		baseURL = "https://nominatim.openstreetmap.org"
+	}
+	userAgent := os.Getenv("GEOCODER_USER_AGENT")
+	if userAgent == "" {
+		userAgent = "SteppeWay/1.0"
+	}
+	return NewNominatim(baseURL, userAgent, os.Getenv("GEOCODER_COUNTRY_CODES"))
+}
+
+// MismatchThresholdKm is how far a record's location may sit from its
+// geocoded address before it is flagged. GEOCODE_MISMATCH_KM overrides it.
+func MismatchThresholdKm() float64 {
+	if value, err := strconv.ParseFloat(os.Getenv("GEOCODE_MISMATCH_KM"), 64); err == nil && value > 0 {
+		return value
+	}
+	return 0.5
+}
+
+const (
+	SourceGeocoded   = "geocoded"
+	SourceReverse    = "reverse"
+	SourceVerified   = "verified"
+	SourceUnverified = "unverified"
+)
+
+// Normalized is an address/location pair after geocoding. Source tells which
+// side was filled in or whether the pair could be checked at all.
+type Normalized struct {
+	Address          string   `json:"address"`
+	Location         string   `json:"location"`
+	FormattedAddress string   `json:"formatted_address"`
+	Mismatch         bool     `json:"mismatch"`
+	MismatchKm       *float64 `json:"mismatch_km,omitempty"`
+	Source           string   `json:"source"`
+}
+
+// Normalize fills a missing location from the address or a missing address
+// from the location. When both are given it geocodes the address and flags
+// the pair if the points are further apart than thresholdKm plus the
+// geocoder's own uncertainty. city, when set, narrows the address lookup.
+func Normalize(g Geocoder, address, city, location string, thresholdKm float64) (*Normalized, error) {
+	address = strings.TrimSpace(address)
+	city = strings.TrimSpace(city)
+	location = strings.TrimSpace(location)
+
+	var point *models.GeoPoint
+	if location != "" {
+		parsed, err := models.ParseGeoPoint(location)
+		if err != nil {
+			return nil, err
+		}
+		point = &parsed
+		location = parsed.String()
+	}
+
+	normalized := &Normalized{Address: address, Location: location, Source: SourceUnverified}
+
+	switch {
+	case address != "":
+		query := address
+		if city != "" && !strings.Contains(strings.ToLower(address), strings.ToLower(city)) {
+			query += ", " + city
+		}
+
+		result, err := g.Geocode(query)
+		if err == ErrNotFound {
+			return normalized, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		normalized.FormattedAddress = result.Address
+
+		if point == nil {
+			normalized.Location = result.Point.String()
+			normalized.Source = SourceGeocoded
+			return normalized, nil
+		}
+
+		distance := math.Round(point.DistanceKm(result.Point)*100) / 100
+		normalized.MismatchKm = &distance
+		normalized.Mismatch = distance > thresholdKm+result.RadiusKm
+		normalized.Source = SourceVerified
+	case point != nil:
+		result, err := g.Reverse(*point)
+		if err == ErrNotFound {
+			return normalized, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		normalized.Address = result.Address
+		normalized.FormattedAddress = result.Address
+		normalized.Source = SourceReverse
+	}
+
+	return normalized, nil
+}
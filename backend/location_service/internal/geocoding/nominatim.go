@@ -0,0 +1,174 @@
+package geocoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"location_service/internal/models"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	nominatimInterval  = time.Second
+	nominatimCacheSize = 1000
+)
+
+// Nominatim talks to a Nominatim-compatible API. Requests are spaced one
+// second apart, as the public instance's usage policy requires, and results
+// are cached because catalog records are geocoded again on every edit.
+type Nominatim struct {
+	BaseURL      string
+	UserAgent    string
+	CountryCodes string
+
+	client *http.Client
+
+	throttle sync.Mutex
+	lastCall time.Time
+
+	cacheMu sync.Mutex
+	cache   map[string]*Result
+}
+
+func NewNominatim(baseURL, userAgent, countryCodes string) *Nominatim {
+	return &Nominatim{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		UserAgent:    userAgent,
+		CountryCodes: countryCodes,
+		client:       &http.Client{Timeout: 5 * time.Second},
+		cache:        make(map[string]*Result),
+	}
+}
+
+type nominatimPlace struct {
+	Lat         string   `json:"lat"`
+	Lon         string   `json:"lon"`
+	DisplayName string   `json:"display_name"`
+	BoundingBox []string `json:"boundingbox"`
+	Error       string   `json:"error"`
+}
+
+func (n *Nominatim) Geocode(address string) (*Result, error) {
+	params := url.Values{}
+	params.Set("q", address)
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	if n.CountryCodes != "" {
+		params.Set("countrycodes", n.CountryCodes)
+	}
+
+	return n.cached("geocode:"+strings.ToLower(address), func() (*Result, error) {
+		var places []nominatimPlace
+		if err := n.get("/search", params, &places); err != nil {
+			return nil, err
+		}
+		if len(places) == 0 {
+			return nil, ErrNotFound
+		}
+		return places[0].result()
+	})
+}
+
+func (n *Nominatim) Reverse(point models.GeoPoint) (*Result, error) {
+	params := url.Values{}
+	params.Set("lat", strconv.FormatFloat(point.Lat, 'f', 6, 64))
+	params.Set("lon", strconv.FormatFloat(point.Lng, 'f', 6, 64))
+	params.Set("format", "jsonv2")
+
+	return n.cached("reverse:"+params.Get("lat")+","+params.Get("lon"), func() (*Result, error) {
+		var place nominatimPlace
+		if err := n.get("/reverse", params, &place); err != nil {
+			return nil, err
+		}
+		if place.Error != "" {
+			return nil, ErrNotFound
+		}
+		result, err := place.result()
+		if err != nil {
+			return nil, err
+		}
+		// Reverse lookups describe the requested point, not the matched feature.
+		result.Point = point
+		return result, nil
+	})
+}
+
+func (n *Nominatim) get(path string, params url.Values, dest interface{}) error {
+	n.throttle.Lock()
+	if wait := nominatimInterval - time.Since(n.lastCall); wait > 0 {
+		time.Sleep(wait)
+	}
+	n.lastCall = time.Now()
+	n.throttle.Unlock()
+
+	req, err := http.NewRequest("GET", n.BaseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", n.UserAgent)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("geocoder returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+// cached only remembers successful lookups so that outages are not stored.
+func (n *Nominatim) cached(key string, lookup func() (*Result, error)) (*Result, error) {
+	n.cacheMu.Lock()
+	if result, ok := n.cache[key]; ok {
+		n.cacheMu.Unlock()
+		return result, nil
+	}
+	n.cacheMu.Unlock()
+
+	result, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	n.cacheMu.Lock()
+	if len(n.cache) >= nominatimCacheSize {
+		n.cache = make(map[string]*Result)
+	}
+	n.cache[key] = result
+	n.cacheMu.Unlock()
+
+	return result, nil
+}
+
+func (p nominatimPlace) result() (*Result, error) {
+	point, err := models.ParseGeoPoint(p.Lat + "," + p.Lon)
+	if err != nil {
+		return nil, fmt.Errorf("geocoder returned invalid coordinates: %v", err)
+	}
+
+	result := &Result{Point: point, Address: p.DisplayName}
+
+	// boundingbox is [min_lat, max_lat, min_lon, max_lon]; half its diagonal
+	// bounds how far the feature extends from the returned point.
+	if len(p.BoundingBox) == 4 {
+		values := make([]float64, 4)
+		for i, value := range p.BoundingBox {
+			if values[i], err = strconv.ParseFloat(value, 64); err != nil {
+				return result, nil
+			}
+		}
+		corner := models.GeoPoint{Lat: values[0], Lng: values[2]}
+		opposite := models.GeoPoint{Lat: values[1], Lng: values[3]}
+		result.RadiusKm = math.Round(corner.DistanceKm(opposite)/2*100) / 100
+	}
+
+	return result, nil
+}
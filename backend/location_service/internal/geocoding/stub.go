@@ -0,0 +1,57 @@
+package geocoding
+
+import (
+	"location_service/internal/cities"
+	"location_service/internal/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Stub geocodes offline against the city table: an address resolves to the
+// center of the city it mentions and a point to the city containing it. It
+// is only as precise as a city, which RadiusKm reports.
+type Stub struct{}
+
+func (Stub) Geocode(address string) (*Result, error) {
+	parts := strings.Split(address, ",")
+	// The city usually comes after the street, so try the parts from the end.
+	for i := len(parts) - 1; i >= 0; i-- {
+		city, err := cities.FindByName(parts[i])
+		if err == gorm.ErrRecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &Result{
+			Point:    city.Center,
+			Address:  strings.TrimSpace(address),
+			RadiusKm: cityRadiusKm(city),
+		}, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (Stub) Reverse(point models.GeoPoint) (*Result, error) {
+	city, err := cities.FindByPoint(point)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	city.LocalizeName(models.DefaultLocale)
+	return &Result{
+		Point:    point,
+		Address:  city.Name + ", " + city.Country,
+		RadiusKm: cityRadiusKm(city),
+	}, nil
+}
+
+func cityRadiusKm(city *models.City) float64 {
+	corner := models.GeoPoint{Lat: city.BBox.MinLat, Lng: city.BBox.MinLng}
+	opposite := models.GeoPoint{Lat: city.BBox.MaxLat, Lng: city.BBox.MaxLng}
+	return corner.DistanceKm(opposite) / 2
+}
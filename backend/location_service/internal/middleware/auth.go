@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+)
+
+func AdminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Incoming admin request to: %s", r.URL.Path)
+
+		_, err := r.Cookie("session_token")
+		if err != nil {
+			log.Printf("No session_token cookie found: %v", err)
+			http.Error(w, "Unauthorized - No session token", http.StatusUnauthorized)
+			return
+		}
+
+		// Call to auth-service to verify admin status
+		authServiceURL := "http://auth-service:8082/validate-admin"
+		req, err := http.NewRequest("GET", authServiceURL, nil)
+		if err != nil {
+			log.Printf("Error creating admin validation request: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		req.Header.Set("Cookie", r.Header.Get("Cookie"))
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Printf("Error calling auth service: %v", err)
+			http.Error(w, "Unauthorized - Auth service error", http.StatusUnauthorized)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			log.Printf("Admin validation failed with status: %d", resp.StatusCode)
+			http.Error(w, "Unauthorized - Admin access required", http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		var authResponse map[string]interface{}
+		json.Unmarshal(body, &authResponse)
+
+		adminID, exists := authResponse["admin_id"].(float64)
+		if !exists {
+			log.Println("Auth service response did not contain admin_id")
+			http.Error(w, "Unauthorized - Invalid admin session", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "admin_id", uint(adminID))
+		r = r.WithContext(ctx)
+
+		log.Printf("Admin authentication successful: admin_id=%v", int(adminID))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const DefaultLocale = "en"
+
+type City struct {
+	gorm.Model
+	Slug     string      `json:"slug" gorm:"uniqueIndex;not null"`
+	Country  string      `json:"country" gorm:"size:2;index;not null"` // ISO 3166-1 alpha-2
+	Timezone string      `json:"timezone" gorm:"not null"`
+	Center   GeoPoint    `json:"center" gorm:"embedded;embeddedPrefix:center_"`
+	BBox     BoundingBox `json:"bbox" gorm:"embedded;embeddedPrefix:bbox_"`
+	Name     string      `json:"name" gorm:"-"`
+	Names    []CityName  `json:"names" gorm:"foreignKey:CityID;constraint:OnDelete:CASCADE;"`
+}
+
+// CityName is a localized or alternative spelling. NameKey is the
+// transliterated form used for matching free-text input.
+type CityName struct {
+	gorm.Model
+	CityID  uint   `json:"city_id" gorm:"index;not null"`
+	Locale  string `json:"locale" gorm:"size:8;not null"`
+	Name    string `json:"name" gorm:"not null"`
+	NameKey string `json:"-" gorm:"index;not null"`
+}
+
+// LocalizeName fills Name from Names, falling back to English and then to
+// any known name.
+func (c *City) LocalizeName(locale string) {
+	c.Name = c.Slug
+	fallback := ""
+	for _, name := range c.Names {
+		if name.Locale == locale {
+			c.Name = name.Name
+			return
+		}
+		if name.Locale == DefaultLocale && fallback == "" {
+			fallback = name.Name
+		}
+	}
+	if fallback != "" {
+		c.Name = fallback
+	} else if len(c.Names) > 0 {
+		c.Name = c.Names[0].Name
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusKm = 6371.0
+
+// GeoPoint is a WGS84 coordinate. Other services still store points as
+// "lat,lng" strings, which ParseGeoPoint and String convert from and to.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func ParseGeoPoint(location string) (GeoPoint, error) {
+	parts := strings.Split(location, ",")
+	if len(parts) != 2 {
+		return GeoPoint{}, fmt.Errorf("location must be \"lat,lng\"")
+	}
+
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil {
+		return GeoPoint{}, fmt.Errorf("location must be \"lat,lng\"")
+	}
+
+	point := GeoPoint{Lat: lat, Lng: lng}
+	if !point.Valid() {
+		return GeoPoint{}, fmt.Errorf("coordinates out of range")
+	}
+	return point, nil
+}
+
+func (p GeoPoint) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+func (p GeoPoint) String() string {
+	return strconv.FormatFloat(p.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(p.Lng, 'f', -1, 64)
+}
+
+// DistanceKm is the haversine distance between two points.
+func (p GeoPoint) DistanceKm(other GeoPoint) float64 {
+	lat1 := p.Lat * math.Pi / 180
+	lat2 := other.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (other.Lng - p.Lng) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+func (b BoundingBox) Contains(p GeoPoint) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+func (b BoundingBox) Valid() bool {
+	return b.MinLat < b.MaxLat && b.MinLng < b.MaxLng &&
+		GeoPoint{Lat: b.MinLat, Lng: b.MinLng}.Valid() &&
+		GeoPoint{Lat: b.MaxLat, Lng: b.MaxLng}.Valid()
+}
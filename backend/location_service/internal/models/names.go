@@ -0,0 +1,40 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// cyrillicToLatin covers Russian and Kazakh letters. Kazakh-specific letters
+// map to their closest common English spelling ("Қарағанды" -> "karagandy").
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'ә': "a", 'ғ': "g", 'қ': "k", 'ң': "n", 'ө': "o", 'ұ': "u", 'ү': "u",
+	'һ': "h", 'і': "i",
+}
+
+// NameKey normalizes a city name for matching: lowercase, punctuation and
+// extra whitespace dropped, Cyrillic transliterated to Latin. "Almaty ",
+// "almaty" and "Алматы" all produce "almaty".
+func NameKey(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		for _, r := range word {
+			if latin, ok := cyrillicToLatin[r]; ok {
+				b.WriteString(latin)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"location_service/internal/controllers"
+	"location_service/internal/geocoding"
+	"location_service/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func SetupRoutes() *mux.Router {
+	r := mux.NewRouter()
+
+	cityController := controllers.CityController{}
+	geocodeController := controllers.GeocodeController{Geocoder: geocoding.FromEnv()}
+
+	r.HandleFunc("/cities", cityController.ListCities).Methods("GET")
+	r.HandleFunc("/cities/resolve", cityController.ResolveCity).Methods("GET")
+	r.HandleFunc("/cities/{id:[0-9]+}", cityController.GetCity).Methods("GET")
+
+	r.HandleFunc("/geocode", geocodeController.Geocode).Methods("GET")
+	r.HandleFunc("/geocode/reverse", geocodeController.Reverse).Methods("GET")
+	r.HandleFunc("/geocode/normalize", geocodeController.Normalize).Methods("GET")
+
+	admin := r.PathPrefix("/admin/cities").Subrouter()
+	admin.Use(middleware.AdminAuthMiddleware)
+	admin.HandleFunc("", cityController.CreateCity).Methods("POST")
+	admin.HandleFunc("/{id:[0-9]+}", cityController.UpdateCity).Methods("PUT")
+	admin.HandleFunc("/{id:[0-9]+}", cityController.DeleteCity).Methods("DELETE")
+	admin.HandleFunc("/backfill", cityController.RunBackfill).Methods("POST")
+
+	return r
+}
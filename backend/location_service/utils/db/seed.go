@@ -0,0 +1,80 @@
+package db
+
+import (
+	"location_service/internal/models"
+	"log"
+	"math"
+)
+
+type citySeed struct {
+	Slug     string
+	Country  string
+	Timezone string
+	Lat, Lng float64
+	RadiusKm float64
+	Names    [][2]string // locale, name; the first name per locale is the display name
+}
+
+var defaultCities = []citySeed{
+	{"almaty", "KZ", "Asia/Almaty", 43.2383, 76.9456, 35, [][2]string{{"en", "Almaty"}, {"ru", "Алматы"}, {"kk", "Алматы"}, {"en", "Alma-Ata"}, {"ru", "Алма-Ата"}}},
+	{"astana", "KZ", "Asia/Almaty", 51.1694, 71.4491, 30, [][2]string{{"en", "Astana"}, {"ru", "Астана"}, {"kk", "Астана"}, {"en", "Nur-Sultan"}, {"ru", "Нур-Султан"}}},
+	{"shymkent", "KZ", "Asia/Almaty", 42.3417, 69.5901, 30, [][2]string{{"en", "Shymkent"}, {"ru", "Шымкент"}, {"kk", "Шымкент"}, {"en", "Chimkent"}, {"ru", "Чимкент"}}},
+	{"karaganda", "KZ", "Asia/Almaty", 49.8047, 73.1094, 25, [][2]string{{"en", "Karaganda"}, {"ru", "Караганда"}, {"kk", "Қарағанды"}, {"en", "Karagandy"}}},
+	{"aktobe", "KZ", "Asia/Aqtobe", 50.2839, 57.1670, 25, [][2]string{{"en", "Aktobe"}, {"ru", "Актобе"}, {"kk", "Ақтөбе"}, {"en", "Aqtobe"}}},
+	{"taraz", "KZ", "Asia/Almaty", 42.9000, 71.3667, 20, [][2]string{{"en", "Taraz"}, {"ru", "Тараз"}, {"kk", "Тараз"}}},
+	{"pavlodar", "KZ", "Asia/Almaty", 52.2873, 76.9674, 20, [][2]string{{"en", "Pavlodar"}, {"ru", "Павлодар"}, {"kk", "Павлодар"}}},
+	{"oskemen", "KZ", "Asia/Almaty", 49.9483, 82.6279, 20, [][2]string{{"en", "Oskemen"}, {"ru", "Усть-Каменогорск"}, {"kk", "Өскемен"}, {"en", "Ust-Kamenogorsk"}}},
+	{"semey", "KZ", "Asia/Almaty", 50.4111, 80.2275, 20, [][2]string{{"en", "Semey"}, {"ru", "Семей"}, {"kk", "Семей"}, {"en", "Semipalatinsk"}, {"ru", "Семипалатинск"}}},
+	{"atyrau", "KZ", "Asia/Atyrau", 47.0945, 51.9238, 20, [][2]string{{"en", "Atyrau"}, {"ru", "Атырау"}, {"kk", "Атырау"}}},
+	{"kostanay", "KZ", "Asia/Qostanay", 53.2144, 63.6246, 20, [][2]string{{"en", "Kostanay"}, {"ru", "Костанай"}, {"kk", "Қостанай"}}},
+	{"kyzylorda", "KZ", "Asia/Qyzylorda", 44.8488, 65.4823, 20, [][2]string{{"en", "Kyzylorda"}, {"ru", "Кызылорда"}, {"kk", "Қызылорда"}}},
+	{"oral", "KZ", "Asia/Oral", 51.2333, 51.3667, 20, [][2]string{{"en", "Oral"}, {"ru", "Уральск"}, {"kk", "Орал"}, {"en", "Uralsk"}}},
+	{"petropavl", "KZ", "Asia/Almaty", 54.8750, 69.1625, 20, [][2]string{{"en", "Petropavl"}, {"ru", "Петропавловск"}, {"kk", "Петропавл"}, {"en", "Petropavlovsk"}}},
+	{"aktau", "KZ", "Asia/Aqtau", 43.6500, 51.1667, 20, [][2]string{{"en", "Aktau"}, {"ru", "Актау"}, {"kk", "Ақтау"}}},
+	{"turkistan", "KZ", "Asia/Almaty", 43.2973, 68.2518, 15, [][2]string{{"en", "Turkistan"}, {"ru", "Туркестан"}, {"kk", "Түркістан"}}},
+	{"taldykorgan", "KZ", "Asia/Almaty", 45.0156, 78.3739, 15, [][2]string{{"en", "Taldykorgan"}, {"ru", "Талдыкорган"}, {"kk", "Талдықорған"}}},
+	{"kokshetau", "KZ", "Asia/Almaty", 53.2833, 69.3833, 15, [][2]string{{"en", "Kokshetau"}, {"ru", "Кокшетау"}, {"kk", "Көкшетау"}}},
+}
+
+// BoundingBoxAround returns a box extending radiusKm from the center in
+// every direction.
+func BoundingBoxAround(center models.GeoPoint, radiusKm float64) models.BoundingBox {
+	latDelta := radiusKm / 111.0
+	lngDelta := radiusKm / (111.0 * math.Max(math.Cos(center.Lat*math.Pi/180), 0.01))
+	return models.BoundingBox{
+		MinLat: center.Lat - latDelta,
+		MinLng: center.Lng - lngDelta,
+		MaxLat: center.Lat + latDelta,
+		MaxLng: center.Lng + lngDelta,
+	}
+}
+
+func seedCities() {
+	var count int64
+	if err := DB.Model(&models.City{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
+	for _, seed := range defaultCities {
+		center := models.GeoPoint{Lat: seed.Lat, Lng: seed.Lng}
+		city := models.City{
+			Slug:     seed.Slug,
+			Country:  seed.Country,
+			Timezone: seed.Timezone,
+			Center:   center,
+			BBox:     BoundingBoxAround(center, seed.RadiusKm),
+		}
+		for _, name := range seed.Names {
+			city.Names = append(city.Names, models.CityName{
+				Locale:  name[0],
+				Name:    name[1],
+				NameKey: models.NameKey(name[1]),
+			})
+		}
+		if err := DB.Create(&city).Error; err != nil {
+			log.Printf("Failed to seed city %s: %v", seed.Slug, err)
+		}
+	}
+
+	log.Printf("Seeded %d cities", len(defaultCities))
+}
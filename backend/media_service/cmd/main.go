@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"media_service/internal/gc"
+	"media_service/internal/media"
+	"media_service/internal/routes"
+	"media_service/internal/signing"
+	"media_service/internal/storage"
+	"media_service/utils/db"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %s", name, value, fallback)
+	}
+	return fallback
+}
+
+func main() {
+	db.ConnectDB()
+
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure media storage: %v", err)
+	}
+	log.Printf("Using %s media storage", store.Name())
+
+	secret := os.Getenv("MEDIA_URL_SECRET")
+	if secret == "" {
+		log.Println("MEDIA_URL_SECRET is not set, using an insecure default")
+		secret = "change-me-media-secret"
+	}
+
+	mediaService := &media.Service{
+		Store:    store,
+		Signer:   &signing.Signer{Secret: []byte(secret)},
+		MaxBytes: media.MaxBytesFromEnv(),
+	}
+	mediaService.StartProcessing(media.WorkersFromEnv())
+
+	legacyUploadsDir := os.Getenv("LEGACY_UPLOADS_DIR")
+	if legacyUploadsDir == "" {
+		legacyUploadsDir = "/app/uploads"
+	}
+
+	keep := "users/default_user.jpg"
+	if value, ok := os.LookupEnv("MEDIA_GC_KEEP"); ok {
+		keep = value
+	}
+	dryRun, _ := strconv.ParseBool(os.Getenv("MEDIA_GC_DRY_RUN"))
+	collector := &gc.Collector{
+		Store:     store,
+		Grace:     durationFromEnv("MEDIA_GC_GRACE", 24*time.Hour),
+		DryRun:    dryRun,
+		LegacyDir: legacyUploadsDir,
+		Keep:      strings.FieldsFunc(keep, func(r rune) bool { return r == ',' }),
+		Metrics:   gc.NewMetrics(),
+	}
+	collector.Start(durationFromEnv("MEDIA_GC_INTERVAL", 6*time.Hour))
+
+	router := routes.SetupRoutes(mediaService, collector, legacyUploadsDir)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8094"
+	}
+
+	log.Printf("Media service running on port %s", port)
+
+	log.Fatal(http.ListenAndServe(":"+port, router))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"media_service/internal/s3stub"
+	"media_service/internal/storage/sigv4"
+	"net/http"
+	"os"
+)
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func main() {
+	server := &s3stub.Server{
+		Root: getenv("S3STUB_DIR", "/data"),
+		Creds: sigv4.Credentials{
+			AccessKey: getenv("S3_ACCESS_KEY", "minioadmin"),
+			SecretKey: getenv("S3_SECRET_KEY", "minioadmin"),
+			Region:    getenv("S3_REGION", "us-east-1"),
+		},
+	}
+
+	port := getenv("PORT", "9000")
+	log.Printf("S3 stub serving %s on port %s", server.Root, port)
+	log.Fatal(http.ListenAndServe(":"+port, server))
+}
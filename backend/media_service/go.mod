@@ -0,0 +1,23 @@
+module media_service
+
+go 1.24.0
+
+require (
+	github.com/HugoSmits86/nativewebp v0.9.3
+	github.com/gorilla/mux v1.8.1
+	golang.org/x/image v0.24.0
+	gorm.io/driver/postgres v1.5.11
+	gorm.io/gorm v1.26.1
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/pgx/v5 v5.5.5 // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"media_service/internal/gc"
+	"net/http"
+	"strconv"
+)
+
+type GCController struct {
+	Collector *gc.Collector
+	Metrics   *gc.Metrics
+}
+
+// Run lets admins reconcile now instead of waiting for the next tick.
+// ?dry_run=true only reports; it defaults to the configured mode.
+func (c *GCController) Run(w http.ResponseWriter, r *http.Request) {
+	dryRun := c.Collector.DryRun
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid dry_run", http.StatusBadRequest)
+			return
+		}
+		dryRun = parsed
+	}
+
+	writeJSON(w, http.StatusOK, c.Collector.Run(r.Context(), dryRun))
+}
+
+// LastReport returns the report of the latest run.
+func (c *GCController) LastReport(w http.ResponseWriter, r *http.Request) {
+	report := c.Collector.LastReport()
+	if report == nil {
+		http.Error(w, "No reconciliation has run yet", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// ServeMetrics serves reconciliation metrics for Prometheus. It is not routed
+// by the gateway.
+func (c *GCController) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	c.Metrics.Write(w)
+}
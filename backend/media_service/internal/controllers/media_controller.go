@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"media_service/internal/imaging"
+	"media_service/internal/media"
+	"media_service/internal/models"
+	"media_service/internal/signing"
+	"media_service/internal/storage"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type MediaController struct {
+	Media *media.Service
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeMediaError maps media errors to HTTP statuses.
+func writeMediaError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, media.ErrNotFound), errors.Is(err, storage.ErrNotFound):
+		http.Error(w, media.ErrNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, media.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, media.ErrEmpty), errors.Is(err, media.ErrInvalidHash),
+		errors.Is(err, media.ErrHashMismatch), errors.Is(err, media.ErrInvalidUpload),
+		errors.Is(err, media.ErrUnknownVariant), errors.Is(err, imaging.ErrInvalidImage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, imaging.ErrUnsupportedType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, imaging.ErrTooManyPixels):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, media.ErrNotPending), errors.Is(err, media.ErrNotUploaded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, media.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		log.Printf("Media error: %v", err)
+		http.Error(w, "Failed to process media", http.StatusInternalServerError)
+	}
+}
+
+func contextUserID(r *http.Request) (uint, bool) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	return userID, ok
+}
+
+// withURL fills in the stable URL other services store for a media, and
+// those of its variants.
+func withURL(m *models.Media) *models.Media {
+	m.URL = fmt.Sprintf("/media/%d", m.ID)
+	for i := range m.Variants {
+		m.Variants[i].URL = m.URL + "?variant=" + m.Variants[i].Name
+	}
+	return m
+}
+
+func (c *MediaController) findMedia(w http.ResponseWriter, r *http.Request) (*models.Media, bool) {
+	m, err := media.Find(mux.Vars(r)["id"])
+	if err != nil {
+		writeMediaError(w, err)
+		return nil, false
+	}
+	return withURL(m), true
+}
+
+// upload stores the "file" part of a multipart request.
+func (c *MediaController) upload(w http.ResponseWriter, r *http.Request, params media.UploadParams) {
+	r.Body = http.MaxBytesReader(w, r.Body, c.Media.MaxBytes+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeMediaError(w, media.ErrTooLarge)
+			return
+		}
+		http.Error(w, "No file provided", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	params.OriginalName = header.Filename
+	m, err := c.Media.Upload(r.Context(), file, params)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, withURL(m))
+}
+
+// InternalUpload lets other services store files; owner_service names the
+// caller. It is not routed by the gateway.
+func (c *MediaController) InternalUpload(w http.ResponseWriter, r *http.Request) {
+	c.upload(w, r, media.UploadParams{OwnerService: r.URL.Query().Get("owner_service")})
+}
+
+// Upload stores a file sent by a signed-in user.
+func (c *MediaController) Upload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+	c.upload(w, r, media.UploadParams{UploadedBy: &userID})
+}
+
+// CreateUpload returns a signed URL the user PUTs the file to, after
+// which they call Complete. Content already stored is ready at once.
+func (c *MediaController) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	var req media.UploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	m, uploadURL, err := c.Media.CreateUpload(r.Context(), req, media.UploadParams{UploadedBy: &userID})
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{"media": withURL(m)}
+	if uploadURL != "" {
+		response["upload_url"] = uploadURL
+		response["upload_method"] = http.MethodPut
+		response["expires_at"] = time.Now().Add(media.UploadTTL)
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// ReceiveUpload takes the body of a signed upload URL. The signature
+// authorizes the request, so it needs no session.
+func (c *MediaController) ReceiveUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, media.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err := c.Media.Signer.Verify(http.MethodPut, media.UploadPath(uint(id)), r.URL.Query(), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	m, ok := c.findMedia(w, r)
+	if !ok {
+		return
+	}
+	if err := c.Media.Receive(r.Context(), m, r.Body); err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// Complete marks an upload made to a presigned store URL as done.
+func (c *MediaController) Complete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+	m, ok := c.findMedia(w, r)
+	if !ok {
+		return
+	}
+	if m.UploadedBy == nil || *m.UploadedBy != userID {
+		writeMediaError(w, media.ErrForbidden)
+		return
+	}
+	if err := c.Media.Complete(r.Context(), m); err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// findObject resolves the media of a request and the original or the
+// variant named by ?variant=.
+func (c *MediaController) findObject(w http.ResponseWriter, r *http.Request) (*media.Object, bool) {
+	m, ok := c.findMedia(w, r)
+	if !ok {
+		return nil, false
+	}
+	object, err := c.Media.Object(m, r.URL.Query().Get("variant"))
+	if err != nil {
+		writeMediaError(w, err)
+		return nil, false
+	}
+	return object, true
+}
+
+// Get redirects to a short-lived signed URL, so /media/{id} can be used
+// wherever an image URL is expected; ?variant=thumb, medium or large picks
+// a resized WebP.
+func (c *MediaController) Get(w http.ResponseWriter, r *http.Request) {
+	object, ok := c.findObject(w, r)
+	if !ok {
+		return
+	}
+	url, _, err := c.Media.DownloadURL(object)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	// Browsers may reuse the redirect for a while, never past the URL's
+	// expiry.
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(media.DownloadTTL.Seconds()/2)))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// GetURL returns a signed download URL and its expiry.
+func (c *MediaController) GetURL(w http.ResponseWriter, r *http.Request) {
+	object, ok := c.findObject(w, r)
+	if !ok {
+		return
+	}
+	url, expires, err := c.Media.DownloadURL(object)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"url": url, "expires_at": expires})
+}
+
+func (c *MediaController) GetInfo(w http.ResponseWriter, r *http.Request) {
+	m, ok := c.findMedia(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// Content serves a media through the service for stores that cannot sign
+// URLs themselves.
+func (c *MediaController) Content(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, media.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if !c.verifyDownload(w, r, media.ContentPath(uint(id))) {
+		return
+	}
+
+	c.serveContent(w, r, "")
+}
+
+// VariantContent serves a variant like Content serves the original.
+func (c *MediaController) VariantContent(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		http.Error(w, media.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if !c.verifyDownload(w, r, media.VariantPath(uint(id), vars["variant"])) {
+		return
+	}
+
+	c.serveContent(w, r, vars["variant"])
+}
+
+func (c *MediaController) verifyDownload(w http.ResponseWriter, r *http.Request, path string) bool {
+	// HEAD requests reuse the GET signature.
+	if err := c.Media.Signer.Verify(http.MethodGet, path, r.URL.Query(), time.Now()); err != nil {
+		status := http.StatusForbidden
+		if err == signing.ErrExpired {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
+		return false
+	}
+	return true
+}
+
+// InternalContent serves a media, or its ?variant=, to other services,
+// which fetch from inside the network and need no signed URL. It is not
+// routed by the gateway.
+func (c *MediaController) InternalContent(w http.ResponseWriter, r *http.Request) {
+	c.serveContent(w, r, r.URL.Query().Get("variant"))
+}
+
+func (c *MediaController) serveContent(w http.ResponseWriter, r *http.Request, variant string) {
+	m, ok := c.findMedia(w, r)
+	if !ok {
+		return
+	}
+	object, err := c.Media.Object(m, variant)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	body, err := c.Media.Open(r.Context(), object)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	defer body.Close()
+
+	// Content never changes for a key, so caches may keep it.
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+object.Key+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !strings.HasPrefix(object.ContentType, "image/") {
+		// Never render uploaded HTML or scripts inline.
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, body)
+}
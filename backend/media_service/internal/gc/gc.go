@@ -0,0 +1,370 @@
+// Package gc finds uploads nothing refers to any more, such as the images
+// of deleted items or replaced profile pictures, and removes them once
+// they are older than a grace period.
+package gc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"media_service/internal/models"
+	"media_service/internal/storage"
+	"media_service/utils/db"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSamples caps how many orphans a report lists by name.
+const maxSamples = 100
+
+// Report describes one reconciliation run. In a dry run nothing is
+// removed and the Removed counts stay zero.
+type Report struct {
+	DryRun     bool           `json:"dry_run"`
+	StartedAt  time.Time      `json:"started_at"`
+	Duration   string         `json:"duration"`
+	References map[string]int `json:"references"`
+	// Orphaned and Removed are keyed by kind: "media" rows, "objects" in
+	// the store and "legacy" files under the old uploads directory.
+	Orphaned       map[string]int `json:"orphaned"`
+	Removed        map[string]int `json:"removed"`
+	OrphanedBytes  int64          `json:"orphaned_bytes"`
+	ReclaimedBytes int64          `json:"reclaimed_bytes"`
+	Samples        []string       `json:"samples,omitempty"`
+	Errors         []string       `json:"errors,omitempty"`
+}
+
+func (r *Report) orphan(kind, name string, size int64) {
+	r.Orphaned[kind]++
+	r.OrphanedBytes += size
+	if len(r.Samples) < maxSamples {
+		r.Samples = append(r.Samples, kind+": "+name)
+	}
+}
+
+func (r *Report) fail(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// Collector reconciles the media table, the object store and the legacy
+// uploads directory against the image tables of every service.
+type Collector struct {
+	Store storage.Storage
+	// Grace protects recent uploads whose owning row may not be written
+	// yet, and objects still being uploaded.
+	Grace time.Duration
+	// DryRun makes scheduled runs report without removing anything.
+	DryRun bool
+	// LegacyDir holds files uploaded before the media service; Keep lists
+	// path.Match patterns, relative to it, that are never removed.
+	LegacyDir string
+	Keep      []string
+
+	Metrics *Metrics
+
+	mu   sync.Mutex
+	last *Report
+}
+
+// Start runs a reconciliation every interval in the background.
+func (c *Collector) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			c.logRun(c.Run(context.Background(), c.DryRun))
+		}
+	}()
+}
+
+func (c *Collector) logRun(report *Report) {
+	for _, message := range report.Errors {
+		log.Printf("Media GC error: %s", message)
+	}
+	log.Printf("Media GC finished in %s (dry run: %v): orphaned=%v removed=%v reclaimed=%d bytes",
+		report.Duration, report.DryRun, report.Orphaned, report.Removed, report.ReclaimedBytes)
+}
+
+// LastReport returns the report of the latest run, or nil.
+func (c *Collector) LastReport() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Run reconciles once. Nothing is removed when any service's references
+// cannot be read, since a missing table would make everything it refers
+// to look orphaned.
+func (c *Collector) Run(ctx context.Context, dryRun bool) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	started := time.Now()
+	cutoff := started.Add(-c.Grace)
+	report := &Report{
+		DryRun:     dryRun,
+		StartedAt:  started,
+		References: make(map[string]int),
+		Orphaned:   make(map[string]int),
+		Removed:    make(map[string]int),
+	}
+
+	mediaIDs, legacyPaths := c.references(report)
+	if len(report.Errors) == 0 {
+		orphanedIDs := c.collectMedia(report, mediaIDs, cutoff, dryRun)
+		if len(report.Errors) == 0 {
+			c.collectObjects(ctx, report, orphanedIDs, cutoff, dryRun)
+		}
+		c.collectLegacy(report, legacyPaths, cutoff, dryRun)
+	}
+
+	report.Duration = time.Since(started).String()
+	c.last = report
+	if c.Metrics != nil {
+		c.Metrics.record(report, time.Since(started))
+	}
+	return report
+}
+
+// references reads every source and splits the URLs into media IDs and
+// paths under the legacy uploads directory.
+func (c *Collector) references(report *Report) (map[uint]bool, map[string]bool) {
+	mediaIDs := make(map[uint]bool)
+	legacyPaths := make(map[string]bool)
+
+	for _, src := range sources {
+		if src.Legacy != "" && !db.DB.Migrator().HasTable(src.Legacy) {
+			continue
+		}
+		var urls []sql.NullString
+		if err := db.DB.Raw(src.Query).Scan(&urls).Error; err != nil {
+			report.fail("%s: %v", src.Name, err)
+			continue
+		}
+		for _, value := range urls {
+			refs := []string{value.String}
+			if src.Text {
+				refs = embeddedPaths.FindAllString(value.String, -1)
+			}
+			for _, url := range refs {
+				url, _, _ = strings.Cut(url, "?")
+				if value, ok := strings.CutPrefix(url, "/media/"); ok {
+					if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+						mediaIDs[uint(id)] = true
+						report.References[src.Name]++
+					}
+				} else if value, ok := strings.CutPrefix(url, "/uploads/"); ok {
+					legacyPaths[path.Clean(value)] = true
+					report.References[src.Name]++
+				}
+			}
+		}
+	}
+	return mediaIDs, legacyPaths
+}
+
+// embeddedPaths finds the media and upload paths in a document, whether
+// relative or in absolute URLs, up to where a Markdown link or HTML
+// attribute would end.
+var embeddedPaths = regexp.MustCompile(`/(?:media/\d+|uploads/[^\s"'()<>\[\]?#]+)`)
+
+// collectMedia removes media rows older than cutoff that nothing refers
+// to, pending uploads that were never finished included. It returns their
+// IDs, which in a dry run are still in the table.
+func (c *Collector) collectMedia(report *Report, referenced map[uint]bool, cutoff time.Time, dryRun bool) map[uint]bool {
+	var candidates []models.Media
+	if err := db.DB.Where("created_at < ?", cutoff).Order("id").Find(&candidates).Error; err != nil {
+		report.fail("media: %v", err)
+		return nil
+	}
+
+	orphaned := make(map[uint]bool)
+	var ids []uint
+	for _, media := range candidates {
+		if referenced[media.ID] {
+			continue
+		}
+		orphaned[media.ID] = true
+		ids = append(ids, media.ID)
+		report.orphan("media", fmt.Sprintf("%d (%s)", media.ID, media.OwnerService), media.Size)
+	}
+	if dryRun || len(ids) == 0 {
+		return orphaned
+	}
+
+	// Hard delete: a soft-deleted row would still hold on to its object.
+	if err := db.DB.Unscoped().Where("id IN ?", ids).Delete(&models.Media{}).Error; err != nil {
+		report.fail("media: %v", err)
+		return orphaned
+	}
+	report.Removed["media"] = len(ids)
+	return orphaned
+}
+
+// collectObjects removes stored objects older than cutoff that no media
+// row uses any more: content of removed media, variants of that content,
+// raw direct uploads replaced by their stripped copy, and temporary files
+// of interrupted writes.
+func (c *Collector) collectObjects(ctx context.Context, report *Report, removedIDs map[uint]bool, cutoff time.Time, dryRun bool) {
+	type row struct {
+		ID     uint
+		Key    string
+		SHA256 string
+	}
+	var rows []row
+	if err := db.DB.Unscoped().Model(&models.Media{}).Select("id, key, sha256").Scan(&rows).Error; err != nil {
+		report.fail("objects: %v", err)
+		return
+	}
+	liveKeys := make(map[string]bool)
+	liveSums := make(map[string]bool)
+	for _, r := range rows {
+		if removedIDs[r.ID] {
+			continue
+		}
+		liveKeys[r.Key] = true
+		liveSums[r.SHA256] = true
+	}
+
+	var orphans []storage.ObjectInfo
+	err := c.Store.List(ctx, "", func(object storage.ObjectInfo) error {
+		if object.LastModified.After(cutoff) || liveKeys[object.Key] {
+			return nil
+		}
+		if sum, ok := variantSum(object.Key); ok && liveSums[sum] {
+			return nil
+		}
+		orphans = append(orphans, object)
+		return nil
+	})
+	if err != nil {
+		report.fail("objects: %v", err)
+		return
+	}
+
+	for _, object := range orphans {
+		report.orphan("objects", object.Key, object.Size)
+		if dryRun {
+			continue
+		}
+		// An upload of the same content may have claimed the object
+		// since the media table was read.
+		if inUse, err := objectInUse(object.Key); err != nil || inUse {
+			if err != nil {
+				report.fail("objects: %s: %v", object.Key, err)
+			}
+			continue
+		}
+		if err := c.Store.Delete(ctx, object.Key); err != nil {
+			report.fail("objects: %s: %v", object.Key, err)
+			continue
+		}
+		report.Removed["objects"]++
+		report.ReclaimedBytes += object.Size
+	}
+
+	if dryRun {
+		return
+	}
+	var sums []string
+	if err := db.DB.Model(&models.MediaVariant{}).Distinct().Pluck("sha256", &sums).Error; err != nil {
+		report.fail("variants: %v", err)
+		return
+	}
+	var stale []string
+	for _, sum := range sums {
+		if !liveSums[sum] {
+			stale = append(stale, sum)
+		}
+	}
+	if len(stale) > 0 {
+		if err := db.DB.Where("sha256 IN ?", stale).Delete(&models.MediaVariant{}).Error; err != nil {
+			report.fail("variants: %v", err)
+		}
+	}
+}
+
+func objectInUse(key string) (bool, error) {
+	query := db.DB.Model(&models.Media{}).Where("key = ?", key)
+	if sum, ok := variantSum(key); ok {
+		query = db.DB.Model(&models.Media{}).Where("sha256 = ?", sum)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// variantSum extracts the content hash from a key built by
+// media.VariantKey.
+func variantSum(key string) (string, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 5 || parts[0] != "variants" {
+		return "", false
+	}
+	return parts[3], true
+}
+
+// collectLegacy removes files under LegacyDir older than cutoff whose
+// /uploads/... URL nothing refers to.
+func (c *Collector) collectLegacy(report *Report, referenced map[string]bool, cutoff time.Time, dryRun bool) {
+	if c.LegacyDir == "" {
+		return
+	}
+	if _, err := os.Stat(c.LegacyDir); os.IsNotExist(err) {
+		return
+	}
+
+	err := filepath.WalkDir(c.LegacyDir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(c.LegacyDir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if referenced[rel] || c.keep(rel) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+
+		report.orphan("legacy", rel, info.Size())
+		if dryRun {
+			return nil
+		}
+		if err := os.Remove(file); err != nil {
+			report.fail("legacy: %s: %v", rel, err)
+			return nil
+		}
+		report.Removed["legacy"]++
+		report.ReclaimedBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		report.fail("legacy: %v", err)
+	}
+}
+
+func (c *Collector) keep(rel string) bool {
+	for _, pattern := range c.Keep {
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package gc
+
+import (
+	"context"
+	"fmt"
+	"media_service/internal/media"
+	"media_service/internal/models"
+	"media_service/internal/storage"
+	"media_service/utils/db"
+	"media_service/utils/testdb"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"markdown image", "Look: ![harbour](/media/12)", []string{"/media/12"}},
+		{"absolute url with query", "![a](https://travel.example/media/7?w=640)", []string{"/media/7"}},
+		{"html image", `<img src="/uploads/blogs/a.jpg" alt="">`, []string{"/uploads/blogs/a.jpg"}},
+		{"legacy upload in a link", "[file](/uploads/blogs/cover.png)", []string{"/uploads/blogs/cover.png"}},
+		{"several", "![](/media/1) and ![](/media/2)", []string{"/media/1", "/media/2"}},
+		{"no media", "Just text about /mediation and /uploads", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := embeddedPaths.FindAllString(tt.content, -1)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// useTestDB points db.DB at a schema of its own holding the media tables
+// and an images table standing in for the services' image tables, which
+// are the only sources read.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	conn := testdb.Open(t)
+	if err := conn.AutoMigrate(&models.Media{}, &models.MediaVariant{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Exec("CREATE TABLE images (url text)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	previous, previousSources := db.DB, sources
+	db.DB = conn
+	sources = []source{{Name: "images", Query: "SELECT url FROM images"}}
+	t.Cleanup(func() { db.DB, sources = previous, previousSources })
+}
+
+func sum(c byte) string {
+	return strings.Repeat(string(c), 64)
+}
+
+// seedMedia adds a ready media row for the content with the given sum,
+// created age ago.
+func seedMedia(t *testing.T, sha256 string, age time.Duration) models.Media {
+	t.Helper()
+	row := models.Media{Key: media.ContentKey(sha256), SHA256: sha256, Status: models.MediaReady, Size: 4}
+	row.CreatedAt = time.Now().Add(-age)
+	if err := db.DB.Create(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+// newStore returns a local store with put to add objects last modified
+// age ago.
+func newStore(t *testing.T) (storage.Storage, func(key string, age time.Duration)) {
+	t.Helper()
+	root := t.TempDir()
+	store, err := storage.NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(key string, age time.Duration) {
+		t.Helper()
+		if err := store.Put(context.Background(), key, strings.NewReader("data"), 4, ""); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, put
+}
+
+func exists(t *testing.T, store storage.Storage, key string) bool {
+	t.Helper()
+	_, err := store.Stat(context.Background(), key)
+	if err != nil && err != storage.ErrNotFound {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestRunRemovesNothingWhenASourceFails(t *testing.T) {
+	useTestDB(t)
+	sources = append(sources, source{Name: "missing", Query: "SELECT url FROM missing_images"})
+
+	store, put := newStore(t)
+	orphan := seedMedia(t, sum('a'), 48*time.Hour)
+	put(orphan.Key, 48*time.Hour)
+	put(media.ContentKey(sum('b')), 48*time.Hour)
+
+	c := &Collector{Store: store, Grace: time.Hour}
+	report := c.Run(context.Background(), false)
+
+	if len(report.Errors) != 1 || !strings.HasPrefix(report.Errors[0], "missing:") {
+		t.Fatalf("errors = %q, want one for the missing source", report.Errors)
+	}
+	if len(report.Removed) != 0 || len(report.Orphaned) != 0 {
+		t.Errorf("removed %v and found orphaned %v despite the failed source", report.Removed, report.Orphaned)
+	}
+	var count int64
+	db.DB.Model(&models.Media{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d media rows left, want 1", count)
+	}
+	for _, key := range []string{orphan.Key, media.ContentKey(sum('b'))} {
+		if !exists(t, store, key) {
+			t.Errorf("%s was removed", key)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	useTestDB(t)
+	store, put := newStore(t)
+
+	used := seedMedia(t, sum('a'), 48*time.Hour)
+	orphan := seedMedia(t, sum('b'), 48*time.Hour)
+	recent := seedMedia(t, sum('c'), time.Minute)
+	for _, row := range []models.Media{used, orphan, recent} {
+		put(row.Key, 48*time.Hour)
+	}
+	if err := db.DB.Exec("INSERT INTO images (url) VALUES (?)", fmt.Sprintf("/media/%d", used.ID)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Collector{Store: store, Grace: time.Hour}
+	if report := c.Run(context.Background(), true); report.Orphaned["media"] != 1 || len(report.Removed) != 0 {
+		t.Fatalf("dry run orphaned %v and removed %v", report.Orphaned, report.Removed)
+	}
+	if !exists(t, store, orphan.Key) {
+		t.Fatal("dry run removed an object")
+	}
+
+	report := c.Run(context.Background(), false)
+	if len(report.Errors) != 0 {
+		t.Fatalf("errors: %q", report.Errors)
+	}
+	if report.Removed["media"] != 1 || report.Removed["objects"] != 1 {
+		t.Errorf("removed %v, want one media row and its object", report.Removed)
+	}
+	var ids []uint
+	db.DB.Unscoped().Model(&models.Media{}).Order("id").Pluck("id", &ids)
+	if want := []uint{used.ID, recent.ID}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("media left %v, want %v", ids, want)
+	}
+	if exists(t, store, orphan.Key) || !exists(t, store, used.Key) || !exists(t, store, recent.Key) {
+		t.Error("only the orphan's object should be removed")
+	}
+}
+
+func TestCollectObjects(t *testing.T) {
+	useTestDB(t)
+	store, put := newStore(t)
+
+	live := seedMedia(t, sum('a'), 48*time.Hour)
+	removed := seedMedia(t, sum('b'), 48*time.Hour)
+	variants := []models.MediaVariant{
+		{SHA256: live.SHA256, Name: "thumb", Key: media.VariantKey(live.SHA256, "thumb")},
+		{SHA256: removed.SHA256, Name: "thumb", Key: media.VariantKey(removed.SHA256, "thumb")},
+	}
+	if err := db.DB.Create(&variants).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	kept := map[string]time.Duration{
+		live.Key:                               48 * time.Hour,
+		media.VariantKey(live.SHA256, "thumb"): 48 * time.Hour,
+		media.VariantKey(live.SHA256, "large"): 48 * time.Hour,
+		media.ContentKey(sum('c')):             time.Minute,
+		media.VariantKey(sum('c'), "thumb"):    time.Minute,
+	}
+	gone := map[string]time.Duration{
+		removed.Key: 48 * time.Hour,
+		media.VariantKey(removed.SHA256, "thumb"): 48 * time.Hour,
+		media.ContentKey(sum('d')):                48 * time.Hour,
+		media.VariantKey(sum('d'), "thumb"):       48 * time.Hour,
+		"sha256/dd/dd/.upload-123":                48 * time.Hour,
+	}
+	for key, age := range kept {
+		put(key, age)
+	}
+	for key, age := range gone {
+		put(key, age)
+	}
+
+	c := &Collector{Store: store, Grace: time.Hour}
+	report := &Report{Orphaned: map[string]int{}, Removed: map[string]int{}}
+	c.collectObjects(context.Background(), report, map[uint]bool{removed.ID: true}, time.Now().Add(-c.Grace), false)
+
+	if len(report.Errors) != 0 {
+		t.Fatalf("errors: %q", report.Errors)
+	}
+	if report.Removed["objects"] != len(gone) {
+		t.Errorf("removed %d objects, want %d", report.Removed["objects"], len(gone))
+	}
+	for key := range kept {
+		if !exists(t, store, key) {
+			t.Errorf("%s was removed", key)
+		}
+	}
+	for key := range gone {
+		if exists(t, store, key) {
+			t.Errorf("%s was kept", key)
+		}
+	}
+
+	var sums []string
+	db.DB.Model(&models.MediaVariant{}).Pluck("sha256", &sums)
+	if !reflect.DeepEqual(sums, []string{live.SHA256}) {
+		t.Errorf("variant rows left for %v, want only the live content's", sums)
+	}
+}
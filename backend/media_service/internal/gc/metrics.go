@@ -0,0 +1,120 @@
+package gc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+var kinds = []string{"media", "objects", "legacy"}
+
+// Metrics accumulates reconciliation results across runs and writes them
+// in the Prometheus text format.
+type Metrics struct {
+	mu             sync.Mutex
+	runs           map[string]int64
+	failedRuns     int64
+	orphaned       map[string]int64
+	removed        map[string]int64
+	reclaimedBytes int64
+	references     map[string]int
+	lastOrphaned   map[string]int
+	lastRun        time.Time
+	lastDuration   time.Duration
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		runs:     make(map[string]int64),
+		orphaned: make(map[string]int64),
+		removed:  make(map[string]int64),
+	}
+}
+
+func (m *Metrics) record(report *Report, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mode := "delete"
+	if report.DryRun {
+		mode = "dry_run"
+	}
+	m.runs[mode]++
+	if len(report.Errors) > 0 {
+		m.failedRuns++
+	}
+	for kind, count := range report.Orphaned {
+		m.orphaned[kind] += int64(count)
+	}
+	for kind, count := range report.Removed {
+		m.removed[kind] += int64(count)
+	}
+	m.reclaimedBytes += report.ReclaimedBytes
+	m.references = report.References
+	m.lastOrphaned = report.Orphaned
+	m.lastRun = report.StartedAt
+	m.lastDuration = duration
+}
+
+// Write writes every metric, so a scrape of /metrics sees them all.
+func (m *Metrics) Write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP media_gc_runs_total Reconciliation runs by mode.")
+	fmt.Fprintln(w, "# TYPE media_gc_runs_total counter")
+	for _, mode := range []string{"delete", "dry_run"} {
+		fmt.Fprintf(w, "media_gc_runs_total{mode=%q} %d\n", mode, m.runs[mode])
+	}
+
+	fmt.Fprintln(w, "# HELP media_gc_failed_runs_total Runs that reported errors.")
+	fmt.Fprintln(w, "# TYPE media_gc_failed_runs_total counter")
+	fmt.Fprintf(w, "media_gc_failed_runs_total %d\n", m.failedRuns)
+
+	fmt.Fprintln(w, "# HELP media_gc_orphans_found_total Unreferenced uploads found, dry runs included.")
+	fmt.Fprintln(w, "# TYPE media_gc_orphans_found_total counter")
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "media_gc_orphans_found_total{kind=%q} %d\n", kind, m.orphaned[kind])
+	}
+
+	fmt.Fprintln(w, "# HELP media_gc_removed_total Unreferenced uploads removed.")
+	fmt.Fprintln(w, "# TYPE media_gc_removed_total counter")
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "media_gc_removed_total{kind=%q} %d\n", kind, m.removed[kind])
+	}
+
+	fmt.Fprintln(w, "# HELP media_gc_reclaimed_bytes_total Bytes freed by removing objects and legacy files.")
+	fmt.Fprintln(w, "# TYPE media_gc_reclaimed_bytes_total counter")
+	fmt.Fprintf(w, "media_gc_reclaimed_bytes_total %d\n", m.reclaimedBytes)
+
+	fmt.Fprintln(w, "# HELP media_gc_orphans Unreferenced uploads found by the last run.")
+	fmt.Fprintln(w, "# TYPE media_gc_orphans gauge")
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "media_gc_orphans{kind=%q} %d\n", kind, m.lastOrphaned[kind])
+	}
+
+	fmt.Fprintln(w, "# HELP media_gc_references Upload URLs each source referred to in the last run.")
+	fmt.Fprintln(w, "# TYPE media_gc_references gauge")
+	names := make([]string, 0, len(m.references))
+	for name := range m.references {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "media_gc_references{source=%q} %d\n", name, m.references[name])
+	}
+
+	fmt.Fprintln(w, "# HELP media_gc_last_run_timestamp_seconds Start of the last run.")
+	fmt.Fprintln(w, "# TYPE media_gc_last_run_timestamp_seconds gauge")
+	lastRun := int64(0)
+	if !m.lastRun.IsZero() {
+		lastRun = m.lastRun.Unix()
+	}
+	fmt.Fprintf(w, "media_gc_last_run_timestamp_seconds %d\n", lastRun)
+
+	fmt.Fprintln(w, "# HELP media_gc_last_run_duration_seconds Duration of the last run.")
+	fmt.Fprintln(w, "# TYPE media_gc_last_run_duration_seconds gauge")
+	fmt.Fprintf(w, "media_gc_last_run_duration_seconds %g\n", m.lastDuration.Seconds())
+}
@@ -0,0 +1,134 @@
+package gc
+
+// source reads the image URLs one table still uses. Services soft-delete
+// their items and leave the image rows behind, so rows whose parent is
+// deleted do not count. Copies of image URLs in favorites and plans keep
+// the media alive as well, so saved plans never lose their pictures.
+// Review images moved to review-service keep their legacy copies alive
+// until those tables are dropped.
+type source struct {
+	Name  string
+	Query string
+	// Legacy names a table that only databases from before a migration
+	// have; the source is skipped when the table does not exist.
+	Legacy string
+	// Text marks a query that selects documents, such as Markdown, rather
+	// than URLs; every media or upload path found in them counts.
+	Text bool
+}
+
+var sources = []source{
+	{
+		Name: "accommodation_images",
+		Query: `SELECT i.url FROM accommodation_images i
+			JOIN accommodations a ON a.id = i.accommodation_id
+			WHERE i.deleted_at IS NULL AND a.deleted_at IS NULL`,
+	},
+	{
+		Name: "room_images",
+		Query: `SELECT i.url FROM room_images i
+			JOIN room_types t ON t.id = i.room_type_id
+			JOIN accommodations a ON a.id = t.accommodation_id
+			WHERE i.deleted_at IS NULL AND t.deleted_at IS NULL AND a.deleted_at IS NULL`,
+	},
+	{
+		Name: "review_images",
+		Query: `SELECT i.url FROM review_images i
+			JOIN accommodation_reviews r ON r.id = i.review_id
+			WHERE i.deleted_at IS NULL AND r.deleted_at IS NULL`,
+		Legacy: "accommodation_reviews",
+	},
+	{
+		Name:  "attractions",
+		Query: `SELECT image_url AS url FROM attractions WHERE deleted_at IS NULL`,
+	},
+	{
+		Name: "attraction_images",
+		Query: `SELECT i.url FROM attraction_images i
+			JOIN attractions a ON a.id = i.attraction_id
+			WHERE i.deleted_at IS NULL AND a.deleted_at IS NULL`,
+	},
+	{
+		Name:  "events",
+		Query: `SELECT image_url AS url FROM events WHERE deleted_at IS NULL`,
+	},
+	{
+		Name: "event_images",
+		Query: `SELECT i.url FROM event_images i
+			JOIN events e ON e.id = i.event_id
+			WHERE i.deleted_at IS NULL AND e.deleted_at IS NULL`,
+	},
+	{
+		Name: "place_images",
+		Query: `SELECT i.url FROM place_images i
+			JOIN places p ON p.id = i.place_id
+			WHERE i.deleted_at IS NULL AND p.deleted_at IS NULL`,
+	},
+	{
+		Name: "dish_images",
+		Query: `SELECT i.url FROM dish_images i
+			JOIN dishes d ON d.id = i.dish_id
+			JOIN places p ON p.id = d.place_id
+			WHERE i.deleted_at IS NULL AND d.deleted_at IS NULL AND p.deleted_at IS NULL`,
+	},
+	{
+		Name:   "reviews_legacy_duplicates",
+		Query:  `SELECT image_url AS url FROM reviews_legacy_duplicates WHERE image_url <> ''`,
+		Legacy: "reviews_legacy_duplicates",
+	},
+	{
+		Name: "food_review_images",
+		Query: `SELECT i.url FROM food_review_images i
+			JOIN food_reviews r ON r.id = i.review_id
+			WHERE i.deleted_at IS NULL AND r.deleted_at IS NULL`,
+		Legacy: "food_reviews",
+	},
+	{
+		Name:  "blogs",
+		Query: `SELECT cover_image AS url FROM blogs WHERE deleted_at IS NULL AND cover_image <> ''`,
+	},
+	{
+		Name: "blog_images",
+		Query: `SELECT i.url FROM blog_images i
+			JOIN blogs b ON b.id = i.blog_id
+			WHERE b.deleted_at IS NULL`,
+	},
+	{
+		Name: "blog_embeds",
+		Query: `SELECT e.image_url AS url FROM blog_embeds e
+			JOIN blogs b ON b.id = e.blog_id
+			WHERE b.deleted_at IS NULL AND e.image_url <> ''`,
+	},
+	{
+		Name:  "blog_content",
+		Query: `SELECT content AS url FROM blogs WHERE deleted_at IS NULL AND content <> ''`,
+		Text:  true,
+	},
+	{
+		Name: "comment_images",
+		Query: `SELECT i.url FROM comment_images i
+			JOIN comments c ON c.id = i.comment_id
+			WHERE c.deleted_at IS NULL`,
+	},
+	{
+		Name:  "profiles",
+		Query: `SELECT profile_img AS url FROM profiles`,
+	},
+	{
+		Name: "review_photos",
+		Query: `SELECT p.url FROM review_photos p
+			JOIN reviews r ON r.id = p.review_id`,
+	},
+	{
+		Name:  "favorites",
+		Query: `SELECT image_url AS url FROM favorites WHERE deleted_at IS NULL`,
+	},
+	{
+		Name:  "plan_items",
+		Query: `SELECT image_url AS url FROM plan_items WHERE deleted_at IS NULL`,
+	},
+	{
+		Name:  "template_items",
+		Query: `SELECT image_url AS url FROM template_items WHERE deleted_at IS NULL`,
+	},
+}
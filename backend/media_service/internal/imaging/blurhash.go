@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+const (
+	blurHashComponentsX = 4
+	blurHashComponentsY = 3
+	blurHashAlphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+)
+
+// BlurHash encodes a compact placeholder for img (see blurha.sh) that
+// clients can paint while the real image loads.
+func BlurHash(img image.Image) string {
+	// The hash only keeps a few frequencies, so a small copy is enough.
+	small := fit(img, 32)
+	bounds := small.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := small.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			linear[y*width+x] = [3]float64{
+				srgbToLinear(int(r >> 8)),
+				srgbToLinear(int(g >> 8)),
+				srgbToLinear(int(b >> 8)),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, blurHashComponentsX*blurHashComponentsY)
+	for j := 0; j < blurHashComponentsY; j++ {
+		for i := 0; i < blurHashComponentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					pixel := linear[y*width+x]
+					factor[0] += basis * pixel[0]
+					factor[1] += basis * pixel[1]
+					factor[2] += basis * pixel[2]
+				}
+			}
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var hash strings.Builder
+	encode83(&hash, (blurHashComponentsX-1)+(blurHashComponentsY-1)*9, 1)
+
+	maximum := 0.0
+	for _, factor := range factors[1:] {
+		maximum = math.Max(maximum, math.Max(math.Abs(factor[0]), math.Max(math.Abs(factor[1]), math.Abs(factor[2]))))
+	}
+	quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(maximum*166-0.5))))
+	maximum = float64(quantisedMaximum+1) / 166
+	encode83(&hash, quantisedMaximum, 1)
+
+	dc := factors[0]
+	encode83(&hash, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+	for _, factor := range factors[1:] {
+		quantise := func(value float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(value/maximum, 0.5)*9+9.5))))
+		}
+		encode83(&hash, quantise(factor[0])*19*19+quantise(factor[1])*19+quantise(factor[2]), 2)
+	}
+	return hash.String()
+}
+
+func encode83(hash *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		hash.WriteByte(blurHashAlphabet[digit])
+	}
+}
+
+func srgbToLinear(value int) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,61 @@
+// Package imaging validates uploaded images, strips their metadata and
+// renders the resized variants and blurhash placeholders served for them.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels caps the decoded size of an image, so a small file cannot
+// expand into gigabytes of pixels.
+const MaxPixels = 50_000_000
+
+var (
+	ErrUnsupportedType = errors.New("Only JPEG, PNG, GIF and WebP images are accepted")
+	ErrInvalidImage    = errors.New("File is not a valid image")
+	ErrTooManyPixels   = errors.New("Image dimensions are too large")
+)
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Detect returns the content type of data from its magic bytes, ignoring
+// whatever the client claimed.
+func Detect(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// Dimensions reads the width and height of an image from its header,
+// swapped when orientation rotates it by 90 degrees.
+func Dimensions(data []byte, orientation int) (int, int, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return 0, 0, ErrInvalidImage
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return 0, 0, ErrTooManyPixels
+	}
+	if orientation >= 5 {
+		return config.Height, config.Width, nil
+	}
+	return config.Width, config.Height, nil
+}
@@ -0,0 +1,255 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// Strip removes EXIF, XMP, IPTC and comment metadata, which can carry the
+// GPS position a photo was taken at. The EXIF orientation is the one tag
+// worth keeping, so it is returned and written back on its own; colour
+// profiles are kept as well.
+func Strip(contentType string, data []byte) ([]byte, int, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	case "image/gif":
+		// GIF has no EXIF; its extensions are needed for animation.
+		return data, 1, nil
+	}
+	return nil, 0, ErrUnsupportedType
+}
+
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, ErrInvalidImage
+	}
+
+	var head, rest bytes.Buffer
+	orientation := 1
+	i := 2
+	for {
+		if i+2 > len(data) || data[i] != 0xFF {
+			return nil, 0, ErrInvalidImage
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// Fill byte before a marker.
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan: entropy-coded data follows, no more
+			// metadata segments.
+			rest.Write(data[i:])
+			break
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			rest.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+		if i+4 > len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		segment := data[i : i+2+length]
+		i += 2 + length
+
+		switch {
+		case marker == 0xE1:
+			if body := segment[4:]; bytes.HasPrefix(body, []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(body[6:])
+			}
+		case marker == 0xE0 && rest.Len() == 0:
+			// JFIF must stay the first segment.
+			head.Write(segment)
+		case marker == 0xE0 || marker == 0xE2 || marker == 0xEE:
+			// JFIF extensions, ICC profile and Adobe colour transform
+			// affect how the image renders.
+			rest.Write(segment)
+		case marker >= 0xE3 && marker <= 0xEF, marker == 0xFE:
+			// Other application segments (XMP, IPTC, maker notes) and
+			// comments.
+		default:
+			rest.Write(segment)
+		}
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write([]byte{0xFF, 0xD8})
+	out.Write(head.Bytes())
+	if orientation > 1 {
+		exif := append([]byte("Exif\x00\x00"), orientationTIFF(orientation)...)
+		out.Write([]byte{0xFF, 0xE1})
+		binary.Write(out, binary.BigEndian, uint16(len(exif)+2))
+		out.Write(exif)
+	}
+	out.Write(rest.Bytes())
+	return out.Bytes(), orientation, nil
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are dropped from PNGs; text chunks often hold camera
+// or editing software details.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, int, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, 0, ErrInvalidImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	orientation := 1
+	wroteOrientation := false
+	for i := len(pngSignature); ; {
+		if i+8 > len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length
+		if length < 0 || end > len(data) || end < i {
+			return nil, 0, ErrInvalidImage
+		}
+
+		switch {
+		case chunkType == "eXIf":
+			orientation = exifOrientation(data[i+8 : i+8+length])
+		case chunkType == "IDAT" && orientation > 1 && !wroteOrientation:
+			// eXIf must come before the image data.
+			writePNGChunk(out, "eXIf", orientationTIFF(orientation))
+			wroteOrientation = true
+			out.Write(data[i:end])
+		case !pngMetadataChunks[chunkType]:
+			out.Write(data[i:end])
+		}
+		i = end
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out.Bytes(), orientation, nil
+}
+
+func writePNGChunk(out *bytes.Buffer, chunkType string, body []byte) {
+	binary.Write(out, binary.BigEndian, uint32(len(body)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(body)
+	out.WriteString(chunkType)
+	out.Write(body)
+	binary.Write(out, binary.BigEndian, crc.Sum32())
+}
+
+func stripWebP(data []byte) ([]byte, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, 0, ErrInvalidImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[0:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		chunkType := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if size < 0 || end > len(data) || end < i {
+			return nil, 0, ErrInvalidImage
+		}
+
+		switch chunkType {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if size > 0 {
+				// Clear the EXIF and XMP present flags.
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	// Browsers ignore EXIF orientation in WebP, so it is not kept.
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, 1, nil
+}
+
+// exifOrientation reads the orientation tag (0x0112) from the first IFD
+// of a TIFF-structured EXIF block, defaulting to 1.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		value := int(order.Uint16(tiff[entry+8:]))
+		if value < 1 || value > 8 {
+			return 1
+		}
+		return value
+	}
+	return 1
+}
+
+// orientationTIFF builds an EXIF block holding only the orientation tag.
+func orientationTIFF(orientation int) []byte {
+	var b bytes.Buffer
+	b.WriteString("MM")
+	binary.Write(&b, binary.BigEndian, uint16(42))
+	binary.Write(&b, binary.BigEndian, uint32(8))
+	binary.Write(&b, binary.BigEndian, uint16(1))
+	// Tag, type SHORT, count 1, value padded to four bytes.
+	binary.Write(&b, binary.BigEndian, uint16(0x0112))
+	binary.Write(&b, binary.BigEndian, uint16(3))
+	binary.Write(&b, binary.BigEndian, uint32(1))
+	binary.Write(&b, binary.BigEndian, uint16(orientation))
+	binary.Write(&b, binary.BigEndian, uint16(0))
+	// No next IFD.
+	binary.Write(&b, binary.BigEndian, uint32(0))
+	return b.Bytes()
+}
@@ -0,0 +1,201 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// gpsMarker stands in for the coordinates a camera writes; no stripped
+// image may contain it.
+const gpsMarker = "52.3676N4.9041E"
+
+// exifTIFF builds a little-endian EXIF block with an orientation tag and
+// a GPS IFD holding gpsMarker.
+func exifTIFF(orientation uint16) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("II")
+	binary.Write(&b, le, uint16(42))
+	binary.Write(&b, le, uint32(8))
+
+	// IFD0 at 8: orientation and the GPS IFD pointer.
+	const gpsIFD = 8 + 2 + 2*12 + 4
+	binary.Write(&b, le, uint16(2))
+	binary.Write(&b, le, []uint16{0x0112, 3})
+	binary.Write(&b, le, uint32(1))
+	binary.Write(&b, le, []uint16{orientation, 0})
+	binary.Write(&b, le, []uint16{0x8825, 4})
+	binary.Write(&b, le, uint32(1))
+	binary.Write(&b, le, uint32(gpsIFD))
+	binary.Write(&b, le, uint32(0))
+
+	// GPS IFD: one ASCII entry pointing past itself.
+	const gpsData = gpsIFD + 2 + 12 + 4
+	binary.Write(&b, le, uint16(1))
+	binary.Write(&b, le, []uint16{0x0002, 2})
+	binary.Write(&b, le, uint32(len(gpsMarker)+1))
+	binary.Write(&b, le, uint32(gpsData))
+	binary.Write(&b, le, uint32(0))
+	b.WriteString(gpsMarker + "\x00")
+	return b.Bytes()
+}
+
+func jpegSegment(marker byte, body []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(body)+2))
+	return append(segment, body...)
+}
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 4; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 30), uint8(y * 60), 100, 255})
+		}
+	}
+	return img
+}
+
+// cameraJPEG encodes a JPEG with a JFIF segment followed by the metadata
+// a phone camera would add.
+func cameraJPEG(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write(jpegSegment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")))
+	out.Write(jpegSegment(0xE1, append([]byte("Exif\x00\x00"), exifTIFF(orientation)...)))
+	out.Write(jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+gpsMarker+"</x:xmpmeta>")))
+	out.Write(jpegSegment(0xED, []byte("Photoshop 3.0\x00"+gpsMarker)))
+	out.Write(jpegSegment(0xFE, []byte("taken at "+gpsMarker)))
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+// jpegOrientation reads the orientation of the EXIF segment of a JPEG,
+// or 0 when it has none.
+func jpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF && data[i+1] != 0xDA; {
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		body := data[i+4 : i+2+length]
+		if data[i+1] == 0xE1 && bytes.HasPrefix(body, []byte("Exif\x00\x00")) {
+			return exifOrientation(body[6:])
+		}
+		i += 2 + length
+	}
+	return 0
+}
+
+func TestStripJPEG(t *testing.T) {
+	tests := []struct {
+		name            string
+		orientation     uint16
+		wantOrientation int
+		wantExif        int
+	}{
+		{"rotated photo keeps its orientation", 6, 6, 6},
+		{"upright photo drops EXIF entirely", 1, 1, 0},
+		{"invalid orientation reads as upright", 9, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, orientation, err := Strip("image/jpeg", cameraJPEG(t, tt.orientation))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if orientation != tt.wantOrientation {
+				t.Errorf("orientation = %d, want %d", orientation, tt.wantOrientation)
+			}
+			if bytes.Contains(stripped, []byte(gpsMarker)) {
+				t.Error("stripped JPEG still contains the GPS position")
+			}
+			if bytes.Contains(stripped, []byte("xmpmeta")) || bytes.Contains(stripped, []byte("Photoshop")) {
+				t.Error("stripped JPEG still contains XMP or IPTC")
+			}
+			if got := jpegOrientation(stripped); got != tt.wantExif {
+				t.Errorf("EXIF orientation in the output = %d, want %d", got, tt.wantExif)
+			}
+			if !bytes.Equal(stripped[2:4], []byte{0xFF, 0xE0}) {
+				t.Error("JFIF is no longer the first segment")
+			}
+			img, err := jpeg.Decode(bytes.NewReader(stripped))
+			if err != nil {
+				t.Fatalf("stripped JPEG does not decode: %v", err)
+			}
+			if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 4 {
+				t.Errorf("stripped JPEG is %v", img.Bounds())
+			}
+		})
+	}
+}
+
+func pngChunk(chunkType string, body []byte) []byte {
+	var b bytes.Buffer
+	writePNGChunk(&b, chunkType, body)
+	return b.Bytes()
+}
+
+func TestStripPNG(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	data := encoded.Bytes()
+	// Metadata goes after IHDR, which is 8+4+4+13+4 bytes in.
+	ihdrEnd := len(pngSignature) + 25
+	var camera bytes.Buffer
+	camera.Write(data[:ihdrEnd])
+	camera.Write(pngChunk("eXIf", exifTIFF(8)))
+	camera.Write(pngChunk("tEXt", []byte("Location\x00"+gpsMarker)))
+	camera.Write(data[ihdrEnd:])
+
+	stripped, orientation, err := Strip("image/png", camera.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orientation != 8 {
+		t.Errorf("orientation = %d, want 8", orientation)
+	}
+	if bytes.Contains(stripped, []byte(gpsMarker)) {
+		t.Error("stripped PNG still contains the GPS position")
+	}
+	if !bytes.Contains(stripped, pngChunk("eXIf", orientationTIFF(8))) {
+		t.Error("stripped PNG lost its orientation")
+	}
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Fatalf("stripped PNG does not decode: %v", err)
+	}
+}
+
+func TestStripRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        error
+	}{
+		{"unsupported type", "image/tiff", []byte("II*\x00"), ErrUnsupportedType},
+		{"not a JPEG", "image/jpeg", []byte("GIF89a"), ErrInvalidImage},
+		{"truncated JPEG segment", "image/jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E'}, ErrInvalidImage},
+		{"not a PNG", "image/png", []byte("\xFF\xD8\xFF"), ErrInvalidImage},
+		{"PNG without IEND", "image/png", append([]byte(nil), pngSignature...), ErrInvalidImage},
+		{"not a WebP", "image/webp", []byte("RIFF\x00\x00\x00\x00WAVE"), ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Strip(tt.contentType, tt.data); err != tt.want {
+				t.Fatalf("Strip = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
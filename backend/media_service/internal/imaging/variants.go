@@ -0,0 +1,138 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+
+	"github.com/HugoSmits86/nativewebp"
+	xdraw "golang.org/x/image/draw"
+)
+
+// Size is a responsive variant, fitted inside a MaxSide square.
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+var Sizes = []Size{
+	{Name: "thumb", MaxSide: 320},
+	{Name: "medium", MaxSide: 800},
+	{Name: "large", MaxSide: 1600},
+}
+
+// KnownSize reports whether name is one of Sizes.
+func KnownSize(name string) bool {
+	for _, size := range Sizes {
+		if size.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Variant is an encoded WebP rendition of an image.
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Decode decodes an image and turns it upright according to its EXIF
+// orientation.
+func Decode(data []byte, orientation int) (image.Image, error) {
+	if _, _, err := Dimensions(data, orientation); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	return orient(img, orientation), nil
+}
+
+// Variants renders every size as WebP. Images are never scaled up, so a
+// small image gets variants at its own size.
+func Variants(img image.Image) ([]Variant, error) {
+	variants := make([]Variant, 0, len(Sizes))
+	for _, size := range Sizes {
+		scaled := fit(img, size.MaxSide)
+
+		var buf bytes.Buffer
+		if err := nativewebp.Encode(&buf, scaled, nil); err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{
+			Name:   size.Name,
+			Width:  scaled.Bounds().Dx(),
+			Height: scaled.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+	return variants, nil
+}
+
+// fit scales img down to fit inside a maxSide square.
+func fit(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+	if width >= height {
+		height = max(1, height*maxSide/width)
+		width = maxSide
+	} else {
+		width = max(1, width*maxSide/height)
+		height = maxSide
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, xdraw.Src, nil)
+	return dst
+}
+
+// orient applies an EXIF orientation (1-8) so the image displays upright.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = width-1-x, y
+			case 3: // rotated 180
+				dx, dy = width-1-x, height-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, height-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = height-1-y, x
+			case 7: // transversed
+				dx, dy = height-1-y, width-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, width-1-x
+			}
+			i := src.PixOffset(x, y)
+			j := dst.PixOffset(dx, dy)
+			copy(dst.Pix[j:j+4], src.Pix[i:i+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,418 @@
+// Package media stores uploads under content-addressed keys and hands out
+// signed URLs for them.
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"media_service/internal/imaging"
+	"media_service/internal/models"
+	"media_service/internal/signing"
+	"media_service/internal/storage"
+	"media_service/utils/db"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxBytes = 20 << 20
+	// DownloadTTL and UploadTTL bound the signed URLs the service issues.
+	DownloadTTL = 15 * time.Minute
+	UploadTTL   = 15 * time.Minute
+)
+
+var (
+	ErrNotFound       = errors.New("Media not found")
+	ErrTooLarge       = errors.New("File is too large")
+	ErrEmpty          = errors.New("File is empty")
+	ErrInvalidHash    = errors.New("sha256 must be 64 hex characters")
+	ErrHashMismatch   = errors.New("Uploaded content does not match sha256")
+	ErrNotPending     = errors.New("Media is already uploaded")
+	ErrNotUploaded    = errors.New("Media has not been uploaded yet")
+	ErrForbidden      = errors.New("Unauthorized - not the uploader")
+	ErrInvalidUpload  = errors.New("size must be positive")
+	ErrUnknownVariant = errors.New("Unknown variant")
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type Service struct {
+	Store  storage.Storage
+	Signer *signing.Signer
+	// MaxBytes caps a single upload.
+	MaxBytes int64
+
+	queue chan uint
+}
+
+// MaxBytesFromEnv reads MEDIA_MAX_BYTES, defaulting to 20 MiB.
+func MaxBytesFromEnv() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MEDIA_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return defaultMaxBytes
+}
+
+// ContentKey is the storage key of content with the given hex SHA-256,
+// fanned out over two directory levels.
+func ContentKey(sum string) string {
+	return "sha256/" + sum[:2] + "/" + sum[2:4] + "/" + sum
+}
+
+// UploadParams describe a file. Its content type is always sniffed.
+type UploadParams struct {
+	OriginalName string
+	OwnerService string
+	UploadedBy   *uint
+}
+
+// read loads body, capped at MaxBytes, and hashes it as received.
+func (s *Service) read(body io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(body, s.MaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > s.MaxBytes {
+		return nil, "", ErrTooLarge
+	}
+	if len(data) == 0 {
+		return nil, "", ErrEmpty
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// content is an upload that passed validation, with its metadata stripped.
+type content struct {
+	data        []byte
+	sum         string
+	contentType string
+	orientation int
+	width       int
+	height      int
+}
+
+// sanitize accepts images by their magic bytes only and strips their
+// metadata. The stored file, and so its key, is the stripped one.
+func sanitize(data []byte) (*content, error) {
+	contentType, err := imaging.Detect(data)
+	if err != nil {
+		return nil, err
+	}
+	stripped, orientation, err := imaging.Strip(contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := imaging.Dimensions(stripped, orientation)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(stripped)
+	return &content{
+		data:        stripped,
+		sum:         hex.EncodeToString(sum[:]),
+		contentType: contentType,
+		orientation: orientation,
+		width:       width,
+		height:      height,
+	}, nil
+}
+
+// putOnce stores content unless an object with its key already exists.
+func (s *Service) putOnce(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if _, err := s.Store.Stat(ctx, key); err == nil {
+		return nil
+	} else if err != storage.ErrNotFound {
+		return err
+	}
+	return s.Store.Put(ctx, key, body, size, contentType)
+}
+
+// store puts sanitized content and fills in the media fields derived
+// from it.
+func (s *Service) store(ctx context.Context, media *models.Media, c *content) error {
+	key := ContentKey(c.sum)
+	if err := s.putOnce(ctx, key, bytes.NewReader(c.data), int64(len(c.data)), c.contentType); err != nil {
+		return fmt.Errorf("failed to store object: %w", err)
+	}
+	media.Key = key
+	media.SHA256 = c.sum
+	media.ContentType = c.contentType
+	media.Size = int64(len(c.data))
+	media.Width = c.width
+	media.Height = c.height
+	media.Orientation = c.orientation
+	media.Status = models.MediaReady
+	media.ProcessingStatus = models.ProcessingQueued
+	return nil
+}
+
+// Upload validates and stores body and records it as ready. Variants are
+// rendered in the background.
+func (s *Service) Upload(ctx context.Context, body io.Reader, params UploadParams) (*models.Media, error) {
+	data, _, err := s.read(body)
+	if err != nil {
+		return nil, err
+	}
+	c, err := sanitize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	media := &models.Media{
+		OriginalName: params.OriginalName,
+		OwnerService: params.OwnerService,
+		UploadedBy:   params.UploadedBy,
+	}
+	if err := s.store(ctx, media, c); err != nil {
+		return nil, err
+	}
+	if err := db.DB.Create(media).Error; err != nil {
+		return nil, err
+	}
+	s.Enqueue(media.ID)
+	return media, nil
+}
+
+// UploadRequest announces a file the client will upload itself.
+type UploadRequest struct {
+	SHA256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	ContentType  string `json:"content_type"`
+	OriginalName string `json:"original_name"`
+}
+
+// CreateUpload records a pending media for content the client will PUT to
+// the returned URL. Content that is already stored as is, with no metadata
+// to strip, is ready at once and gets no URL.
+func (s *Service) CreateUpload(ctx context.Context, req UploadRequest, params UploadParams) (*models.Media, string, error) {
+	if !hashPattern.MatchString(req.SHA256) {
+		return nil, "", ErrInvalidHash
+	}
+	if req.Size <= 0 {
+		return nil, "", ErrInvalidUpload
+	}
+	if req.Size > s.MaxBytes {
+		return nil, "", ErrTooLarge
+	}
+
+	media := &models.Media{
+		Key:          ContentKey(req.SHA256),
+		SHA256:       req.SHA256,
+		ContentType:  req.ContentType,
+		Size:         req.Size,
+		OriginalName: req.OriginalName,
+		Status:       models.MediaPending,
+		OwnerService: params.OwnerService,
+		UploadedBy:   params.UploadedBy,
+	}
+
+	// Only ready media point at content that passed sanitize, so the raw
+	// object at a key is never trusted on its own.
+	var existing models.Media
+	err := db.DB.Where("sha256 = ? AND status = ?", req.SHA256, models.MediaReady).
+		Order("id").First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", err
+	}
+	if err == nil {
+		media.ContentType = existing.ContentType
+		media.Size = existing.Size
+		media.Width = existing.Width
+		media.Height = existing.Height
+		media.Orientation = existing.Orientation
+		media.BlurHash = existing.BlurHash
+		media.Status = models.MediaReady
+		media.ProcessingStatus = existing.ProcessingStatus
+		if media.ProcessingStatus != models.ProcessingDone {
+			media.ProcessingStatus = models.ProcessingQueued
+		}
+	}
+	if err := db.DB.Create(media).Error; err != nil {
+		return nil, "", err
+	}
+	if media.Status == models.MediaReady {
+		s.Enqueue(media.ID)
+		return media, "", nil
+	}
+
+	uploadURL, err := s.uploadURL(media)
+	if err != nil {
+		return nil, "", err
+	}
+	return media, uploadURL, nil
+}
+
+func (s *Service) uploadURL(media *models.Media) (string, error) {
+	if presigner, ok := s.Store.(storage.Presigner); ok {
+		return presigner.PresignPut(media.Key, UploadTTL)
+	}
+	return s.Signer.Sign(http.MethodPut, UploadPath(media.ID), UploadTTL, time.Now()), nil
+}
+
+// UploadPath is where clients PUT content when the store cannot take it
+// directly.
+func UploadPath(id uint) string {
+	return fmt.Sprintf("/media/uploads/%d", id)
+}
+
+// ContentPath serves the content of a media through the service.
+func ContentPath(id uint) string {
+	return fmt.Sprintf("/media/%d/content", id)
+}
+
+// VariantPath serves a variant of a media through the service.
+func VariantPath(id uint, name string) string {
+	return fmt.Sprintf("/media/%d/variants/%s", id, name)
+}
+
+// Receive stores the body of a signed PUT to UploadPath. The content must
+// match the announced hash.
+func (s *Service) Receive(ctx context.Context, media *models.Media, body io.Reader) error {
+	if media.Status != models.MediaPending {
+		return ErrNotPending
+	}
+	data, sum, err := s.read(body)
+	if err != nil {
+		return err
+	}
+	if sum != media.SHA256 {
+		return ErrHashMismatch
+	}
+	return s.finish(ctx, media, data)
+}
+
+// Complete finishes an upload made straight to the store. The object is
+// read back and hashed because the presigned URL does not bind the body;
+// content that does not match, or is not an image, is deleted. The raw
+// object stays behind when stripping metadata changed its key, since
+// another pending upload may still be writing to it.
+func (s *Service) Complete(ctx context.Context, media *models.Media) error {
+	if media.Status != models.MediaPending {
+		return nil
+	}
+	body, _, err := s.Store.Get(ctx, media.Key)
+	if err == storage.ErrNotFound {
+		return ErrNotUploaded
+	}
+	if err != nil {
+		return err
+	}
+	data, sum, err := s.read(body)
+	body.Close()
+	if err == nil && sum != media.SHA256 {
+		err = ErrHashMismatch
+	}
+	if err == nil {
+		err = s.finish(ctx, media, data)
+	}
+	if errors.Is(err, ErrTooLarge) || errors.Is(err, ErrEmpty) || errors.Is(err, ErrHashMismatch) ||
+		errors.Is(err, imaging.ErrUnsupportedType) || errors.Is(err, imaging.ErrInvalidImage) ||
+		errors.Is(err, imaging.ErrTooManyPixels) {
+		s.Store.Delete(ctx, media.Key)
+	}
+	return err
+}
+
+// finish sanitizes the content of a pending upload and marks it ready.
+func (s *Service) finish(ctx context.Context, media *models.Media, data []byte) error {
+	c, err := sanitize(data)
+	if err != nil {
+		return err
+	}
+	if err := s.store(ctx, media, c); err != nil {
+		return err
+	}
+	err = db.DB.Model(media).Updates(map[string]interface{}{
+		"key":               media.Key,
+		"sha256":            media.SHA256,
+		"content_type":      media.ContentType,
+		"size":              media.Size,
+		"width":             media.Width,
+		"height":            media.Height,
+		"orientation":       media.Orientation,
+		"status":            media.Status,
+		"processing_status": media.ProcessingStatus,
+	}).Error
+	if err != nil {
+		return err
+	}
+	s.Enqueue(media.ID)
+	return nil
+}
+
+// Find returns a media by ID with its variants.
+func Find(id string) (*models.Media, error) {
+	var media models.Media
+	if err := db.DB.First(&media, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if media.Status == models.MediaReady {
+		if err := db.DB.Where("sha256 = ?", media.SHA256).Order("id").Find(&media.Variants).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &media, nil
+}
+
+// Object is a stored file of a media: the original or one of its
+// variants.
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+	// Path serves the object through the service when the store cannot
+	// sign URLs.
+	Path string
+}
+
+// Object picks the original (variant "") or a named variant of a ready
+// media. Until variants are rendered the original stands in for them.
+func (s *Service) Object(media *models.Media, variant string) (*Object, error) {
+	if media.Status != models.MediaReady {
+		return nil, ErrNotUploaded
+	}
+	original := &Object{Key: media.Key, ContentType: media.ContentType, Size: media.Size, Path: ContentPath(media.ID)}
+	if variant == "" {
+		return original, nil
+	}
+	if !imaging.KnownSize(variant) {
+		return nil, ErrUnknownVariant
+	}
+	for _, v := range media.Variants {
+		if v.Name == variant {
+			return &Object{Key: v.Key, ContentType: v.ContentType, Size: v.Size, Path: VariantPath(media.ID, v.Name)}, nil
+		}
+	}
+	return original, nil
+}
+
+// DownloadURL returns a URL the object can be fetched from until it
+// expires, straight from the store when it supports presigning.
+func (s *Service) DownloadURL(object *Object) (string, time.Time, error) {
+	expires := time.Now().Add(DownloadTTL)
+	if presigner, ok := s.Store.(storage.Presigner); ok {
+		url, err := presigner.PresignGet(object.Key, DownloadTTL)
+		return url, expires, err
+	}
+	return s.Signer.Sign(http.MethodGet, object.Path, DownloadTTL, time.Now()), expires, nil
+}
+
+// Open returns the content of an object.
+func (s *Service) Open(ctx context.Context, object *Object) (io.ReadCloser, error) {
+	body, _, err := s.Store.Get(ctx, object.Key)
+	return body, err
+}
@@ -0,0 +1,197 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"media_service/internal/imaging"
+	"media_service/internal/models"
+	"media_service/utils/db"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	queueSize = 1024
+	// sweepInterval is how often media left queued, e.g. because the
+	// queue was full, are picked up again.
+	sweepInterval = time.Minute
+)
+
+// WorkersFromEnv reads MEDIA_WORKERS, defaulting to one per CPU.
+func WorkersFromEnv() int {
+	if value, err := strconv.Atoi(os.Getenv("MEDIA_WORKERS")); err == nil && value > 0 {
+		return value
+	}
+	return runtime.NumCPU()
+}
+
+// VariantKey is the storage key of a variant of the content with the
+// given hex SHA-256.
+func VariantKey(sum, name string) string {
+	return "variants/" + sum[:2] + "/" + sum[2:4] + "/" + sum + "/" + name + ".webp"
+}
+
+// StartProcessing renders variants and blurhashes of ready media on a pool
+// of workers, so uploads return as soon as the original is stored. The
+// queue lives in the media table: Enqueue only hurries things along.
+func (s *Service) StartProcessing(workers int) {
+	// Media a previous run was working on when it stopped.
+	err := db.DB.Model(&models.Media{}).
+		Where("processing_status = ?", models.ProcessingRunning).
+		Update("processing_status", models.ProcessingQueued).Error
+	if err != nil {
+		log.Printf("Failed to requeue media processing: %v", err)
+	}
+
+	s.queue = make(chan uint, queueSize)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for id := range s.queue {
+				s.process(id)
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			s.enqueueQueued()
+			<-ticker.C
+		}
+	}()
+}
+
+// Enqueue asks a worker to process a media. A full queue drops the
+// request; the next sweep finds the media again.
+func (s *Service) Enqueue(id uint) {
+	if s.queue == nil {
+		return
+	}
+	select {
+	case s.queue <- id:
+	default:
+	}
+}
+
+func (s *Service) enqueueQueued() {
+	var ids []uint
+	err := db.DB.Model(&models.Media{}).
+		Where("status = ? AND processing_status = ?", models.MediaReady, models.ProcessingQueued).
+		Order("id").Limit(queueSize).Pluck("id", &ids).Error
+	if err != nil {
+		log.Printf("Failed to list queued media: %v", err)
+		return
+	}
+	for _, id := range ids {
+		s.Enqueue(id)
+	}
+}
+
+// process claims a queued media and renders it. The claim keeps two
+// workers from processing the same media when it was enqueued twice.
+func (s *Service) process(id uint) {
+	claim := db.DB.Model(&models.Media{}).
+		Where("id = ? AND processing_status = ?", id, models.ProcessingQueued).
+		Update("processing_status", models.ProcessingRunning)
+	if claim.Error != nil {
+		log.Printf("Failed to claim media %d: %v", id, claim.Error)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		return
+	}
+
+	var media models.Media
+	if err := db.DB.First(&media, id).Error; err != nil {
+		log.Printf("Failed to load media %d: %v", id, err)
+		return
+	}
+
+	started := time.Now()
+	updates := map[string]interface{}{"processing_status": models.ProcessingDone, "processing_error": ""}
+	blurHash, err := s.render(context.Background(), &media)
+	if err != nil {
+		log.Printf("Failed to process media %d: %v", id, err)
+		updates["processing_status"] = models.ProcessingFailed
+		updates["processing_error"] = err.Error()
+	} else {
+		updates["blur_hash"] = blurHash
+		log.Printf("Processed media %d in %s", id, time.Since(started).Round(time.Millisecond))
+	}
+	if err := db.DB.Model(&media).Updates(updates).Error; err != nil {
+		log.Printf("Failed to save processing result of media %d: %v", id, err)
+	}
+}
+
+// render stores the variants of a media's content and returns its
+// blurhash. Content processed before for another media is reused.
+func (s *Service) render(ctx context.Context, media *models.Media) (string, error) {
+	var count int64
+	if err := db.DB.Model(&models.MediaVariant{}).Where("sha256 = ?", media.SHA256).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count == int64(len(imaging.Sizes)) {
+		var done models.Media
+		err := db.DB.Where("sha256 = ? AND processing_status = ? AND blur_hash <> ''", media.SHA256, models.ProcessingDone).
+			First(&done).Error
+		if err == nil {
+			return done.BlurHash, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+	}
+
+	body, _, err := s.Store.Get(ctx, media.Key)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, s.MaxBytes+1))
+	body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	img, err := imaging.Decode(data, media.Orientation)
+	if err != nil {
+		return "", err
+	}
+	variants, err := imaging.Variants(img)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range variants {
+		key := VariantKey(media.SHA256, v.Name)
+		if err := s.Store.Put(ctx, key, bytes.NewReader(v.Data), int64(len(v.Data)), "image/webp"); err != nil {
+			return "", err
+		}
+		row := models.MediaVariant{
+			SHA256:      media.SHA256,
+			Name:        v.Name,
+			Key:         key,
+			ContentType: "image/webp",
+			Width:       v.Width,
+			Height:      v.Height,
+			Size:        int64(len(v.Data)),
+		}
+		err := db.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "sha256"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"key", "content_type", "width", "height", "size"}),
+		}).Create(&row).Error
+		if err != nil {
+			return "", err
+		}
+	}
+	return imaging.BlurHash(img), nil
+}
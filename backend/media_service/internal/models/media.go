@@ -0,0 +1,60 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	MediaPending = "pending"
+	MediaReady   = "ready"
+)
+
+// Processing states of the variants and blurhash of a ready media.
+const (
+	ProcessingQueued  = "queued"
+	ProcessingRunning = "processing"
+	ProcessingDone    = "done"
+	ProcessingFailed  = "failed"
+)
+
+// Media is one uploaded file. Objects are stored under a key derived from
+// their SHA-256, so identical uploads share a single object while keeping
+// their own rows and IDs.
+type Media struct {
+	gorm.Model
+	Key          string `json:"-" gorm:"index;not null"`
+	SHA256       string `json:"sha256" gorm:"size:64;index;not null"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	OriginalName string `json:"original_name"`
+	Status       string `json:"status" gorm:"index;not null"`
+	// OwnerService names the service the media was uploaded for, e.g.
+	// "events"; UploadedBy is set for uploads made by users directly.
+	OwnerService string `json:"owner_service" gorm:"index"`
+	UploadedBy   *uint  `json:"uploaded_by,omitempty" gorm:"index"`
+
+	// Width and Height are the upright dimensions; Orientation is the EXIF
+	// orientation kept in the stored file.
+	Width            int    `json:"width"`
+	Height           int    `json:"height"`
+	Orientation      int    `json:"-" gorm:"not null;default:1"`
+	BlurHash         string `json:"blurhash,omitempty"`
+	ProcessingStatus string `json:"processing_status,omitempty" gorm:"index"`
+	ProcessingError  string `json:"processing_error,omitempty"`
+
+	URL      string         `json:"url" gorm:"-"`
+	Variants []MediaVariant `json:"variants,omitempty" gorm:"-"`
+}
+
+// MediaVariant is a resized WebP rendition. Variants belong to content
+// rather than to a media row, so identical uploads share them.
+type MediaVariant struct {
+	ID          uint   `json:"-" gorm:"primaryKey"`
+	SHA256      string `json:"-" gorm:"size:64;uniqueIndex:idx_media_variant;not null"`
+	Name        string `json:"name" gorm:"uniqueIndex:idx_media_variant;not null"`
+	Key         string `json:"-" gorm:"not null"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+
+	URL string `json:"url" gorm:"-"`
+}
@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"log"
+	"media_service/internal/controllers"
+	"media_service/internal/gc"
+	"media_service/internal/media"
+	"media_service/internal/middleware"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func SetupRoutes(mediaService *media.Service, collector *gc.Collector, legacyUploadsDir string) *mux.Router {
+	r := mux.NewRouter()
+
+	mediaController := &controllers.MediaController{Media: mediaService}
+	gcController := &controllers.GCController{Collector: collector, Metrics: collector.Metrics}
+
+	// Files uploaded before the media service existed are still referenced
+	// by their /uploads/... URLs, so they are served read-only from here.
+	log.Printf("Serving legacy uploads from: %s", legacyUploadsDir)
+	r.PathPrefix("/uploads/").Handler(
+		http.StripPrefix("/uploads/", http.FileServer(http.Dir(legacyUploadsDir))),
+	).Methods("GET", "HEAD")
+
+	internal := r.PathPrefix("/internal/media").Subrouter()
+	internal.Use(middleware.RequireServiceToken)
+	internal.HandleFunc("", mediaController.InternalUpload).Methods("POST")
+	internal.HandleFunc("/{id:[0-9]+}/content", mediaController.InternalContent).Methods("GET", "HEAD")
+	r.HandleFunc("/metrics", gcController.ServeMetrics).Methods("GET")
+
+	r.HandleFunc("/media/uploads/{id:[0-9]+}", mediaController.ReceiveUpload).Methods("PUT")
+	r.HandleFunc("/media/{id:[0-9]+}", mediaController.Get).Methods("GET", "HEAD")
+	r.HandleFunc("/media/{id:[0-9]+}/url", mediaController.GetURL).Methods("GET")
+	r.HandleFunc("/media/{id:[0-9]+}/info", mediaController.GetInfo).Methods("GET")
+	r.HandleFunc("/media/{id:[0-9]+}/content", mediaController.Content).Methods("GET", "HEAD")
+	r.HandleFunc("/media/{id:[0-9]+}/variants/{variant}", mediaController.VariantContent).Methods("GET", "HEAD")
+
+	user := r.PathPrefix("/media").Subrouter()
+	user.Use(middleware.AuthMiddleware)
+	user.HandleFunc("", mediaController.Upload).Methods("POST")
+	user.HandleFunc("/uploads", mediaController.CreateUpload).Methods("POST")
+	user.HandleFunc("/{id:[0-9]+}/complete", mediaController.Complete).Methods("POST")
+
+	admin := r.PathPrefix("/admin/media").Subrouter()
+	admin.Use(middleware.AdminAuthMiddleware)
+	admin.HandleFunc("/gc", gcController.LastReport).Methods("GET")
+	admin.HandleFunc("/gc", gcController.Run).Methods("POST")
+
+	return r
+}
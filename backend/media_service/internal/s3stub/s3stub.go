@@ -0,0 +1,246 @@
+// Package s3stub is a small MinIO-like S3 server for local development.
+// It checks SigV4 signatures like a real store and keeps objects on disk,
+// one directory per bucket, with content types in ".meta" side files.
+package s3stub
+
+import (
+	"encoding/xml"
+	"io"
+	"io/fs"
+	"log"
+	"media_service/internal/storage/sigv4"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	metaSuffix   = ".meta"
+	listPageSize = 1000
+)
+
+type Server struct {
+	Root  string
+	Creds sigv4.Credentials
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: message})
+}
+
+func (s *Server) lookup(accessKey string) (sigv4.Credentials, bool) {
+	return s.Creds, accessKey == s.Creds.AccessKey
+}
+
+// objectPath splits "/bucket/key" and maps it under Root.
+func (s *Server) objectPath(urlPath string) (string, bool) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	if !ok || bucket == "" || key == "" || strings.HasSuffix(key, metaSuffix) {
+		return "", false
+	}
+	for _, part := range strings.Split(bucket+"/"+key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", false
+		}
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(bucket+"/"+key)), true
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Like MinIO, allow browsers on any origin to use presigned URLs.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, PUT")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := sigv4.Verify(r, s.lookup, time.Now()); err != nil {
+		switch err {
+		case sigv4.ErrMissingAuth:
+			writeError(w, http.StatusForbidden, "AccessDenied", err.Error())
+		case sigv4.ErrExpired:
+			writeError(w, http.StatusForbidden, "AccessDenied", "Request has expired")
+		default:
+			writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		}
+		return
+	}
+
+	if bucket := strings.Trim(r.URL.Path, "/"); r.Method == http.MethodGet && bucket != "" && !strings.Contains(bucket, "/") {
+		s.list(w, r, bucket)
+		return
+	}
+
+	path, ok := s.objectPath(r.URL.Path)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Expected /bucket/key")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.put(w, r, path)
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, path)
+	case http.MethodDelete:
+		os.Remove(path)
+		os.Remove(path + metaSuffix)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method not allowed")
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("s3stub: %v", err)
+		writeError(w, http.StatusInternalServerError, "InternalError", "Failed to store object")
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		log.Printf("s3stub: %v", err)
+		writeError(w, http.StatusInternalServerError, "InternalError", "Failed to store object")
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.WriteFile(path+metaSuffix, []byte(r.Header.Get("Content-Type")), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		log.Printf("s3stub: %v", err)
+		writeError(w, http.StatusInternalServerError, "InternalError", "Failed to store object")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+		return
+	}
+
+	contentType := "application/octet-stream"
+	if meta, err := os.ReadFile(path + metaSuffix); err == nil && len(meta) > 0 {
+		contentType = string(meta)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(stat.Size(), 10))
+	w.Header().Set("Last-Modified", stat.ModTime().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		io.Copy(w, file)
+	}
+}
+
+type listEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	Size         int64  `xml:"Size"`
+}
+
+type listResult struct {
+	XMLName               xml.Name    `xml:"ListBucketResult"`
+	Name                  string      `xml:"Name"`
+	Prefix                string      `xml:"Prefix"`
+	KeyCount              int         `xml:"KeyCount"`
+	MaxKeys               int         `xml:"MaxKeys"`
+	IsTruncated           bool        `xml:"IsTruncated"`
+	NextContinuationToken string      `xml:"NextContinuationToken,omitempty"`
+	Contents              []listEntry `xml:"Contents"`
+}
+
+// list answers ListObjectsV2; the continuation token is the last key of
+// the previous page.
+func (s *Server) list(w http.ResponseWriter, r *http.Request, bucket string) {
+	if bucket == "." || bucket == ".." {
+		writeError(w, http.StatusBadRequest, "InvalidBucketName", "Invalid bucket name")
+		return
+	}
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	after := query.Get("continuation-token")
+	root := filepath.Join(s.Root, bucket)
+
+	var entries []listEntry
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, metaSuffix) || strings.HasPrefix(name, ".put-") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			return nil
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, listEntry{
+			Key:          key,
+			LastModified: stat.ModTime().UTC().Format(time.RFC3339),
+			Size:         stat.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		log.Printf("s3stub: %v", err)
+		writeError(w, http.StatusInternalServerError, "InternalError", "Failed to list objects")
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	result := listResult{Name: bucket, Prefix: prefix, MaxKeys: listPageSize}
+	if len(entries) > listPageSize {
+		entries = entries[:listPageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = entries[len(entries)-1].Key
+	}
+	result.Contents = entries
+	result.KeyCount = len(entries)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,62 @@
+package signing
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	signer := &Signer{Secret: []byte("secret")}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	signed := signer.Sign("GET", "/media/12/content", time.Minute, now)
+
+	path, rawQuery, _ := strings.Cut(signed, "?")
+	if path != "/media/12/content" {
+		t.Fatalf("Sign changed the path to %s", path)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(name, value string) url.Values {
+		changed := url.Values{}
+		for k, v := range query {
+			changed[k] = v
+		}
+		changed.Set(name, value)
+		return changed
+	}
+	tampered := []byte(query.Get("signature"))
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		method string
+		path   string
+		query  url.Values
+		now    time.Time
+		want   error
+	}{
+		{"valid", signer, "GET", "/media/12/content", query, now, nil},
+		{"valid until the second it expires", signer, "GET", "/media/12/content", query, now.Add(time.Minute), nil},
+		{"expired", signer, "GET", "/media/12/content", query, now.Add(time.Minute + time.Second), ErrExpired},
+		{"other method", signer, "PUT", "/media/12/content", query, now, ErrInvalidSignature},
+		{"other path", signer, "GET", "/media/13/content", query, now, ErrInvalidSignature},
+		{"other secret", &Signer{Secret: []byte("other")}, "GET", "/media/12/content", query, now, ErrInvalidSignature},
+		{"tampered signature", signer, "GET", "/media/12/content", with("signature", string(tampered)), now, ErrInvalidSignature},
+		{"extended expiry", signer, "GET", "/media/12/content", with("expires", "99999999999"), now, ErrInvalidSignature},
+		{"missing expiry", signer, "GET", "/media/12/content", with("expires", ""), now, ErrInvalidSignature},
+		{"missing signature", signer, "GET", "/media/12/content", with("signature", ""), now, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.signer.Verify(tt.method, tt.path, tt.query, tt.now); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"media_service/internal/storage/sigv4"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	// Endpoint is the address the media service uses, e.g.
+	// http://minio:9000. PublicURL, when set, replaces it in presigned
+	// URLs handed to browsers.
+	Endpoint  string
+	PublicURL string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3 talks to an S3-compatible store with path-style URLs, which MinIO
+// and most other implementations accept.
+type S3 struct {
+	config S3Config
+	creds  sigv4.Credentials
+	client *http.Client
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	config.PublicURL = strings.TrimRight(config.PublicURL, "/")
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT: %v", err)
+	}
+	return &S3{
+		config: config,
+		creds: sigv4.Credentials{
+			AccessKey: config.AccessKey,
+			SecretKey: config.SecretKey,
+			Region:    config.Region,
+		},
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3) Name() string {
+	return "s3"
+}
+
+func (s *S3) objectURL(base, key string) string {
+	return base + "/" + sigv4.EscapePath(s.config.Bucket+"/"+key)
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(s.config.Endpoint, key), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sigv4.Sign(req, s.creds, time.Now())
+	return s.client.Do(req)
+}
+
+// s3Error reads the failed response; S3 reports details as XML.
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	return fmt.Errorf("s3 returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, responseInfo(key, resp), nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, nil, s3Error(resp)
+	}
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return responseInfo(key, resp), nil
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("s3 returned %d", resp.StatusCode)
+	}
+}
+
+// Delete succeeds for missing objects, as S3 itself does.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List pages through ListObjectsV2.
+func (s *S3) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			s.config.Endpoint+"/"+sigv4.EscapePath(s.config.Bucket)+"?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		sigv4.Sign(req, s.creds, time.Now())
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		var result listBucketResult
+		if resp.StatusCode != http.StatusOK {
+			err = s3Error(resp)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			if err := fn(ObjectInfo{Key: object.Key, Size: object.Size, LastModified: object.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3) presign(method, key string, expires time.Duration) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	base := s.config.Endpoint
+	if s.config.PublicURL != "" {
+		base = s.config.PublicURL
+	}
+	return sigv4.Presign(method, s.objectURL(base, key), s.creds, expires, time.Now())
+}
+
+func (s *S3) PresignGet(key string, expires time.Duration) (string, error) {
+	return s.presign(http.MethodGet, key, expires)
+}
+
+func (s *S3) PresignPut(key string, expires time.Duration) (string, error) {
+	return s.presign(http.MethodPut, key, expires)
+}
+
+func responseInfo(key string, resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{Key: key, ContentType: resp.Header.Get("Content-Type")}
+	info.Size, _ = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	info.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info
+}
@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"media_service/internal/s3stub"
+	"media_service/internal/storage/sigv4"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCreds = sigv4.Credentials{AccessKey: "minioadmin", SecretKey: "minioadmin", Region: "us-east-1"}
+
+// newTestS3 starts an s3stub server and returns a store talking to it.
+func newTestS3(t *testing.T) (*S3, *s3stub.Server, string) {
+	t.Helper()
+	stub := &s3stub.Server{Root: t.TempDir(), Creds: testCreds}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "media",
+		AccessKey: testCreds.AccessKey,
+		SecretKey: testCreds.SecretKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, stub, server.URL
+}
+
+func put(t *testing.T, store Storage, key, content, contentType string) {
+	t.Helper()
+	if err := store.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), contentType); err != nil {
+		t.Fatalf("Put(%s): %v", key, err)
+	}
+}
+
+func TestS3PutGetStatDelete(t *testing.T) {
+	store, _, _ := newTestS3(t)
+	ctx := context.Background()
+
+	put(t, store, "originals/ab/photo.jpg", "jpeg bytes", "image/jpeg")
+
+	body, info, err := store.Get(ctx, "originals/ab/photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(body)
+	body.Close()
+	if string(content) != "jpeg bytes" {
+		t.Errorf("Get content = %q", content)
+	}
+	if info.Size != 10 || info.ContentType != "image/jpeg" || info.LastModified.IsZero() {
+		t.Errorf("Get info = %+v", info)
+	}
+
+	stat, err := store.Stat(ctx, "originals/ab/photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Size != 10 || stat.ContentType != "image/jpeg" {
+		t.Errorf("Stat = %+v", stat)
+	}
+
+	// Put replaces an existing object.
+	put(t, store, "originals/ab/photo.jpg", "png", "image/png")
+	if stat, err := store.Stat(ctx, "originals/ab/photo.jpg"); err != nil || stat.Size != 3 || stat.ContentType != "image/png" {
+		t.Errorf("Stat after replace = %+v, %v", stat, err)
+	}
+
+	if err := store.Delete(ctx, "originals/ab/photo.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Stat(ctx, "originals/ab/photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+	}
+	if _, _, err := store.Get(ctx, "originals/ab/photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "originals/ab/photo.jpg"); err != nil {
+		t.Errorf("Delete of a missing object = %v", err)
+	}
+}
+
+func TestS3InvalidKeys(t *testing.T) {
+	store, _, _ := newTestS3(t)
+	ctx := context.Background()
+	for _, key := range []string{"", "/abs", "a/../b", "a//b", `a\b`} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.PresignGet(key, time.Minute); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("PresignGet(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestS3WrongCredentials(t *testing.T) {
+	_, _, endpoint := newTestS3(t)
+	store, err := NewS3(S3Config{Endpoint: endpoint, Bucket: "media", AccessKey: "minioadmin", SecretKey: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(context.Background(), "a.txt", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("Put with a wrong secret = %v", err)
+	}
+}
+
+func listKeys(t *testing.T, store Storage, prefix string) []string {
+	t.Helper()
+	var keys []string
+	err := store.List(context.Background(), prefix, func(object ObjectInfo) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List(%q): %v", prefix, err)
+	}
+	return keys
+}
+
+func TestS3List(t *testing.T) {
+	store, _, _ := newTestS3(t)
+	for _, key := range []string{"originals/b.jpg", "originals/a.jpg", "variants/x/1.webp", "tmp.txt"} {
+		put(t, store, key, "data", "")
+	}
+
+	if got := strings.Join(listKeys(t, store, "originals/"), ","); got != "originals/a.jpg,originals/b.jpg" {
+		t.Errorf("List(originals/) = %s", got)
+	}
+	if got := len(listKeys(t, store, "")); got != 4 {
+		t.Errorf("List() found %d objects, want 4", got)
+	}
+	if got := listKeys(t, store, "missing/"); len(got) != 0 {
+		t.Errorf("List(missing/) = %v", got)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := store.List(context.Background(), "", func(ObjectInfo) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("List stopped with %v after %d calls", err, calls)
+	}
+}
+
+// The stub returns at most 1000 keys a page; List must follow the
+// continuation tokens.
+func TestS3ListPages(t *testing.T) {
+	store, stub, _ := newTestS3(t)
+	dir := filepath.Join(stub.Root, "media", "many")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	const objects = 1001
+	for i := 0; i < objects; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%04d", i)), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := listKeys(t, store, "many/")
+	if len(keys) != objects {
+		t.Fatalf("List found %d objects, want %d", len(keys), objects)
+	}
+	if keys[0] != "many/0000" || keys[objects-1] != "many/1000" {
+		t.Errorf("List returned %s … %s", keys[0], keys[objects-1])
+	}
+}
+
+func do(t *testing.T, method, rawURL string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestS3PresignedPutAndGet(t *testing.T) {
+	store, _, _ := newTestS3(t)
+
+	putURL, err := store.PresignPut("uploads/direct.jpg", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := do(t, http.MethodPut, putURL, []byte("browser upload")); resp.StatusCode != http.StatusOK {
+		t.Fatalf("presigned PUT returned %d", resp.StatusCode)
+	}
+
+	getURL, err := store.PresignGet("uploads/direct.jpg", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := do(t, http.MethodGet, getURL, nil)
+	content, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(content) != "browser upload" {
+		t.Fatalf("presigned GET returned %d %q", resp.StatusCode, content)
+	}
+}
+
+func TestS3PresignedRejected(t *testing.T) {
+	store, _, _ := newTestS3(t)
+	put(t, store, "a.jpg", "data", "image/jpeg")
+
+	getURL, err := store.PresignGet("a.jpg", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putURL, err := store.PresignPut("a.jpg", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered, _ := url.Parse(getURL)
+	query := tampered.Query()
+	signature := []byte(query.Get("X-Amz-Signature"))
+	signature[0] ^= 1
+	query.Set("X-Amz-Signature", string(signature))
+	tampered.RawQuery = query.Encode()
+
+	otherKey := strings.Replace(getURL, "/media/a.jpg?", "/media/b.jpg?", 1)
+
+	longer, _ := url.Parse(getURL)
+	query = longer.Query()
+	query.Set("X-Amz-Expires", "3600")
+	longer.RawQuery = query.Encode()
+
+	// Signed two hours ago for one hour, as an old link would be.
+	objectURL := strings.SplitN(getURL, "?", 2)[0]
+	expired, err := sigv4.Presign(http.MethodGet, objectURL, testCreds, time.Hour, time.Now().Add(-2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		code   string
+	}{
+		{"expired", http.MethodGet, expired, "AccessDenied"},
+		{"tampered signature", http.MethodGet, tampered.String(), "SignatureDoesNotMatch"},
+		{"other key", http.MethodGet, otherKey, "SignatureDoesNotMatch"},
+		{"extended expiry", http.MethodGet, longer.String(), "SignatureDoesNotMatch"},
+		{"GET URL used to PUT", http.MethodPut, getURL, "SignatureDoesNotMatch"},
+		{"PUT URL used to GET", http.MethodGet, putURL, "SignatureDoesNotMatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(t, tt.method, tt.url, []byte("overwrite"))
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), tt.code) {
+				t.Fatalf("got %d %s, want 403 %s", resp.StatusCode, body, tt.code)
+			}
+		})
+	}
+
+	// None of the rejected PUTs changed the object.
+	body, _, err := store.Get(context.Background(), "a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if content, _ := io.ReadAll(body); string(content) != "data" {
+		t.Errorf("object changed to %q", content)
+	}
+}
+
+func TestS3PresignUsesPublicURL(t *testing.T) {
+	store, err := NewS3(S3Config{
+		Endpoint:  "http://minio:9000/",
+		PublicURL: "https://media.example.com/",
+		Bucket:    "media",
+		AccessKey: "key",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	presigned, err := store.PresignGet("originals/a b.jpg", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(presigned, "https://media.example.com/media/originals/a%20b.jpg?") {
+		t.Errorf("PresignGet = %s", presigned)
+	}
+}
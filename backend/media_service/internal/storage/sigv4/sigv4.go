@@ -0,0 +1,263 @@
+// Package sigv4 signs and verifies AWS Signature Version 4 requests for the
+// S3 API, both in the Authorization header and as presigned query strings.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm = "AWS4-HMAC-SHA256"
+	service   = "s3"
+	// UnsignedPayload skips hashing the body, which S3 accepts for both
+	// header and query signing.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	timeFormat      = "20060102T150405Z"
+	dateFormat      = "20060102"
+	maxClockSkew    = 15 * time.Minute
+	maxPresignAge   = 7 * 24 * time.Hour
+)
+
+var (
+	ErrMissingAuth      = errors.New("request is not signed")
+	ErrInvalidSignature = errors.New("signature does not match")
+	ErrExpired          = errors.New("request has expired")
+)
+
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// escape is the RFC 3986 encoding SigV4 expects: everything but unreserved
+// characters is percent-encoded, and "/" too unless it separates a path.
+func escape(value string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// EscapePath encodes an object path for use in a request URL.
+func EscapePath(path string) string {
+	return escape(path, true)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if key != "X-Amz-Signature" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escape(key, false)+"="+escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func canonicalHeaders(header http.Header, host string, signed []string) string {
+	var b strings.Builder
+	for _, name := range signed {
+		value := header.Get(name)
+		if name == "host" {
+			value = host
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(strings.Fields(value), " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func canonicalRequest(method, path string, query url.Values, header http.Header, host string, signed []string, payloadHash string) string {
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		method,
+		escape(path, true),
+		canonicalQuery(query),
+		canonicalHeaders(header, host, signed),
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func scope(date, region string) string {
+	return date + "/" + region + "/" + service + "/aws4_request"
+}
+
+func signature(creds Credentials, stamp time.Time, canonical string) string {
+	date := stamp.Format(dateFormat)
+	stringToSign := strings.Join([]string{
+		algorithm,
+		stamp.Format(timeFormat),
+		scope(date, creds.Region),
+		sha256Hex(canonical),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretKey), date)
+	key = hmacSHA256(key, creds.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// Sign adds the SigV4 Authorization header to req. The body is not hashed.
+func Sign(req *http.Request, creds Credentials, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", UnsignedPayload)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signed = append([]string{"content-type"}, signed...)
+	}
+	canonical := canonicalRequest(req.Method, req.URL.Path, req.URL.Query(), req.Header, req.URL.Host, signed, UnsignedPayload)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKey, scope(now.Format(dateFormat), creds.Region),
+		strings.Join(signed, ";"), signature(creds, now, canonical)))
+}
+
+// Presign returns rawURL with a query-string signature valid for expires.
+// Only the host header is signed, so any client can use the URL.
+func Presign(method, rawURL string, creds Credentials, expires time.Duration, now time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	now = now.UTC()
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", creds.AccessKey+"/"+scope(now.Format(dateFormat), creds.Region))
+	query.Set("X-Amz-Date", now.Format(timeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonical := canonicalRequest(method, u.Path, query, http.Header{}, u.Host, []string{"host"}, UnsignedPayload)
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature(creds, now, canonical)
+	return u.String(), nil
+}
+
+// Verify checks a header-signed or presigned request against the secret
+// of lookup(accessKey).
+func Verify(r *http.Request, lookup func(accessKey string) (Credentials, bool), now time.Time) error {
+	if r.URL.Query().Get("X-Amz-Signature") != "" {
+		return verifyPresigned(r, lookup, now)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, algorithm+" ") {
+		return ErrMissingAuth
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, algorithm+" "), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		fields[key] = value
+	}
+
+	creds, date, ok := credentialScope(fields["Credential"], lookup)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	stamp, err := time.Parse(timeFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil || stamp.Format(dateFormat) != date {
+		return ErrInvalidSignature
+	}
+	if skew := now.Sub(stamp); skew > maxClockSkew || skew < -maxClockSkew {
+		return ErrExpired
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	canonical := canonicalRequest(r.Method, r.URL.Path, r.URL.Query(), r.Header, r.Host, signed, payloadHash)
+	if !hmac.Equal([]byte(signature(creds, stamp, canonical)), []byte(fields["Signature"])) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func verifyPresigned(r *http.Request, lookup func(accessKey string) (Credentials, bool), now time.Time) error {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != algorithm {
+		return ErrInvalidSignature
+	}
+	creds, date, ok := credentialScope(query.Get("X-Amz-Credential"), lookup)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	stamp, err := time.Parse(timeFormat, query.Get("X-Amz-Date"))
+	if err != nil || stamp.Format(dateFormat) != date {
+		return ErrInvalidSignature
+	}
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxPresignAge {
+		return ErrInvalidSignature
+	}
+	if now.After(stamp.Add(time.Duration(seconds) * time.Second)) {
+		return ErrExpired
+	}
+
+	signed := strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	canonical := canonicalRequest(r.Method, r.URL.Path, query, r.Header, r.Host, signed, UnsignedPayload)
+	if !hmac.Equal([]byte(signature(creds, stamp, canonical)), []byte(query.Get("X-Amz-Signature"))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// credentialScope reads "AKID/20250101/region/s3/aws4_request".
+func credentialScope(credential string, lookup func(string) (Credentials, bool)) (Credentials, string, bool) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != service || parts[4] != "aws4_request" {
+		return Credentials{}, "", false
+	}
+	creds, ok := lookup(parts[0])
+	if !ok || creds.Region != parts[2] {
+		return Credentials{}, "", false
+	}
+	return creds, parts[1], true
+}
@@ -0,0 +1,88 @@
+// Package storage keeps media objects in a pluggable object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Storage is an object store addressed by slash-separated keys. Put
+// replaces an existing object with the same key.
+type Storage interface {
+	Name() string
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every object whose key starts with prefix,
+	// stopping at the first error fn returns.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// Presigner is implemented by stores clients can talk to directly. The
+// media service hands out its own signed URLs for stores that are not.
+type Presigner interface {
+	PresignGet(key string, expires time.Duration) (string, error)
+	PresignPut(key string, expires time.Duration) (string, error)
+}
+
+// ValidKey rejects keys that could escape the store's root.
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// FromEnv builds the store named by MEDIA_STORAGE: "local" (the default)
+// keeps objects under MEDIA_DIR; "s3" talks to any S3-compatible endpoint
+// configured by S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY and
+// S3_SECRET_KEY, with S3_PUBLIC_URL as the endpoint clients can reach.
+func FromEnv() (Storage, error) {
+	switch strings.ToLower(os.Getenv("MEDIA_STORAGE")) {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "/app/media"
+		}
+		return NewLocal(dir)
+	case "s3":
+		config := S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if config.Endpoint == "" || config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required for s3 storage")
+		}
+		return NewS3(config)
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORAGE %q", os.Getenv("MEDIA_STORAGE"))
+	}
+}
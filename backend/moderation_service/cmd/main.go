@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"moderation_service/internal/moderation"
+	"moderation_service/internal/routes"
+	"moderation_service/internal/screening"
+	"moderation_service/utils/db"
+	"net/http"
+	"os"
+)
+
+func main() {
+	db.ConnectDB()
+
+	screener, err := screening.FromEnv(db.DB)
+	if err != nil {
+		log.Fatalf("Failed to configure screening: %v", err)
+	}
+	for _, check := range screener.Checks {
+		log.Printf("Screening with the %s check", check.Name())
+	}
+
+	moderationService := &moderation.Service{
+		DB:              db.DB,
+		Screener:        screener,
+		Content:         moderation.NewContent(),
+		ReportThreshold: moderation.ThresholdFromEnv(),
+	}
+
+	router := routes.SetupRoutes(moderationService)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8095"
+	}
+
+	log.Printf("Moderation service running on port %s", port)
+
+	log.Fatal(http.ListenAndServe(":"+port, router))
+}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"moderation_service/internal/models"
+	"moderation_service/internal/moderation"
+	"moderation_service/internal/screening"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type ModerationController struct {
+	Moderation *moderation.Service
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeModerationError maps moderation errors to HTTP statuses.
+func writeModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, moderation.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, moderation.ErrAlreadyReported), errors.Is(err, moderation.ErrAlreadyDeleted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, moderation.ErrOwnContent):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, moderation.ErrUnknownContentType), errors.Is(err, moderation.ErrInvalidReason),
+		errors.Is(err, moderation.ErrInvalidAction), errors.Is(err, moderation.ErrReasonRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Moderation error: %v", err)
+		http.Error(w, "Moderation failed", http.StatusInternalServerError)
+	}
+}
+
+// pagination reads page and page_size, 20 items by default and at most 100.
+func pagination(r *http.Request) (int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	return page, min(pageSize, 100)
+}
+
+func paginated(key string, data interface{}, page, pageSize int, total int64) map[string]interface{} {
+	return map[string]interface{}{
+		key: data,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	}
+}
+
+type screenRequest struct {
+	ContentType string `json:"content_type"`
+	ContentID   uint   `json:"content_id"`
+	AuthorID    uint   `json:"author_id"`
+	Text        string `json:"text"`
+	Edit        bool   `json:"edit"`
+}
+
+// Screen is called by content services when content is posted or edited.
+// A "hold" verdict means the content should not be shown until a
+// moderator approves it.
+func (c *ModerationController) Screen(w http.ResponseWriter, r *http.Request) {
+	var req screenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ContentID == 0 {
+		http.Error(w, "content_id is required", http.StatusBadRequest)
+		return
+	}
+
+	flags, err := c.Moderation.Screen(r.Context(), screening.Submission{
+		ContentType: req.ContentType,
+		ContentID:   req.ContentID,
+		AuthorID:    req.AuthorID,
+		Text:        req.Text,
+		Edit:        req.Edit,
+	})
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+
+	verdict := "allow"
+	if len(flags) > 0 {
+		verdict = "hold"
+	} else {
+		flags = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"verdict": verdict, "flags": flags})
+}
+
+type reportRequest struct {
+	ContentType string `json:"content_type"`
+	ContentID   uint   `json:"content_id"`
+	AuthorID    uint   `json:"author_id"`
+	Text        string `json:"text"`
+	ReporterID  uint   `json:"reporter_id"`
+	Reason      string `json:"reason"`
+	Details     string `json:"details"`
+}
+
+// Report is called by content services on behalf of the reporting user.
+func (c *ModerationController) Report(w http.ResponseWriter, r *http.Request) {
+	var req reportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ContentID == 0 || req.ReporterID == 0 {
+		http.Error(w, "content_id and reporter_id are required", http.StatusBadRequest)
+		return
+	}
+
+	item, err := c.Moderation.Report(r.Context(), moderation.ReportInput{
+		ContentType: req.ContentType,
+		ContentID:   req.ContentID,
+		AuthorID:    req.AuthorID,
+		Text:        req.Text,
+		ReporterID:  req.ReporterID,
+		Reason:      req.Reason,
+		Details:     req.Details,
+	})
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"message":        "Report received",
+		"content_status": item.ContentStatus,
+	})
+}
+
+// Queue lists queue items. ?status= is pending by default, or approved,
+// hidden, deleted or all.
+func (c *ModerationController) Queue(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := pagination(r)
+	items, total, err := c.Moderation.Queue(r.Context(), moderation.QueueFilter{
+		Status:      r.URL.Query().Get("status"),
+		ContentType: r.URL.Query().Get("content_type"),
+		Page:        page,
+		PageSize:    pageSize,
+	})
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, paginated("items", items, page, pageSize, total))
+}
+
+func itemID(r *http.Request) uint {
+	id, _ := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	return uint(id)
+}
+
+// GetItem returns a queue item with its reports and audit log.
+func (c *ModerationController) GetItem(w http.ResponseWriter, r *http.Request) {
+	item, err := c.Moderation.Get(r.Context(), itemID(r))
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// Act approves, hides or deletes the content of a queue item. The body
+// carries the reason: {"reason": "..."}.
+func (c *ModerationController) Act(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	item, err := c.Moderation.Act(r.Context(), itemID(r), adminID, mux.Vars(r)["action"], req.Reason)
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// Audit lists moderation decisions, filtered by ?moderator_id=,
+// ?content_type=, ?content_id= and ?action=.
+func (c *ModerationController) Audit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	moderatorID, _ := strconv.ParseUint(query.Get("moderator_id"), 10, 32)
+	contentID, _ := strconv.ParseUint(query.Get("content_id"), 10, 32)
+
+	page, pageSize := pagination(r)
+	actions, total, err := c.Moderation.Audit(r.Context(), moderation.AuditFilter{
+		ModeratorID: uint(moderatorID),
+		ContentType: query.Get("content_type"),
+		ContentID:   uint(contentID),
+		Action:      query.Get("action"),
+		Page:        page,
+		PageSize:    pageSize,
+	})
+	if err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, paginated("actions", actions, page, pageSize, total))
+}
+
+// Reasons lists the reasons users can give when reporting content.
+func (c *ModerationController) Reasons(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, models.ReportReasons)
+}
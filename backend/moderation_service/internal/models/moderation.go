@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Content types that can be reported and screened.
+const (
+	ContentReview  = "review"
+	ContentBlog    = "blog"
+	ContentComment = "comment"
+)
+
+// Queue item statuses.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusHidden   = "hidden"
+	StatusDeleted  = "deleted"
+)
+
+// Content statuses, as the owning services store them. ContentDeleted only
+// appears on queue items.
+const (
+	ContentVisible = "visible"
+	ContentHeld    = "held"
+	ContentHidden  = "hidden"
+	ContentDeleted = "deleted"
+)
+
+// Moderator actions, plus the hold the system places on its own.
+const (
+	ActionApprove = "approve"
+	ActionHide    = "hide"
+	ActionDelete  = "delete"
+	ActionHold    = "hold"
+)
+
+// ReportReasons are the reasons a user can pick when reporting content.
+var ReportReasons = []string{"spam", "offensive", "harassment", "misinformation", "other"}
+
+type StringArray []string
+
+func (s StringArray) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *StringArray) Scan(value interface{}) error {
+	if value == nil {
+		*s = StringArray{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		return errors.New("invalid scan source for StringArray")
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// ModerationItem is a piece of content in the moderation queue. Each
+// content has at most one item, which reopens when it is reported again
+// after a decision.
+type ModerationItem struct {
+	ID            uint        `json:"id" gorm:"primaryKey"`
+	ContentType   string      `json:"content_type" gorm:"size:16;not null;uniqueIndex:idx_moderation_items_content,priority:1"`
+	ContentID     uint        `json:"content_id" gorm:"not null;uniqueIndex:idx_moderation_items_content,priority:2"`
+	AuthorID      uint        `json:"author_id" gorm:"index"`
+	Excerpt       string      `json:"excerpt"`
+	Status        string      `json:"status" gorm:"size:16;not null;index"`
+	ContentStatus string      `json:"content_status" gorm:"size:16;not null;default:visible"`
+	Flags         StringArray `json:"flags" gorm:"type:json"`
+	ReportCount   int         `json:"report_count" gorm:"not null;default:0"`
+	OpenReports   int         `json:"open_reports" gorm:"not null;default:0"`
+	ResolvedBy    *uint       `json:"resolved_by,omitempty"`
+	ResolvedAt    *time.Time  `json:"resolved_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+
+	Reports []ModerationReport `json:"reports,omitempty" gorm:"foreignKey:ItemID;constraint:OnDelete:CASCADE;"`
+	Audit   []ModerationAction `json:"audit,omitempty" gorm:"foreignKey:ItemID"`
+}
+
+// ModerationReport is one user's report of an item. A user reports an
+// item once.
+type ModerationReport struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	ItemID     uint      `json:"item_id" gorm:"not null;uniqueIndex:idx_moderation_reports_reporter,priority:1"`
+	ReporterID uint      `json:"reporter_id" gorm:"not null;uniqueIndex:idx_moderation_reports_reporter,priority:2"`
+	Reason     string    `json:"reason" gorm:"size:32;not null"`
+	Details    string    `json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ModerationAction is the audit log of decisions on items: every
+// moderator action and every hold placed automatically, for which
+// ModeratorID is nil.
+type ModerationAction struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ItemID      uint      `json:"item_id" gorm:"not null;index"`
+	ContentType string    `json:"content_type" gorm:"size:16;not null;index:idx_moderation_audit_content,priority:1"`
+	ContentID   uint      `json:"content_id" gorm:"not null;index:idx_moderation_audit_content,priority:2"`
+	ModeratorID *uint     `json:"moderator_id,omitempty" gorm:"index"`
+	Action      string    `json:"action" gorm:"size:16;not null"`
+	Reason      string    `json:"reason"`
+	FromStatus  string    `json:"from_status" gorm:"size:16"`
+	ToStatus    string    `json:"to_status" gorm:"size:16"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+}
+
+// ModerationSubmission records that an author posted content, for
+// posting-rate checks.
+type ModerationSubmission struct {
+	ID          uint      `gorm:"primaryKey"`
+	AuthorID    uint      `gorm:"not null;index:idx_moderation_submissions_author,priority:1"`
+	ContentType string    `gorm:"size:16;not null"`
+	ContentID   uint      `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"not null;index:idx_moderation_submissions_author,priority:2"`
+}
@@ -0,0 +1,99 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"moderation_service/internal/models"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrContentGone is returned when the content was deleted by its owner.
+var ErrContentGone = errors.New("content no longer exists")
+
+func getReviewServiceURL() string {
+	if u := os.Getenv("REVIEW_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://review-service:8086"
+}
+
+func getBlogsServiceURL() string {
+	if u := os.Getenv("BLOGS_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://blogs-service:8081"
+}
+
+// Content applies decisions in the services that own the content, through
+// their /internal/{kind}/{id} endpoints.
+type Content struct {
+	Client *http.Client
+}
+
+func NewContent() *Content {
+	return &Content{Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func contentURL(contentType string, id uint) (string, error) {
+	switch contentType {
+	case models.ContentReview:
+		return fmt.Sprintf("%s/internal/reviews/%d", getReviewServiceURL(), id), nil
+	case models.ContentBlog:
+		return fmt.Sprintf("%s/internal/blogs/%d", getBlogsServiceURL(), id), nil
+	case models.ContentComment:
+		return fmt.Sprintf("%s/internal/comments/%d", getBlogsServiceURL(), id), nil
+	}
+	return "", ErrUnknownContentType
+}
+
+// SetStatus makes content visible, held or hidden.
+func (c *Content) SetStatus(ctx context.Context, contentType string, id uint, status string) error {
+	target, err := contentURL(contentType, id)
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]string{"status": status})
+	return c.do(ctx, http.MethodPut, target+"/moderation", body)
+}
+
+// Delete removes content for good.
+func (c *Content) Delete(ctx context.Context, contentType string, id uint) error {
+	target, err := contentURL(contentType, id)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodDelete, target, nil)
+}
+
+func (c *Content) do(ctx context.Context, method, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrContentGone
+	case resp.StatusCode >= 300:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%s %s returned %d: %s", method, target, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+// Package moderation keeps the queue of content waiting for a moderator:
+// content held by automatic screening and content users reported.
+// Decisions are applied in the services that own the content and recorded
+// in the audit log.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"moderation_service/internal/models"
+	"moderation_service/internal/screening"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound           = errors.New("moderation item not found")
+	ErrUnknownContentType = errors.New("unknown content type")
+	ErrInvalidReason      = errors.New("invalid report reason")
+	ErrAlreadyReported    = errors.New("content already reported by this user")
+	ErrOwnContent         = errors.New("users cannot report their own content")
+	ErrInvalidAction      = errors.New("invalid moderation action")
+	ErrReasonRequired     = errors.New("a reason is required to hide or delete content")
+	ErrAlreadyDeleted     = errors.New("content was already deleted")
+)
+
+// excerptLength caps the text kept with queue items.
+const excerptLength = 500
+
+// ThresholdFromEnv reads MODERATION_REPORT_THRESHOLD, the number of open
+// reports at which content is held until a moderator looks at it.
+func ThresholdFromEnv() int {
+	if value := os.Getenv("MODERATION_REPORT_THRESHOLD"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid MODERATION_REPORT_THRESHOLD=%q, using 3", value)
+	}
+	return 3
+}
+
+type Service struct {
+	DB              *gorm.DB
+	Screener        *screening.Screener
+	Content         *Content
+	ReportThreshold int
+}
+
+func validContentType(contentType string) bool {
+	switch contentType {
+	case models.ContentReview, models.ContentBlog, models.ContentComment:
+		return true
+	}
+	return false
+}
+
+func excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if runes := []rune(text); len(runes) > excerptLength {
+		return string(runes[:excerptLength]) + "…"
+	}
+	return text
+}
+
+// lockItem returns the queue item of some content, creating it when the
+// content has none, locked for the rest of tx.
+func lockItem(tx *gorm.DB, contentType string, contentID, authorID uint, text string) (*models.ModerationItem, error) {
+	item := models.ModerationItem{
+		ContentType:   contentType,
+		ContentID:     contentID,
+		AuthorID:      authorID,
+		Excerpt:       excerpt(text),
+		Status:        models.StatusPending,
+		ContentStatus: models.ContentVisible,
+		Flags:         models.StringArray{},
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&item).Error; err != nil {
+		return nil, err
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("content_type = ? AND content_id = ?", contentType, contentID).
+		First(&item).Error
+	if err != nil {
+		return nil, err
+	}
+	if text != "" {
+		item.Excerpt = excerpt(text)
+	}
+	if authorID != 0 {
+		item.AuthorID = authorID
+	}
+	return &item, nil
+}
+
+func audit(tx *gorm.DB, item *models.ModerationItem, moderatorID *uint, action, reason, from string) error {
+	return tx.Create(&models.ModerationAction{
+		ItemID:      item.ID,
+		ContentType: item.ContentType,
+		ContentID:   item.ContentID,
+		ModeratorID: moderatorID,
+		Action:      action,
+		Reason:      reason,
+		FromStatus:  from,
+		ToStatus:    item.Status,
+	}).Error
+}
+
+// Screen runs the automatic checks on content that was just posted or
+// edited. When a check flags it, the content is queued as held and the
+// flags are returned; the owning service is expected to hold it.
+func (s *Service) Screen(ctx context.Context, submission screening.Submission) ([]string, error) {
+	if !validContentType(submission.ContentType) {
+		return nil, ErrUnknownContentType
+	}
+
+	flags := s.Screener.Screen(ctx, submission)
+
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if !submission.Edit && submission.AuthorID != 0 {
+			record := models.ModerationSubmission{
+				AuthorID:    submission.AuthorID,
+				ContentType: submission.ContentType,
+				ContentID:   submission.ContentID,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		if len(flags) == 0 {
+			return nil
+		}
+
+		item, err := lockItem(tx, submission.ContentType, submission.ContentID, submission.AuthorID, submission.Text)
+		if err != nil {
+			return err
+		}
+		if item.ContentStatus == models.ContentHidden || item.ContentStatus == models.ContentDeleted {
+			// Moderators already took it down; edits do not bring it back.
+			return nil
+		}
+		from := item.Status
+		item.Status = models.StatusPending
+		item.ContentStatus = models.ContentHeld
+		item.Flags = flags
+		item.ResolvedBy = nil
+		item.ResolvedAt = nil
+		if err := tx.Save(item).Error; err != nil {
+			return err
+		}
+		return audit(tx, item, nil, models.ActionHold, strings.Join(flags, "; "), from)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// ReportInput is a user's report of some content.
+type ReportInput struct {
+	ContentType string
+	ContentID   uint
+	AuthorID    uint
+	Text        string
+	ReporterID  uint
+	Reason      string
+	Details     string
+}
+
+// Report queues reported content. Content that collects ReportThreshold
+// open reports is held until a moderator decides on it.
+func (s *Service) Report(ctx context.Context, input ReportInput) (*models.ModerationItem, error) {
+	if !validContentType(input.ContentType) {
+		return nil, ErrUnknownContentType
+	}
+	if !slices.Contains(models.ReportReasons, input.Reason) {
+		return nil, ErrInvalidReason
+	}
+	if input.AuthorID != 0 && input.AuthorID == input.ReporterID {
+		return nil, ErrOwnContent
+	}
+
+	var item *models.ModerationItem
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		item, err = lockItem(tx, input.ContentType, input.ContentID, input.AuthorID, input.Text)
+		if err != nil {
+			return err
+		}
+		if item.Status == models.StatusDeleted {
+			return ErrAlreadyDeleted
+		}
+
+		report := models.ModerationReport{
+			ItemID:     item.ID,
+			ReporterID: input.ReporterID,
+			Reason:     input.Reason,
+			Details:    strings.TrimSpace(input.Details),
+		}
+		if err := tx.Create(&report).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadyReported
+			}
+			return err
+		}
+
+		// A report after an approval reopens the item; hidden content stays
+		// hidden.
+		from := item.Status
+		if item.Status == models.StatusApproved {
+			item.Status = models.StatusPending
+			item.ResolvedBy = nil
+			item.ResolvedAt = nil
+		}
+		item.ReportCount++
+		item.OpenReports++
+
+		if item.ContentStatus == models.ContentVisible && item.OpenReports >= s.ReportThreshold {
+			err := s.Content.SetStatus(ctx, item.ContentType, item.ContentID, models.ContentHeld)
+			switch {
+			case errors.Is(err, ErrContentGone):
+				item.Status = models.StatusDeleted
+				item.ContentStatus = models.ContentDeleted
+			case err != nil:
+				// The report still counts; a moderator will see it.
+				log.Printf("Failed to hold %s %d: %v", item.ContentType, item.ContentID, err)
+			default:
+				item.ContentStatus = models.ContentHeld
+				reason := fmt.Sprintf("%d open reports", item.OpenReports)
+				if err := audit(tx, item, nil, models.ActionHold, reason, from); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Save(item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Act applies a moderator's decision on an item. Hiding and deleting need
+// a reason, which is kept in the audit log.
+func (s *Service) Act(ctx context.Context, itemID, moderatorID uint, action, reason string) (*models.ModerationItem, error) {
+	reason = strings.TrimSpace(reason)
+	switch action {
+	case models.ActionApprove:
+	case models.ActionHide, models.ActionDelete:
+		if reason == "" {
+			return nil, ErrReasonRequired
+		}
+	default:
+		return nil, ErrInvalidAction
+	}
+
+	var item models.ModerationItem
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, itemID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if item.Status == models.StatusDeleted {
+			return ErrAlreadyDeleted
+		}
+
+		from := item.Status
+		switch action {
+		case models.ActionApprove:
+			err = s.Content.SetStatus(ctx, item.ContentType, item.ContentID, models.ContentVisible)
+			item.Status, item.ContentStatus = models.StatusApproved, models.ContentVisible
+		case models.ActionHide:
+			err = s.Content.SetStatus(ctx, item.ContentType, item.ContentID, models.ContentHidden)
+			item.Status, item.ContentStatus = models.StatusHidden, models.ContentHidden
+		case models.ActionDelete:
+			err = s.Content.Delete(ctx, item.ContentType, item.ContentID)
+			item.Status, item.ContentStatus = models.StatusDeleted, models.ContentDeleted
+		}
+		if errors.Is(err, ErrContentGone) {
+			// The author deleted it meanwhile; record the decision anyway.
+			item.Status, item.ContentStatus = models.StatusDeleted, models.ContentDeleted
+			reason = strings.TrimSpace(reason + " (content no longer exists)")
+		} else if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		item.OpenReports = 0
+		item.ResolvedBy = &moderatorID
+		item.ResolvedAt = &now
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+		return audit(tx, &item, &moderatorID, action, reason, from)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// QueueFilter selects queue items. Status defaults to pending.
+type QueueFilter struct {
+	Status      string
+	ContentType string
+	Page        int
+	PageSize    int
+}
+
+// Queue lists items, most reported first and then oldest first.
+func (s *Service) Queue(ctx context.Context, filter QueueFilter) ([]models.ModerationItem, int64, error) {
+	query := s.DB.WithContext(ctx).Model(&models.ModerationItem{})
+	if filter.Status != "all" {
+		status := filter.Status
+		if status == "" {
+			status = models.StatusPending
+		}
+		query = query.Where("status = ?", status)
+	}
+	if filter.ContentType != "" {
+		query = query.Where("content_type = ?", filter.ContentType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var items []models.ModerationItem
+	err := query.Order("open_reports DESC, updated_at ASC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&items).Error
+	return items, total, err
+}
+
+// Get returns an item with its reports and audit log.
+func (s *Service) Get(ctx context.Context, id uint) (*models.ModerationItem, error) {
+	var item models.ModerationItem
+	err := s.DB.WithContext(ctx).
+		Preload("Reports", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		Preload("Audit", func(db *gorm.DB) *gorm.DB { return db.Order("created_at DESC") }).
+		First(&item, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// AuditFilter selects audit log entries. Zero values match everything.
+type AuditFilter struct {
+	ModeratorID uint
+	ContentType string
+	ContentID   uint
+	Action      string
+	Page        int
+	PageSize    int
+}
+
+// Audit lists audit log entries, newest first.
+func (s *Service) Audit(ctx context.Context, filter AuditFilter) ([]models.ModerationAction, int64, error) {
+	query := s.DB.WithContext(ctx).Model(&models.ModerationAction{})
+	if filter.ModeratorID != 0 {
+		query = query.Where("moderator_id = ?", filter.ModeratorID)
+	}
+	if filter.ContentType != "" {
+		query = query.Where("content_type = ?", filter.ContentType)
+	}
+	if filter.ContentID != 0 {
+		query = query.Where("content_id = ?", filter.ContentID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var actions []models.ModerationAction
+	err := query.Order("created_at DESC, id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&actions).Error
+	return actions, total, err
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"moderation_service/internal/controllers"
+	"moderation_service/internal/middleware"
+	"moderation_service/internal/moderation"
+
+	"github.com/gorilla/mux"
+)
+
+func SetupRoutes(moderationService *moderation.Service) *mux.Router {
+	r := mux.NewRouter()
+
+	moderationController := &controllers.ModerationController{Moderation: moderationService}
+
+	// Called by the services that own the content; not routed by the gateway.
+	internal := r.PathPrefix("/internal/moderation").Subrouter()
+	internal.Use(middleware.RequireServiceToken)
+	internal.HandleFunc("/screen", moderationController.Screen).Methods("POST")
+	internal.HandleFunc("/reports", moderationController.Report).Methods("POST")
+
+	admin := r.PathPrefix("/admin/moderation").Subrouter()
+	admin.Use(middleware.AdminAuthMiddleware)
+	admin.HandleFunc("/queue", moderationController.Queue).Methods("GET")
+	admin.HandleFunc("/queue/{id:[0-9]+}", moderationController.GetItem).Methods("GET")
+	admin.HandleFunc("/queue/{id:[0-9]+}/{action:approve|hide|delete}", moderationController.Act).Methods("POST")
+	admin.HandleFunc("/audit", moderationController.Audit).Methods("GET")
+	admin.HandleFunc("/reasons", moderationController.Reasons).Methods("GET")
+
+	return r
+}
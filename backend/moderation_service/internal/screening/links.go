@@ -0,0 +1,83 @@
+package screening
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'()]+`)
+
+// defaultShorteners hide where a link goes, which spammers rely on.
+var defaultShorteners = []string{
+	"bit.ly", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd", "buff.ly",
+	"cutt.ly", "rebrand.ly", "shorturl.at", "tiny.cc", "rb.gy",
+}
+
+// LinkSpam holds submissions with more than MaxLinks links, links through
+// URL shorteners, or the same link repeated.
+type LinkSpam struct {
+	MaxLinks   int
+	Shorteners map[string]bool
+}
+
+// newLinkSpam reads MODERATION_MAX_LINKS (default 2) and
+// MODERATION_SHORTENERS, which replaces the built-in shortener hosts.
+func newLinkSpam(*gorm.DB) (Check, error) {
+	hosts := defaultShorteners
+	if value, ok := os.LookupEnv("MODERATION_SHORTENERS"); ok {
+		hosts = strings.Split(value, ",")
+	}
+
+	check := &LinkSpam{
+		MaxLinks:   intFromEnv("MODERATION_MAX_LINKS", 2),
+		Shorteners: map[string]bool{},
+	}
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			check.Shorteners[host] = true
+		}
+	}
+	return check, nil
+}
+
+func (c *LinkSpam) Name() string { return "links" }
+
+func (c *LinkSpam) Check(_ context.Context, submission Submission) (string, error) {
+	links := linkPattern.FindAllString(submission.Text, -1)
+	if len(links) == 0 {
+		return "", nil
+	}
+	if len(links) > c.MaxLinks {
+		return fmt.Sprintf("contains %d links", len(links)), nil
+	}
+
+	seen := map[string]bool{}
+	for _, link := range links {
+		link = strings.TrimRight(link, ".,;:!?")
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		parsed, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+
+		host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+		if c.Shorteners[host] {
+			return fmt.Sprintf("links through shortener %s", host), nil
+		}
+
+		key := host + strings.TrimRight(parsed.EscapedPath(), "/")
+		if seen[key] {
+			return "repeats the same link", nil
+		}
+		seen[key] = true
+	}
+	return "", nil
+}
@@ -0,0 +1,49 @@
+package screening
+
+import (
+	"context"
+	"fmt"
+	"moderation_service/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostingRate holds content from authors who post more than Limit times
+// within Window. Edits do not count.
+type PostingRate struct {
+	DB     *gorm.DB
+	Limit  int
+	Window time.Duration
+}
+
+// newPostingRate reads MODERATION_RATE_LIMIT (default 5) and
+// MODERATION_RATE_WINDOW (default 10m).
+func newPostingRate(db *gorm.DB) (Check, error) {
+	return &PostingRate{
+		DB:     db,
+		Limit:  intFromEnv("MODERATION_RATE_LIMIT", 5),
+		Window: durationFromEnv("MODERATION_RATE_WINDOW", 10*time.Minute),
+	}, nil
+}
+
+func (c *PostingRate) Name() string { return "rate" }
+
+func (c *PostingRate) Check(ctx context.Context, submission Submission) (string, error) {
+	if submission.Edit || submission.AuthorID == 0 {
+		return "", nil
+	}
+
+	var recent int64
+	err := c.DB.WithContext(ctx).Model(&models.ModerationSubmission{}).
+		Where("author_id = ? AND created_at > ?", submission.AuthorID, time.Now().Add(-c.Window)).
+		Count(&recent).Error
+	if err != nil {
+		return "", err
+	}
+	// The submission being screened is not recorded yet.
+	if recent >= int64(c.Limit) {
+		return fmt.Sprintf("posted %d times within %s", recent+1, c.Window), nil
+	}
+	return "", nil
+}
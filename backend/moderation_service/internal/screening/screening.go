@@ -0,0 +1,125 @@
+// Package screening runs automatic checks on content as it is posted.
+// Checks are pluggable: each is registered under a name and
+// MODERATION_CHECKS lists the ones that run.
+package screening
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Submission is content being posted or edited.
+type Submission struct {
+	ContentType string
+	ContentID   uint
+	AuthorID    uint
+	Text        string
+	// Edit is set for changes to content posted earlier; they do not count
+	// as new posts.
+	Edit bool
+}
+
+// Check inspects a submission and returns why it should be held for a
+// moderator, or "" to let it through.
+type Check interface {
+	Name() string
+	Check(ctx context.Context, submission Submission) (string, error)
+}
+
+// Builder creates a check, configured from the environment.
+type Builder func(db *gorm.DB) (Check, error)
+
+var builders = map[string]Builder{}
+
+// Register makes a check available to MODERATION_CHECKS.
+func Register(name string, build Builder) {
+	builders[name] = build
+}
+
+func init() {
+	Register("words", newWordList)
+	Register("links", newLinkSpam)
+	Register("rate", newPostingRate)
+}
+
+// Screener runs checks in order.
+type Screener struct {
+	Checks []Check
+}
+
+// FromEnv builds the checks named in MODERATION_CHECKS, by default all
+// built-in ones.
+func FromEnv(db *gorm.DB) (*Screener, error) {
+	names := "words,links,rate"
+	if value, ok := os.LookupEnv("MODERATION_CHECKS"); ok {
+		names = value
+	}
+
+	screener := &Screener{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		build, ok := builders[name]
+		if !ok {
+			known := make([]string, 0, len(builders))
+			for knownName := range builders {
+				known = append(known, knownName)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown check %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		check, err := build(db)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		screener.Checks = append(screener.Checks, check)
+	}
+	return screener, nil
+}
+
+// Screen returns the reasons to hold a submission, as "check: reason". A
+// check that fails is logged and skipped, so that posting keeps working.
+func (s *Screener) Screen(ctx context.Context, submission Submission) []string {
+	var flags []string
+	for _, check := range s.Checks {
+		reason, err := check.Check(ctx, submission)
+		if err != nil {
+			log.Printf("Screening check %s failed: %v", check.Name(), err)
+			continue
+		}
+		if reason != "" {
+			flags = append(flags, check.Name()+": "+reason)
+		}
+	}
+	return flags
+}
+
+func intFromEnv(name string, fallback int) int {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %d", name, value, fallback)
+	}
+	return fallback
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %s", name, value, fallback)
+	}
+	return fallback
+}
@@ -0,0 +1,91 @@
+package screening
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// WordList holds submissions that contain a blocked word or phrase. Words
+// match whole, ignoring case and punctuation, so "class" does not match
+// "ass" but "f.r.e.e  m0ney" matches "free money".
+type WordList struct {
+	phrases []string
+}
+
+// leet undoes the most common letter substitutions.
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// normalize lowercases text and reduces it to space-separated words, with a
+// space at both ends so that phrases can be matched with strings.Contains.
+func normalize(text string) string {
+	text = leet.Replace(strings.ToLower(text))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+	for i, word := range words {
+		words[i] = strings.ReplaceAll(word, ".", "")
+	}
+	return " " + strings.Join(strings.Fields(strings.Join(words, " ")), " ") + " "
+}
+
+// NewWordList blocks the given words and phrases.
+func NewWordList(phrases []string) *WordList {
+	list := &WordList{}
+	for _, phrase := range phrases {
+		if normalized := strings.TrimSpace(normalize(phrase)); normalized != "" {
+			list.phrases = append(list.phrases, normalized)
+		}
+	}
+	return list
+}
+
+// newWordList reads MODERATION_BLOCKED_WORDS, a comma-separated list, and
+// MODERATION_WORDLIST_FILE, one word or phrase per line with # comments.
+func newWordList(*gorm.DB) (Check, error) {
+	var phrases []string
+	if value := os.Getenv("MODERATION_BLOCKED_WORDS"); value != "" {
+		phrases = append(phrases, strings.Split(value, ",")...)
+	}
+
+	if path := os.Getenv("MODERATION_WORDLIST_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				phrases = append(phrases, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewWordList(phrases), nil
+}
+
+func (l *WordList) Name() string { return "words" }
+
+func (l *WordList) Check(_ context.Context, submission Submission) (string, error) {
+	if len(l.phrases) == 0 {
+		return "", nil
+	}
+	text := normalize(submission.Text)
+	for _, phrase := range l.phrases {
+		if strings.Contains(text, " "+phrase+" ") {
+			return fmt.Sprintf("contains blocked phrase %q", phrase), nil
+		}
+	}
+	return "", nil
+}
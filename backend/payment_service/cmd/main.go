@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"payment_service/internal/payments"
+	"payment_service/internal/provider"
+	"payment_service/internal/routes"
+	"payment_service/utils/db"
+	"time"
+)
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %s", name, value, fallback)
+	}
+	return fallback
+}
+
+func main() {
+	db.ConnectDB()
+
+	paymentProvider, err := provider.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure payment provider: %v", err)
+	}
+	log.Printf("Using %s payment provider", paymentProvider.Name())
+
+	service := &payments.Service{Provider: paymentProvider}
+	service.Start(durationFromEnv("PAYMENT_SWEEP_INTERVAL", time.Minute))
+
+	simulate := os.Getenv("PAYMENT_SIMULATION") == "true"
+	if simulate {
+		if _, ok := paymentProvider.(*provider.Fake); !ok {
+			log.Fatal("PAYMENT_SIMULATION needs the fake payment provider")
+		}
+		log.Print("WARNING: payment simulation is on; users can complete payments without paying")
+	}
+
+	router := routes.SetupRoutes(service, simulate)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8093"
+	}
+
+	log.Printf("Payment service running on port %s", port)
+
+	log.Fatal(http.ListenAndServe(":"+port, router))
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"payment_service/internal/models"
+	"payment_service/internal/payments"
+	"payment_service/internal/provider"
+	"payment_service/internal/subjects"
+	"payment_service/utils/db"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const maxWebhookBytes = 1 << 20
+
+type PaymentController struct {
+	Payments *payments.Service
+}
+
+type CreatePaymentRequest struct {
+	SubjectType string `json:"subject_type"`
+	SubjectID   uint   `json:"subject_id"`
+}
+
+type RefundRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+type SimulateRequest struct {
+	Outcome string `json:"outcome"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writePaymentError maps payment errors to HTTP statuses.
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch err {
+	case payments.ErrNotFound, subjects.ErrNotFound:
+		http.Error(w, "Payment not found", http.StatusNotFound)
+	case payments.ErrUnknownSubject, payments.ErrInvalidAmount:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case payments.ErrNotPayable, payments.ErrInvalidState, provider.ErrInvalidTransition:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case payments.ErrForbidden:
+		http.Error(w, "Unauthorized - not the owner", http.StatusUnauthorized)
+	default:
+		log.Printf("Payment error: %v", err)
+		http.Error(w, "Failed to process payment", http.StatusBadGateway)
+	}
+}
+
+func contextUserID(r *http.Request) (uint, bool) {
+	userID, ok := r.Context().Value("user_id").(uint)
+	return userID, ok
+}
+
+func (c *PaymentController) myPayment(w http.ResponseWriter, r *http.Request) (*models.Payment, bool) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var payment models.Payment
+	if err := db.DB.Where("id = ? AND user_id = ?", mux.Vars(r)["id"], userID).First(&payment).Error; err != nil {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &payment, true
+}
+
+// CreatePayment starts a payment for one of the user's subjects and returns
+// the client secret the frontend hands to the provider's payment form.
+func (c *PaymentController) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.SubjectID == 0 {
+		http.Error(w, "subject_id is required", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := c.Payments.Create(r.Context(), userID, req.SubjectType, req.SubjectID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+func (c *PaymentController) ListMyPayments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := contextUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized - user ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	query := db.DB.Where("user_id = ?", userID)
+	if subjectType := r.URL.Query().Get("subject_type"); subjectType != "" {
+		query = query.Where("subject_type = ?", subjectType)
+	}
+	if subjectID := r.URL.Query().Get("subject_id"); subjectID != "" {
+		id, err := strconv.ParseUint(subjectID, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid subject_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("subject_id = ?", id)
+	}
+
+	paymentList := []models.Payment{}
+	if err := query.Order("created_at DESC").Limit(100).Find(&paymentList).Error; err != nil {
+		http.Error(w, "Failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"payments": paymentList})
+}
+
+func (c *PaymentController) GetMyPayment(w http.ResponseWriter, r *http.Request) {
+	payment, ok := c.myPayment(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
+}
+
+// Webhook receives provider events. Unverifiable requests are rejected;
+// events the service does not use are acknowledged so the provider stops
+// retrying them.
+func (c *PaymentController) Webhook(w http.ResponseWriter, r *http.Request) {
+	if mux.Vars(r)["provider"] != c.Payments.Provider.Name() {
+		http.Error(w, "Unknown payment provider", http.StatusNotFound)
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		http.Error(w, "Failed to read webhook", http.StatusBadRequest)
+		return
+	}
+
+	event, err := c.Payments.Provider.VerifyWebhook(payload, r.Header)
+	if err == provider.ErrUnhandledEvent {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		log.Printf("Rejected %s webhook: %v", c.Payments.Provider.Name(), err)
+		http.Error(w, "Invalid webhook", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.Payments.HandleEvent(r.Context(), event); err != nil {
+		log.Printf("Failed to handle webhook %s: %v", event.ID, err)
+		http.Error(w, "Failed to handle webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Simulate completes a payment with the fake provider: it stands in for the
+// provider's payment form and delivers the resulting webhook through the
+// same path real events take. Other providers return 404.
+func (c *PaymentController) Simulate(w http.ResponseWriter, r *http.Request) {
+	fake, ok := c.Payments.Provider.(*provider.Fake)
+	if !ok {
+		http.Error(w, "Simulation is only available with the fake provider", http.StatusNotFound)
+		return
+	}
+
+	payment, ok := c.myPayment(w, r)
+	if !ok {
+		return
+	}
+	if payment.ProviderPaymentID == nil {
+		http.Error(w, payments.ErrInvalidState.Error(), http.StatusConflict)
+		return
+	}
+
+	var req SimulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Outcome != provider.OutcomeAuthorize && req.Outcome != provider.OutcomeFail && req.Outcome != provider.OutcomeCancel {
+		http.Error(w, "outcome must be authorize, fail or cancel", http.StatusBadRequest)
+		return
+	}
+
+	payload, header, err := fake.Simulate(*payment.ProviderPaymentID, req.Outcome)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	event, err := fake.VerifyWebhook(payload, header)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	if err := c.Payments.HandleEvent(r.Context(), event); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	if err := db.DB.First(payment, payment.ID).Error; err != nil {
+		http.Error(w, "Failed to fetch payment", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
+}
+
+// RefundCancelled refunds the payments of a subject its service just
+// cancelled. The body is {"subject_type": "...", "subject_id": ...}.
+func (c *PaymentController) RefundCancelled(w http.ResponseWriter, r *http.Request) {
+	var req CreatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SubjectID == 0 {
+		http.Error(w, "subject_type and subject_id are required", http.StatusBadRequest)
+		return
+	}
+
+	refunded, err := c.Payments.RefundCancelled(r.Context(), req.SubjectType, req.SubjectID)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	for i := range refunded {
+		refunded[i].ClientSecret = ""
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"payments": refunded})
+}
+
+// ListSubjectPayments lists the payments of a subject the admin owns.
+func (c *PaymentController) ListSubjectPayments(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - admin ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	subjectType := r.URL.Query().Get("subject_type")
+	subjectID, err := strconv.ParseUint(r.URL.Query().Get("subject_id"), 10, 32)
+	if subjectType == "" || err != nil {
+		http.Error(w, "subject_type and subject_id are required", http.StatusBadRequest)
+		return
+	}
+
+	subject := models.Payment{SubjectType: subjectType, SubjectID: uint(subjectID)}
+	if err := payments.CheckOwner(&subject, adminID); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	paymentList := []models.Payment{}
+	err = db.DB.Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).
+		Order("created_at DESC").Find(&paymentList).Error
+	if err != nil {
+		http.Error(w, "Failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+	for i := range paymentList {
+		paymentList[i].ClientSecret = ""
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"payments": paymentList})
+}
+
+func (c *PaymentController) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("admin_id").(uint)
+	if !ok {
+		http.Error(w, "Unauthorized - admin ID missing", http.StatusUnauthorized)
+		return
+	}
+
+	paymentID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RefundRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	payment, err := c.Payments.Refund(r.Context(), uint(paymentID), adminID, req.Amount)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	payment.ClientSecret = ""
+
+	writeJSON(w, http.StatusOK, payment)
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PaymentPending           = "pending"
+	PaymentAuthorized        = "authorized"
+	PaymentCaptured          = "captured"
+	PaymentFailed            = "failed"
+	PaymentCancelled         = "cancelled"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
+// Payment is one attempt to pay for a subject, e.g. a reservation. Amounts
+// are kept in the currency's major unit like the rest of the catalog.
+type Payment struct {
+	gorm.Model
+	SubjectType       string  `json:"subject_type" gorm:"index:idx_payment_subject;not null"`
+	SubjectID         uint    `json:"subject_id" gorm:"index:idx_payment_subject;not null"`
+	UserID            uint    `json:"user_id" gorm:"index;not null"`
+	Provider          string  `json:"provider" gorm:"not null"`
+	ProviderPaymentID *string `json:"provider_payment_id,omitempty" gorm:"uniqueIndex"`
+	ClientSecret      string  `json:"client_secret,omitempty"`
+	Amount            float64 `json:"amount" gorm:"not null"`
+	Currency          string  `json:"currency" gorm:"size:3;not null"`
+	AmountCaptured    float64 `json:"amount_captured"`
+	AmountRefunded    float64 `json:"amount_refunded"`
+	Status            string  `json:"status" gorm:"index;not null"`
+	FailureReason     string  `json:"failure_reason,omitempty"`
+
+	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
+	CapturedAt   *time.Time `json:"captured_at,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+}
+
+// Open reports whether the payment can still be completed.
+func (p *Payment) Open() bool {
+	return p.Status == PaymentPending || p.Status == PaymentAuthorized || p.Status == PaymentFailed
+}
+
+// WebhookEvent records every processed provider event. The unique index
+// makes redelivered events no-ops.
+type WebhookEvent struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	Provider    string    `json:"provider" gorm:"not null;uniqueIndex:idx_webhook_event"`
+	EventID     string    `json:"event_id" gorm:"not null;uniqueIndex:idx_webhook_event"`
+	Type        string    `json:"type"`
+	PaymentID   *uint     `json:"payment_id" gorm:"index"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
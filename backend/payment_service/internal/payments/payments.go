@@ -0,0 +1,533 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"payment_service/internal/models"
+	"payment_service/internal/provider"
+	"payment_service/internal/subjects"
+	"payment_service/utils/db"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotFound       = errors.New("payment not found")
+	ErrUnknownSubject = errors.New("unknown subject_type")
+	ErrNotPayable     = errors.New("this item can no longer be paid for")
+	ErrInvalidState   = errors.New("payment cannot change from its current status")
+	ErrInvalidAmount  = errors.New("amount must be positive and not exceed the captured amount left to refund")
+	ErrForbidden      = errors.New("not allowed to manage this payment")
+)
+
+// Service moves payments through their lifecycle: create an intent,
+// capture it once the provider reports an authorization and the subject is
+// still payable, cancel it when the subject lapsed, and refund on request.
+type Service struct {
+	Provider provider.Provider
+}
+
+func lockPayment(tx *gorm.DB, id uint) (*models.Payment, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	return &payment, nil
+}
+
+func subjectKind(payment *models.Payment) (subjects.Kind, error) {
+	kind, ok := subjects.Get(payment.SubjectType)
+	if !ok {
+		return nil, ErrUnknownSubject
+	}
+	return kind, nil
+}
+
+// save stores the payment and mirrors its status onto the subject.
+func save(tx *gorm.DB, payment *models.Payment) error {
+	if err := tx.Save(payment).Error; err != nil {
+		return err
+	}
+	kind, err := subjectKind(payment)
+	if err != nil {
+		return err
+	}
+	return kind.SetPaymentStatus(tx, payment.SubjectID, payment.ID, payment.Status)
+}
+
+// Create starts paying for a subject owned by the user. An open payment for
+// the same subject is returned instead of creating a second one, so clients
+// can safely retry.
+func (s *Service) Create(ctx context.Context, userID uint, subjectType string, subjectID uint) (*models.Payment, error) {
+	kind, ok := subjects.Get(subjectType)
+	if !ok {
+		return nil, ErrUnknownSubject
+	}
+
+	tx := db.DB.Begin()
+	defer tx.Rollback()
+
+	subject, err := kind.Load(tx, subjectID)
+	if err == subjects.ErrNotFound || (err == nil && subject.UserID != userID) {
+		return nil, subjects.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !subject.Payable || subject.Amount <= 0 {
+		return nil, ErrNotPayable
+	}
+
+	var existing models.Payment
+	err = tx.Where("subject_type = ? AND subject_id = ? AND provider = ? AND status IN ?",
+		subjectType, subjectID, s.Provider.Name(),
+		[]string{models.PaymentPending, models.PaymentAuthorized, models.PaymentFailed}).
+		Order("id DESC").First(&existing).Error
+	if err == nil && existing.Amount == subject.Amount && existing.ProviderPaymentID != nil {
+		return &existing, tx.Commit().Error
+	}
+
+	payment := &models.Payment{
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		UserID:      userID,
+		Provider:    s.Provider.Name(),
+		Amount:      subject.Amount,
+		Currency:    subject.Currency,
+		Status:      models.PaymentPending,
+	}
+	if err := tx.Create(payment).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	intent, err := s.Provider.CreateIntent(ctx, provider.IntentParams{
+		Amount:      provider.ToMinor(payment.Amount, payment.Currency),
+		Currency:    payment.Currency,
+		Description: subject.Description,
+		Metadata: map[string]string{
+			"payment_id":   fmt.Sprint(payment.ID),
+			"subject_type": subjectType,
+			"subject_id":   fmt.Sprint(subjectID),
+		},
+		IdempotencyKey: fmt.Sprintf("payment-%d-create", payment.ID),
+	})
+	if err != nil {
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = "Could not reach the payment provider"
+		db.DB.Save(payment)
+		return nil, err
+	}
+
+	payment.ProviderPaymentID = &intent.ID
+	payment.ClientSecret = intent.ClientSecret
+	if err := db.DB.Transaction(func(tx *gorm.DB) error { return save(tx, payment) }); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// markCaptured records a capture and confirms the subject. It reports
+// whether the subject could not be confirmed, in which case the money has
+// to go back.
+func markCaptured(tx *gorm.DB, payment *models.Payment, amount float64) (bool, error) {
+	kind, err := subjectKind(payment)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	payment.Status = models.PaymentCaptured
+	payment.AmountCaptured = amount
+	payment.CapturedAt = &now
+	payment.FailureReason = ""
+	if err := tx.Save(payment).Error; err != nil {
+		return false, err
+	}
+
+	err = kind.Paid(tx, payment.SubjectID, payment.ID, payment.Status)
+	if err == subjects.ErrNotPayable {
+		return true, kind.SetPaymentStatus(tx, payment.SubjectID, payment.ID, payment.Status)
+	}
+	return false, err
+}
+
+// HandleEvent applies a verified webhook event. Each provider event is
+// recorded in the same transaction as its effects, so redeliveries are
+// ignored, and status changes only move forward, so late or out of order
+// events cannot undo newer state. When settling the payment afterwards
+// fails, the record is dropped again so that the provider's retry settles
+// it; the sweep catches what retries miss.
+func (s *Service) HandleEvent(ctx context.Context, event *provider.Event) error {
+	var settle, refund bool
+	var paymentID uint
+
+	record := models.WebhookEvent{
+		Provider:    s.Provider.Name(),
+		EventID:     event.ID,
+		Type:        event.Type,
+		ProcessedAt: time.Now(),
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var payment models.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_payment_id = ?", s.Provider.Name(), event.IntentID).
+			First(&payment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Ignoring %s for unknown intent %s", event.Type, event.IntentID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		paymentID = payment.ID
+		if err := tx.Model(&record).Update("payment_id", payment.ID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch event.Type {
+		case provider.EventAuthorized:
+			if payment.Status == models.PaymentAuthorized {
+				settle = true
+				return nil
+			}
+			if payment.Status != models.PaymentPending && payment.Status != models.PaymentFailed {
+				return nil
+			}
+			payment.Status = models.PaymentAuthorized
+			payment.AuthorizedAt = &now
+			payment.FailureReason = ""
+			settle = true
+			return save(tx, &payment)
+
+		case provider.EventCaptured:
+			if !payment.Open() {
+				return nil
+			}
+			refund, err = markCaptured(tx, &payment, provider.FromMinor(event.Amount, payment.Currency))
+			return err
+
+		case provider.EventFailed:
+			if payment.Status != models.PaymentPending && payment.Status != models.PaymentFailed {
+				return nil
+			}
+			payment.Status = models.PaymentFailed
+			payment.FailureReason = event.FailureReason
+			return save(tx, &payment)
+
+		case provider.EventCanceled:
+			if !payment.Open() {
+				return nil
+			}
+			payment.Status = models.PaymentCancelled
+			return save(tx, &payment)
+
+		case provider.EventRefunded:
+			if payment.Status != models.PaymentCaptured && payment.Status != models.PaymentPartiallyRefunded {
+				return nil
+			}
+			applyRefunded(&payment, provider.FromMinor(event.AmountRefunded, payment.Currency))
+			return save(tx, &payment)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if settle {
+		err = s.Settle(ctx, paymentID)
+	} else if refund {
+		err = s.refundUnconfirmed(ctx, paymentID)
+	}
+	if err != nil {
+		if forgetErr := db.DB.Delete(&record).Error; forgetErr != nil {
+			log.Printf("Failed to forget webhook %s for a retry: %v", event.ID, forgetErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// applyRefunded sets the cumulative refunded amount, never lowering it.
+func applyRefunded(payment *models.Payment, total float64) {
+	if total <= payment.AmountRefunded {
+		return
+	}
+	now := time.Now()
+	payment.AmountRefunded = math.Min(total, payment.AmountCaptured)
+	payment.RefundedAt = &now
+	payment.Status = models.PaymentPartiallyRefunded
+	if payment.AmountRefunded >= payment.AmountCaptured {
+		payment.Status = models.PaymentRefunded
+	}
+}
+
+// Settle captures an authorized payment while its subject is still payable
+// and cancels the authorization otherwise, so customers are never charged
+// for an expired hold.
+func (s *Service) Settle(ctx context.Context, paymentID uint) error {
+	var payment models.Payment
+	if err := db.DB.First(&payment, paymentID).Error; err != nil {
+		return ErrNotFound
+	}
+	if payment.Status != models.PaymentAuthorized || payment.ProviderPaymentID == nil {
+		return nil
+	}
+	kind, err := subjectKind(&payment)
+	if err != nil {
+		return err
+	}
+
+	var payable bool
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		subject, err := kind.Load(tx, payment.SubjectID)
+		if err != nil && err != subjects.ErrNotFound {
+			return err
+		}
+		payable = subject != nil && subject.Payable
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !payable {
+		if _, err := s.Provider.Cancel(ctx, *payment.ProviderPaymentID, fmt.Sprintf("payment-%d-cancel", payment.ID)); err != nil {
+			return err
+		}
+		return db.DB.Transaction(func(tx *gorm.DB) error {
+			locked, err := lockPayment(tx, payment.ID)
+			if err != nil || !locked.Open() {
+				return err
+			}
+			locked.Status = models.PaymentCancelled
+			locked.FailureReason = ErrNotPayable.Error()
+			return save(tx, locked)
+		})
+	}
+
+	intent, err := s.Provider.Capture(ctx, *payment.ProviderPaymentID,
+		provider.ToMinor(payment.Amount, payment.Currency), fmt.Sprintf("payment-%d-capture", payment.ID))
+	if err != nil {
+		return err
+	}
+	if intent.Status != provider.IntentCaptured {
+		return nil
+	}
+
+	var refund bool
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockPayment(tx, payment.ID)
+		if err != nil || !locked.Open() {
+			return err
+		}
+		refund, err = markCaptured(tx, locked, provider.FromMinor(intent.AmountCaptured, locked.Currency))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if refund {
+		return s.refundUnconfirmed(ctx, payment.ID)
+	}
+	return nil
+}
+
+// refundUnconfirmed returns the money of a payment whose subject lapsed
+// between authorization and capture.
+func (s *Service) refundUnconfirmed(ctx context.Context, paymentID uint) error {
+	log.Printf("Payment %d was captured for an item that is no longer available, refunding", paymentID)
+	_, err := s.refund(ctx, paymentID, 0)
+	return err
+}
+
+// RefundCancelled refunds in full the captured payments of a subject that
+// was cancelled. It returns the refunded payments; a subject that is not
+// cancelled has none. Payments an admin already refunded part of are left
+// for the admin to settle, who may have agreed on the rest with the
+// customer.
+func (s *Service) RefundCancelled(ctx context.Context, subjectType string, subjectID uint) ([]models.Payment, error) {
+	kind, ok := subjects.Get(subjectType)
+	if !ok {
+		return nil, ErrUnknownSubject
+	}
+
+	var cancelled bool
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		subject, err := kind.Load(tx, subjectID)
+		if err != nil {
+			return err
+		}
+		cancelled = subject.Cancelled
+		return nil
+	})
+	if err != nil || !cancelled {
+		return []models.Payment{}, err
+	}
+
+	var ids []uint
+	err = db.DB.Model(&models.Payment{}).
+		Where("subject_type = ? AND subject_id = ? AND status = ?", subjectType, subjectID, models.PaymentCaptured).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	refunded := []models.Payment{}
+	for _, id := range ids {
+		payment, err := s.refund(ctx, id, 0)
+		if err != nil {
+			return refunded, err
+		}
+		refunded = append(refunded, *payment)
+	}
+	return refunded, nil
+}
+
+// Refund returns part or all of a captured payment. Only the admin who owns
+// the subject may refund it; amount zero refunds everything left.
+func (s *Service) Refund(ctx context.Context, paymentID, adminID uint, amount float64) (*models.Payment, error) {
+	var payment models.Payment
+	if err := db.DB.First(&payment, paymentID).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	if err := CheckOwner(&payment, adminID); err != nil {
+		return nil, err
+	}
+	return s.refund(ctx, paymentID, amount)
+}
+
+// CheckOwner verifies that the admin owns the payment's subject.
+func CheckOwner(payment *models.Payment, adminID uint) error {
+	kind, err := subjectKind(payment)
+	if err != nil {
+		return err
+	}
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		subject, err := kind.Load(tx, payment.SubjectID)
+		if err == subjects.ErrNotFound {
+			return ErrForbidden
+		}
+		if err != nil {
+			return err
+		}
+		if subject.OwnerAdminID != adminID {
+			return ErrForbidden
+		}
+		return nil
+	})
+}
+
+func (s *Service) refund(ctx context.Context, paymentID uint, amount float64) (*models.Payment, error) {
+	var payment models.Payment
+	if err := db.DB.First(&payment, paymentID).Error; err != nil {
+		return nil, ErrNotFound
+	}
+	if payment.Status != models.PaymentCaptured && payment.Status != models.PaymentPartiallyRefunded {
+		return nil, ErrInvalidState
+	}
+
+	remaining := payment.AmountCaptured - payment.AmountRefunded
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining+0.005 {
+		return nil, ErrInvalidAmount
+	}
+
+	// Keyed by what was refunded before, so retrying the same request never
+	// refunds twice while a later refund gets a fresh key.
+	key := fmt.Sprintf("payment-%d-refund-%d", payment.ID, provider.ToMinor(payment.AmountRefunded, payment.Currency))
+	refund, err := s.Provider.Refund(ctx, *payment.ProviderPaymentID, provider.ToMinor(amount, payment.Currency), key)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *models.Payment
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockPayment(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+		applyRefunded(locked, payment.AmountRefunded+provider.FromMinor(refund.Amount, locked.Currency))
+		updated = locked
+		return save(tx, locked)
+	})
+	return updated, err
+}
+
+// stuckAfter is how long a payment may stay authorized before the sweep
+// settles it, leaving time for the webhook that normally does.
+const stuckAfter = time.Minute
+
+// Sweep settles payments left authorized because settling them failed,
+// and refunds the captured payments of cancelled or lapsed subjects that
+// no admin refunded part of. It
+// returns how many payments it settled and refunded.
+func (s *Service) Sweep(ctx context.Context) (int, int, error) {
+	var authorized []uint
+	err := db.DB.Model(&models.Payment{}).
+		Where("provider = ? AND status = ? AND authorized_at < ?",
+			s.Provider.Name(), models.PaymentAuthorized, time.Now().Add(-stuckAfter)).
+		Pluck("id", &authorized).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	settled := 0
+	for _, id := range authorized {
+		if err := s.Settle(ctx, id); err != nil {
+			log.Printf("Failed to settle payment %d: %v", id, err)
+			continue
+		}
+		settled++
+	}
+
+	refunded := 0
+	for subjectType, kind := range subjects.All() {
+		ids, err := kind.RefundsDue(db.DB)
+		if err != nil {
+			return settled, refunded, fmt.Errorf("%s refunds: %w", subjectType, err)
+		}
+		for _, id := range ids {
+			if _, err := s.refund(ctx, id, 0); err != nil {
+				log.Printf("Failed to refund payment %d of a cancelled %s: %v", id, subjectType, err)
+				continue
+			}
+			refunded++
+		}
+	}
+	return settled, refunded, nil
+}
+
+// Start runs Sweep right away and then on every tick.
+func (s *Service) Start(interval time.Duration) {
+	go func() {
+		for {
+			settled, refunded, err := s.Sweep(context.Background())
+			if err != nil {
+				log.Printf("Payment sweep error: %v", err)
+			}
+			if settled > 0 || refunded > 0 {
+				log.Printf("Payment sweep settled %d and refunded %d payments", settled, refunded)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,380 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"payment_service/internal/models"
+	"payment_service/internal/provider"
+	"payment_service/utils/db"
+	"payment_service/utils/testdb"
+	"testing"
+	"time"
+)
+
+// subjectTables stand in for the columns of accommodation-service's and
+// events-service's tables that payments read and update.
+var subjectTables = []string{
+	`CREATE TABLE accommodations (id bigserial PRIMARY KEY, admin_id bigint, name text, deleted_at timestamptz)`,
+	`CREATE TABLE reservations (id bigserial PRIMARY KEY, accommodation_id bigint, user_id bigint,
+		total_price double precision, currency text, status text, hold_expires_at timestamptz,
+		check_in date, check_out date, payment_id bigint, payment_status text,
+		confirmed_at timestamptz, updated_at timestamptz, deleted_at timestamptz)`,
+	`CREATE TABLE events (id bigserial PRIMARY KEY, admin_id bigint, title text,
+		price double precision, currency text)`,
+	`CREATE TABLE event_registrations (id bigserial PRIMARY KEY, event_id bigint, user_id bigint,
+		status text, hold_expires_at timestamptz, payment_id bigint, payment_status text,
+		confirmed_at timestamptz, updated_at timestamptz, deleted_at timestamptz)`,
+}
+
+// useTestDB points db.DB at a schema of its own holding the payment tables
+// and the subject tables.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	conn := testdb.Open(t)
+	if err := conn.AutoMigrate(&models.Payment{}, &models.WebhookEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range subjectTables {
+		if err := conn.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+}
+
+// heldReservation adds a reservation of user 1 held until holdFor from now.
+func heldReservation(t *testing.T, holdFor time.Duration) uint {
+	t.Helper()
+	var accommodationID, reservationID uint
+	if err := db.DB.Raw(`INSERT INTO accommodations (admin_id, name) VALUES (7, 'Hotel') RETURNING id`).Scan(&accommodationID).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := db.DB.Raw(`INSERT INTO reservations (accommodation_id, user_id, total_price, currency, status,
+			hold_expires_at, check_in, check_out)
+		VALUES (?, 1, 25000, 'KZT', 'held', ?, CURRENT_DATE + 30, CURRENT_DATE + 32) RETURNING id`,
+		accommodationID, time.Now().Add(holdFor)).Scan(&reservationID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reservationID
+}
+
+// heldTicket adds a place of user 1 at a priced event, held for an hour.
+func heldTicket(t *testing.T) uint {
+	t.Helper()
+	var eventID, registrationID uint
+	if err := db.DB.Raw(`INSERT INTO events (admin_id, title, price, currency) VALUES (7, 'Concert', 5000, 'KZT') RETURNING id`).Scan(&eventID).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := db.DB.Raw(`INSERT INTO event_registrations (event_id, user_id, status, hold_expires_at)
+		VALUES (?, 1, 'held', ?) RETURNING id`, eventID, time.Now().Add(time.Hour)).Scan(&registrationID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registrationID
+}
+
+func ticketStatus(t *testing.T, id uint) string {
+	t.Helper()
+	var status string
+	if err := db.DB.Raw(`SELECT status FROM event_registrations WHERE id = ?`, id).Scan(&status).Error; err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func reservationStatus(t *testing.T, id uint) (string, string) {
+	t.Helper()
+	var row struct {
+		Status        string
+		PaymentStatus *string
+	}
+	if err := db.DB.Raw(`SELECT status, payment_status FROM reservations WHERE id = ?`, id).Scan(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.PaymentStatus == nil {
+		return row.Status, ""
+	}
+	return row.Status, *row.PaymentStatus
+}
+
+func loadPayment(t *testing.T, id uint) models.Payment {
+	t.Helper()
+	var payment models.Payment
+	if err := db.DB.First(&payment, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func webhookCount(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	if err := db.DB.Model(&models.WebhookEvent{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// flakyProvider fails the first captures, as a provider outage would.
+type flakyProvider struct {
+	*provider.Fake
+	failures int
+	captures int
+}
+
+func (p *flakyProvider) Capture(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*provider.Intent, error) {
+	p.captures++
+	if p.failures > 0 {
+		p.failures--
+		return nil, errors.New("provider unavailable")
+	}
+	return p.Fake.Capture(ctx, intentID, amount, idempotencyKey)
+}
+
+// simulate has the fake provider produce the verified event for outcome.
+func simulate(t *testing.T, fake *provider.Fake, payment *models.Payment, outcome string) *provider.Event {
+	t.Helper()
+	payload, header, err := fake.Simulate(*payment.ProviderPaymentID, outcome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := fake.VerifyWebhook(payload, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestHandleEventIgnoresRedeliveries(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	fake := &flakyProvider{Fake: provider.NewFake("whsec")}
+	service := &Service{Provider: fake}
+
+	reservationID := heldReservation(t, time.Hour)
+	payment, err := service.Create(ctx, 1, "reservation", reservationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := simulate(t, fake.Fake, payment, provider.OutcomeAuthorize)
+
+	for delivery := 1; delivery <= 3; delivery++ {
+		if err := service.HandleEvent(ctx, authorized); err != nil {
+			t.Fatalf("delivery %d: %v", delivery, err)
+		}
+	}
+
+	got := loadPayment(t, payment.ID)
+	if got.Status != models.PaymentCaptured || got.AmountCaptured != 25000 {
+		t.Errorf("payment = %s, captured %v", got.Status, got.AmountCaptured)
+	}
+	if fake.captures != 1 {
+		t.Errorf("captured %d times", fake.captures)
+	}
+	if count := webhookCount(t); count != 1 {
+		t.Errorf("%d webhook events recorded, want 1", count)
+	}
+	if status, paymentStatus := reservationStatus(t, reservationID); status != "confirmed" || paymentStatus != models.PaymentCaptured {
+		t.Errorf("reservation = %s, payment %s", status, paymentStatus)
+	}
+}
+
+// A webhook whose settle failed is forgotten, so the provider's retry of
+// the same event settles the payment.
+func TestHandleEventRetriesFailedSettle(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+	fake := &flakyProvider{Fake: provider.NewFake("whsec"), failures: 1}
+	service := &Service{Provider: fake}
+
+	reservationID := heldReservation(t, time.Hour)
+	payment, err := service.Create(ctx, 1, "reservation", reservationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := simulate(t, fake.Fake, payment, provider.OutcomeAuthorize)
+
+	if err := service.HandleEvent(ctx, authorized); err == nil {
+		t.Fatal("HandleEvent succeeded although the capture failed")
+	}
+	if got := loadPayment(t, payment.ID); got.Status != models.PaymentAuthorized {
+		t.Errorf("payment after the failed capture = %s, want authorized", got.Status)
+	}
+	if count := webhookCount(t); count != 0 {
+		t.Errorf("the failed event is still recorded (%d rows)", count)
+	}
+	if status, _ := reservationStatus(t, reservationID); status != "held" {
+		t.Errorf("reservation after the failed capture = %s", status)
+	}
+
+	if err := service.HandleEvent(ctx, authorized); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if got := loadPayment(t, payment.ID); got.Status != models.PaymentCaptured {
+		t.Errorf("payment after the retry = %s, want captured", got.Status)
+	}
+	if status, _ := reservationStatus(t, reservationID); status != "confirmed" {
+		t.Errorf("reservation after the retry = %s", status)
+	}
+	if count := webhookCount(t); count != 1 {
+		t.Errorf("%d webhook events recorded, want 1", count)
+	}
+}
+
+func TestHandleEventOutOfOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		expire      bool
+		late        string
+		wantPayment string
+		wantStatus  string
+	}{
+		// A failure reported after the capture cannot undo it.
+		{"failed after capture", false, provider.EventFailed, models.PaymentCaptured, "confirmed"},
+		// The hold lapsed before the authorization arrived, so it is
+		// cancelled instead of captured.
+		{"authorized after the hold expired", true, "", models.PaymentCancelled, "held"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDB(t)
+			ctx := context.Background()
+			fake := &flakyProvider{Fake: provider.NewFake("whsec")}
+			service := &Service{Provider: fake}
+
+			reservationID := heldReservation(t, time.Hour)
+			payment, err := service.Create(ctx, 1, "reservation", reservationID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.expire {
+				if err := db.DB.Exec(`UPDATE reservations SET hold_expires_at = ? WHERE id = ?`,
+					time.Now().Add(-time.Minute), reservationID).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := service.HandleEvent(ctx, simulate(t, fake.Fake, payment, provider.OutcomeAuthorize)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.late != "" {
+				// The fake refuses to fail a captured intent, so the late
+				// event is built by hand.
+				late := &provider.Event{ID: "evt_late", Type: tt.late, IntentID: *payment.ProviderPaymentID}
+				if err := service.HandleEvent(ctx, late); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := loadPayment(t, payment.ID); got.Status != tt.wantPayment {
+				t.Errorf("payment = %s, want %s", got.Status, tt.wantPayment)
+			}
+			if status, _ := reservationStatus(t, reservationID); status != tt.wantStatus {
+				t.Errorf("reservation = %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// Cancelled subjects get back payments still captured in full, whether the
+// owning service asks or the sweep finds them. Once an admin refunded part
+// of a payment, the rest is left to the admin.
+func TestRefundsOfCancelledSubjects(t *testing.T) {
+	tests := []struct {
+		name         string
+		partial      float64
+		sweep        bool
+		wantPayment  string
+		wantRefunded float64
+	}{
+		{"captured, on request", 0, false, models.PaymentRefunded, 25000},
+		{"captured, by the sweep", 0, true, models.PaymentRefunded, 25000},
+		{"partially refunded, on request", 5000, false, models.PaymentPartiallyRefunded, 5000},
+		{"partially refunded, by the sweep", 5000, true, models.PaymentPartiallyRefunded, 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDB(t)
+			ctx := context.Background()
+			fake := &flakyProvider{Fake: provider.NewFake("whsec")}
+			service := &Service{Provider: fake}
+
+			reservationID := heldReservation(t, time.Hour)
+			payment, err := service.Create(ctx, 1, "reservation", reservationID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := service.HandleEvent(ctx, simulate(t, fake.Fake, payment, provider.OutcomeAuthorize)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.partial > 0 {
+				if _, err := service.refund(ctx, payment.ID, tt.partial); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := db.DB.Exec(`UPDATE reservations SET status = 'cancelled' WHERE id = ?`, reservationID).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.sweep {
+				if _, _, err := service.Sweep(ctx); err != nil {
+					t.Fatal(err)
+				}
+			} else if _, err := service.RefundCancelled(ctx, "reservation", reservationID); err != nil {
+				t.Fatal(err)
+			}
+
+			got := loadPayment(t, payment.ID)
+			if got.Status != tt.wantPayment || got.AmountRefunded != tt.wantRefunded {
+				t.Errorf("payment = %s, refunded %v; want %s, refunded %v",
+					got.Status, got.AmountRefunded, tt.wantPayment, tt.wantRefunded)
+			}
+		})
+	}
+}
+
+// A held ticket is confirmed when its payment is captured; one whose hold
+// lapsed before the authorization arrived is not charged.
+func TestTicketConfirmedOnCapture(t *testing.T) {
+	tests := []struct {
+		name        string
+		holdFor     time.Duration
+		wantPayment string
+		wantStatus  string
+	}{
+		{"hold still active", time.Hour, models.PaymentCaptured, "confirmed"},
+		{"hold lapsed", -time.Minute, models.PaymentCancelled, "held"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestDB(t)
+			ctx := context.Background()
+			fake := &flakyProvider{Fake: provider.NewFake("whsec")}
+			service := &Service{Provider: fake}
+
+			// Payments are created while the hold is active.
+			registrationID := heldTicket(t)
+			payment, err := service.Create(ctx, 1, "ticket", registrationID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.DB.Exec(`UPDATE event_registrations SET hold_expires_at = ? WHERE id = ?`,
+				time.Now().Add(tt.holdFor), registrationID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := service.HandleEvent(ctx, simulate(t, fake.Fake, payment, provider.OutcomeAuthorize)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := loadPayment(t, payment.ID); got.Status != tt.wantPayment {
+				t.Errorf("payment = %s, want %s", got.Status, tt.wantPayment)
+			}
+			if status := ticketStatus(t, registrationID); status != tt.wantStatus {
+				t.Errorf("ticket = %s, want %s", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fake outcomes a client can simulate for an intent, standing in for the
+// card form that would talk to a real provider.
+const (
+	OutcomeAuthorize = "authorize"
+	OutcomeFail      = "fail"
+	OutcomeCancel    = "cancel"
+)
+
+var ErrInvalidTransition = errors.New("payment intent cannot change from its current status")
+
+// Fake is an in-memory provider for local development and tests. It keeps
+// intents in process memory, honours idempotency keys like a real provider,
+// and signs the webhooks it produces the same way Stripe does.
+type Fake struct {
+	secret string
+
+	mu       sync.Mutex
+	intents  map[string]*fakeIntent
+	keys     map[string]interface{}
+	sequence int
+}
+
+type fakeIntent struct {
+	Intent
+	refunded int64
+}
+
+type fakeEvent struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	IntentID       string `json:"intent_id"`
+	Amount         int64  `json:"amount"`
+	AmountRefunded int64  `json:"amount_refunded"`
+	FailureReason  string `json:"failure_reason,omitempty"`
+}
+
+func NewFake(secret string) *Fake {
+	return &Fake{
+		secret:  secret,
+		intents: make(map[string]*fakeIntent),
+		keys:    make(map[string]interface{}),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) nextID(prefix string) string {
+	f.sequence++
+	return fmt.Sprintf("%s_fake_%d", prefix, f.sequence)
+}
+
+func randomSecret() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (f *Fake) CreateIntent(ctx context.Context, params IntentParams) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cached, ok := f.keys[params.IdempotencyKey].(*Intent); ok {
+		copied := *cached
+		return &copied, nil
+	}
+	if params.Amount <= 0 {
+		return nil, errors.New("amount must be greater than zero")
+	}
+
+	id := f.nextID("pi")
+	intent := &fakeIntent{Intent: Intent{
+		ID:           id,
+		Status:       IntentPending,
+		ClientSecret: id + "_secret_" + randomSecret(),
+		Amount:       params.Amount,
+	}}
+	f.intents[id] = intent
+
+	copied := intent.Intent
+	if params.IdempotencyKey != "" {
+		f.keys[params.IdempotencyKey] = &copied
+	}
+	return &copied, nil
+}
+
+func (f *Fake) Capture(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cached, ok := f.keys[idempotencyKey].(*Intent); ok {
+		copied := *cached
+		return &copied, nil
+	}
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status != IntentAuthorized {
+		return nil, ErrInvalidTransition
+	}
+	if amount <= 0 || amount > intent.Amount {
+		amount = intent.Amount
+	}
+	intent.Status = IntentCaptured
+	intent.AmountCaptured = amount
+
+	copied := intent.Intent
+	if idempotencyKey != "" {
+		f.keys[idempotencyKey] = &copied
+	}
+	return &copied, nil
+}
+
+func (f *Fake) Cancel(ctx context.Context, intentID string, idempotencyKey string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status == IntentCaptured {
+		return nil, ErrInvalidTransition
+	}
+	intent.Status = IntentCanceled
+
+	copied := intent.Intent
+	return &copied, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cached, ok := f.keys[idempotencyKey].(*Refund); ok {
+		copied := *cached
+		return &copied, nil
+	}
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	remaining := intent.AmountCaptured - intent.refunded
+	if intent.Status != IntentCaptured || remaining <= 0 {
+		return nil, ErrInvalidTransition
+	}
+	if amount <= 0 || amount > remaining {
+		amount = remaining
+	}
+	intent.refunded += amount
+
+	refund := &Refund{ID: f.nextID("re"), Amount: amount, Status: "succeeded"}
+	if idempotencyKey != "" {
+		f.keys[idempotencyKey] = refund
+	}
+	copied := *refund
+	return &copied, nil
+}
+
+// Simulate moves an intent as if the customer had finished, failed or
+// abandoned the payment form, and returns the signed webhook the provider
+// would deliver for it.
+func (f *Fake) Simulate(intentID, outcome string) ([]byte, http.Header, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return nil, nil, ErrIntentNotFound
+	}
+
+	event := fakeEvent{ID: f.nextID("evt"), IntentID: intent.ID, Amount: intent.Amount}
+	switch outcome {
+	case OutcomeAuthorize:
+		if intent.Status != IntentPending && intent.Status != IntentFailed {
+			return nil, nil, ErrInvalidTransition
+		}
+		intent.Status = IntentAuthorized
+		event.Type = EventAuthorized
+	case OutcomeFail:
+		if intent.Status != IntentPending && intent.Status != IntentFailed {
+			return nil, nil, ErrInvalidTransition
+		}
+		intent.Status = IntentFailed
+		intent.FailureReason = "Your card was declined."
+		event.Type = EventFailed
+		event.FailureReason = intent.FailureReason
+	case OutcomeCancel:
+		if intent.Status == IntentCaptured {
+			return nil, nil, ErrInvalidTransition
+		}
+		intent.Status = IntentCanceled
+		event.Type = EventCanceled
+	default:
+		return nil, nil, fmt.Errorf("unknown outcome %q", outcome)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set("Fake-Signature", "t="+timestamp+",v1="+sign(payload, timestamp, f.secret))
+	return payload, header, nil
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := verifySignature(payload, header.Get("Fake-Signature"), f.secret, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event fakeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &Event{
+		ID:             event.ID,
+		Type:           event.Type,
+		IntentID:       event.IntentID,
+		Amount:         event.Amount,
+		AmountRefunded: event.AmountRefunded,
+		FailureReason:  event.FailureReason,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Intent states, mirroring the parts of Stripe's PaymentIntent lifecycle
+// the service relies on. Payments are authorized first and captured once the
+// booked item is confirmed to still be available.
+const (
+	IntentPending    = "pending"
+	IntentAuthorized = "authorized"
+	IntentCaptured   = "captured"
+	IntentFailed     = "failed"
+	IntentCanceled   = "canceled"
+)
+
+// Normalized webhook event types.
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventFailed     = "payment.failed"
+	EventCanceled   = "payment.canceled"
+	EventRefunded   = "payment.refunded"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnhandledEvent   = errors.New("unhandled webhook event")
+	ErrIntentNotFound   = errors.New("payment intent not found")
+)
+
+type IntentParams struct {
+	// Amount is in the currency's minor unit, e.g. tiyn for KZT.
+	Amount         int64
+	Currency       string
+	Description    string
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+type Intent struct {
+	ID             string
+	Status         string
+	ClientSecret   string
+	Amount         int64
+	AmountCaptured int64
+	FailureReason  string
+}
+
+type Refund struct {
+	ID     string
+	Amount int64
+	Status string
+}
+
+// Event is a verified webhook event reduced to what the service needs.
+// AmountRefunded is the intent's cumulative refunded amount.
+type Event struct {
+	ID             string
+	Type           string
+	IntentID       string
+	Amount         int64
+	AmountRefunded int64
+	FailureReason  string
+}
+
+// Provider is a payment processor. Every mutating call takes an idempotency
+// key so retries after timeouts never charge or refund twice.
+type Provider interface {
+	Name() string
+	CreateIntent(ctx context.Context, params IntentParams) (*Intent, error)
+	Capture(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Intent, error)
+	Cancel(ctx context.Context, intentID string, idempotencyKey string) (*Intent, error)
+	Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error)
+	// VerifyWebhook checks the request signature and parses the event.
+	// Events the service does not act on return ErrUnhandledEvent.
+	VerifyWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+// FromEnv builds the provider named by PAYMENT_PROVIDER: "stripe" needs
+// STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET; "fake" (the default) keeps
+// everything in memory and signs its webhooks with FAKE_WEBHOOK_SECRET.
+func FromEnv() (Provider, error) {
+	switch strings.ToLower(os.Getenv("PAYMENT_PROVIDER")) {
+	case "stripe":
+		secretKey := os.Getenv("STRIPE_SECRET_KEY")
+		webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+		if secretKey == "" || webhookSecret == "" {
+			return nil, errors.New("STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET are required for the stripe provider")
+		}
+		return NewStripe(os.Getenv("STRIPE_API_URL"), secretKey, webhookSecret), nil
+	case "", "fake":
+		secret := os.Getenv("FAKE_WEBHOOK_SECRET")
+		if secret == "" {
+			secret = "fake-webhook-secret"
+		}
+		return NewFake(secret), nil
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", os.Getenv("PAYMENT_PROVIDER"))
+	}
+}
+
+// zeroDecimal lists ISO 4217 currencies without a minor unit.
+var zeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// ToMinor converts an amount to the currency's minor unit.
+func ToMinor(amount float64, currency string) int64 {
+	if zeroDecimal[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinor converts an amount in minor units back to a decimal amount.
+func FromMinor(amount int64, currency string) float64 {
+	if zeroDecimal[strings.ToUpper(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
@@ -0,0 +1,277 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStripeURL = "https://api.stripe.com"
+	// signatureTolerance rejects replayed webhooks older than this.
+	signatureTolerance = 5 * time.Minute
+)
+
+// Stripe talks to the Stripe API directly over HTTP. Intents are created
+// with manual capture, so cards are only authorized until Capture.
+type Stripe struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	client        *http.Client
+}
+
+func NewStripe(baseURL, secretKey, webhookSecret string) *Stripe {
+	if baseURL == "" {
+		baseURL = defaultStripeURL
+	}
+	return &Stripe{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+type stripeIntent struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	ClientSecret     string `json:"client_secret"`
+	Amount           int64  `json:"amount"`
+	AmountReceived   int64  `json:"amount_received"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+func (i stripeIntent) intent() *Intent {
+	intent := &Intent{
+		ID:             i.ID,
+		ClientSecret:   i.ClientSecret,
+		Amount:         i.Amount,
+		AmountCaptured: i.AmountReceived,
+	}
+	switch i.Status {
+	case "requires_capture":
+		intent.Status = IntentAuthorized
+	case "succeeded":
+		intent.Status = IntentCaptured
+	case "canceled":
+		intent.Status = IntentCanceled
+	default:
+		intent.Status = IntentPending
+		if i.LastPaymentError != nil {
+			intent.Status = IntentFailed
+			intent.FailureReason = i.LastPaymentError.Message
+		}
+	}
+	return intent
+}
+
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s *Stripe) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.secretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body stripeError
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrIntentNotFound
+		}
+		return fmt.Errorf("stripe returned %d: %s", resp.StatusCode, body.Error.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (s *Stripe) CreateIntent(ctx context.Context, params IntentParams) (*Intent, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(params.Amount, 10))
+	form.Set("currency", strings.ToLower(params.Currency))
+	form.Set("capture_method", "manual")
+	form.Set("automatic_payment_methods[enabled]", "true")
+	if params.Description != "" {
+		form.Set("description", params.Description)
+	}
+	for key, value := range params.Metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+
+	var intent stripeIntent
+	if err := s.post(ctx, "/v1/payment_intents", form, params.IdempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+	return intent.intent(), nil
+}
+
+func (s *Stripe) Capture(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Intent, error) {
+	form := url.Values{}
+	if amount > 0 {
+		form.Set("amount_to_capture", strconv.FormatInt(amount, 10))
+	}
+
+	var intent stripeIntent
+	if err := s.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/capture", form, idempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+	return intent.intent(), nil
+}
+
+func (s *Stripe) Cancel(ctx context.Context, intentID string, idempotencyKey string) (*Intent, error) {
+	var intent stripeIntent
+	if err := s.post(ctx, "/v1/payment_intents/"+url.PathEscape(intentID)+"/cancel", url.Values{}, idempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+	return intent.intent(), nil
+}
+
+func (s *Stripe) Refund(ctx context.Context, intentID string, amount int64, idempotencyKey string) (*Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", intentID)
+	if amount > 0 {
+		form.Set("amount", strconv.FormatInt(amount, 10))
+	}
+
+	var refund struct {
+		ID     string `json:"id"`
+		Amount int64  `json:"amount"`
+		Status string `json:"status"`
+	}
+	if err := s.post(ctx, "/v1/refunds", form, idempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+	return &Refund{ID: refund.ID, Amount: refund.Amount, Status: refund.Status}, nil
+}
+
+// verifySignature checks a Stripe-Signature header: t=<unix time> followed
+// by one or more v1=<hex HMAC-SHA256 of "t.payload">.
+func verifySignature(payload []byte, header, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := sign(payload, timestamp, secret)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func sign(payload []byte, timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Stripe) VerifyWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := verifySignature(payload, header.Get("Stripe-Signature"), s.webhookSecret, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	event := &Event{ID: envelope.ID}
+	if envelope.Type == "charge.refunded" {
+		var charge struct {
+			PaymentIntent  string `json:"payment_intent"`
+			AmountRefunded int64  `json:"amount_refunded"`
+		}
+		if err := json.Unmarshal(envelope.Data.Object, &charge); err != nil {
+			return nil, fmt.Errorf("invalid charge object: %w", err)
+		}
+		event.Type = EventRefunded
+		event.IntentID = charge.PaymentIntent
+		event.AmountRefunded = charge.AmountRefunded
+		return event, nil
+	}
+
+	var intent stripeIntent
+	if err := json.Unmarshal(envelope.Data.Object, &intent); err != nil {
+		return nil, fmt.Errorf("invalid payment intent object: %w", err)
+	}
+	event.IntentID = intent.ID
+	event.Amount = intent.Amount
+
+	switch envelope.Type {
+	case "payment_intent.amount_capturable_updated":
+		event.Type = EventAuthorized
+	case "payment_intent.succeeded":
+		event.Type = EventCaptured
+		event.Amount = intent.AmountReceived
+	case "payment_intent.payment_failed":
+		event.Type = EventFailed
+		if intent.LastPaymentError != nil {
+			event.FailureReason = intent.LastPaymentError.Message
+		}
+	case "payment_intent.canceled":
+		event.Type = EventCanceled
+	default:
+		return event, ErrUnhandledEvent
+	}
+	return event, nil
+}
@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"payment_service/internal/controllers"
+	"payment_service/internal/middleware"
+	"payment_service/internal/payments"
+
+	"github.com/gorilla/mux"
+)
+
+// SetupRoutes registers the payment endpoints. The simulate endpoint,
+// which lets users pay without paying, exists only when simulate is set.
+func SetupRoutes(service *payments.Service, simulate bool) *mux.Router {
+	r := mux.NewRouter()
+
+	paymentController := &controllers.PaymentController{Payments: service}
+
+	r.HandleFunc("/payments/webhooks/{provider}", paymentController.Webhook).Methods("POST")
+
+	// Used by the services that own the subjects; not routed by the gateway.
+	internal := r.PathPrefix("/internal/payments").Subrouter()
+	internal.Use(middleware.RequireServiceToken)
+	internal.HandleFunc("/refunds", paymentController.RefundCancelled).Methods("POST")
+
+	user := r.PathPrefix("/user/payments").Subrouter()
+	user.Use(middleware.AuthMiddleware)
+	user.HandleFunc("", paymentController.CreatePayment).Methods("POST")
+	user.HandleFunc("", paymentController.ListMyPayments).Methods("GET")
+	user.HandleFunc("/{id:[0-9]+}", paymentController.GetMyPayment).Methods("GET")
+	if simulate {
+		user.HandleFunc("/{id:[0-9]+}/simulate", paymentController.Simulate).Methods("POST")
+	}
+
+	admin := r.PathPrefix("/admin/payments").Subrouter()
+	admin.Use(middleware.AdminAuthMiddleware)
+	admin.HandleFunc("", paymentController.ListSubjectPayments).Methods("GET")
+	admin.HandleFunc("/{id:[0-9]+}/refund", paymentController.RefundPayment).Methods("POST")
+
+	return r
+}
@@ -0,0 +1,206 @@
+package subjects
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound   = errors.New("subject not found")
+	ErrNotPayable = errors.New("subject can no longer be paid for")
+)
+
+// Subject is something a user pays for, read from the owning service's
+// tables in the shared database.
+type Subject struct {
+	Type         string
+	ID           uint
+	UserID       uint
+	OwnerAdminID uint
+	Amount       float64
+	Currency     string
+	Description  string
+	Payable      bool
+	// Cancelled is set once the subject was cancelled or lapsed, so money
+	// captured for it is owed back.
+	Cancelled bool
+}
+
+// Kind connects payments to one type of subject. Implementations update the
+// subject inside the payment's transaction, so a captured payment and a
+// confirmed booking are committed together.
+type Kind interface {
+	// Load reads and locks the subject.
+	Load(tx *gorm.DB, id uint) (*Subject, error)
+	// SetPaymentStatus mirrors the latest payment state onto the subject.
+	SetPaymentStatus(tx *gorm.DB, id, paymentID uint, status string) error
+	// Paid confirms the subject once its payment is captured. It returns
+	// ErrNotPayable when the subject expired or was cancelled meanwhile.
+	Paid(tx *gorm.DB, id, paymentID uint, status string) error
+	// RefundsDue lists the payments of cancelled subjects that are still
+	// captured in full; partially refunded ones are the admin's to settle.
+	RefundsDue(tx *gorm.DB) ([]uint, error)
+}
+
+var kinds = map[string]Kind{
+	"reservation": reservationKind{},
+	"ticket":      ticketKind{},
+}
+
+func Get(subjectType string) (Kind, bool) {
+	kind, ok := kinds[subjectType]
+	return kind, ok
+}
+
+// All returns every kind of subject by its subject_type.
+func All() map[string]Kind {
+	return kinds
+}
+
+// reservationKind covers accommodation reservations. A held reservation can
+// be paid until its hold expires; capture confirms it.
+type reservationKind struct{}
+
+func (reservationKind) Load(tx *gorm.DB, id uint) (*Subject, error) {
+	var row struct {
+		ID            uint
+		UserID        uint
+		TotalPrice    float64
+		Currency      string
+		Status        string
+		HoldExpiresAt *time.Time
+		AdminID       uint
+		Name          string
+		CheckIn       time.Time
+		CheckOut      time.Time
+	}
+	err := tx.Raw(`SELECT r.id, r.user_id, r.total_price, r.currency, r.status, r.hold_expires_at,
+			r.check_in, r.check_out, a.admin_id, a.name
+		FROM reservations r
+		JOIN accommodations a ON a.id = r.accommodation_id
+		WHERE r.id = ? AND r.deleted_at IS NULL
+		FOR UPDATE OF r`, id).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	if row.ID == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &Subject{
+		Type:         "reservation",
+		ID:           row.ID,
+		UserID:       row.UserID,
+		OwnerAdminID: row.AdminID,
+		Amount:       row.TotalPrice,
+		Currency:     row.Currency,
+		Description: fmt.Sprintf("%s, %s to %s", row.Name,
+			row.CheckIn.Format("2006-01-02"), row.CheckOut.Format("2006-01-02")),
+		Payable:   row.Status == "held" && row.HoldExpiresAt != nil && row.HoldExpiresAt.After(time.Now()),
+		Cancelled: row.Status == "cancelled" || row.Status == "expired",
+	}, nil
+}
+
+func (reservationKind) RefundsDue(tx *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := tx.Raw(`SELECT p.id FROM payments p
+		JOIN reservations r ON r.id = p.subject_id
+		WHERE p.subject_type = 'reservation' AND p.deleted_at IS NULL
+			AND p.status = 'captured'
+			AND r.status IN ('cancelled', 'expired')`).Scan(&ids).Error
+	return ids, err
+}
+
+func (reservationKind) SetPaymentStatus(tx *gorm.DB, id, paymentID uint, status string) error {
+	return tx.Exec(`UPDATE reservations SET payment_id = ?, payment_status = ?, updated_at = NOW() WHERE id = ?`,
+		paymentID, status, id).Error
+}
+
+func (reservationKind) Paid(tx *gorm.DB, id, paymentID uint, status string) error {
+	result := tx.Exec(`UPDATE reservations
+		SET status = 'confirmed', confirmed_at = NOW(), hold_expires_at = NULL,
+			payment_id = ?, payment_status = ?, updated_at = NOW()
+		WHERE id = ? AND status = 'held' AND hold_expires_at > NOW()`, paymentID, status, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPayable
+	}
+	return nil
+}
+
+// ticketKind covers event registrations of priced events. A held place can
+// be paid until its hold expires; capture confirms it, so the ticket passes
+// check-in.
+type ticketKind struct{}
+
+func (ticketKind) Load(tx *gorm.DB, id uint) (*Subject, error) {
+	var row struct {
+		ID            uint
+		UserID        uint
+		Status        string
+		HoldExpiresAt *time.Time
+		Price         float64
+		Currency      string
+		AdminID       uint
+		Title         string
+	}
+	err := tx.Raw(`SELECT reg.id, reg.user_id, reg.status, reg.hold_expires_at,
+			e.price, e.currency, e.admin_id, e.title
+		FROM event_registrations reg
+		JOIN events e ON e.id = reg.event_id
+		WHERE reg.id = ? AND reg.deleted_at IS NULL
+		FOR UPDATE OF reg`, id).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	if row.ID == 0 {
+		return nil, ErrNotFound
+	}
+
+	held := row.Status == "held" && row.HoldExpiresAt != nil && row.HoldExpiresAt.After(time.Now())
+	return &Subject{
+		Type:         "ticket",
+		ID:           row.ID,
+		UserID:       row.UserID,
+		OwnerAdminID: row.AdminID,
+		Amount:       row.Price,
+		Currency:     row.Currency,
+		Description:  "Ticket for " + row.Title,
+		Payable:      held && row.Price > 0,
+		Cancelled:    row.Status == "cancelled" || row.Status == "expired",
+	}, nil
+}
+
+func (ticketKind) RefundsDue(tx *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := tx.Raw(`SELECT p.id FROM payments p
+		JOIN event_registrations reg ON reg.id = p.subject_id
+		WHERE p.subject_type = 'ticket' AND p.deleted_at IS NULL
+			AND p.status = 'captured'
+			AND reg.status IN ('cancelled', 'expired')`).Scan(&ids).Error
+	return ids, err
+}
+
+func (ticketKind) SetPaymentStatus(tx *gorm.DB, id, paymentID uint, status string) error {
+	return tx.Exec(`UPDATE event_registrations SET payment_id = ?, payment_status = ?, updated_at = NOW() WHERE id = ?`,
+		paymentID, status, id).Error
+}
+
+func (ticketKind) Paid(tx *gorm.DB, id, paymentID uint, status string) error {
+	result := tx.Exec(`UPDATE event_registrations
+		SET status = 'confirmed', confirmed_at = NOW(), hold_expires_at = NULL,
+			payment_id = ?, payment_status = ?, updated_at = NOW()
+		WHERE id = ? AND status = 'held' AND hold_expires_at > NOW()`, paymentID, status, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPayable
+	}
+	return nil
+}
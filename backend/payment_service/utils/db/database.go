@@ -0,0 +1,37 @@
+package db
+
+import (
+	"log"
+	"os"
+	"payment_service/internal/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var DB *gorm.DB
+
+func ConnectDB() {
+	dsn := "host=db user=postgres password=123456 dbname=TravelApp port=5432 sslmode=disable"
+	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
+		dsn = dbURL
+	}
+
+	var err error
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	log.Println("Connected to the database")
+
+	err = DB.AutoMigrate(
+		&models.Payment{},
+		&models.WebhookEvent{},
+	)
+	if err != nil {
+		log.Fatalf("Failed to migrate database schemas: %v", err)
+	}
+
+	log.Println("Database migration completed")
+}
@@ -0,0 +1,64 @@
+// Package testdb gives tests a schema of their own in the PostgreSQL
+// database named by TEST_DATABASE_URL.
+package testdb
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open connects to a new, empty schema that is dropped when t ends. Tests
+// are skipped when TEST_DATABASE_URL is not set; `make test-db` in backend/
+// starts a PostgreSQL and runs them against it.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set; run `make test-db` in backend/ or point it at a PostgreSQL database")
+	}
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	base, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := base.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	conn, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+		base.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := base.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"plan_service/internal/handlers"
+	"plan_service/internal/middleware"
 	"plan_service/internal/models"
 	database "plan_service/utils/db"
 	"time"
@@ -24,6 +25,7 @@ func main() {
 		&models.PlanItem{},
 		&models.PlanTemplate{},
 		&models.TemplateItem{},
+		&models.TemplateRating{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database schemas: %v", err)
@@ -48,8 +50,12 @@ func main() {
 	api.HandleFunc("/plans/{id:[0-9]+}/directions", planHandler.GetPlanDirections).Methods("GET")
 	api.HandleFunc("/plans/items/{fromItemId:[0-9]+}/directions/{toItemId:[0-9]+}", planHandler.GetDirectionsBetweenItems).Methods("GET")
 
+	api.HandleFunc("/plans/{id:[0-9]+}/bundle", planHandler.GetPlanBundle).Methods("GET")
+	api.HandleFunc("/plans/{id:[0-9]+}/bundle/manifest", planHandler.GetPlanBundleManifest).Methods("GET")
+
 	api.HandleFunc("/templates", planHandler.GetTemplates).Methods("GET")
 	api.HandleFunc("/templates", planHandler.CreateTemplate).Methods("POST")
+	api.HandleFunc("/templates/mine", planHandler.GetMyTemplates).Methods("GET")
 	api.HandleFunc("/templates/{id:[0-9]+}", planHandler.GetTemplate).Methods("GET")
 	api.HandleFunc("/templates/{id:[0-9]+}", planHandler.UpdateTemplate).Methods("PUT")
 	api.HandleFunc("/templates/{id:[0-9]+}", planHandler.DeleteTemplate).Methods("DELETE")
@@ -58,6 +64,11 @@ func main() {
 	api.HandleFunc("/templates/items/{itemId:[0-9]+}", planHandler.UpdateTemplateItem).Methods("PUT")
 	api.HandleFunc("/templates/items/{itemId:[0-9]+}", planHandler.DeleteTemplateItem).Methods("DELETE")
 	api.HandleFunc("/templates/create-plan", planHandler.CreatePlanFromTemplate).Methods("POST")
+	api.HandleFunc("/templates/{id:[0-9]+}/publish", planHandler.PublishTemplate).Methods("POST")
+	api.HandleFunc("/templates/{id:[0-9]+}/unpublish", planHandler.UnpublishTemplate).Methods("POST")
+	api.HandleFunc("/templates/{id:[0-9]+}/rate", planHandler.RateTemplate).Methods("POST")
+	api.Handle("/templates/{id:[0-9]+}/curate", middleware.AdminAuthMiddleware(http.HandlerFunc(planHandler.CurateTemplate))).Methods("POST")
+	api.Handle("/templates/{id:[0-9]+}/curate", middleware.AdminAuthMiddleware(http.HandlerFunc(planHandler.UncurateTemplate))).Methods("DELETE")
 
 	srv := &http.Server{
 		Addr:         ":8087",
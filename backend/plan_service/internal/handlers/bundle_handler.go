@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"plan_service/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *PlanHandler) buildBundle(w http.ResponseWriter, r *http.Request) (*utils.PlanBundle, bool) {
+	vars := mux.Vars(r)
+	planID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		errorResponse(w, "Invalid plan ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		errorResponse(w, "User not authenticated", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	plan, err := h.service.GetPlan(uint(planID), userID)
+	if err != nil {
+		errorResponse(w, "Plan not found or access denied", http.StatusNotFound)
+		return nil, false
+	}
+
+	items, err := h.service.GetPlanItems(uint(planID))
+	if err != nil {
+		errorResponse(w, "Failed to retrieve plan items", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "driving"
+	}
+
+	validModes := map[string]bool{
+		"driving":   true,
+		"walking":   true,
+		"bicycling": true,
+		"transit":   true,
+	}
+	if !validModes[mode] {
+		errorResponse(w, "Invalid transportation mode. Use: driving, walking, bicycling, or transit", http.StatusBadRequest)
+		return nil, false
+	}
+
+	bundle, err := utils.BuildPlanBundle(*plan, items, mode)
+	if err != nil {
+		errorResponse(w, "Failed to build bundle: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return bundle, true
+}
+
+// GetPlanBundle streams the offline archive for a plan. Clients holding an
+// older bundle can pass the file hashes they already have in "have" to get
+// only the changed files, or send If-None-Match to skip an unchanged bundle.
+func (h *PlanHandler) GetPlanBundle(w http.ResponseWriter, r *http.Request) {
+	bundle, ok := h.buildBundle(w, r)
+	if !ok {
+		return
+	}
+
+	etag := fmt.Sprintf("\"%s\"", bundle.Manifest.Hash)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Bundle-Hash", bundle.Manifest.Hash)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	known := make(map[string]bool)
+	if have := r.URL.Query().Get("have"); have != "" {
+		for _, hash := range strings.Split(have, ",") {
+			if hash = strings.TrimSpace(hash); hash != "" {
+				known[hash] = true
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"plan-%d-%s.zip\"", bundle.Manifest.PlanID, bundle.Manifest.Hash[:12]))
+	w.WriteHeader(http.StatusOK)
+
+	if err := bundle.WriteZip(w, known); err != nil {
+		log.Printf("Error writing bundle for plan %d: %v", bundle.Manifest.PlanID, err)
+	}
+}
+
+func (h *PlanHandler) GetPlanBundleManifest(w http.ResponseWriter, r *http.Request) {
+	bundle, ok := h.buildBundle(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", bundle.Manifest.Hash))
+	responseWriter(w, bundle.Manifest, http.StatusOK)
+}
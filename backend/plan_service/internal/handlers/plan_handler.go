@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"plan_service/internal/middleware"
 	"plan_service/internal/models"
 	"plan_service/internal/services"
 
@@ -162,7 +164,17 @@ func (h *PlanHandler) GetUserPlans(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	plans, err := h.service.GetUserPlans(userID)
+	var cityID uint64
+	if cityIDParam := r.URL.Query().Get("city_id"); cityIDParam != "" {
+		var err error
+		cityID, err = strconv.ParseUint(cityIDParam, 10, 32)
+		if err != nil {
+			errorResponse(w, "Invalid city_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	plans, err := h.service.GetUserPlans(userID, uint(cityID))
 	if err != nil {
 		errorResponse(w, "Failed to retrieve plans: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -287,10 +299,101 @@ func (h *PlanHandler) OptimizeRoute(w http.ResponseWriter, r *http.Request) {
 	responseWriter(w, items, http.StatusOK)
 }
 
+// canManageTemplate reports whether the user owns the template or is an admin.
+func canManageTemplate(r *http.Request, template *models.PlanTemplate, userID uint) bool {
+	if userID != 0 && template.OwnerID == userID {
+		return true
+	}
+	_, err := middleware.ValidateAdmin(r)
+	return err == nil
+}
+
+func canViewTemplate(r *http.Request, template *models.PlanTemplate, userID uint) bool {
+	if template.IsPublic && template.Status == models.TemplateStatusPublished {
+		return true
+	}
+	return canManageTemplate(r, template, userID)
+}
+
 func (h *PlanHandler) GetTemplates(w http.ResponseWriter, r *http.Request) {
-	category := r.URL.Query().Get("category")
+	query := r.URL.Query()
+
+	filter := services.TemplateFilter{
+		Category: query.Get("category"),
+		Country:  query.Get("country"),
+		City:     query.Get("city"),
+		Sort:     query.Get("sort"),
+		Page:     1,
+		PageSize: 20,
+	}
+
+	if cityIDParam := query.Get("city_id"); cityIDParam != "" {
+		cityID, err := strconv.ParseUint(cityIDParam, 10, 32)
+		if err != nil {
+			errorResponse(w, "Invalid city_id", http.StatusBadRequest)
+			return
+		}
+		filter.CityID = uint(cityID)
+	}
+	if duration := query.Get("duration"); duration != "" {
+		d, err := strconv.Atoi(duration)
+		if err != nil || d <= 0 {
+			errorResponse(w, "Invalid duration", http.StatusBadRequest)
+			return
+		}
+		filter.MinDuration = d
+		filter.MaxDuration = d
+	}
+	if minDuration := query.Get("min_duration"); minDuration != "" {
+		d, err := strconv.Atoi(minDuration)
+		if err != nil || d <= 0 {
+			errorResponse(w, "Invalid min_duration", http.StatusBadRequest)
+			return
+		}
+		filter.MinDuration = d
+	}
+	if maxDuration := query.Get("max_duration"); maxDuration != "" {
+		d, err := strconv.Atoi(maxDuration)
+		if err != nil || d <= 0 {
+			errorResponse(w, "Invalid max_duration", http.StatusBadRequest)
+			return
+		}
+		filter.MaxDuration = d
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	if pageSize, err := strconv.Atoi(query.Get("page_size")); err == nil && pageSize > 0 && pageSize <= 100 {
+		filter.PageSize = pageSize
+	}
+
+	templates, total, err := h.service.GetTemplates(filter)
+	if err != nil {
+		errorResponse(w, "Failed to retrieve templates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"templates": templates,
+		"pagination": map[string]interface{}{
+			"page":        filter.Page,
+			"page_size":   filter.PageSize,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(filter.PageSize))),
+		},
+	}
+
+	responseWriter(w, response, http.StatusOK)
+}
+
+func (h *PlanHandler) GetMyTemplates(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserID(r)
+	if userID == 0 {
+		errorResponse(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
 
-	templates, err := h.service.GetTemplates(category)
+	templates, err := h.service.GetUserTemplates(userID)
 	if err != nil {
 		errorResponse(w, "Failed to retrieve templates: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -314,6 +417,7 @@ func (h *PlanHandler) CreatePlanFromTemplate(w http.ResponseWriter, r *http.Requ
 	var request struct {
 		TemplateID uint   `json:"template_id"`
 		StartDate  string `json:"start_date"`
+		IsPublic   bool   `json:"is_public"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -329,7 +433,7 @@ func (h *PlanHandler) CreatePlanFromTemplate(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	plan, err := h.service.CreatePlanFromTemplate(request.TemplateID, userID, startDate)
+	plan, err := h.service.CreatePlanFromTemplate(request.TemplateID, userID, startDate, request.IsPublic)
 	if err != nil {
 		log.Printf("Error creating plan: %v", err)
 		errorResponse(w, "Failed to create plan from template: "+err.Error(), http.StatusInternalServerError)
@@ -351,6 +455,9 @@ func (h *PlanHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	template.ID = 0
+	template.OwnerID = userID
+
 	if err := h.service.CreateTemplate(&template); err != nil {
 		errorResponse(w, "Failed to create template: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -368,7 +475,7 @@ func (h *PlanHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	template, err := h.service.GetTemplate(uint(templateID))
-	if err != nil {
+	if err != nil || !canViewTemplate(r, template, GetUserID(r)) {
 		errorResponse(w, "Template not found", http.StatusNotFound)
 		return
 	}
@@ -390,20 +497,37 @@ func (h *PlanHandler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	template, err := h.service.GetTemplate(uint(templateID))
+	if err != nil {
+		errorResponse(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if !canManageTemplate(r, template, userID) {
+		errorResponse(w, "Not authorized to modify this template", http.StatusForbidden)
+		return
+	}
+
 	var updates models.PlanTemplate
 	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
 		errorResponse(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	updates.ID = uint(templateID)
+	template.Title = updates.Title
+	template.Description = updates.Description
+	template.City = updates.City
+	template.Country = updates.Country
+	template.Duration = updates.Duration
+	template.Category = updates.Category
+	template.IsPublic = updates.IsPublic
 
-	if err := h.service.UpdateTemplate(&updates); err != nil {
+	if err := h.service.UpdateTemplate(template); err != nil {
 		errorResponse(w, "Failed to update template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	responseWriter(w, updates, http.StatusOK)
+	responseWriter(w, template, http.StatusOK)
 }
 
 func (h *PlanHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
@@ -420,6 +544,17 @@ func (h *PlanHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	template, err := h.service.GetTemplate(uint(templateID))
+	if err != nil {
+		errorResponse(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if !canManageTemplate(r, template, userID) {
+		errorResponse(w, "Not authorized to delete this template", http.StatusForbidden)
+		return
+	}
+
 	if err := h.service.DeleteTemplate(uint(templateID)); err != nil {
 		errorResponse(w, "Failed to delete template: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -436,6 +571,12 @@ func (h *PlanHandler) GetTemplateItems(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	template, err := h.service.GetTemplate(uint(templateID))
+	if err != nil || !canViewTemplate(r, template, GetUserID(r)) {
+		errorResponse(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
 	items, err := h.service.GetTemplateItems(uint(templateID))
 	if err != nil {
 		errorResponse(w, "Failed to retrieve template items: "+err.Error(), http.StatusInternalServerError)
@@ -459,12 +600,24 @@ func (h *PlanHandler) AddItemToTemplate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	template, err := h.service.GetTemplate(uint(templateID))
+	if err != nil {
+		errorResponse(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if !canManageTemplate(r, template, userID) {
+		errorResponse(w, "Not authorized to modify this template", http.StatusForbidden)
+		return
+	}
+
 	var templateItem models.TemplateItem
 	if err := json.NewDecoder(r.Body).Decode(&templateItem); err != nil {
 		errorResponse(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	templateItem.ID = 0
 	templateItem.TemplateID = uint(templateID)
 
 	if err := h.service.AddItemToTemplate(&templateItem); err != nil {
@@ -489,13 +642,27 @@ func (h *PlanHandler) UpdateTemplateItem(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	existingItem, err := h.service.GetTemplateItem(uint(itemID))
+	if err != nil {
+		errorResponse(w, "Template item not found", http.StatusNotFound)
+		return
+	}
+
+	template, err := h.service.GetTemplate(existingItem.TemplateID)
+	if err != nil || !canManageTemplate(r, template, userID) {
+		errorResponse(w, "Not authorized to modify this template", http.StatusForbidden)
+		return
+	}
+
 	var updates models.TemplateItem
 	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
 		errorResponse(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	updates.ID = uint(itemID)
+	updates.ID = existingItem.ID
+	updates.CreatedAt = existingItem.CreatedAt
+	updates.TemplateID = existingItem.TemplateID
 
 	if err := h.service.UpdateTemplateItem(&updates); err != nil {
 		errorResponse(w, "Failed to update template item: "+err.Error(), http.StatusInternalServerError)
@@ -519,6 +686,18 @@ func (h *PlanHandler) DeleteTemplateItem(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	existingItem, err := h.service.GetTemplateItem(uint(itemID))
+	if err != nil {
+		errorResponse(w, "Template item not found", http.StatusNotFound)
+		return
+	}
+
+	template, err := h.service.GetTemplate(existingItem.TemplateID)
+	if err != nil || !canManageTemplate(r, template, userID) {
+		errorResponse(w, "Not authorized to modify this template", http.StatusForbidden)
+		return
+	}
+
 	if err := h.service.DeleteTemplateItem(uint(itemID)); err != nil {
 		errorResponse(w, "Failed to delete template item: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -526,3 +705,132 @@ func (h *PlanHandler) DeleteTemplateItem(w http.ResponseWriter, r *http.Request)
 
 	responseWriter(w, map[string]string{"message": "Template item deleted successfully"}, http.StatusOK)
 }
+
+func (h *PlanHandler) setTemplateStatus(w http.ResponseWriter, r *http.Request, status string) {
+	vars := mux.Vars(r)
+	templateID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		errorResponse(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		errorResponse(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	template, err := h.service.GetTemplate(uint(templateID))
+	if err != nil {
+		errorResponse(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if !canManageTemplate(r, template, userID) {
+		errorResponse(w, "Not authorized to modify this template", http.StatusForbidden)
+		return
+	}
+
+	if status == models.TemplateStatusPublished {
+		items, err := h.service.GetTemplateItems(template.ID)
+		if err != nil {
+			errorResponse(w, "Failed to retrieve template items", http.StatusInternalServerError)
+			return
+		}
+		if len(items) == 0 {
+			errorResponse(w, "Template must have at least one item to be published", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.service.SetTemplateStatus(template, status); err != nil {
+		errorResponse(w, "Failed to update template status: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter(w, template, http.StatusOK)
+}
+
+func (h *PlanHandler) PublishTemplate(w http.ResponseWriter, r *http.Request) {
+	h.setTemplateStatus(w, r, models.TemplateStatusPublished)
+}
+
+func (h *PlanHandler) UnpublishTemplate(w http.ResponseWriter, r *http.Request) {
+	h.setTemplateStatus(w, r, models.TemplateStatusDraft)
+}
+
+func (h *PlanHandler) setTemplateCurated(w http.ResponseWriter, r *http.Request, curated bool) {
+	vars := mux.Vars(r)
+	templateID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		errorResponse(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	template, err := h.service.GetTemplate(uint(templateID))
+	if err != nil {
+		errorResponse(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.service.SetTemplateCurated(template, curated); err != nil {
+		errorResponse(w, "Failed to update template curation: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	responseWriter(w, template, http.StatusOK)
+}
+
+func (h *PlanHandler) CurateTemplate(w http.ResponseWriter, r *http.Request) {
+	h.setTemplateCurated(w, r, true)
+}
+
+func (h *PlanHandler) UncurateTemplate(w http.ResponseWriter, r *http.Request) {
+	h.setTemplateCurated(w, r, false)
+}
+
+func (h *PlanHandler) RateTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	templateID, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		errorResponse(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	userID := GetUserID(r)
+	if userID == 0 {
+		errorResponse(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Rating int `json:"rating"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		errorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if request.Rating < 1 || request.Rating > 5 {
+		errorResponse(w, "Invalid rating - must be between 1 and 5", http.StatusBadRequest)
+		return
+	}
+
+	template, err := h.service.GetTemplate(uint(templateID))
+	if err != nil || template.Status != models.TemplateStatusPublished || !template.IsPublic {
+		errorResponse(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	if template.OwnerID == userID {
+		errorResponse(w, "You cannot rate your own template", http.StatusBadRequest)
+		return
+	}
+
+	template, err = h.service.RateTemplate(template.ID, userID, request.Rating)
+	if err != nil {
+		errorResponse(w, "Failed to rate template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter(w, template, http.StatusOK)
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+func getAuthServiceURL() string {
+	if url := os.Getenv("AUTH_SERVICE_URL"); url != "" {
+		return url
+	}
+	return "http://auth-service:8082"
+}
+
+// ValidateAdmin asks auth-service whether the session cookie on the request
+// belongs to an admin and returns the admin ID if it does.
+func ValidateAdmin(r *http.Request) (uint, error) {
+	req, err := http.NewRequest("GET", getAuthServiceURL()+"/validate-admin", nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Cookie", r.Header.Get("Cookie"))
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("admin validation returned status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var adminResp struct {
+		AdminID uint `json:"admin_id"`
+	}
+	if err := json.Unmarshal(body, &adminResp); err != nil {
+		return 0, err
+	}
+	if adminResp.AdminID == 0 {
+		return 0, fmt.Errorf("admin validation response did not contain admin_id")
+	}
+
+	return adminResp.AdminID, nil
+}
+
+func AdminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminID, err := ValidateAdmin(r)
+		if err != nil {
+			log.Printf("Admin validation failed for %s: %v", r.URL.Path, err)
+			http.Error(w, "Unauthorized - Admin access denied", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "admin_id", adminID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -14,6 +14,7 @@ type Plan struct {
 	UserID      uint      `json:"user_id"`
 	IsPublic    bool      `json:"is_public" gorm:"default:false"`
 	City        string    `json:"city"`
+	CityID      *uint     `json:"city_id" gorm:"index"`
 }
 
 type PlanItem struct {
@@ -35,15 +36,35 @@ type PlanItem struct {
 	AccommodationType string    `json:"accommodation_type,omitempty"` // For accommodations
 }
 
+const (
+	TemplateStatusDraft     = "draft"
+	TemplateStatusPublished = "published"
+)
+
 type PlanTemplate struct {
 	gorm.Model
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	City        string `json:"city"`
-	Country     string `json:"country"`
-	Duration    int    `json:"duration"`
-	Category    string `json:"category"`
-	IsPublic    bool   `json:"is_public" gorm:"default:true"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	City          string     `json:"city" gorm:"index"`
+	CityID        *uint      `json:"city_id" gorm:"index"`
+	Country       string     `json:"country" gorm:"index"`
+	Duration      int        `json:"duration"`
+	Category      string     `json:"category" gorm:"index"`
+	IsPublic      bool       `json:"is_public" gorm:"default:true"`
+	OwnerID       uint       `json:"owner_id" gorm:"index"`
+	Status        string     `json:"status" gorm:"index;default:published"` // Existing rows stay visible; new templates start as drafts
+	PublishedAt   *time.Time `json:"published_at,omitempty"`
+	IsCurated     bool       `json:"is_curated" gorm:"default:false"`
+	UsageCount    int        `json:"usage_count" gorm:"default:0"`
+	RatingCount   int        `json:"rating_count" gorm:"default:0"`
+	AverageRating float64    `json:"average_rating" gorm:"default:0"`
+}
+
+type TemplateRating struct {
+	gorm.Model
+	TemplateID uint `json:"template_id" gorm:"uniqueIndex:idx_template_rating_user;not null"`
+	UserID     uint `json:"user_id" gorm:"uniqueIndex:idx_template_rating_user;not null"`
+	Rating     int  `json:"rating" gorm:"not null;check:rating >= 1 AND rating <= 5"`
 }
 
 type TemplateItem struct {
@@ -3,16 +3,36 @@ package services
 import (
 	"errors"
 	"log"
+	"math"
 	"plan_service/internal/models"
 	"plan_service/utils"
 	database "plan_service/utils/db"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type PlanService struct {
 }
 
+// resolveCity links a free-text city to its canonical ID. Unknown cities
+// keep their text and no ID; location-service backfills them later.
+func resolveCity(city string) (*uint, string) {
+	if city == "" {
+		return nil, city
+	}
+	cityID, cityName, err := utils.ResolveCity(city)
+	if err != nil {
+		log.Printf("Error resolving city %q: %v", city, err)
+	}
+	if cityID == nil {
+		return nil, city
+	}
+	return cityID, cityName
+}
+
 func (s *PlanService) CreatePlan(plan *models.Plan) error {
+	plan.CityID, plan.City = resolveCity(plan.City)
 	return database.DB.Create(plan).Error
 }
 
@@ -25,6 +45,7 @@ func (s *PlanService) GetPlan(planID uint, userID uint) (*models.Plan, error) {
 	return &plan, nil
 }
 func (s *PlanService) UpdatePlan(plan *models.Plan) error {
+	plan.CityID, plan.City = resolveCity(plan.City)
 	return database.DB.Save(plan).Error
 }
 
@@ -36,9 +57,13 @@ func (s *PlanService) DeletePlan(planID uint, userID uint) error {
 	return result.Error
 }
 
-func (s *PlanService) GetUserPlans(userID uint) ([]models.Plan, error) {
+func (s *PlanService) GetUserPlans(userID uint, cityID uint) ([]models.Plan, error) {
 	var plans []models.Plan
-	result := database.DB.Where("user_id = ?", userID).Find(&plans)
+	query := database.DB.Where("user_id = ?", userID)
+	if cityID != 0 {
+		query = query.Where("city_id = ?", cityID)
+	}
+	result := query.Find(&plans)
 	return plans, result.Error
 }
 
@@ -137,24 +162,77 @@ func (s *PlanService) OptimizeRoute(planID uint, userID uint) error {
 	return tx.Commit().Error
 }
 
-func (s *PlanService) GetTemplates(category string) ([]models.PlanTemplate, error) {
+type TemplateFilter struct {
+	Category    string
+	Country     string
+	City        string
+	CityID      uint
+	MinDuration int
+	MaxDuration int
+	Sort        string
+	Page        int
+	PageSize    int
+}
+
+var templateSortOrders = map[string]string{
+	"popular": "is_curated DESC, usage_count DESC, average_rating DESC",
+	"rating":  "average_rating DESC, rating_count DESC",
+	"newest":  "published_at DESC NULLS LAST, created_at DESC",
+}
+
+func (s *PlanService) GetTemplates(filter TemplateFilter) ([]models.PlanTemplate, int64, error) {
 	var templates []models.PlanTemplate
-	query := database.DB.Where("is_public = ?", true)
+	query := database.DB.Model(&models.PlanTemplate{}).
+		Where("is_public = ? AND status = ?", true, models.TemplateStatusPublished)
 
-	if category != "" {
-		query = query.Where("category = ?", category)
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Country != "" {
+		query = query.Where("LOWER(country) = LOWER(?)", filter.Country)
+	}
+	if filter.CityID != 0 {
+		query = query.Where("city_id = ?", filter.CityID)
+	} else if filter.City != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", filter.City)
+	}
+	if filter.MinDuration > 0 {
+		query = query.Where("duration >= ?", filter.MinDuration)
+	}
+	if filter.MaxDuration > 0 {
+		query = query.Where("duration <= ?", filter.MaxDuration)
 	}
 
-	result := query.Find(&templates)
-	return templates, result.Error
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order, ok := templateSortOrders[filter.Sort]
+	if !ok {
+		order = templateSortOrders["popular"]
+	}
+
+	result := query.Order(order).
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&templates)
+	return templates, total, result.Error
 }
 
-func (s *PlanService) CreatePlanFromTemplate(templateID uint, userID uint, startDate time.Time) (*models.Plan, error) {
+func (s *PlanService) CreatePlanFromTemplate(templateID uint, userID uint, startDate time.Time, isPublic bool) (*models.Plan, error) {
 	var template models.PlanTemplate
 	if err := database.DB.First(&template, templateID).Error; err != nil {
 		return nil, err
 	}
 
+	// Like viewing it, using a template needs it published and public
+	// unless the user owns it.
+	published := template.Status == models.TemplateStatusPublished && template.IsPublic
+	if !published && template.OwnerID != userID {
+		return nil, errors.New("template is not published")
+	}
+
 	plan := models.Plan{
 		Title:       template.Title,
 		Description: template.Description,
@@ -162,14 +240,19 @@ func (s *PlanService) CreatePlanFromTemplate(templateID uint, userID uint, start
 		EndDate:     startDate.AddDate(0, 0, template.Duration),
 		UserID:      userID,
 		City:        template.City,
+		CityID:      template.CityID,
+		IsPublic:    isPublic,
 	}
 
-	if err := database.DB.Create(&plan).Error; err != nil {
+	tx := database.DB.Begin()
+	if err := tx.Create(&plan).Error; err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
 	var templateItems []models.TemplateItem
-	if err := database.DB.Where("template_id = ?", templateID).Order("day_number, order_in_day").Find(&templateItems).Error; err != nil {
+	if err := tx.Where("template_id = ?", templateID).Order("day_number, order_in_day").Find(&templateItems).Error; err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
@@ -186,10 +269,159 @@ func (s *PlanService) CreatePlanFromTemplate(templateID uint, userID uint, start
 			OrderIndex:   tItem.OrderInDay,
 		}
 
-		if err := database.DB.Create(&planItem).Error; err != nil {
+		if err := tx.Create(&planItem).Error; err != nil {
+			tx.Rollback()
 			return nil, err
 		}
 	}
 
+	if err := tx.Model(&models.PlanTemplate{}).Where("id = ?", templateID).
+		UpdateColumn("usage_count", gorm.Expr("usage_count + 1")).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
 	return &plan, nil
 }
+
+func (s *PlanService) GetUserTemplates(ownerID uint) ([]models.PlanTemplate, error) {
+	var templates []models.PlanTemplate
+	result := database.DB.Where("owner_id = ?", ownerID).Order("updated_at DESC").Find(&templates)
+	return templates, result.Error
+}
+
+func (s *PlanService) CreateTemplate(template *models.PlanTemplate) error {
+	template.Status = models.TemplateStatusDraft
+	template.PublishedAt = nil
+	template.IsCurated = false
+	template.UsageCount = 0
+	template.RatingCount = 0
+	template.AverageRating = 0
+	template.CityID, template.City = resolveCity(template.City)
+	return database.DB.Create(template).Error
+}
+
+func (s *PlanService) GetTemplate(templateID uint) (*models.PlanTemplate, error) {
+	var template models.PlanTemplate
+	if err := database.DB.First(&template, templateID).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (s *PlanService) UpdateTemplate(template *models.PlanTemplate) error {
+	template.CityID, template.City = resolveCity(template.City)
+	return database.DB.Model(template).Select("title", "description", "city", "city_id", "country", "duration", "category", "is_public").
+		Updates(template).Error
+}
+
+func (s *PlanService) DeleteTemplate(templateID uint) error {
+	tx := database.DB.Begin()
+	if err := tx.Where("template_id = ?", templateID).Delete(&models.TemplateItem{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("template_id = ?", templateID).Delete(&models.TemplateRating{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Delete(&models.PlanTemplate{}, templateID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (s *PlanService) SetTemplateStatus(template *models.PlanTemplate, status string) error {
+	updates := map[string]interface{}{"status": status}
+	if status == models.TemplateStatusPublished && template.PublishedAt == nil {
+		now := time.Now()
+		updates["published_at"] = &now
+	}
+	if status == models.TemplateStatusDraft {
+		updates["is_curated"] = false
+	}
+	return database.DB.Model(template).Updates(updates).Error
+}
+
+func (s *PlanService) SetTemplateCurated(template *models.PlanTemplate, curated bool) error {
+	if curated && template.Status != models.TemplateStatusPublished {
+		return errors.New("only published templates can be curated")
+	}
+	return database.DB.Model(template).Update("is_curated", curated).Error
+}
+
+// RateTemplate stores one rating per user and refreshes the template's
+// aggregate in the same transaction.
+func (s *PlanService) RateTemplate(templateID uint, userID uint, rating int) (*models.PlanTemplate, error) {
+	tx := database.DB.Begin()
+
+	var existing models.TemplateRating
+	err := tx.Where("template_id = ? AND user_id = ?", templateID, userID).First(&existing).Error
+	if err == nil {
+		existing.Rating = rating
+		err = tx.Save(&existing).Error
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = tx.Create(&models.TemplateRating{TemplateID: templateID, UserID: userID, Rating: rating}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var stats struct {
+		Count   int
+		Average float64
+	}
+	if err := tx.Model(&models.TemplateRating{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(rating), 0) AS average").
+		Where("template_id = ?", templateID).
+		Scan(&stats).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Model(&models.PlanTemplate{}).Where("id = ?", templateID).Updates(map[string]interface{}{
+		"rating_count":   stats.Count,
+		"average_rating": math.Round(stats.Average*10) / 10,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return s.GetTemplate(templateID)
+}
+
+func (s *PlanService) GetTemplateItems(templateID uint) ([]models.TemplateItem, error) {
+	var items []models.TemplateItem
+	result := database.DB.Where("template_id = ?", templateID).Order("day_number, order_in_day").Find(&items)
+	return items, result.Error
+}
+
+func (s *PlanService) GetTemplateItem(itemID uint) (*models.TemplateItem, error) {
+	var item models.TemplateItem
+	if err := database.DB.First(&item, itemID).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *PlanService) AddItemToTemplate(item *models.TemplateItem) error {
+	return database.DB.Create(item).Error
+}
+
+func (s *PlanService) UpdateTemplateItem(item *models.TemplateItem) error {
+	return database.DB.Save(item).Error
+}
+
+func (s *PlanService) DeleteTemplateItem(itemID uint) error {
+	return database.DB.Delete(&models.TemplateItem{}, itemID).Error
+}
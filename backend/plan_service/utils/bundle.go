@@ -0,0 +1,367 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"plan_service/internal/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BundleManifestPath = "manifest.json"
+	DefaultUploadsDir  = "/app/uploads"
+)
+
+// bundleModTime is fixed so that identical content always produces identical archives.
+var bundleModTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type BundleFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+type BundleManifest struct {
+	PlanID   uint         `json:"plan_id"`
+	Hash     string       `json:"hash"`
+	Mode     string       `json:"mode"`
+	Files    []BundleFile `json:"files"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+type PlanBundle struct {
+	Manifest BundleManifest
+	files    map[string][]byte
+}
+
+type bundleItem struct {
+	models.PlanItem
+	DetailsPath string
+	ImagePaths  []string
+}
+
+func getUploadsDir() string {
+	if dir := os.Getenv("UPLOADS_DIR"); dir != "" {
+		return dir
+	}
+	return DefaultUploadsDir
+}
+
+// BuildPlanBundle collects everything a client needs to browse a plan offline:
+// the plan itself, item details from the source services, their images,
+// directions and a printable HTML summary.
+func BuildPlanBundle(plan models.Plan, items []models.PlanItem, mode string) (*PlanBundle, error) {
+	bundle := &PlanBundle{
+		Manifest: BundleManifest{PlanID: plan.ID, Mode: mode},
+		files:    make(map[string][]byte),
+	}
+
+	planJSON, err := json.MarshalIndent(struct {
+		models.Plan
+		Items []models.PlanItem `json:"items"`
+	}{Plan: plan, Items: items}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	bundle.addFile("plan.json", planJSON)
+
+	summaryItems := make([]bundleItem, 0, len(items))
+	for _, item := range items {
+		entry := bundleItem{PlanItem: item}
+		imageURLs := []string{}
+		if item.ImageURL != "" {
+			imageURLs = append(imageURLs, item.ImageURL)
+		}
+
+		details, err := GetItemDetailsRaw(item.ItemType, item.ItemID)
+		if err != nil {
+			bundle.warn("details for %s %d unavailable: %v", item.ItemType, item.ItemID, err)
+		} else {
+			entry.DetailsPath = fmt.Sprintf("items/%s-%d.json", item.ItemType, item.ItemID)
+			bundle.addFile(entry.DetailsPath, details)
+			imageURLs = append(imageURLs, extractImageURLs(details)...)
+		}
+
+		seen := make(map[string]bool)
+		for _, imageURL := range imageURLs {
+			if seen[imageURL] {
+				continue
+			}
+			seen[imageURL] = true
+
+			imagePath, err := bundle.addImage(imageURL)
+			if err != nil {
+				bundle.warn("image %s skipped: %v", imageURL, err)
+				continue
+			}
+			entry.ImagePaths = append(entry.ImagePaths, imagePath)
+		}
+
+		summaryItems = append(summaryItems, entry)
+	}
+
+	var directions *DirectionsResult
+	if countItemsWithLocation(items) >= 2 {
+		directions, err = GetDirectionsForPlanItems(items, mode)
+		if err != nil {
+			bundle.warn("directions unavailable: %v", err)
+			directions = nil
+		} else if directions.Status != "OK" {
+			bundle.warn("directions API returned status %s", directions.Status)
+		}
+	}
+	if directions != nil {
+		directionsJSON, err := json.MarshalIndent(directions, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		bundle.addFile("directions.json", directionsJSON)
+	}
+
+	summary, err := renderBundleSummary(plan, summaryItems, directions)
+	if err != nil {
+		return nil, err
+	}
+	bundle.addFile("index.html", summary)
+
+	bundle.finalize()
+	return bundle, nil
+}
+
+func (b *PlanBundle) addFile(name string, data []byte) {
+	b.files[name] = data
+}
+
+func (b *PlanBundle) warn(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Printf("Bundle for plan %d: %s", b.Manifest.PlanID, message)
+	b.Manifest.Warnings = append(b.Manifest.Warnings, message)
+}
+
+func (b *PlanBundle) addImage(imageURL string) (string, error) {
+	if value, ok := strings.CutPrefix(imageURL, "/media/"); ok {
+		return b.addMedia(value)
+	}
+	if !strings.HasPrefix(imageURL, "/uploads/") {
+		return "", fmt.Errorf("not a local upload")
+	}
+
+	relPath := path.Clean(strings.TrimPrefix(imageURL, "/uploads/"))
+	if relPath == "." || strings.HasPrefix(relPath, "..") {
+		return "", fmt.Errorf("invalid upload path")
+	}
+
+	archivePath := "images/" + relPath
+	if _, exists := b.files[archivePath]; exists {
+		return archivePath, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(getUploadsDir(), filepath.FromSlash(relPath)))
+	if err != nil {
+		return "", err
+	}
+
+	b.addFile(archivePath, data)
+	return archivePath, nil
+}
+
+// addMedia adds an image stored in media-service, referenced as
+// "/media/{id}".
+func (b *PlanBundle) addMedia(value string) (string, error) {
+	mediaID, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid media URL")
+	}
+
+	archivePath := fmt.Sprintf("images/media/%d", mediaID)
+	if _, exists := b.files[archivePath]; exists {
+		return archivePath, nil
+	}
+
+	data, err := FetchMedia(mediaID)
+	if err != nil {
+		return "", err
+	}
+
+	b.addFile(archivePath, data)
+	return archivePath, nil
+}
+
+// finalize hashes every file and derives the bundle hash from the sorted
+// per-file hashes, so the bundle hash only changes when content does.
+func (b *PlanBundle) finalize() {
+	names := make([]string, 0, len(b.files))
+	for name := range b.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	overall := sha256.New()
+	b.Manifest.Files = make([]BundleFile, 0, len(names))
+	for _, name := range names {
+		sum := sha256.Sum256(b.files[name])
+		fileHash := hex.EncodeToString(sum[:])
+		b.Manifest.Files = append(b.Manifest.Files, BundleFile{
+			Path:   name,
+			SHA256: fileHash,
+			Size:   len(b.files[name]),
+		})
+		fmt.Fprintf(overall, "%s %s\n", fileHash, name)
+	}
+
+	b.Manifest.Hash = hex.EncodeToString(overall.Sum(nil))
+}
+
+// WriteZip writes the bundle as a zip archive. Files whose hash is listed in
+// known are left out so clients only download what changed; the manifest is
+// always included.
+func (b *PlanBundle) WriteZip(w io.Writer, known map[string]bool) error {
+	archive := zip.NewWriter(w)
+
+	manifestJSON, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeZipEntry(archive, BundleManifestPath, manifestJSON); err != nil {
+		return err
+	}
+
+	for _, file := range b.Manifest.Files {
+		if known[file.SHA256] {
+			continue
+		}
+		if err := writeZipEntry(archive, file.Path, b.files[file.Path]); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writeZipEntry(archive *zip.Writer, name string, data []byte) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: bundleModTime,
+	}
+	entry, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, bytes.NewReader(data))
+	return err
+}
+
+func countItemsWithLocation(items []models.PlanItem) int {
+	count := 0
+	for _, item := range items {
+		if item.Location != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// extractImageURLs picks image references out of a source service response.
+// Services expose either a single "image_url" or an "images" list of {url}.
+func extractImageURLs(details []byte) []string {
+	var payload struct {
+		ImageURL string `json:"image_url"`
+		Images   []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	}
+	if err := json.Unmarshal(details, &payload); err != nil {
+		return nil
+	}
+
+	urls := []string{}
+	if payload.ImageURL != "" {
+		urls = append(urls, payload.ImageURL)
+	}
+	for _, image := range payload.Images {
+		if image.URL != "" {
+			urls = append(urls, image.URL)
+		}
+	}
+	return urls
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+var bundleSummaryTemplate = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("Mon, 02 Jan 2006 15:04")
+	},
+	// Directions instructions arrive as HTML fragments; keep only the text.
+	"stripTags": func(s string) string {
+		return htmlTagPattern.ReplaceAllString(s, "")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Plan.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.item { border-bottom: 1px solid #ddd; padding: 1em 0; }
+.item img { max-width: 240px; margin-right: 0.5em; }
+.muted { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Plan.Title}}</h1>
+<p class="muted">{{date .Plan.StartDate}} &ndash; {{date .Plan.EndDate}}{{if .Plan.City}} &middot; {{.Plan.City}}{{end}}</p>
+<p>{{.Plan.Description}}</p>
+<h2>Itinerary</h2>
+{{range .Items}}
+<div class="item">
+<h3>{{.OrderIndex}}. {{.Title}}</h3>
+<p class="muted">{{.ItemType}}{{if not .ScheduledFor.IsZero}} &middot; {{date .ScheduledFor}}{{end}}{{if .Duration}} &middot; {{.Duration}} min{{end}}</p>
+{{if .Address}}<p>{{.Address}}</p>{{end}}
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Notes}}<p><em>{{.Notes}}</em></p>{{end}}
+{{range .ImagePaths}}<img src="{{.}}" alt="">{{end}}
+{{if .DetailsPath}}<p><a href="{{.DetailsPath}}">Details</a></p>{{end}}
+</div>
+{{end}}
+{{with .Directions}}{{range .Routes}}
+<h2>Directions</h2>
+<p>{{.StartAddress}} &rarr; {{.EndAddress}} ({{.Distance}}, {{.Duration}})</p>
+<ol>
+{{range .Steps}}<li>{{stripTags .Instruction}} <span class="muted">{{.Distance}}</span></li>
+{{end}}</ol>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+func renderBundleSummary(plan models.Plan, items []bundleItem, directions *DirectionsResult) ([]byte, error) {
+	var buf bytes.Buffer
+	err := bundleSummaryTemplate.Execute(&buf, struct {
+		Plan       models.Plan
+		Items      []bundleItem
+		Directions *DirectionsResult
+	}{Plan: plan, Items: items, Directions: directions})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"plan_service/internal/models"
+	"time"
 )
 
 func GetAttraction(attractionID uint) (*models.AttractionResponse, error) {
@@ -103,3 +106,94 @@ func GetAccommodation(accommodationID uint) (*models.AccommodationResponse, erro
 
 	return &accommodation, nil
 }
+
+var itemDetailURLs = map[string]string{
+	"attraction":    "http://attraction-service:8085/attractions/%d",
+	"event":         "http://events-service:8083/events/%d",
+	"food":          "http://food-service:8090/places/%d",
+	"accommodation": "http://accommodation-service:8089/accommodations/%d",
+}
+
+func GetItemDetailsRaw(itemType string, itemID uint) ([]byte, error) {
+	urlFormat, ok := itemDetailURLs[itemType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported item type: %s", itemType)
+	}
+
+	resp, err := http.Get(fmt.Sprintf(urlFormat, itemID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s service returned status: %d", itemType, resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func getLocationServiceURL() string {
+	if u := os.Getenv("LOCATION_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://location-service:8092"
+}
+
+// ResolveCity looks up the canonical city for a free-text name in
+// location-service. It returns nil when the name is unknown or the service
+// is unreachable.
+func ResolveCity(name string) (*uint, string, error) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(getLocationServiceURL() + "/cities/resolve?name=" + url.QueryEscape(name))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("location service returned status: %d", resp.StatusCode)
+	}
+
+	var city struct {
+		ID   uint   `json:"ID"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&city); err != nil {
+		return nil, "", err
+	}
+
+	return &city.ID, city.Name, nil
+}
+
+func getMediaServiceURL() string {
+	if u := os.Getenv("MEDIA_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://media-service:8094"
+}
+
+// FetchMedia downloads a file stored in media-service.
+func FetchMedia(mediaID uint64) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/internal/media/%d/content", getMediaServiceURL(), mediaID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("media service returned status: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
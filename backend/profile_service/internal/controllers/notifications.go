@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"profile_service/internal/db"
+	"profile_service/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxResolve caps the usernames resolved in one request.
+const maxResolve = 50
+
+// currentUserID is the user the gateway authenticated.
+func currentUserID(r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(r.Header.Get("X-User-ID"), 10, 32)
+	return uint(id), err == nil && id > 0
+}
+
+// ResolveUsernames maps usernames, matched without regard to case, to
+// their profiles for other services. Unknown usernames are left out. The
+// body is {"usernames": [...]}.
+func ResolveUsernames(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Usernames []string `json:"usernames"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(input.Usernames) > maxResolve {
+		http.Error(w, "Too many usernames", http.StatusBadRequest)
+		return
+	}
+
+	names := make([]string, 0, len(input.Usernames))
+	for _, name := range input.Usernames {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	profiles := []models.Profile{}
+	if len(names) > 0 {
+		if err := db.DB.Where("LOWER(username) IN ?", names).Find(&profiles).Error; err != nil {
+			http.Error(w, "Failed to resolve usernames", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result := make([]map[string]interface{}, 0, len(profiles))
+	for _, p := range profiles {
+		result = append(result, map[string]interface{}{"user_id": p.UserID, "username": p.Username})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// CreateNotifications stores notifications sent by other services. The
+// body is {"notifications": [...]}.
+func CreateNotifications(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Notifications []models.Notification `json:"notifications"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	notifications := make([]models.Notification, 0, len(input.Notifications))
+	for _, n := range input.Notifications {
+		if n.UserID == 0 || n.Type == "" {
+			http.Error(w, "user_id and type are required", http.StatusBadRequest)
+			return
+		}
+		notifications = append(notifications, models.Notification{
+			UserID:        n.UserID,
+			Type:          n.Type,
+			ActorID:       n.ActorID,
+			ActorUsername: n.ActorUsername,
+			Excerpt:       n.Excerpt,
+			URL:           n.URL,
+		})
+	}
+	if len(notifications) > 0 {
+		if err := db.DB.Create(&notifications).Error; err != nil {
+			log.Printf("Failed to store notifications: %v", err)
+			http.Error(w, "Failed to store notifications", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// GetNotifications lists the user's notifications, newest first, with the
+// number of unread ones. ?unread=true keeps the unread ones; page and
+// page_size (up to 100) page through them.
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, pageSize := 1, 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if size, err := strconv.Atoi(r.URL.Query().Get("page_size")); err == nil && size > 0 {
+		pageSize = min(size, 100)
+	}
+
+	query := db.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	var unread int64
+	if err := query.Session(&gorm.Session{}).Where("read_at IS NULL").Count(&unread).Error; err != nil {
+		http.Error(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	notifications := []models.Notification{}
+	err := query.Count(&total).Error
+	if err == nil {
+		err = query.Order("created_at DESC, id DESC").
+			Offset((page - 1) * pageSize).Limit(pageSize).
+			Find(&notifications).Error
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+		"unread":        unread,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(pageSize))),
+		},
+	})
+}
+
+// MarkNotificationsRead marks the user's notifications with the given IDs
+// read, or all of them when the body has none: {"ids": [...]}.
+func MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		IDs []uint `json:"ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	query := db.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(input.IDs) > 0 {
+		query = query.Where("id IN ?", input.IDs)
+	}
+	result := query.Update("read_at", time.Now())
+	if result.Error != nil {
+		http.Error(w, "Failed to mark notifications read", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"marked": result.RowsAffected})
+}
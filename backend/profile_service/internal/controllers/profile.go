@@ -9,7 +9,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"profile_service/internal/db"
 	"profile_service/internal/models"
 	"profile_service/utils"
@@ -113,23 +112,14 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	file, handler, err := r.FormFile("image")
 	if err == nil {
 		defer file.Close()
-		filename := strconv.FormatInt(int64(profile.UserID), 10) + "_" + handler.Filename
-		savePath := "uploads/users/" + filename
-
-		dst, err := createOrReplaceFile(savePath)
-		if err != nil {
-			http.Error(w, "Unable to save image", http.StatusInternalServerError)
-			return
-		}
-		defer dst.Close()
-
-		_, err = dst.ReadFrom(file)
+		imageURL, err := storeMedia(file, handler.Filename)
 		if err != nil {
-			http.Error(w, "Error saving file", http.StatusInternalServerError)
+			log.Printf("Failed to store profile image: %v", err)
+			http.Error(w, "Unable to save image", uploadErrorStatus(err))
 			return
 		}
-
-		profile.ProfileImg = "/" + savePath
+		profile.ProfileImg = imageURL
+		profile.ProfileMediaID = models.MediaIDFromURL(imageURL)
 	}
 
 	if err := db.DB.Save(&profile).Error; err != nil {
@@ -149,11 +139,6 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(profile)
 }
 
-func createOrReplaceFile(path string) (*os.File, error) {
-	_ = os.MkdirAll("uploads/users", os.ModePerm)
-	return os.Create(path)
-}
-
 func updateAuthService(userID, username, email string) error {
 	authServiceURL := "http://auth-service:8082/update-user"
 
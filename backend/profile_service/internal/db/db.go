@@ -17,7 +17,7 @@ func InitDB() {
 		log.Fatalf("Could not connect to the database: %v", err)
 	}
 
-	err = db.AutoMigrate(&models.Profile{})
+	err = db.AutoMigrate(&models.Profile{}, &models.Notification{})
 	if err != nil {
 		log.Fatalf("Failed to auto-migrate: %v", err)
 	}
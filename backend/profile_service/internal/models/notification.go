@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Kinds of notifications.
+const (
+	NotificationMention = "mention"
+)
+
+// Notification tells a user that someone did something involving them,
+// such as mentioning them in a comment. URL points at what it is about.
+type Notification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index:idx_notifications_user,priority:1" json:"user_id"`
+	Type          string     `gorm:"size:32;not null" json:"type"`
+	ActorID       uint       `json:"actor_id"`
+	ActorUsername string     `json:"actor_username"`
+	Excerpt       string     `json:"excerpt"`
+	URL           string     `json:"url"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `gorm:"index:idx_notifications_user,priority:2" json:"created_at"`
+}
@@ -3,11 +3,13 @@ package models
 import "time"
 
 type Profile struct {
-	UserID     uint      `gorm:"primaryKey" json:"user_id"`
-	Username   string    `json:"username"`
-	Email      string    `json:"email"`
-	Bio        string    `json:"bio"`
-	ProfileImg string    `json:"profile_img"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	UserID     uint   `gorm:"primaryKey" json:"user_id"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Bio        string `json:"bio"`
+	ProfileImg string `json:"profile_img"`
+	// ProfileMediaID is the media-service ID of an uploaded ProfileImg.
+	ProfileMediaID *uint     `json:"profile_media_id,omitempty" gorm:"index"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
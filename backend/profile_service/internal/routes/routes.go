@@ -2,13 +2,21 @@ package routes
 
 import (
 	"github.com/gorilla/mux"
-	"net/http"
 	"profile_service/internal/controllers"
+	"profile_service/internal/middleware"
 )
 
 func SetupRoutes(r *mux.Router) {
 	r.HandleFunc("/user/profiles", controllers.CreateProfile).Methods("POST")
 	r.HandleFunc("/user/profiles/{user_id}", controllers.GetProfile).Methods("GET")
 	r.HandleFunc("/user/profiles/{user_id}", controllers.UpdateProfile).Methods("PATCH")
-	r.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
+
+	r.HandleFunc("/user/notifications", controllers.GetNotifications).Methods("GET")
+	r.HandleFunc("/user/notifications/read", controllers.MarkNotificationsRead).Methods("POST")
+
+	// Used by other services; not routed by the gateway.
+	internal := r.PathPrefix("/internal").Subrouter()
+	internal.Use(middleware.RequireServiceToken)
+	internal.HandleFunc("/profiles/resolve", controllers.ResolveUsernames).Methods("POST")
+	internal.HandleFunc("/notifications", controllers.CreateNotifications).Methods("POST")
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"os"
 )
 
 func NotifyBlogsService(userID uint, username string) {
@@ -12,12 +13,21 @@ func NotifyBlogsService(userID uint, username string) {
 		"user_id":  userID,
 		"username": username,
 	})
-	_, err := http.Post(
+	req, err := http.NewRequest(
+		http.MethodPost,
 		"http://blogs-service:8081/internal/sync-username",
-		"application/json",
 		bytes.NewReader(body),
 	)
 	if err != nil {
 		log.Printf("blogs-service sync failed: %v", err)
+		return
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("blogs-service sync failed: %v", err)
+		return
+	}
+	resp.Body.Close()
 }
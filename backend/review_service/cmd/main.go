@@ -3,16 +3,53 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 	"review_service/internal/handlers"
+	"review_service/internal/middleware"
+	"review_service/internal/rating"
+	"review_service/internal/verification"
 	"review_service/utils"
+	"strconv"
+	"time"
 )
 
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %s", name, value, fallback)
+	}
+	return fallback
+}
+
+func floatFromEnv(name string, fallback float64) float64 {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %v", name, value, fallback)
+	}
+	return fallback
+}
+
 func main() {
 	utils.ConnectDB()
 
+	// Reviews written before the visit they report on is proven get their
+	// badge on a later run.
+	verification.Start(durationFromEnv("REVIEW_VERIFY_INTERVAL", time.Hour))
+
+	// Scores drift from the means of their item types as other items are
+	// rated, and are brought back in line on every run.
+	rating.PriorWeight = floatFromEnv("REVIEW_PRIOR_WEIGHT", rating.PriorWeight)
+	rating.Start(durationFromEnv("REVIEW_RESCORE_INTERVAL", time.Hour))
+
 	http.HandleFunc("/reviews", handlers.ReviewRouter)
+	http.HandleFunc("/reviews/stats", handlers.GetStats)
 	http.HandleFunc("/reviews/", handlers.ReviewByIDRouter)
-	http.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
+	http.Handle("/internal/reviews/",
+		middleware.RequireServiceToken(http.HandlerFunc(handlers.InternalReviewRouter)))
 
 	log.Println("Review service running on :8086")
 	log.Fatal(http.ListenAndServe(":8086", nil))
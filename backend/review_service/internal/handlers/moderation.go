@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"review_service/internal/models"
+	"strings"
+	"time"
+)
+
+func getModerationServiceURL() string {
+	if u := os.Getenv("MODERATION_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://moderation-service:8095"
+}
+
+var moderationClient = &http.Client{Timeout: 5 * time.Second}
+
+func postModeration(path string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, getModerationServiceURL()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Token", os.Getenv("INTERNAL_TOKEN"))
+	return moderationClient.Do(req)
+}
+
+// screenReview has moderation-service check a review that was just posted
+// or edited, and holds it when a check flags it. Reviews stay up when
+// moderation-service is unreachable.
+func screenReview(review *models.Review, edit bool) {
+	resp, err := postModeration("/internal/moderation/screen", map[string]interface{}{
+		"content_type": "review",
+		"content_id":   review.ID,
+		"author_id":    review.UserID,
+		"text":         review.Comment,
+		"edit":         edit,
+	})
+	if err != nil {
+		log.Printf("Error screening review %d: %v", review.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Verdict string   `json:"verdict"`
+		Flags   []string `json:"flags"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Screening review %d returned %d", review.ID, resp.StatusCode)
+		return
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Error decoding screening response: %v", err)
+		return
+	}
+
+	if result.Verdict == "hold" && review.ModerationStatus == models.StatusVisible {
+		if err := reviewService.Moderate(review, models.StatusHeld); err != nil {
+			log.Printf("Failed to hold review %d: %v", review.ID, err)
+			return
+		}
+		log.Printf("Review %d held for moderation: %s", review.ID, strings.Join(result.Flags, "; "))
+	}
+}
+
+// ReportReview forwards a user's report of a review to moderation-service.
+// The body is {"reason": "...", "details": "..."}.
+func ReportReview(w http.ResponseWriter, r *http.Request, id uint) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	review, err := reviewService.Get(id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if review.ModerationStatus != models.StatusVisible {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+
+	var input struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := postModeration("/internal/moderation/reports", map[string]interface{}{
+		"content_type": "review",
+		"content_id":   review.ID,
+		"author_id":    review.UserID,
+		"text":         fmt.Sprintf("%d/5: %s", review.Rating, review.Comment),
+		"reporter_id":  userID,
+		"reason":       input.Reason,
+		"details":      input.Details,
+	})
+	if err != nil {
+		log.Printf("Error reporting review %d: %v", review.ID, err)
+		http.Error(w, "Failed to report review", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
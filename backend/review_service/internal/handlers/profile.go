@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func getProfileServiceURL() string {
+	if u := os.Getenv("PROFILE_SERVICE_URL"); u != "" {
+		return u
+	}
+	return "http://profile-service:8084"
+}
+
+// getUserProfile returns the username and profile image shown next to a
+// review. Reviews are still accepted when profile-service is unreachable.
+func getUserProfile(userID uint) (string, string) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("%s/user/profiles/%d", getProfileServiceURL(), userID))
+	if err != nil {
+		log.Printf("Error fetching user profile: %v", err)
+		return "", ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", ""
+	}
+
+	var profile struct {
+		Username   string `json:"username"`
+		ProfileImg string `json:"profile_img"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		log.Printf("Error decoding profile response: %v", err)
+		return "", ""
+	}
+	return profile.Username, profile.ProfileImg
+}
@@ -2,16 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
+	"math"
 	"net/http"
-	"os"
 	"review_service/internal/models"
+	"review_service/internal/repository"
 	"review_service/internal/service"
-	"review_service/utils"
 	"strconv"
 	"strings"
-	"time"
+
+	"gorm.io/gorm"
 )
 
 var reviewService = service.NewReviewService()
@@ -22,115 +24,621 @@ func ReviewRouter(w http.ResponseWriter, r *http.Request) {
 		CreateReview(w, r)
 	case http.MethodGet:
 		GetReviews(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func ReviewByIDRouter(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/reviews/")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	value, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/reviews/"), "/")
+	id, ok := reviewID(w, value)
+	if !ok {
+		return
+	}
+
+	switch action {
+	case "":
+	case "report":
+		ReportReview(w, r, id)
+		return
+	case "helpful":
+		VoteReview(w, r, id)
+		return
+	case "reply":
+		ReplyToReview(w, r, id)
+		return
+	default:
+		http.NotFound(w, r)
 		return
 	}
 
 	switch r.Method {
+	case http.MethodGet:
+		GetReview(w, r, id)
 	case http.MethodPut:
-		UpdateReview(w, r, uint(id))
+		UpdateReview(w, r, id)
 	case http.MethodDelete:
-		DeleteReview(w, r, uint(id))
+		DeleteReview(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func CreateReview(w http.ResponseWriter, r *http.Request) {
-	r.ParseMultipartForm(10 << 20)
-	attractionID, _ := strconv.Atoi(r.FormValue("attraction_id"))
-	rating, _ := strconv.Atoi(r.FormValue("rating"))
-	comment := r.FormValue("comment")
+// InternalReviewRouter serves other services, which check permissions
+// themselves, such as an owner removing a review of their listing or
+// moderation-service applying a decision. The gateway does not route
+// /internal.
+func InternalReviewRouter(w http.ResponseWriter, r *http.Request) {
+	value, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/internal/reviews/"), "/")
+	id, ok := reviewID(w, value)
+	if !ok {
+		return
+	}
+
+	switch {
+	case action == "moderation" && r.Method == http.MethodPut:
+		var input struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		review, err := reviewService.Get(id)
+		if err != nil {
+			writeLookupError(w, err)
+			return
+		}
+		if err := reviewService.Moderate(review, input.Status); err != nil {
+			writeServiceError(w, err, "Failed to update moderation status")
+			return
+		}
+		writeJSON(w, http.StatusOK, review)
+	case action != "":
+		http.NotFound(w, r)
+	case r.Method == http.MethodDelete:
+		review, err := reviewService.Get(id)
+		if err != nil {
+			writeLookupError(w, err)
+			return
+		}
+		if err := reviewService.Delete(review); err != nil {
+			http.Error(w, "Failed to delete", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func reviewID(w http.ResponseWriter, value string) (uint, bool) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
 
-	username := r.Header.Get("X-Username")
-	userIDStr := r.Header.Get("X-User-ID")
-	userID, err := strconv.Atoi(userIDStr)
+func currentUserID(r *http.Request) (uint, bool) {
+	userID, err := strconv.ParseUint(r.Header.Get("X-User-ID"), 10, 32)
 	if err != nil {
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+func writeServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrAlreadyReviewed):
+		http.Error(w, "You have already reviewed this item", http.StatusConflict)
+	case errors.Is(err, service.ErrItemNotFound):
+		http.Error(w, "Item not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrUnknownItemType),
+		errors.Is(err, service.ErrInvalidRating),
+		errors.Is(err, service.ErrTooManyImages),
+		errors.Is(err, service.ErrInvalidStatus),
+		errors.Is(err, service.ErrInvalidAspect),
+		errors.Is(err, service.ErrEmptyReply):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrOwnReview), errors.Is(err, service.ErrNotItemOwner):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("%s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+func writeLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Failed to load review", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// uploadPhotos stores the "images" files of a multipart request, and the
+// single "image" file older clients send. room is how many more photos the
+// review can take.
+func uploadPhotos(r *http.Request, room int) ([]models.ReviewPhoto, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	files := append(r.MultipartForm.File["images"], r.MultipartForm.File["image"]...)
+	if len(files) > room {
+		return nil, service.ErrTooManyImages
+	}
+
+	var photos []models.ReviewPhoto
+	for _, fileHeader := range files {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		url, err := storeMedia(file, fileHeader.Filename)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, models.ReviewPhoto{URL: url})
+	}
+	return photos, nil
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrTooManyImages) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Failed to store review image: %v", err)
+	http.Error(w, "Could not save image", uploadErrorStatus(err))
+}
+
+// formAspects reads the aspect ratings of an item type from form fields
+// named after the aspects, such as cleanliness=4.
+func formAspects(r *http.Request, itemType string) ([]models.ReviewAspect, error) {
+	var aspects []models.ReviewAspect
+	for _, aspect := range models.ItemAspects[itemType] {
+		value := r.FormValue(aspect)
+		if value == "" {
+			continue
+		}
+		rating, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", service.ErrInvalidAspect, aspect)
+		}
+		aspects = append(aspects, models.ReviewAspect{Aspect: aspect, Rating: rating})
+	}
+	return aspects, nil
+}
+
+func CreateReview(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var imageURL string
-	file, handler, err := r.FormFile("image")
+	// Attraction clients still send attraction_id.
+	itemType := r.FormValue("item_type")
+	itemIDStr := r.FormValue("item_id")
+	if attractionID := r.FormValue("attraction_id"); itemType == "" && attractionID != "" {
+		itemType = models.ItemAttraction
+		itemIDStr = attractionID
+	}
+	itemID, err := strconv.ParseUint(itemIDStr, 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	rating, err := strconv.Atoi(r.FormValue("rating"))
+	if err != nil || rating < 1 || rating > 5 {
+		http.Error(w, service.ErrInvalidRating.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Check what can be checked before uploading anything.
+	if err := service.CheckItem(itemType, uint(itemID)); err != nil {
+		writeServiceError(w, err, "Could not create review")
+		return
+	}
+	aspects, err := formAspects(r, itemType)
 	if err == nil {
-		defer file.Close()
-		os.MkdirAll("uploads", os.ModePerm)
-		filename := fmt.Sprintf("uploads/%d_%s", time.Now().UnixNano(), handler.Filename)
-		dst, _ := os.Create(filename)
-		defer dst.Close()
-		io.Copy(dst, file)
-		imageURL = "/" + filename
+		err = service.CheckAspects(itemType, aspects)
+	}
+	if err != nil {
+		writeServiceError(w, err, "Could not create review")
+		return
+	}
+	_, existing, err := reviewService.List(repository.ReviewFilter{ItemType: itemType, ItemID: uint(itemID), UserID: userID, IncludeModerated: true, PageSize: 1})
+	if err == nil && existing > 0 {
+		writeServiceError(w, service.ErrAlreadyReviewed, "")
+		return
+	}
+
+	images, err := uploadPhotos(r, service.MaxImages)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	username, profileImg := getUserProfile(userID)
+	if header := r.Header.Get("X-Username"); header != "" {
+		username = header
 	}
 
 	review := models.Review{
-		AttractionID: uint(attractionID),
-		UserID:       uint(userID),
-		Rating:       rating,
-		Comment:      comment,
-		Username:     username,
-		ImageURL:     imageURL,
+		ItemType:   itemType,
+		ItemID:     uint(itemID),
+		UserID:     userID,
+		Username:   username,
+		ProfileImg: profileImg,
+		Rating:     rating,
+		Comment:    r.FormValue("comment"),
+		Images:     images,
+		Aspects:    aspects,
+
+		ModerationStatus: models.StatusVisible,
 	}
 
 	if err := reviewService.Create(&review); err != nil {
-		http.Error(w, "Could not create review", http.StatusInternalServerError)
+		writeServiceError(w, err, "Could not create review")
 		return
 	}
+	screenReview(&review, false)
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(review)
+	writeJSON(w, http.StatusCreated, review)
 }
 
+// markVoted tells the requesting user which reviews they found helpful.
+func markVoted(r *http.Request, reviews []models.Review) error {
+	userID, _ := currentUserID(r)
+	return reviewService.MarkVoted(reviews, userID)
+}
+
+// GetReviews lists reviews. ?verified=true keeps verified visits only, and
+// ?sort= orders them by newest (default), verified, helpful, highest or
+// lowest.
 func GetReviews(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("attraction_id")
-	if idStr != "" {
-		id, _ := strconv.Atoi(idStr)
-		reviews, _ := reviewService.GetByAttraction(uint(id))
-		json.NewEncoder(w).Encode(reviews)
+	query := r.URL.Query()
+
+	var verifiedOnly bool
+	if value := query.Get("verified"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid verified", http.StatusBadRequest)
+			return
+		}
+		verifiedOnly = parsed
+	}
+	sort := query.Get("sort")
+	if !repository.ValidSort(sort) {
+		http.Error(w, "Invalid sort", http.StatusBadRequest)
+		return
+	}
+
+	// The attraction API predates item types and returns a bare list.
+	if attractionID := query.Get("attraction_id"); attractionID != "" || len(query) == 0 {
+		filter := repository.ReviewFilter{VerifiedOnly: verifiedOnly, Sort: sort}
+		if attractionID != "" {
+			id, err := strconv.ParseUint(attractionID, 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid attraction ID", http.StatusBadRequest)
+				return
+			}
+			filter.ItemType = models.ItemAttraction
+			filter.ItemID = uint(id)
+		}
+		reviews, _, err := reviewService.List(filter)
+		if err == nil {
+			err = markVoted(r, reviews)
+		}
+		if err != nil {
+			http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, reviews)
 		return
 	}
 
-	reviews, _ := reviewService.GetAll()
-	json.NewEncoder(w).Encode(reviews)
+	filter := repository.ReviewFilter{VerifiedOnly: verifiedOnly, Sort: sort, Page: 1, PageSize: 20}
+	if itemType := query.Get("item_type"); itemType != "" {
+		if _, ok := models.ItemTables[itemType]; !ok {
+			http.Error(w, service.ErrUnknownItemType.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.ItemType = itemType
+	}
+	for name, target := range map[string]*uint{"item_id": &filter.ItemID, "user_id": &filter.UserID} {
+		if value := query.Get(name); value != "" {
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*target = uint(id)
+		}
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	if pageSize, err := strconv.Atoi(query.Get("page_size")); err == nil && pageSize > 0 {
+		filter.PageSize = min(pageSize, 100)
+	}
+
+	reviews, total, err := reviewService.List(filter)
+	if err == nil {
+		err = markVoted(r, reviews)
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"reviews": reviews,
+		"pagination": map[string]interface{}{
+			"page":        filter.Page,
+			"page_size":   filter.PageSize,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(filter.PageSize))),
+		},
+	}
+	if filter.ItemType != "" && filter.ItemID != 0 {
+		stats, err := reviewService.Stats(filter.ItemType, filter.ItemID)
+		if err != nil {
+			http.Error(w, "Failed to fetch review stats", http.StatusInternalServerError)
+			return
+		}
+		response["stats"] = stats
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetReview returns a review. Reviews held or hidden by moderation are only
+// shown to their author.
+func GetReview(w http.ResponseWriter, r *http.Request, id uint) {
+	review, err := reviewService.Get(id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if userID, _ := currentUserID(r); review.ModerationStatus != models.StatusVisible && review.UserID != userID {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	reviews := []models.Review{*review}
+	if err := markVoted(r, reviews); err != nil {
+		http.Error(w, "Failed to load review", http.StatusInternalServerError)
+		return
+	}
+	review = &reviews[0]
+	writeJSON(w, http.StatusOK, review)
 }
 
+// GetStats returns the rating aggregate of one item.
+func GetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	itemID, err := strconv.ParseUint(r.URL.Query().Get("item_id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	stats, err := reviewService.Stats(r.URL.Query().Get("item_type"), uint(itemID))
+	if err != nil {
+		writeServiceError(w, err, "Failed to fetch review stats")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// UpdateReview takes JSON with rating, comment and an aspects object, or a
+// multipart form with the same fields that may also add images;
+// delete_existing_images=true replaces the existing ones. Aspects that are
+// left out keep their rating.
 func UpdateReview(w http.ResponseWriter, r *http.Request, id uint) {
-	var input models.Review
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// 🧠 Загружаем существующий отзыв из БД
-	var existing models.Review
-	if err := utils.DB.First(&existing, id).Error; err != nil {
-		http.Error(w, "Review not found", http.StatusNotFound)
+	existing, err := reviewService.Get(id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if existing.UserID != userID {
+		http.Error(w, "Not the review owner", http.StatusForbidden)
 		return
 	}
 
-	// 🔧 Обновляем только нужные поля
-	existing.Comment = input.Comment
-	existing.Rating = input.Rating
-	existing.UpdatedAt = time.Now()
+	var images []models.ReviewPhoto
+	replaceImages := false
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		if value := r.FormValue("rating"); value != "" {
+			rating, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, service.ErrInvalidRating.Error(), http.StatusBadRequest)
+				return
+			}
+			existing.Rating = rating
+		}
+		if comment := r.FormValue("comment"); comment != "" {
+			existing.Comment = comment
+		}
+		existing.Aspects, err = formAspects(r, existing.ItemType)
+		if err != nil {
+			writeServiceError(w, err, "Failed to update")
+			return
+		}
+		replaceImages = r.FormValue("delete_existing_images") == "true"
+	} else {
+		var input struct {
+			Rating  *int           `json:"rating"`
+			Comment *string        `json:"comment"`
+			Aspects map[string]int `json:"aspects"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		if input.Rating != nil {
+			existing.Rating = *input.Rating
+		}
+		if input.Comment != nil {
+			existing.Comment = *input.Comment
+		}
+		existing.Aspects = nil
+		for aspect, rating := range input.Aspects {
+			existing.Aspects = append(existing.Aspects, models.ReviewAspect{Aspect: aspect, Rating: rating})
+		}
+	}
+	if existing.Rating < 1 || existing.Rating > 5 {
+		http.Error(w, service.ErrInvalidRating.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// 💾 Сохраняем
-	if err := utils.DB.Save(&existing).Error; err != nil {
-		http.Error(w, "Failed to update", http.StatusInternalServerError)
+	room := service.MaxImages
+	if !replaceImages {
+		room -= len(existing.Images)
+	}
+	images, err = uploadPhotos(r, room)
+	if err != nil {
+		writeUploadError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(existing)
+	if err := reviewService.Update(existing, images, replaceImages); err != nil {
+		writeServiceError(w, err, "Failed to update")
+		return
+	}
+	screenReview(existing, true)
+
+	updated, err := reviewService.Get(id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
 }
 
 func DeleteReview(w http.ResponseWriter, r *http.Request, id uint) {
-	if err := reviewService.Delete(id); err != nil {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	review, err := reviewService.Get(id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if review.UserID != userID {
+		http.Error(w, "Not the review owner", http.StatusForbidden)
+		return
+	}
+
+	if err := reviewService.Delete(review); err != nil {
 		http.Error(w, "Failed to delete", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// VoteReview marks a review helpful for the user (POST) or takes the vote
+// back (DELETE).
+func VoteReview(w http.ResponseWriter, r *http.Request, id uint) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	review, err := reviewService.Get(id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if review.ModerationStatus != models.StatusVisible {
+		http.Error(w, "Review not found", http.StatusNotFound)
+		return
+	}
+
+	helpful := r.Method == http.MethodPost
+	count, err := reviewService.Vote(review, userID, helpful)
+	if err != nil {
+		writeServiceError(w, err, "Failed to vote")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"helpful_count": count, "voted_helpful": helpful})
+}
+
+// ReplyToReview sets (PUT {"body": "..."}) or removes (DELETE) the official
+// reply of the item's owner.
+func ReplyToReview(w http.ResponseWriter, r *http.Request, id uint) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	review, err := reviewService.Get(id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := reviewService.DeleteReply(review, userID); err != nil {
+			writeServiceError(w, err, "Failed to delete reply")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var input struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	reply, err := reviewService.Reply(review, userID, input.Body)
+	if err != nil {
+		writeServiceError(w, err, "Failed to save reply")
+		return
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+)
+
+// ServiceTokenHeader carries the token other services present on calls to
+// /internal routes. Its value is shared through the INTERNAL_TOKEN variable.
+const ServiceTokenHeader = "X-Internal-Token"
+
+// RequireServiceToken lets through only requests that carry INTERNAL_TOKEN.
+// With no token configured every call is refused, so an unconfigured
+// deployment does not leave its internal routes open.
+func RequireServiceToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := os.Getenv("INTERNAL_TOKEN")
+		if token == "" {
+			log.Printf("INTERNAL_TOKEN is not set, refusing %s %s", r.Method, r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		given := r.Header.Get(ServiceTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			log.Printf("Rejected %s %s: missing or wrong service token", r.Method, r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
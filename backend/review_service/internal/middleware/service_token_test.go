@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireServiceToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		configured string
+		sent       string
+		want       int
+	}{
+		{"matching token", "secret", "secret", http.StatusNoContent},
+		{"no token sent", "secret", "", http.StatusForbidden},
+		{"wrong token", "secret", "secreT", http.StatusForbidden},
+		{"longer token", "secret", "secret2", http.StatusForbidden},
+		{"not configured", "", "", http.StatusForbidden},
+		{"not configured but sent", "", "secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INTERNAL_TOKEN", tt.configured)
+			req := httptest.NewRequest(http.MethodDelete, "/internal/reviews/1", nil)
+			if tt.sent != "" {
+				req.Header.Set(ServiceTokenHeader, tt.sent)
+			}
+			rec := httptest.NewRecorder()
+			RequireServiceToken(ok).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,208 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// Item types that can be reviewed, and the table each one lives in.
+const (
+	ItemAttraction    = "attraction"
+	ItemPlace         = "place"
+	ItemAccommodation = "accommodation"
+	ItemEvent         = "event"
+)
+
+var ItemTables = map[string]string{
+	ItemAttraction:    "attractions",
+	ItemPlace:         "places",
+	ItemAccommodation: "accommodations",
+	ItemEvent:         "events",
+}
+
+// ItemAspects are what items of a type can be rated on besides the overall
+// rating.
+var ItemAspects = map[string][]string{
+	ItemPlace:         {"food_quality", "service", "value"},
+	ItemAccommodation: {"cleanliness", "service", "value"},
+}
+
+// Moderation statuses. Only visible reviews are listed and counted in
+// stats; held reviews wait for a moderator, hidden ones were taken down.
+const (
+	StatusVisible = "visible"
+	StatusHeld    = "held"
+	StatusHidden  = "hidden"
+)
+
+// Review is one user's review of an item. A user reviews an item at most
+// once.
 type Review struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	AttractionID uint      `json:"attraction_id"`
-	UserID       uint      `json:"user_id"`
-	Username     string    `json:"username"`
-	Rating       int       `json:"rating"`
-	Comment      string    `json:"comment"`
-	ImageURL     string    `json:"image_url"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID         uint          `json:"id" gorm:"primaryKey"`
+	ItemType   string        `json:"item_type" gorm:"size:32;not null;uniqueIndex:idx_reviews_item_user,priority:1"`
+	ItemID     uint          `json:"item_id" gorm:"not null;uniqueIndex:idx_reviews_item_user,priority:2"`
+	UserID     uint          `json:"user_id" gorm:"not null;index;uniqueIndex:idx_reviews_item_user,priority:3"`
+	Username   string        `json:"username"`
+	ProfileImg string        `json:"profile_img"`
+	Rating     int           `json:"rating" gorm:"not null;check:rating >= 1 AND rating <= 5"`
+	Comment    string        `json:"comment"`
+	Images     []ReviewPhoto `json:"images" gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE;"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+
+	// ModerationStatus is set by moderation-service.
+	ModerationStatus string `json:"moderation_status" gorm:"size:16;not null;default:visible;index"`
+
+	// Verified marks a verified visit; VerifiedVia says what proved it
+	// (see package verification).
+	Verified    bool       `json:"verified" gorm:"not null;default:false;index"`
+	VerifiedVia string     `json:"verified_via,omitempty" gorm:"size:16"`
+	VerifiedAt  *time.Time `json:"verified_at,omitempty"`
+
+	Aspects      []ReviewAspect `json:"aspects" gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE;"`
+	HelpfulCount int            `json:"helpful_count" gorm:"not null;default:0;index"`
+	Reply        *ReviewReply   `json:"reply,omitempty" gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE;"`
+	// VotedHelpful tells the requesting user whether they voted for it.
+	VotedHelpful bool `json:"voted_helpful" gorm:"-"`
+}
+
+// ReviewAspect is the rating of one aspect of an item, from ItemAspects.
+type ReviewAspect struct {
+	ReviewID uint   `json:"-" gorm:"primaryKey"`
+	Aspect   string `json:"aspect" gorm:"primaryKey;size:32"`
+	Rating   int    `json:"rating" gorm:"not null;check:rating >= 1 AND rating <= 5"`
+}
+
+// ReviewVote is a user finding a review helpful. A user votes for a review
+// once.
+type ReviewVote struct {
+	ReviewID  uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// ReviewReply is the official answer to a review by the admin who owns the
+// item. A review has at most one.
+type ReviewReply struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReviewID  uint      `json:"review_id" gorm:"not null;uniqueIndex"`
+	AdminID   uint      `json:"admin_id" gorm:"not null"`
+	Body      string    `json:"body" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ReviewPhoto struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ReviewID  uint      `json:"review_id" gorm:"index;not null"`
+	URL       string    `json:"url" gorm:"not null"`
+	MediaID   *uint     `json:"media_id,omitempty" gorm:"index"`
+	Position  int       `json:"position" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BeforeCreate records the media-service ID behind the image URL.
+func (p *ReviewPhoto) BeforeCreate(tx *gorm.DB) error {
+	p.MediaID = MediaIDFromURL(p.URL)
+	return nil
+}
+
+// ReviewStats aggregates the visible ratings of one item. It is updated in
+// the same transaction as the reviews it counts, by adding and removing
+// single ratings rather than recounting them.
+type ReviewStats struct {
+	ItemType  string    `json:"item_type" gorm:"primaryKey;size:32"`
+	ItemID    uint      `json:"item_id" gorm:"primaryKey"`
+	Count     int64     `json:"count" gorm:"not null;default:0"`
+	Average   float64   `json:"average" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Histogram Histogram      `json:"histogram" gorm:"type:json"`
+	Aspects   AspectAverages `json:"aspects" gorm:"type:json"`
+
+	// Sum is the total of the ratings. Score is their Bayesian average
+	// (see package rating), which lists of items rank by.
+	Sum   int64   `json:"sum" gorm:"not null;default:0"`
+	Score float64 `json:"score" gorm:"not null;default:0;index"`
+}
+
+// Add counts one more rating; ratings outside 1..5, such as 0 for none,
+// are ignored.
+func (s *ReviewStats) Add(rating int) {
+	s.change(rating, 1)
+}
+
+// Remove takes back a rating counted before.
+func (s *ReviewStats) Remove(rating int) {
+	s.change(rating, -1)
+}
+
+func (s *ReviewStats) change(rating int, n int64) {
+	if rating < 1 || rating > 5 {
+		return
+	}
+	s.Count += n
+	s.Sum += n * int64(rating)
+	s.Histogram[rating-1] += n
+	s.Average = 0
+	if s.Count > 0 {
+		s.Average = float64(s.Sum) / float64(s.Count)
+	}
+}
+
+// Histogram counts ratings by stars: Histogram[0] is the number of 1-star
+// ratings.
+type Histogram [5]int64
+
+func (h Histogram) Value() (driver.Value, error) {
+	return json.Marshal(h)
+}
+
+func (h *Histogram) Scan(value interface{}) error {
+	*h = Histogram{}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	case []byte:
+		return json.Unmarshal(v, h)
+	}
+	return errors.New("invalid scan source for Histogram")
+}
+
+// AspectAverages maps aspects to their average rating.
+type AspectAverages map[string]float64
+
+func (a AspectAverages) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *AspectAverages) Scan(value interface{}) error {
+	*a = AspectAverages{}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	case []byte:
+		return json.Unmarshal(v, a)
+	}
+	return errors.New("invalid scan source for AspectAverages")
+}
+
+// ReviewMigration records a legacy review table that has been imported, so
+// that reviews deleted afterwards are not imported again.
+type ReviewMigration struct {
+	Source     string    `gorm:"primaryKey;size:64"`
+	Imported   int       `gorm:"not null"`
+	Skipped    int       `gorm:"not null"`
+	ImportedAt time.Time `gorm:"not null"`
 }
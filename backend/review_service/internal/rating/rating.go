@@ -0,0 +1,66 @@
+// Package rating ranks items by a Bayesian average of their ratings: each
+// item starts with PriorWeight imaginary ratings at the mean rating of its
+// item type, so a 5.0 from one review does not outrank a 4.8 from hundreds.
+package rating
+
+import (
+	"log"
+	"review_service/internal/models"
+	"review_service/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PriorWeight is how many ratings the prior counts for.
+var PriorWeight = 10.0
+
+// fallbackMean is the prior of an item type nobody has rated yet.
+const fallbackMean = 3.0
+
+// Score is the Bayesian average of count ratings summing to sum, with a
+// prior of mean.
+func Score(count, sum int64, mean float64) float64 {
+	return (PriorWeight*mean + float64(sum)) / (PriorWeight + float64(count))
+}
+
+// Mean returns the mean visible rating of an item type, read from the
+// stats inside tx.
+func Mean(tx *gorm.DB, itemType string) (float64, error) {
+	var totals struct {
+		Count int64
+		Sum   int64
+	}
+	err := tx.Model(&models.ReviewStats{}).
+		Select("COALESCE(SUM(count), 0) AS count, COALESCE(SUM(sum), 0) AS sum").
+		Where("item_type = ?", itemType).
+		Scan(&totals).Error
+	if err != nil || totals.Count == 0 {
+		return fallbackMean, err
+	}
+	return float64(totals.Sum) / float64(totals.Count), nil
+}
+
+// Rescore recomputes every score against the current mean of its item
+// type.
+func Rescore() (int64, error) {
+	result := utils.DB.Exec(`UPDATE review_stats s
+		SET score = (@weight * COALESCE(m.mean, @fallback) + s.sum) / (@weight + s.count)
+		FROM (SELECT item_type, SUM(sum)::float8 / NULLIF(SUM(count), 0) AS mean
+			FROM review_stats GROUP BY item_type) m
+		WHERE m.item_type = s.item_type`,
+		map[string]interface{}{"weight": PriorWeight, "fallback": fallbackMean})
+	return result.RowsAffected, result.Error
+}
+
+// Start runs Rescore right away and then on every tick.
+func Start(interval time.Duration) {
+	go func() {
+		for {
+			if _, err := Rescore(); err != nil {
+				log.Printf("Rating rescore error: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,76 @@
+package rating
+
+import (
+	"math"
+	"review_service/internal/models"
+	"review_service/utils/testdb"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int64
+		sum   int64
+		mean  float64
+		want  float64
+	}{
+		{"unrated is the mean", 0, 0, 3.5, 3.5},
+		{"one five pulled to the mean", 1, 5, 3, (10*3 + 5) / 11.0},
+		{"many ratings outweigh the prior", 1000, 4800, 3, (10*3 + 4800) / 1010.0},
+		{"ratings at the mean stay there", 20, 80, 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.count, tt.sum, tt.mean); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score(%d, %d, %v) = %v, want %v", tt.count, tt.sum, tt.mean, got, tt.want)
+			}
+		})
+	}
+
+	// A single 5.0 must not outrank a 4.8 from hundreds of reviews.
+	if one, many := Score(1, 5, 3), Score(300, 1440, 3); one >= many {
+		t.Errorf("one 5.0 scored %v, 300 at 4.8 scored %v", one, many)
+	}
+}
+
+func TestMean(t *testing.T) {
+	conn := testdb.Open(t)
+	if err := conn.AutoMigrate(&models.ReviewStats{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := Mean(conn, "place"); err != nil || got != fallbackMean {
+		t.Errorf("Mean of an unrated type = %v, %v; want %v", got, err, fallbackMean)
+	}
+
+	stats := []models.ReviewStats{
+		{ItemType: "place", ItemID: 1, Count: 2, Sum: 9},
+		{ItemType: "place", ItemID: 2, Count: 3, Sum: 6},
+		{ItemType: "place", ItemID: 3},
+		{ItemType: "event", ItemID: 1, Count: 1, Sum: 1},
+	}
+	if err := conn.Create(&stats).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		itemType string
+		want     float64
+	}{
+		{"place", 15.0 / 5},
+		{"event", 1},
+		{"blog", fallbackMean},
+	}
+	for _, tt := range tests {
+		t.Run(tt.itemType, func(t *testing.T) {
+			got, err := Mean(conn, tt.itemType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Mean(%q) = %v, want %v", tt.itemType, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,30 +1,382 @@
 package repository
 
 import (
+	"errors"
 	"review_service/internal/models"
+	"review_service/internal/rating"
+	"review_service/internal/verification"
 	"review_service/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAlreadyReviewed = errors.New("item already reviewed by this user")
+
+// Review orders.
+const (
+	SortNewest   = "newest"
+	SortVerified = "verified"
+	SortHelpful  = "helpful"
+	SortHighest  = "highest"
+	SortLowest   = "lowest"
 )
 
+var reviewOrders = map[string]string{
+	SortNewest:   "created_at DESC, id DESC",
+	SortVerified: "verified DESC, created_at DESC, id DESC",
+	SortHelpful:  "helpful_count DESC, created_at DESC, id DESC",
+	SortHighest:  "rating DESC, created_at DESC, id DESC",
+	SortLowest:   "rating ASC, created_at DESC, id DESC",
+}
+
+// ValidSort reports whether sort names a review order; "" is newest.
+func ValidSort(sort string) bool {
+	_, ok := reviewOrders[sort]
+	return ok || sort == ""
+}
+
+// ReviewFilter narrows a review listing. Zero fields do not filter, except
+// that only visible reviews match unless IncludeModerated is set.
+type ReviewFilter struct {
+	ItemType         string
+	ItemID           uint
+	UserID           uint
+	VerifiedOnly     bool
+	IncludeModerated bool
+	Sort             string
+	Page             int
+	PageSize         int
+}
+
+func photosInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// withDetails loads what is shown with a review.
+func withDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Images", photosInOrder).Preload("Aspects").Preload("Reply")
+}
+
 func CreateReview(r *models.Review) error {
-	return utils.DB.Create(r).Error
+	return utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(r).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadyReviewed
+			}
+			return err
+		}
+		if err := verification.Verify(tx, r); err != nil {
+			return err
+		}
+		return updateStats(tx, r.ItemType, r.ItemID, 0, counted(r.ModerationStatus, r.Rating))
+	})
+}
+
+// counted is the rating a review contributes to the stats of its item:
+// its own when it is visible, none (0) otherwise.
+func counted(status string, rating int) int {
+	if status != models.StatusVisible {
+		return 0
+	}
+	return rating
+}
+
+// lockReview locks a review inside tx and returns it as it is stored, so
+// that the stats can take back what it counted for.
+func lockReview(tx *gorm.DB, id uint) (models.Review, error) {
+	var stored models.Review
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id, item_type, item_id, rating, moderation_status").
+		First(&stored, id).Error
+	return stored, err
+}
+
+func GetReview(id uint) (*models.Review, error) {
+	var review models.Review
+	if err := withDetails(utils.DB).First(&review, id).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetReviews returns one page of matching reviews in the order of
+// filter.Sort, newest first by default, and the number of matches. A zero
+// PageSize returns every match.
+func GetReviews(filter ReviewFilter) ([]models.Review, int64, error) {
+	query := utils.DB.Model(&models.Review{})
+	if filter.ItemType != "" {
+		query = query.Where("item_type = ?", filter.ItemType)
+	}
+	if filter.ItemID != 0 {
+		query = query.Where("item_id = ?", filter.ItemID)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.VerifiedOnly {
+		query = query.Where("verified")
+	}
+	if !filter.IncludeModerated {
+		query = query.Where("moderation_status = ?", models.StatusVisible)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order, ok := reviewOrders[filter.Sort]
+	if !ok {
+		order = reviewOrders[SortNewest]
+	}
+	query = withDetails(query).Order(order)
+	if filter.PageSize > 0 {
+		query = query.Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize)
+	}
+
+	list := []models.Review{}
+	err := query.Find(&list).Error
+	return list, total, err
+}
+
+// UpdateReview saves the review's rating, comment and aspect ratings and
+// appends images, replacing the existing ones when replaceImages is set.
+func UpdateReview(r *models.Review, images []models.ReviewPhoto, replaceImages bool) error {
+	return utils.DB.Transaction(func(tx *gorm.DB) error {
+		stored, err := lockReview(tx, r.ID)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(r).Updates(map[string]interface{}{
+			"rating":     r.Rating,
+			"comment":    r.Comment,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range r.Aspects {
+			r.Aspects[i].ReviewID = r.ID
+		}
+		if len(r.Aspects) > 0 {
+			err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&r.Aspects).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if replaceImages {
+			if err := tx.Where("review_id = ?", r.ID).Delete(&models.ReviewPhoto{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(images) > 0 {
+			var next int
+			if err := tx.Model(&models.ReviewPhoto{}).Where("review_id = ?", r.ID).
+				Select("COALESCE(MAX(position) + 1, 0)").Scan(&next).Error; err != nil {
+				return err
+			}
+			for i := range images {
+				images[i].ReviewID = r.ID
+				images[i].Position = next + i
+			}
+			if err := tx.Create(&images).Error; err != nil {
+				return err
+			}
+		}
+
+		return updateStats(tx, r.ItemType, r.ItemID,
+			counted(stored.ModerationStatus, stored.Rating), counted(stored.ModerationStatus, r.Rating))
+	})
+}
+
+// SetModerationStatus shows, holds or hides a review.
+func SetModerationStatus(r *models.Review, status string) error {
+	return utils.DB.Transaction(func(tx *gorm.DB) error {
+		stored, err := lockReview(tx, r.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(r).Update("moderation_status", status).Error; err != nil {
+			return err
+		}
+		return updateStats(tx, r.ItemType, r.ItemID,
+			counted(stored.ModerationStatus, stored.Rating), counted(status, stored.Rating))
+	})
+}
+
+func DeleteReview(r *models.Review) error {
+	return utils.DB.Transaction(func(tx *gorm.DB) error {
+		stored, err := lockReview(tx, r.ID)
+		if err != nil {
+			return err
+		}
+		for _, dependent := range []interface{}{&models.ReviewPhoto{}, &models.ReviewAspect{}, &models.ReviewVote{}, &models.ReviewReply{}} {
+			if err := tx.Where("review_id = ?", r.ID).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&models.Review{}, r.ID).Error; err != nil {
+			return err
+		}
+		return updateStats(tx, r.ItemType, r.ItemID, counted(stored.ModerationStatus, stored.Rating), 0)
+	})
+}
+
+// GetStats returns the rating aggregate of an item; items without reviews
+// get zero stats.
+func GetStats(itemType string, itemID uint) (*models.ReviewStats, error) {
+	stats := models.ReviewStats{ItemType: itemType, ItemID: itemID}
+	err := utils.DB.Where("item_type = ? AND item_id = ?", itemType, itemID).Limit(1).Find(&stats).Error
+	return &stats, err
+}
+
+// updateStats moves an item's stats from the removed rating to the added
+// one inside tx; 0 stands for no rating. The aspect averages are recounted
+// and the score is taken against the current mean of the item type. The
+// stats row is locked first, so concurrent reviews of one item update it
+// in turn.
+func updateStats(tx *gorm.DB, itemType string, itemID uint, removed, added int) error {
+	stats := models.ReviewStats{ItemType: itemType, ItemID: itemID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&stats).Error; err != nil {
+		return err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("item_type = ? AND item_id = ?", itemType, itemID).First(&stats).Error; err != nil {
+		return err
+	}
+
+	stats.Remove(removed)
+	stats.Add(added)
+
+	var aspects []struct {
+		Aspect  string
+		Average float64
+	}
+	err := tx.Model(&models.ReviewAspect{}).
+		Select("review_aspects.aspect, AVG(review_aspects.rating) AS average").
+		Joins("JOIN reviews ON reviews.id = review_aspects.review_id").
+		Where("reviews.item_type = ? AND reviews.item_id = ? AND reviews.moderation_status = ?", itemType, itemID, models.StatusVisible).
+		Group("review_aspects.aspect").
+		Scan(&aspects).Error
+	if err != nil {
+		return err
+	}
+	averages := models.AspectAverages{}
+	for _, row := range aspects {
+		averages[row.Aspect] = row.Average
+	}
+
+	// The mean includes this item's stats as they were before the change.
+	mean, err := rating.Mean(tx, itemType)
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&models.ReviewStats{}).
+		Where("item_type = ? AND item_id = ?", itemType, itemID).
+		Updates(map[string]interface{}{
+			"count":      stats.Count,
+			"sum":        stats.Sum,
+			"average":    stats.Average,
+			"histogram":  stats.Histogram,
+			"aspects":    averages,
+			"score":      rating.Score(stats.Count, stats.Sum, mean),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// Vote records that a user found a review helpful and returns its new
+// helpful count. Voting twice changes nothing.
+func Vote(reviewID, userID uint) (int, error) {
+	return changeVote(reviewID, func(tx *gorm.DB) (int64, error) {
+		vote := models.ReviewVote{ReviewID: reviewID, UserID: userID, CreatedAt: time.Now()}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&vote)
+		return result.RowsAffected, result.Error
+	}, "helpful_count + 1")
+}
+
+// Unvote takes a user's helpful vote back.
+func Unvote(reviewID, userID uint) (int, error) {
+	return changeVote(reviewID, func(tx *gorm.DB) (int64, error) {
+		result := tx.Where("review_id = ? AND user_id = ?", reviewID, userID).Delete(&models.ReviewVote{})
+		return result.RowsAffected, result.Error
+	}, "helpful_count - 1")
+}
+
+func changeVote(reviewID uint, change func(tx *gorm.DB) (int64, error), count string) (int, error) {
+	var helpful int
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		changed, err := change(tx)
+		if err != nil {
+			return err
+		}
+		if changed > 0 {
+			err := tx.Model(&models.Review{}).Where("id = ?", reviewID).
+				UpdateColumn("helpful_count", gorm.Expr(count)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Review{}).Where("id = ?", reviewID).
+			Pluck("helpful_count", &helpful).Error
+	})
+	return helpful, err
+}
+
+// VotedBy returns which of the reviews a user voted for.
+func VotedBy(userID uint, reviewIDs []uint) (map[uint]bool, error) {
+	var voted []uint
+	err := utils.DB.Model(&models.ReviewVote{}).
+		Where("user_id = ? AND review_id IN ?", userID, reviewIDs).
+		Pluck("review_id", &voted).Error
+	result := make(map[uint]bool, len(voted))
+	for _, id := range voted {
+		result[id] = true
+	}
+	return result, err
 }
 
-func GetAllReviews() ([]models.Review, error) {
-	var list []models.Review
-	err := utils.DB.Find(&list).Error
-	return list, err
+// SaveReply creates or replaces the reply to a review.
+func SaveReply(reply *models.ReviewReply) error {
+	return utils.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "review_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"admin_id", "body", "updated_at"}),
+	}).Create(reply).Error
 }
 
-func GetReviewsByAttraction(attractionID uint) ([]models.Review, error) {
-	var list []models.Review
-	err := utils.DB.Where("attraction_id = ?", attractionID).Find(&list).Error
-	return list, err
+func DeleteReply(reviewID uint) error {
+	return utils.DB.Where("review_id = ?", reviewID).Delete(&models.ReviewReply{}).Error
 }
 
-func UpdateReview(r *models.Review) error {
-	return utils.DB.Save(r).Error
+// ItemOwner returns the admin who owns an item.
+func ItemOwner(itemType string, itemID uint) (uint, error) {
+	table, ok := models.ItemTables[itemType]
+	if !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	var owners []uint
+	err := utils.DB.Table(table).Where("id = ? AND deleted_at IS NULL", itemID).Limit(1).Pluck("admin_id", &owners).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(owners) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return owners[0], nil
 }
 
-func DeleteReview(id uint) error {
-	return utils.DB.Delete(&models.Review{}, id).Error
+// ItemExists reports whether an item of a known type exists and is not
+// deleted.
+func ItemExists(itemType string, itemID uint) (bool, error) {
+	table, ok := models.ItemTables[itemType]
+	if !ok {
+		return false, nil
+	}
+	var count int64
+	err := utils.DB.Table(table).Where("id = ? AND deleted_at IS NULL", itemID).Count(&count).Error
+	return count > 0, err
 }
@@ -1,17 +1,45 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"review_service/internal/models"
 	"review_service/internal/repository"
+	"slices"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxImages caps the photos attached to one review.
+const MaxImages = 10
+
+var (
+	ErrAlreadyReviewed = repository.ErrAlreadyReviewed
+	ErrUnknownItemType = errors.New("unknown item type")
+	ErrItemNotFound    = errors.New("item not found")
+	ErrInvalidRating   = errors.New("rating must be between 1 and 5")
+	ErrTooManyImages   = errors.New("too many images")
+	ErrInvalidStatus   = errors.New("invalid moderation status")
+	ErrInvalidAspect   = errors.New("invalid aspect rating")
+	ErrOwnReview       = errors.New("you cannot vote for your own review")
+	ErrNotItemOwner    = errors.New("only the owner of the item can reply")
+	ErrEmptyReply      = errors.New("reply cannot be empty")
 )
 
 type ReviewService interface {
 	Create(*models.Review) error
-	GetAll() ([]models.Review, error)
-	GetByAttraction(uint) ([]models.Review, error)
-	Update(*models.Review) error
-	Delete(uint) error
+	Get(uint) (*models.Review, error)
+	List(repository.ReviewFilter) ([]models.Review, int64, error)
+	Update(review *models.Review, images []models.ReviewPhoto, replaceImages bool) error
+	Delete(*models.Review) error
+	Moderate(review *models.Review, status string) error
+	Stats(itemType string, itemID uint) (*models.ReviewStats, error)
+	Vote(review *models.Review, userID uint, helpful bool) (int, error)
+	MarkVoted(reviews []models.Review, userID uint) error
+	Reply(review *models.Review, userID uint, body string) (*models.ReviewReply, error)
+	DeleteReply(review *models.Review, userID uint) error
 }
 
 type reviewService struct{}
@@ -20,25 +48,171 @@ func NewReviewService() ReviewService {
 	return &reviewService{}
 }
 
+// CheckItem verifies that an item of a reviewable type exists.
+func CheckItem(itemType string, itemID uint) error {
+	if _, ok := models.ItemTables[itemType]; !ok {
+		return ErrUnknownItemType
+	}
+	exists, err := repository.ItemExists(itemType, itemID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+// CheckAspects verifies that aspect ratings are between 1 and 5 and that
+// items of the type are rated on those aspects.
+func CheckAspects(itemType string, aspects []models.ReviewAspect) error {
+	for _, aspect := range aspects {
+		if aspect.Rating < 1 || aspect.Rating > 5 || !slices.Contains(models.ItemAspects[itemType], aspect.Aspect) {
+			return fmt.Errorf("%w: %s", ErrInvalidAspect, aspect.Aspect)
+		}
+	}
+	return nil
+}
+
 func (s *reviewService) Create(r *models.Review) error {
+	if r.Rating < 1 || r.Rating > 5 {
+		return ErrInvalidRating
+	}
+	if err := CheckAspects(r.ItemType, r.Aspects); err != nil {
+		return err
+	}
+	if len(r.Images) > MaxImages {
+		return ErrTooManyImages
+	}
+	if err := CheckItem(r.ItemType, r.ItemID); err != nil {
+		return err
+	}
+	for i := range r.Images {
+		r.Images[i].Position = i
+	}
 	r.CreatedAt = time.Now()
 	r.UpdatedAt = time.Now()
 	return repository.CreateReview(r)
 }
 
-func (s *reviewService) GetAll() ([]models.Review, error) {
-	return repository.GetAllReviews()
+func (s *reviewService) Get(id uint) (*models.Review, error) {
+	return repository.GetReview(id)
 }
 
-func (s *reviewService) GetByAttraction(attractionID uint) ([]models.Review, error) {
-	return repository.GetReviewsByAttraction(attractionID)
+func (s *reviewService) List(filter repository.ReviewFilter) ([]models.Review, int64, error) {
+	return repository.GetReviews(filter)
 }
 
-func (s *reviewService) Update(r *models.Review) error {
-	r.UpdatedAt = time.Now()
-	return repository.UpdateReview(r)
+func (s *reviewService) Update(r *models.Review, images []models.ReviewPhoto, replaceImages bool) error {
+	if r.Rating < 1 || r.Rating > 5 {
+		return ErrInvalidRating
+	}
+	if err := CheckAspects(r.ItemType, r.Aspects); err != nil {
+		return err
+	}
+	kept := len(r.Images)
+	if replaceImages {
+		kept = 0
+	}
+	if kept+len(images) > MaxImages {
+		return ErrTooManyImages
+	}
+	return repository.UpdateReview(r, images, replaceImages)
+}
+
+func (s *reviewService) Delete(r *models.Review) error {
+	return repository.DeleteReview(r)
+}
+
+func (s *reviewService) Moderate(r *models.Review, status string) error {
+	switch status {
+	case models.StatusVisible, models.StatusHeld, models.StatusHidden:
+	default:
+		return ErrInvalidStatus
+	}
+	if err := repository.SetModerationStatus(r, status); err != nil {
+		return err
+	}
+	r.ModerationStatus = status
+	return nil
+}
+
+func (s *reviewService) Stats(itemType string, itemID uint) (*models.ReviewStats, error) {
+	if _, ok := models.ItemTables[itemType]; !ok {
+		return nil, ErrUnknownItemType
+	}
+	return repository.GetStats(itemType, itemID)
+}
+
+// Vote adds or takes back a user's helpful vote and returns the review's
+// helpful count.
+func (s *reviewService) Vote(r *models.Review, userID uint, helpful bool) (int, error) {
+	if r.UserID == userID {
+		return 0, ErrOwnReview
+	}
+	if helpful {
+		return repository.Vote(r.ID, userID)
+	}
+	return repository.Unvote(r.ID, userID)
+}
+
+// MarkVoted sets VotedHelpful on the reviews the user voted for.
+func (s *reviewService) MarkVoted(reviews []models.Review, userID uint) error {
+	if len(reviews) == 0 || userID == 0 {
+		return nil
+	}
+	ids := make([]uint, len(reviews))
+	for i, r := range reviews {
+		ids[i] = r.ID
+	}
+	voted, err := repository.VotedBy(userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range reviews {
+		reviews[i].VotedHelpful = voted[reviews[i].ID]
+	}
+	return nil
+}
+
+func (s *reviewService) checkOwner(r *models.Review, userID uint) error {
+	owner, err := repository.ItemOwner(r.ItemType, r.ItemID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if owner == 0 || owner != userID {
+		return ErrNotItemOwner
+	}
+	return nil
+}
+
+// Reply sets the official reply to a review. Only the admin who owns the
+// reviewed item can reply.
+func (s *reviewService) Reply(r *models.Review, userID uint, body string) (*models.ReviewReply, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmptyReply
+	}
+	if err := s.checkOwner(r, userID); err != nil {
+		return nil, err
+	}
+
+	reply := models.ReviewReply{ReviewID: r.ID, AdminID: userID, Body: body}
+	if r.Reply != nil {
+		reply.CreatedAt = r.Reply.CreatedAt
+	}
+	if err := repository.SaveReply(&reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
 }
 
-func (s *reviewService) Delete(id uint) error {
-	return repository.DeleteReview(id)
+func (s *reviewService) DeleteReply(r *models.Review, userID uint) error {
+	if err := s.checkOwner(r, userID); err != nil {
+		return err
+	}
+	return repository.DeleteReply(r.ID)
 }
@@ -0,0 +1,149 @@
+// Package verification marks reviews whose author demonstrably visited the
+// item: it was in one of their plans that has ended, they had a confirmed
+// stay there, or they checked in to the event. The evidence is read from
+// the tables of plan-service, accommodation-service and events-service.
+package verification
+
+import (
+	"fmt"
+	"log"
+	"review_service/internal/models"
+	"review_service/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of evidence, recorded in Review.VerifiedVia.
+const (
+	ViaPlan    = "plan"
+	ViaStay    = "stay"
+	ViaCheckIn = "check_in"
+)
+
+// source is one kind of evidence. Condition is a SQL condition on a row of
+// reviews that holds when the evidence exists.
+type source struct {
+	Via       string
+	Tables    []string
+	Condition string
+}
+
+var sources = []source{
+	{
+		// Plans store food places as "food". Plans saved without dates
+		// have the zero time as end date and prove nothing, and so do
+		// plans created or items added after the trip was over.
+		Via:    ViaPlan,
+		Tables: []string{"plans", "plan_items"},
+		Condition: `EXISTS (SELECT 1 FROM plan_items pi JOIN plans p ON p.id = pi.plan_id
+			WHERE p.user_id = reviews.user_id AND pi.item_id = reviews.item_id
+			AND pi.item_type = CASE reviews.item_type WHEN 'place' THEN 'food' ELSE reviews.item_type END
+			AND p.end_date < NOW() AND p.end_date > '1970-01-01'
+			AND p.created_at < p.end_date AND pi.created_at < p.end_date
+			AND p.deleted_at IS NULL AND pi.deleted_at IS NULL)`,
+	},
+	{
+		Via:    ViaStay,
+		Tables: []string{"reservations"},
+		Condition: `reviews.item_type = 'accommodation' AND EXISTS (SELECT 1 FROM reservations res
+			WHERE res.user_id = reviews.user_id AND res.accommodation_id = reviews.item_id
+			AND res.status = 'confirmed' AND res.check_in <= CURRENT_DATE AND res.deleted_at IS NULL)`,
+	},
+	{
+		Via:    ViaCheckIn,
+		Tables: []string{"event_registrations"},
+		Condition: `reviews.item_type = 'event' AND EXISTS (SELECT 1 FROM event_registrations er
+			WHERE er.user_id = reviews.user_id AND er.event_id = reviews.item_id
+			AND er.checked_in_at IS NOT NULL AND er.deleted_at IS NULL)`,
+	},
+}
+
+// available reports whether the tables of a source exist; a service that
+// has never run has not created them.
+func (s source) available(tx *gorm.DB) bool {
+	for _, table := range s.Tables {
+		if !tx.Migrator().HasTable(table) {
+			return false
+		}
+	}
+	return true
+}
+
+// mark verifies the unverified reviews in query that have the evidence of
+// src. updated_at is left alone: verification is not an edit.
+func mark(query *gorm.DB, src source, now time.Time) (int64, error) {
+	result := query.Model(&models.Review{}).
+		Where("NOT reviews.verified").
+		Where(src.Condition).
+		UpdateColumns(map[string]interface{}{
+			"verified":     true,
+			"verified_via": src.Via,
+			"verified_at":  now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Verify checks one review inside tx and records the first evidence found
+// on it.
+func Verify(tx *gorm.DB, review *models.Review) error {
+	if review.Verified {
+		return nil
+	}
+	now := time.Now()
+	for _, src := range sources {
+		if !src.available(tx) {
+			continue
+		}
+		marked, err := mark(tx.Where("reviews.id = ?", review.ID), src, now)
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Via, err)
+		}
+		if marked > 0 {
+			review.Verified = true
+			review.VerifiedVia = src.Via
+			review.VerifiedAt = &now
+			return nil
+		}
+	}
+	return nil
+}
+
+// Run verifies every review that has gained evidence since it was written,
+// such as a review posted before the plan it was in ended.
+func Run() (map[string]int64, []error) {
+	verified := make(map[string]int64)
+	var errs []error
+
+	now := time.Now()
+	for _, src := range sources {
+		if !src.available(utils.DB) {
+			continue
+		}
+		marked, err := mark(utils.DB, src, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.Via, err))
+			continue
+		}
+		if marked > 0 {
+			verified[src.Via] = marked
+		}
+	}
+	return verified, errs
+}
+
+// Start runs Run right away and then on every tick.
+func Start(interval time.Duration) {
+	go func() {
+		for {
+			verified, errs := Run()
+			for _, err := range errs {
+				log.Printf("Review verification error: %v", err)
+			}
+			if len(verified) > 0 {
+				log.Printf("Verified reviews: %v", verified)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -11,7 +11,7 @@ var DB *gorm.DB
 
 func ConnectDB() {
 	dsn := "host=db user=postgres password=123456 dbname=TravelApp port=5432 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -22,10 +22,32 @@ func ConnectDB() {
 		log.Fatal("Database connection is nil after initialization!")
 	}
 
-	err = DB.AutoMigrate(&models.Review{})
+	if err := prepareAttractionReviews(); err != nil {
+		log.Fatalf("Failed to convert attraction reviews: %v", err)
+	}
+
+	err = DB.AutoMigrate(
+		&models.Review{},
+		&models.ReviewPhoto{},
+		&models.ReviewAspect{},
+		&models.ReviewVote{},
+		&models.ReviewReply{},
+		&models.ReviewStats{},
+		&models.ReviewMigration{},
+	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	if err := finishAttractionReviews(); err != nil {
+		log.Fatalf("Failed to convert attraction reviews: %v", err)
+	}
+	if err := importLegacyReviews(); err != nil {
+		log.Fatalf("Failed to import reviews: %v", err)
+	}
+	if err := backfillStats(); err != nil {
+		log.Fatalf("Failed to recount review stats: %v", err)
+	}
+
 	log.Println("Connected to PostgreSQL database successfully!")
 }
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"review_service/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// duplicatesTable keeps the older attraction reviews a user wrote of the
+// same attraction, which the unique index on (item, user) left no room
+// for. Rows are copied whole, image columns included, with the ID of the
+// review that was kept.
+const duplicatesTable = "reviews_legacy_duplicates"
+
+// prepareAttractionReviews gives reviews written before item types their
+// item columns, so that the unique index on (item, user) can be built. A
+// user's latest review of an attraction is kept; the others are archived
+// in duplicatesTable.
+func prepareAttractionReviews() error {
+	if !DB.Migrator().HasTable("reviews") || !DB.Migrator().HasColumn("reviews", "attraction_id") {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS item_type varchar(32)`,
+			`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS item_id bigint`,
+			`UPDATE reviews SET item_type = 'attraction', item_id = attraction_id WHERE item_type IS NULL OR item_type = ''`,
+			`UPDATE reviews SET rating = LEAST(GREATEST(rating, 1), 5) WHERE rating NOT BETWEEN 1 AND 5`,
+			`CREATE TABLE IF NOT EXISTS ` + duplicatesTable + ` AS
+				SELECT r.*, 0::bigint AS kept_review_id, NOW() AS archived_at FROM reviews r WITH NO DATA`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		archived := tx.Exec(`INSERT INTO ` + duplicatesTable + `
+			SELECT r.*, latest.id, NOW() FROM reviews r
+			JOIN (SELECT item_type, item_id, user_id, MAX(id) AS id FROM reviews
+				GROUP BY item_type, item_id, user_id) latest
+			ON latest.item_type = r.item_type AND latest.item_id = r.item_id
+				AND latest.user_id = r.user_id AND latest.id > r.id`)
+		if archived.Error != nil {
+			return archived.Error
+		}
+		deleted := tx.Exec(`DELETE FROM reviews r USING reviews newer
+			WHERE newer.item_type = r.item_type AND newer.item_id = r.item_id
+			AND newer.user_id = r.user_id AND newer.id > r.id`)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		if deleted.RowsAffected != archived.RowsAffected {
+			return fmt.Errorf("archived %d duplicate reviews but found %d to delete", archived.RowsAffected, deleted.RowsAffected)
+		}
+		if archived.RowsAffected > 0 {
+			log.Printf("Archived %d older duplicate attraction reviews in %s", archived.RowsAffected, duplicatesTable)
+		}
+		return nil
+	})
+}
+
+// finishAttractionReviews moves the single image of old attraction reviews
+// into review_photos and drops the columns item types replaced.
+func finishAttractionReviews() error {
+	if !DB.Migrator().HasColumn("reviews", "attraction_id") {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn("reviews", "image_url") {
+			var rows []struct {
+				ID        uint
+				ImageURL  string
+				CreatedAt time.Time
+			}
+			err := tx.Table("reviews").Select("id, image_url, created_at").
+				Where("image_url IS NOT NULL AND image_url <> ''").Scan(&rows).Error
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				photo := models.ReviewPhoto{ReviewID: row.ID, URL: row.ImageURL, CreatedAt: row.CreatedAt}
+				if err := tx.Create(&photo).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, column := range []string{"attraction_id", "image_url", "image_media_id"} {
+			if err := tx.Exec("ALTER TABLE reviews DROP COLUMN IF EXISTS " + column).Error; err != nil {
+				return err
+			}
+		}
+		return rebuildStats(tx, models.ItemAttraction)
+	})
+}
+
+// legacySource is a review table of another service whose reviews moved to
+// this one.
+type legacySource struct {
+	Table      string
+	ItemType   string
+	ItemColumn string
+	ImageTable string
+	ProfileImg bool
+}
+
+var legacySources = []legacySource{
+	{Table: "food_reviews", ItemType: models.ItemPlace, ItemColumn: "place_id", ImageTable: "food_review_images", ProfileImg: true},
+	{Table: "accommodation_reviews", ItemType: models.ItemAccommodation, ItemColumn: "accommodation_id", ImageTable: "review_images"},
+}
+
+// importLegacyReviews copies the reviews of food places and accommodations
+// once. Only a user's latest review of an item is kept; the legacy tables
+// are left untouched.
+func importLegacyReviews() error {
+	for _, src := range legacySources {
+		var done int64
+		if err := DB.Model(&models.ReviewMigration{}).Where("source = ?", src.Table).Count(&done).Error; err != nil {
+			return err
+		}
+		if done > 0 || !DB.Migrator().HasTable(src.Table) {
+			continue
+		}
+		if err := DB.Transaction(func(tx *gorm.DB) error { return importLegacy(tx, src) }); err != nil {
+			return fmt.Errorf("%s: %w", src.Table, err)
+		}
+	}
+	return nil
+}
+
+func importLegacy(tx *gorm.DB, src legacySource) error {
+	profileImg := "''"
+	if src.ProfileImg {
+		profileImg = "COALESCE(profile_img, '')"
+	}
+
+	var rows []struct {
+		ID         uint
+		ItemID     uint
+		UserID     uint
+		Username   string
+		ProfileImg string
+		Rating     int
+		Comment    string
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+	err := tx.Table(src.Table).
+		Select(fmt.Sprintf(`id, %s AS item_id, user_id, COALESCE(username, '') AS username,
+			%s AS profile_img, rating, COALESCE(comment, '') AS comment, created_at, updated_at`,
+			src.ItemColumn, profileImg)).
+		Where("deleted_at IS NULL").
+		Order("id DESC").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	migration := models.ReviewMigration{Source: src.Table, ImportedAt: time.Now()}
+	for _, row := range rows {
+		review := models.Review{
+			ItemType:   src.ItemType,
+			ItemID:     row.ItemID,
+			UserID:     row.UserID,
+			Username:   row.Username,
+			ProfileImg: row.ProfileImg,
+			Rating:     row.Rating,
+			Comment:    row.Comment,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+		}
+		// Rows come newest first, so a conflict is an older review of the
+		// same item by the same user.
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&review).Error; err != nil {
+			return err
+		}
+		if review.ID == 0 {
+			migration.Skipped++
+			continue
+		}
+		migration.Imported++
+
+		var urls []string
+		err := tx.Table(src.ImageTable).
+			Where("review_id = ? AND deleted_at IS NULL", row.ID).
+			Order("id").
+			Pluck("url", &urls).Error
+		if err != nil {
+			return err
+		}
+		for position, url := range urls {
+			photo := models.ReviewPhoto{ReviewID: review.ID, URL: url, Position: position, CreatedAt: row.CreatedAt}
+			if err := tx.Create(&photo).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := rebuildStats(tx, src.ItemType); err != nil {
+		return err
+	}
+	if err := tx.Create(&migration).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d reviews from %s (%d older duplicates skipped)", migration.Imported, src.Table, migration.Skipped)
+	return nil
+}
+
+// rebuildStats recounts the visible ratings of every item of one type.
+// Scores are left to the next rescore.
+func rebuildStats(tx *gorm.DB, itemType string) error {
+	return tx.Exec(`INSERT INTO review_stats (item_type, item_id, count, sum, average, histogram, aspects, updated_at)
+		SELECT item_type, item_id, COUNT(*), SUM(rating), AVG(rating),
+			json_build_array(
+				COUNT(*) FILTER (WHERE rating = 1), COUNT(*) FILTER (WHERE rating = 2),
+				COUNT(*) FILTER (WHERE rating = 3), COUNT(*) FILTER (WHERE rating = 4),
+				COUNT(*) FILTER (WHERE rating = 5)),
+			COALESCE((SELECT json_object_agg(aspect, average) FROM (
+				SELECT a.aspect, AVG(a.rating) AS average FROM review_aspects a
+				JOIN reviews r ON r.id = a.review_id
+				WHERE r.item_type = reviews.item_type AND r.item_id = reviews.item_id
+				AND r.moderation_status = 'visible'
+				GROUP BY a.aspect) aspects), '{}'::json),
+			NOW()
+		FROM reviews
+		WHERE item_type = ? AND moderation_status = 'visible' GROUP BY item_type, item_id
+		ON CONFLICT (item_type, item_id) DO UPDATE
+		SET count = EXCLUDED.count, sum = EXCLUDED.sum, average = EXCLUDED.average, histogram = EXCLUDED.histogram,
+			aspects = EXCLUDED.aspects, updated_at = EXCLUDED.updated_at`,
+		itemType).Error
+}
+
+// backfillStats recounts stats kept before they had histograms or sums.
+// Ratings are at least 1, so counted ratings never sum to 0.
+func backfillStats() error {
+	var missing int64
+	if err := DB.Model(&models.ReviewStats{}).Where("histogram IS NULL OR (count > 0 AND sum = 0)").Count(&missing).Error; err != nil {
+		return err
+	}
+	if missing == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for itemType := range models.ItemTables {
+			if err := rebuildStats(tx, itemType); err != nil {
+				return err
+			}
+		}
+		// Items whose reviews were all removed keep zero stats.
+		return tx.Model(&models.ReviewStats{}).Where("histogram IS NULL").
+			UpdateColumns(map[string]interface{}{"histogram": models.Histogram{}, "aspects": models.AspectAverages{}}).Error
+	})
+}
@@ -0,0 +1,73 @@
+#!/usr/bin/env bash
+# Checks that the files each service keeps its own copy of have not drifted
+# apart. Copies may differ only in their package clause and, for the media
+# client, in the mediaOwner constant.
+set -u
+cd "$(dirname "$0")/.."
+
+status=0
+
+# check NAME FILE... compares every FILE with the first one.
+check() {
+	name=$1
+	shift
+	first=$1
+	shift
+	for file in "$@"; do
+		if ! diff -u --label "$first" --label "$file" <(normalize "$first") <(normalize "$file") >&2; then
+			echo "$name: $file differs from $first" >&2
+			status=1
+		fi
+	done
+}
+
+normalize() {
+	sed -e '/^package /d' -e '/^const mediaOwner = /d' "$1"
+}
+
+check "location client" \
+	food_service/internal/controllers/location.go \
+	accommodation_service/internal/controllers/location.go \
+	attraction_service/internal/controllers/location.go \
+	events_service/internal/controllers/location.go
+
+check "geo helpers" \
+	food_service/internal/models/geo.go \
+	accommodation_service/internal/model/geo.go \
+	events_service/internal/models/geo.go
+
+check "media client" \
+	food_service/internal/controllers/media.go \
+	accommodation_service/internal/controllers/media.go \
+	attraction_service/internal/controllers/media.go \
+	blogs_service/internal/controllers/media.go \
+	events_service/internal/controllers/media.go \
+	profile_service/internal/controllers/media.go \
+	review_service/internal/handlers/media.go
+
+check "media IDs" \
+	food_service/internal/models/media.go \
+	accommodation_service/internal/model/media.go \
+	attraction_service/internal/models/media.go \
+	blogs_service/internal/models/media.go \
+	events_service/internal/models/media.go \
+	profile_service/internal/models/media.go \
+	review_service/internal/models/media.go
+
+check "service token middleware" \
+	review_service/internal/middleware/service_token.go \
+	blogs_service/internal/middleware/service_token.go \
+	media_service/internal/middleware/service_token.go \
+	moderation_service/internal/middleware/service_token.go \
+	payment_service/internal/middleware/service_token.go \
+	profile_service/internal/middleware/service_token.go
+
+check "test database" \
+	accommodation_service/utils/testdb/testdb.go \
+	blogs_service/utils/testdb/testdb.go \
+	events_service/utils/testdb/testdb.go \
+	media_service/utils/testdb/testdb.go \
+	payment_service/utils/testdb/testdb.go \
+	review_service/utils/testdb/testdb.go
+
+exit $status
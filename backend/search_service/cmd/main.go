@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"search_service/internal/indexer"
+	"search_service/routes"
+	"search_service/utils/db"
+	"time"
+)
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+		log.Printf("Invalid %s=%q, using %s", name, value, fallback)
+	}
+	return fallback
+}
+
+func main() {
+	db.ConnectDB()
+
+	ix := indexer.NewIndexer()
+	ix.Start(
+		durationFromEnv("SEARCH_SYNC_INTERVAL", time.Minute),
+		durationFromEnv("SEARCH_FULL_SYNC_INTERVAL", time.Hour),
+	)
+
+	router := routes.SetupRoutes(ix)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8091"
+	}
+
+	log.Printf("Search service running on port %s", port)
+
+	log.Fatal(http.ListenAndServe(":"+port, router))
+}
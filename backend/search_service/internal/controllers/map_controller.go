@@ -0,0 +1,361 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"search_service/internal/indexer"
+	"search_service/internal/models"
+	"search_service/internal/tiles"
+	"search_service/utils/db"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	// clusterGridSize splits each tile into a grid of cells; points that fall
+	// into the same cell are merged into one cluster (32px on a 256px tile).
+	clusterGridSize = 8
+	// Above maxClusterZoom every point is returned on its own.
+	maxClusterZoom   = 16
+	maxTilePoints    = 500
+	maxViewportTiles = 64
+	tileCacheSize    = 4096
+)
+
+// mapTypes are the types with their own coordinates. Dishes share their
+// place's location and blogs have none.
+var mapTypes = []string{
+	models.TypeAttraction,
+	models.TypeEvent,
+	models.TypePlace,
+	models.TypeAccommodation,
+}
+
+type PointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   PointGeometry          `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type cachedTile struct {
+	version  uint64
+	features []Feature
+}
+
+// MapController serves map markers as GeoJSON, clustered per XYZ tile. Tiles
+// are cached in memory until the indexer next changes the index.
+type MapController struct {
+	Indexer *indexer.Indexer
+
+	mu    sync.Mutex
+	cache map[string]cachedTile
+}
+
+func NewMapController(ix *indexer.Indexer) *MapController {
+	return &MapController{Indexer: ix, cache: make(map[string]cachedTile)}
+}
+
+type clusterRow struct {
+	CellX          int
+	CellY          int
+	Count          int
+	Latitude       float64
+	Longitude      float64
+	DocumentID     uint
+	Attractions    int
+	Events         int
+	Places         int
+	Accommodations int
+}
+
+func parseMapTypes(r *http.Request) ([]string, string) {
+	param := r.URL.Query().Get("type")
+	if param == "" {
+		return mapTypes, ""
+	}
+
+	allowed := make(map[string]bool, len(mapTypes))
+	for _, t := range mapTypes {
+		allowed[t] = true
+	}
+
+	var types []string
+	for _, t := range strings.Split(param, ",") {
+		t = strings.TrimSpace(t)
+		if !allowed[t] {
+			return nil, "Invalid type: " + t
+		}
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types, ""
+}
+
+func pointFeature(lat, lng float64, properties map[string]interface{}) Feature {
+	return Feature{
+		Type:       "Feature",
+		Geometry:   PointGeometry{Type: "Point", Coordinates: [2]float64{lng, lat}},
+		Properties: properties,
+	}
+}
+
+func documentFeature(document models.SearchDocument) Feature {
+	properties := map[string]interface{}{
+		"type":      document.ItemType,
+		"id":        document.ItemID,
+		"title":     document.Title,
+		"city":      document.City,
+		"category":  document.Category,
+		"image_url": document.ImageURL,
+	}
+	if document.CityID != nil {
+		properties["city_id"] = *document.CityID
+	}
+	return pointFeature(*document.Latitude, *document.Longitude, properties)
+}
+
+// tileFeatures returns the features of one tile, from the cache when the
+// index has not changed since they were built.
+func (c *MapController) tileFeatures(tile tiles.Tile, types []string) ([]Feature, error) {
+	key := tile.String() + "?" + strings.Join(types, ",")
+	version := c.Indexer.Version()
+
+	c.mu.Lock()
+	cached, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && cached.version == version {
+		return cached.features, nil
+	}
+
+	var features []Feature
+	var err error
+	if tile.Z > maxClusterZoom {
+		features, err = loadTilePoints(tile, types)
+	} else {
+		features, err = loadTileClusters(tile, types)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.cache) >= tileCacheSize {
+		c.cache = make(map[string]cachedTile)
+	}
+	c.cache[key] = cachedTile{version: version, features: features}
+	c.mu.Unlock()
+
+	return features, nil
+}
+
+// tileQuery selects the documents inside the tile. Bounds are half-open so a
+// point on a shared edge belongs to exactly one tile.
+func tileQuery(tile tiles.Tile, types []string) *gorm.DB {
+	minLng, minLat, maxLng, maxLat := tile.Bounds()
+	return db.DB.Table("search_documents").
+		Where("item_type IN ?", types).
+		Where("longitude >= ? AND longitude < ? AND latitude > ? AND latitude <= ?",
+			minLng, maxLng, minLat, maxLat)
+}
+
+func loadTilePoints(tile tiles.Tile, types []string) ([]Feature, error) {
+	query := tileQuery(tile, types)
+
+	var documents []models.SearchDocument
+	if err := query.Order("id").Limit(maxTilePoints).Find(&documents).Error; err != nil {
+		return nil, err
+	}
+
+	features := make([]Feature, 0, len(documents))
+	for _, document := range documents {
+		features = append(features, documentFeature(document))
+	}
+	return features, nil
+}
+
+// loadTileClusters groups the tile's points on a grid in Web Mercator space,
+// so cells look equally sized on screen at every latitude. Cells with a
+// single point come back as that point.
+func loadTileClusters(tile tiles.Tile, types []string) ([]Feature, error) {
+	minLng, _, maxLng, _ := tile.Bounds()
+	cellLng := (maxLng - minLng) / clusterGridSize
+	cellMercator := tile.MercatorSize() / clusterGridSize
+
+	query := tileQuery(tile, types)
+
+	var rows []clusterRow
+	err := query.Select(`floor((longitude - ?) / ?)::int AS cell_x,
+			floor((? - ln(tan(pi() / 4 + radians(latitude) / 2))) / ?)::int AS cell_y,
+			COUNT(*) AS count, AVG(latitude) AS latitude, AVG(longitude) AS longitude,
+			MIN(id) AS document_id,
+			COUNT(*) FILTER (WHERE item_type = ?) AS attractions,
+			COUNT(*) FILTER (WHERE item_type = ?) AS events,
+			COUNT(*) FILTER (WHERE item_type = ?) AS places,
+			COUNT(*) FILTER (WHERE item_type = ?) AS accommodations`,
+		minLng, cellLng, tile.MercatorTop(), cellMercator,
+		models.TypeAttraction, models.TypeEvent, models.TypePlace, models.TypeAccommodation).
+		Group("cell_x, cell_y").
+		Order("cell_y, cell_x").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var singleIDs []uint
+	for _, row := range rows {
+		if row.Count == 1 {
+			singleIDs = append(singleIDs, row.DocumentID)
+		}
+	}
+
+	singles := make(map[uint]models.SearchDocument, len(singleIDs))
+	if len(singleIDs) > 0 {
+		var documents []models.SearchDocument
+		if err := db.DB.Where("id IN ?", singleIDs).Find(&documents).Error; err != nil {
+			return nil, err
+		}
+		for _, document := range documents {
+			singles[document.ID] = document
+		}
+	}
+
+	features := make([]Feature, 0, len(rows))
+	for _, row := range rows {
+		if document, ok := singles[row.DocumentID]; ok && row.Count == 1 {
+			features = append(features, documentFeature(document))
+			continue
+		}
+
+		features = append(features, pointFeature(row.Latitude, row.Longitude, map[string]interface{}{
+			"cluster":     true,
+			"cluster_id":  fmt.Sprintf("%s/%d/%d", tile, row.CellX, row.CellY),
+			"point_count": row.Count,
+			"counts": map[string]int{
+				models.TypeAttraction:    row.Attractions,
+				models.TypeEvent:         row.Events,
+				models.TypePlace:         row.Places,
+				models.TypeAccommodation: row.Accommodations,
+			},
+			"expansion_zoom": tile.Z + 1,
+		}))
+	}
+	return features, nil
+}
+
+// writeFeatures sends a FeatureCollection with caching headers. The ETag is
+// the index version, so clients revalidate cheaply after every sync.
+func (c *MapController) writeFeatures(w http.ResponseWriter, r *http.Request, version uint64, features []Feature) {
+	etag := fmt.Sprintf(`"%d"`, version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if features == nil {
+		features = []Feature{}
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	json.NewEncoder(w).Encode(FeatureCollection{Type: "FeatureCollection", Features: features})
+}
+
+// Tile serves one XYZ tile as GeoJSON.
+func (c *MapController) Tile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	z, _ := strconv.Atoi(vars["z"])
+	x, _ := strconv.Atoi(vars["x"])
+	y, _ := strconv.Atoi(vars["y"])
+	tile := tiles.Tile{Z: z, X: x, Y: y}
+	if !tile.Valid() {
+		http.Error(w, "Invalid tile coordinates", http.StatusBadRequest)
+		return
+	}
+
+	types, errMsg := parseMapTypes(r)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	version := c.Indexer.Version()
+	features, err := c.tileFeatures(tile, types)
+	if err != nil {
+		http.Error(w, "Failed to load map features", http.StatusInternalServerError)
+		return
+	}
+
+	c.writeFeatures(w, r, version, features)
+}
+
+// Viewport serves every tile covering a bbox at a zoom level in one
+// response. Each tile is clustered and cached on its own, so panning only
+// computes the tiles that came into view.
+func (c *MapController) Viewport(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Query().Get("bbox"), ",")
+	if len(parts) != 4 {
+		http.Error(w, "bbox must be min_lng,min_lat,max_lng,max_lat", http.StatusBadRequest)
+		return
+	}
+	values := make([]float64, 4)
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			http.Error(w, "bbox must be min_lng,min_lat,max_lng,max_lat", http.StatusBadRequest)
+			return
+		}
+		values[i] = value
+	}
+	minLng, minLat, maxLng, maxLat := values[0], values[1], values[2], values[3]
+	if minLng > maxLng || minLat > maxLat || minLng < -180 || maxLng > 180 || minLat < -90 || maxLat > 90 {
+		http.Error(w, "Invalid bbox", http.StatusBadRequest)
+		return
+	}
+
+	zoom, err := strconv.Atoi(r.URL.Query().Get("zoom"))
+	if err != nil || zoom < 0 || zoom > tiles.MaxZoom {
+		http.Error(w, "zoom must be between 0 and 20", http.StatusBadRequest)
+		return
+	}
+
+	types, errMsg := parseMapTypes(r)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+
+	cover, ok := tiles.Cover(minLng, minLat, maxLng, maxLat, zoom, maxViewportTiles)
+	if !ok {
+		http.Error(w, "Viewport is too large for this zoom level", http.StatusBadRequest)
+		return
+	}
+
+	version := c.Indexer.Version()
+	features := []Feature{}
+	for _, tile := range cover {
+		tileFeatures, err := c.tileFeatures(tile, types)
+		if err != nil {
+			http.Error(w, "Failed to load map features", http.StatusInternalServerError)
+			return
+		}
+		features = append(features, tileFeatures...)
+	}
+
+	c.writeFeatures(w, r, version, features)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"html"
+	"math"
+	"search_service/utils/db"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxSearchTerms = 8
+
+	highlightStart = "{{hl}}"
+	highlightStop  = "{{/hl}}"
+)
+
+type searchFilter struct {
+	Terms     []string
+	Types     []string
+	City      string
+	CityID    uint
+	Category  string
+	HasPoint  bool
+	Lat       float64
+	Lng       float64
+	RadiusKm  float64
+	HasBounds bool
+	MinLat    float64
+	MinLng    float64
+	MaxLat    float64
+	MaxLng    float64
+	Sort      string
+	Page      int
+	PageSize  int
+}
+
+// splitTerms lowercases the query and keeps only letters and digits so the
+// terms are safe to feed into to_tsquery.
+func splitTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// tsQuery matches every term as a prefix, so "mosc" already finds "moscow".
+func (f *searchFilter) tsQuery() string {
+	parts := make([]string, len(f.Terms))
+	for i, term := range f.Terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func (f *searchFilter) text() string {
+	return strings.Join(f.Terms, " ")
+}
+
+func distanceExpr() string {
+	return `(6371 * acos(LEAST(1, cos(radians(?)) * cos(radians(latitude)) *
+		cos(radians(longitude) - radians(?)) + sin(radians(?)) * sin(radians(latitude)))))`
+}
+
+// applyFilters narrows the document set. skip names a facet dimension to
+// leave unfiltered so that facet counts show the alternatives for it.
+func (f *searchFilter) applyFilters(skip string) *gorm.DB {
+	query := db.DB.Table("search_documents")
+
+	if len(f.Terms) > 0 {
+		// Full-text prefix match, or a trigram match on the title to tolerate typos.
+		query = query.Where("(search_vector @@ to_tsquery('simple', ?) OR lower(?) <% lower(title))",
+			f.tsQuery(), f.text())
+	}
+	if len(f.Types) > 0 && skip != "type" {
+		query = query.Where("item_type IN ?", f.Types)
+	}
+	if f.CityID != 0 && skip != "city" {
+		query = query.Where("city_id = ?", f.CityID)
+	} else if f.City != "" && skip != "city" {
+		query = query.Where("LOWER(city) = LOWER(?)", f.City)
+	}
+	if f.Category != "" && skip != "category" {
+		query = query.Where("LOWER(category) = LOWER(?)", f.Category)
+	}
+	if f.HasBounds {
+		query = query.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?",
+			f.MinLat, f.MaxLat, f.MinLng, f.MaxLng)
+	}
+	if f.HasPoint {
+		// Cheap bounding box first so the index narrows rows before the exact distance check.
+		latDelta := f.RadiusKm / 111.0
+		lngDelta := f.RadiusKm / (111.0 * math.Max(math.Cos(f.Lat*math.Pi/180), 0.01))
+		query = query.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?",
+			f.Lat-latDelta, f.Lat+latDelta, f.Lng-lngDelta, f.Lng+lngDelta).
+			Where(distanceExpr()+" <= ?", f.Lat, f.Lng, f.Lat, f.RadiusKm)
+	}
+
+	return query
+}
+
+// highlight escapes the ts_headline output and turns its markers into <mark> tags.
+func highlight(fragment string) string {
+	escaped := html.EscapeString(fragment)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"search_service/internal/indexer"
+	"search_service/internal/models"
+	"strconv"
+	"strings"
+)
+
+type SearchController struct {
+	Indexer *indexer.Indexer
+}
+
+type SearchResult struct {
+	Type           string   `json:"type" gorm:"column:item_type"`
+	ID             uint     `json:"id" gorm:"column:item_id"`
+	ParentID       uint     `json:"parent_id,omitempty"`
+	Title          string   `json:"title"`
+	TitleHighlight string   `json:"title_highlight,omitempty"`
+	Snippet        string   `json:"snippet,omitempty"`
+	City           string   `json:"city"`
+	CityID         *uint    `json:"city_id,omitempty"`
+	Category       string   `json:"category"`
+	ImageURL       string   `json:"image_url"`
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	Distance       *float64 `json:"distance,omitempty"`
+	Score          float64  `json:"score"`
+}
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+var validTypes = map[string]bool{
+	models.TypeAttraction:    true,
+	models.TypeEvent:         true,
+	models.TypePlace:         true,
+	models.TypeDish:          true,
+	models.TypeAccommodation: true,
+	models.TypeBlog:          true,
+}
+
+var facetColumns = map[string]string{
+	"type":     "item_type",
+	"city":     "city",
+	"category": "category",
+}
+
+func parseFloatParam(r *http.Request, name string) (float64, bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, false, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return parsed, true, nil
+}
+
+func parseSearchFilter(r *http.Request) (*searchFilter, string) {
+	query := r.URL.Query()
+
+	filter := &searchFilter{
+		Terms:    splitTerms(query.Get("q")),
+		City:     strings.TrimSpace(query.Get("city")),
+		Category: strings.TrimSpace(query.Get("category")),
+		Sort:     query.Get("sort"),
+		Page:     1,
+		PageSize: 20,
+	}
+
+	if types := query.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !validTypes[t] {
+				return nil, "Invalid type: " + t
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	if cityID := query.Get("city_id"); cityID != "" {
+		id, err := strconv.ParseUint(cityID, 10, 32)
+		if err != nil {
+			return nil, "Invalid city_id"
+		}
+		filter.CityID = uint(id)
+	}
+
+	lat, hasLat, err1 := parseFloatParam(r, "lat")
+	lng, hasLng, err2 := parseFloatParam(r, "lng")
+	if err1 != nil || err2 != nil || hasLat != hasLng {
+		return nil, "lat and lng must be provided together as numbers"
+	}
+	if hasLat {
+		if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return nil, "Invalid coordinates"
+		}
+		filter.HasPoint = true
+		filter.Lat = lat
+		filter.Lng = lng
+		filter.RadiusKm = 10
+		if radius, ok, err := parseFloatParam(r, "radius"); err != nil || (ok && (radius <= 0 || radius > 500)) {
+			return nil, "Invalid radius - must be between 0 and 500 km"
+		} else if ok {
+			filter.RadiusKm = radius
+		}
+	}
+
+	if bbox := query.Get("bbox"); bbox != "" {
+		parts := strings.Split(bbox, ",")
+		if len(parts) != 4 {
+			return nil, "bbox must be min_lng,min_lat,max_lng,max_lat"
+		}
+		values := make([]float64, 4)
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, "bbox must be min_lng,min_lat,max_lng,max_lat"
+			}
+			values[i] = value
+		}
+		filter.HasBounds = true
+		filter.MinLng, filter.MinLat, filter.MaxLng, filter.MaxLat = values[0], values[1], values[2], values[3]
+		if filter.MinLat > filter.MaxLat || filter.MinLng > filter.MaxLng {
+			return nil, "Invalid bbox"
+		}
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = "relevance"
+		if len(filter.Terms) == 0 {
+			filter.Sort = "newest"
+			if filter.HasPoint {
+				filter.Sort = "distance"
+			}
+		}
+	case "relevance", "newest":
+	case "distance":
+		if !filter.HasPoint {
+			return nil, "Sorting by distance requires lat and lng"
+		}
+	default:
+		return nil, "Invalid sort - use relevance, distance or newest"
+	}
+
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	if pageSize, err := strconv.Atoi(query.Get("page_size")); err == nil && pageSize > 0 && pageSize <= 100 {
+		filter.PageSize = pageSize
+	}
+
+	return filter, ""
+}
+
+// Search runs one query across every indexed catalog type.
+func (c *SearchController) Search(w http.ResponseWriter, r *http.Request) {
+	filter, errMsg := parseSearchFilter(r)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusBadRequest)
+		return
+	}
+	if len(filter.Terms) == 0 && len(filter.Types) == 0 && filter.City == "" && filter.CityID == 0 &&
+		filter.Category == "" && !filter.HasPoint && !filter.HasBounds {
+		http.Error(w, "Search query or filter is required", http.StatusBadRequest)
+		return
+	}
+
+	var total int64
+	if err := filter.applyFilters("").Count(&total).Error; err != nil {
+		http.Error(w, "Failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	selects := []string{"item_type", "item_id", "parent_id", "title", "city", "city_id", "category", "image_url", "latitude", "longitude"}
+	args := []interface{}{}
+	if len(filter.Terms) > 0 {
+		selects = append(selects,
+			"ts_rank_cd(search_vector, to_tsquery('simple', ?)) + word_similarity(lower(?), lower(title)) AS score",
+			"ts_headline('simple', title, to_tsquery('simple', ?), 'StartSel=\""+highlightStart+"\", StopSel=\""+highlightStop+"\", HighlightAll=true') AS title_highlight",
+			"ts_headline('simple', body, to_tsquery('simple', ?), 'StartSel=\""+highlightStart+"\", StopSel=\""+highlightStop+"\", MaxFragments=2, MaxWords=25, MinWords=8') AS snippet",
+		)
+		args = append(args, filter.tsQuery(), filter.text(), filter.tsQuery(), filter.tsQuery())
+	} else {
+		selects = append(selects, "0 AS score")
+	}
+	if filter.HasPoint {
+		selects = append(selects, distanceExpr()+" AS distance")
+		args = append(args, filter.Lat, filter.Lng, filter.Lat)
+	}
+
+	query := filter.applyFilters("").Select(strings.Join(selects, ", "), args...)
+	switch filter.Sort {
+	case "relevance":
+		query = query.Order("score DESC").Order("source_updated_at DESC")
+	case "distance":
+		query = query.Order("distance ASC")
+	case "newest":
+		query = query.Order("source_updated_at DESC")
+	}
+
+	results := []SearchResult{}
+	err := query.Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).Scan(&results).Error
+	if err != nil {
+		http.Error(w, "Failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range results {
+		if results[i].TitleHighlight != "" {
+			results[i].TitleHighlight = highlight(results[i].TitleHighlight)
+		}
+		if results[i].Snippet != "" {
+			results[i].Snippet = highlight(results[i].Snippet)
+		}
+		if results[i].Distance != nil {
+			rounded := math.Round(*results[i].Distance*100) / 100
+			results[i].Distance = &rounded
+		}
+	}
+
+	facets := make(map[string][]FacetValue)
+	for name, column := range facetColumns {
+		values := []FacetValue{}
+		err := filter.applyFilters(name).
+			Select(column + " AS value, COUNT(*) AS count").
+			Where(column + " <> ''").
+			Group(column).
+			Order("count DESC").
+			Limit(20).
+			Scan(&values).Error
+		if err != nil {
+			http.Error(w, "Failed to compute facets", http.StatusInternalServerError)
+			return
+		}
+		facets[name] = values
+	}
+
+	response := map[string]interface{}{
+		"results": results,
+		"facets":  facets,
+		"pagination": map[string]interface{}{
+			"page":        filter.Page,
+			"page_size":   filter.PageSize,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(filter.PageSize))),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Reindex lets admins force a sync instead of waiting for the next tick.
+// A full reindex also removes documents whose source rows are gone.
+func (c *SearchController) Reindex(w http.ResponseWriter, r *http.Request) {
+	full := r.URL.Query().Get("full") == "true"
+	stats := c.Indexer.Sync(full)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
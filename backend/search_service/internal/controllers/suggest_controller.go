@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"search_service/internal/indexer"
+	"search_service/internal/models"
+	"search_service/utils/db"
+	"strconv"
+	"strings"
+)
+
+var suggestTypes = map[string]bool{
+	models.SuggestionCity: true,
+	models.TypeAttraction: true,
+	models.TypePlace:      true,
+	models.TypeEvent:      true,
+	models.TypeDish:       true,
+}
+
+// Suggest returns typeahead completions for cities and catalog names. The
+// query is matched as a prefix of any word in the label, in Cyrillic or Latin.
+func (c *SearchController) Suggest(w http.ResponseWriter, r *http.Request) {
+	keys := indexer.SearchKeys(r.URL.Query().Get("q"))
+	if len(keys) == 0 {
+		http.Error(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 10
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 20 {
+		limit = l
+	}
+
+	inner := db.DB.Model(&models.Suggestion{}).
+		Select("DISTINCT ON (kind, item_id, city_key) kind, item_id, parent_id, label, city, city_id, weight").
+		Order("kind, item_id, city_key, weight DESC")
+
+	conditions := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		conditions[i] = "key LIKE ?"
+		args[i] = key + "%"
+	}
+	inner = inner.Where("("+strings.Join(conditions, " OR ")+")", args...)
+
+	if types := r.URL.Query().Get("type"); types != "" {
+		var kinds []string
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !suggestTypes[t] {
+				http.Error(w, "Invalid type: "+t, http.StatusBadRequest)
+				return
+			}
+			kinds = append(kinds, t)
+		}
+		inner = inner.Where("kind IN ?", kinds)
+	}
+
+	if cityIDParam := r.URL.Query().Get("city_id"); cityIDParam != "" {
+		cityID, err := strconv.ParseUint(cityIDParam, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid city_id", http.StatusBadRequest)
+			return
+		}
+		inner = inner.Where("city_id = ?", cityID)
+	} else if city := r.URL.Query().Get("city"); city != "" {
+		inner = inner.Where("city_key = ?", indexer.CityKey(city))
+	}
+
+	suggestions := []models.Suggestion{}
+	err := db.DB.Table("(?) AS s", inner).
+		Order("weight DESC, length(label), label").
+		Limit(limit).
+		Scan(&suggestions).Error
+	if err != nil {
+		http.Error(w, "Failed to load suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
@@ -0,0 +1,225 @@
+package indexer
+
+import (
+	"fmt"
+	"log"
+	"search_service/internal/models"
+	"search_service/utils/db"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+type SyncStats struct {
+	Full     bool           `json:"full"`
+	Indexed  map[string]int `json:"indexed"`
+	Removed  map[string]int `json:"removed"`
+	Errors   []string       `json:"errors,omitempty"`
+	Duration string         `json:"duration"`
+}
+
+func (s SyncStats) changed() bool {
+	for itemType := range s.Indexed {
+		if s.Indexed[itemType] > 0 || s.Removed[itemType] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Indexer copies catalog entities from the service tables into
+// search_documents. Incremental syncs only look at rows changed since the
+// last run; full syncs also drop documents whose source row is gone.
+type Indexer struct {
+	mu         sync.Mutex
+	watermarks map[string]time.Time
+	version    atomic.Uint64
+}
+
+func NewIndexer() *Indexer {
+	return &Indexer{watermarks: make(map[string]time.Time)}
+}
+
+// Start runs a full sync immediately and then keeps the index fresh in the
+// background.
+func (ix *Indexer) Start(interval, fullInterval time.Duration) {
+	go func() {
+		ix.logSync(true)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lastFull := time.Now()
+
+		for range ticker.C {
+			full := time.Since(lastFull) >= fullInterval
+			if full {
+				lastFull = time.Now()
+			}
+			ix.logSync(full)
+		}
+	}()
+}
+
+func (ix *Indexer) logSync(full bool) {
+	stats := ix.Sync(full)
+	for _, message := range stats.Errors {
+		log.Printf("Search sync error: %s", message)
+	}
+	if full {
+		log.Printf("Full search sync finished in %s: indexed=%v removed=%v", stats.Duration, stats.Indexed, stats.Removed)
+	}
+}
+
+// Version changes whenever a sync modifies the index, so responses built from
+// it can be cached until then.
+func (ix *Indexer) Version() uint64 {
+	return ix.version.Load()
+}
+
+func (ix *Indexer) Sync(full bool) SyncStats {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	started := time.Now()
+	stats := SyncStats{
+		Full:    full,
+		Indexed: make(map[string]int),
+		Removed: make(map[string]int),
+	}
+
+	for _, src := range sources {
+		since := ix.watermarks[src.ItemType]
+		if full {
+			since = time.Time{}
+		}
+
+		indexed, removed, latest, err := syncSource(src, since, full)
+		if err != nil {
+			stats.Errors = append(stats.Errors, fmt.Sprintf("%s: %v", src.ItemType, err))
+			continue
+		}
+
+		stats.Indexed[src.ItemType] = indexed
+		stats.Removed[src.ItemType] = removed
+		if latest.After(ix.watermarks[src.ItemType]) {
+			ix.watermarks[src.ItemType] = latest
+		}
+	}
+
+	if stats.changed() {
+		ix.version.Add(1)
+	}
+	if full || stats.changed() {
+		if err := rebuildSuggestions(); err != nil {
+			stats.Errors = append(stats.Errors, fmt.Sprintf("suggestions: %v", err))
+		}
+	}
+
+	stats.Duration = time.Since(started).Round(time.Millisecond).String()
+	return stats
+}
+
+func syncSource(src source, since time.Time, full bool) (int, int, time.Time, error) {
+	var rows []sourceRow
+	if err := db.DB.Raw(src.Query, since).Scan(&rows).Error; err != nil {
+		return 0, 0, since, err
+	}
+
+	indexed, removed := 0, 0
+	latest := since
+	visibleIDs := make([]uint, 0, len(rows))
+	var hiddenIDs []uint
+
+	documents := make([]models.SearchDocument, 0, len(rows))
+	for _, row := range rows {
+		if row.UpdatedAt.After(latest) {
+			latest = row.UpdatedAt
+		}
+		if !row.Visible {
+			hiddenIDs = append(hiddenIDs, row.ItemID)
+			continue
+		}
+		visibleIDs = append(visibleIDs, row.ItemID)
+		documents = append(documents, toDocument(src.ItemType, row))
+	}
+
+	if len(documents) > 0 {
+		err := db.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "item_type"}, {Name: "item_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"parent_id", "title", "body", "city", "city_id", "category",
+				"latitude", "longitude", "image_url", "source_updated_at", "indexed_at",
+			}),
+		}).CreateInBatches(documents, 200).Error
+		if err != nil {
+			return 0, 0, since, err
+		}
+		indexed = len(documents)
+	}
+
+	if len(hiddenIDs) > 0 {
+		result := db.DB.Where("item_type = ? AND item_id IN ?", src.ItemType, hiddenIDs).
+			Delete(&models.SearchDocument{})
+		if result.Error != nil {
+			return indexed, 0, since, result.Error
+		}
+		removed += int(result.RowsAffected)
+	}
+
+	if full {
+		query := db.DB.Where("item_type = ?", src.ItemType)
+		if len(visibleIDs) > 0 {
+			query = query.Where("item_id NOT IN ?", visibleIDs)
+		}
+		result := query.Delete(&models.SearchDocument{})
+		if result.Error != nil {
+			return indexed, removed, since, result.Error
+		}
+		removed += int(result.RowsAffected)
+	}
+
+	return indexed, removed, latest, nil
+}
+
+func toDocument(itemType string, row sourceRow) models.SearchDocument {
+	document := models.SearchDocument{
+		ItemType:        itemType,
+		ItemID:          row.ItemID,
+		ParentID:        row.ParentID,
+		Title:           strings.TrimSpace(row.Title),
+		Body:            strings.TrimSpace(row.Body),
+		City:            strings.TrimSpace(row.City),
+		CityID:          row.CityID,
+		Category:        strings.TrimSpace(row.Category),
+		ImageURL:        row.ImageURL,
+		SourceUpdatedAt: row.UpdatedAt,
+		IndexedAt:       time.Now(),
+	}
+
+	if lat, lng, ok := ParseLocation(row.Location); ok {
+		document.Latitude = &lat
+		document.Longitude = &lng
+	}
+
+	return document
+}
+
+// ParseLocation reads the "lat,lng" strings the catalog services store.
+func ParseLocation(location string) (float64, float64, bool) {
+	parts := strings.Split(location, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, false
+	}
+
+	return lat, lng, true
+}
@@ -0,0 +1,115 @@
+package indexer
+
+import (
+	"search_service/internal/models"
+	"time"
+)
+
+// source describes how to read one entity type from the table owned by its
+// service. Every query selects the same columns (see sourceRow) and takes a
+// single "changed since" timestamp argument. Soft deletes do not touch
+// updated_at, so deleted_at counts as a change as well.
+type source struct {
+	ItemType string
+	Query    string
+}
+
+type sourceRow struct {
+	ItemID    uint
+	ParentID  uint
+	Title     string
+	Body      string
+	City      string
+	CityID    *uint
+	Category  string
+	Location  string
+	ImageURL  string
+	UpdatedAt time.Time
+	Visible   bool
+}
+
+var sources = []source{
+	{
+		ItemType: models.TypeAttraction,
+		Query: `SELECT id AS item_id, 0 AS parent_id, title,
+				concat_ws(' ', description, address) AS body,
+				coalesce(city, '') AS city, city_id, coalesce(category, '') AS category,
+				coalesce(location, '') AS location, coalesce(image_url, '') AS image_url,
+				GREATEST(updated_at, deleted_at) AS updated_at,
+				(is_published AND deleted_at IS NULL) AS visible
+			FROM attractions
+			WHERE GREATEST(updated_at, deleted_at) > ?`,
+	},
+	{
+		ItemType: models.TypeEvent,
+		Query: `SELECT id AS item_id, 0 AS parent_id, title,
+				concat_ws(' ', description, address) AS body,
+				'' AS city, city_id, coalesce(category, '') AS category,
+				coalesce(location, '') AS location, coalesce(image_url, '') AS image_url,
+				GREATEST(updated_at, deleted_at) AS updated_at,
+				(is_published AND deleted_at IS NULL) AS visible
+			FROM events
+			WHERE GREATEST(updated_at, deleted_at) > ?`,
+	},
+	{
+		ItemType: models.TypePlace,
+		Query: `SELECT p.id AS item_id, 0 AS parent_id, p.name AS title,
+				concat_ws(' ', p.description, p.address, (
+					SELECT string_agg(c.name, ' ') FROM place_cuisines pc
+					JOIN cuisines c ON c.id = pc.cuisine_id
+					WHERE pc.place_id = p.id
+				)) AS body,
+				p.city, p.city_id, p.type AS category,
+				coalesce(p.location, '') AS location,
+				coalesce((SELECT url FROM place_images
+					WHERE place_id = p.id AND deleted_at IS NULL
+					ORDER BY id LIMIT 1), '') AS image_url,
+				GREATEST(p.updated_at, p.deleted_at) AS updated_at,
+				(p.is_published AND p.deleted_at IS NULL) AS visible
+			FROM places p
+			WHERE GREATEST(p.updated_at, p.deleted_at) > ?`,
+	},
+	{
+		ItemType: models.TypeDish,
+		Query: `SELECT d.id AS item_id, d.place_id AS parent_id, d.name AS title,
+				concat_ws(' ', d.description, p.name) AS body,
+				p.city, p.city_id, coalesce(c.name, '') AS category,
+				coalesce(p.location, '') AS location,
+				coalesce((SELECT url FROM dish_images
+					WHERE dish_id = d.id AND deleted_at IS NULL
+					ORDER BY id LIMIT 1), '') AS image_url,
+				GREATEST(d.updated_at, d.deleted_at, p.updated_at, p.deleted_at) AS updated_at,
+				(d.deleted_at IS NULL AND p.is_published AND p.deleted_at IS NULL) AS visible
+			FROM dishes d
+			JOIN places p ON p.id = d.place_id
+			LEFT JOIN cuisines c ON c.id = d.cuisine_id
+			WHERE GREATEST(d.updated_at, d.deleted_at, p.updated_at, p.deleted_at) > ?`,
+	},
+	{
+		ItemType: models.TypeAccommodation,
+		Query: `SELECT a.id AS item_id, 0 AS parent_id, a.name AS title,
+				concat_ws(' ', a.description, a.address, a.amenities::text) AS body,
+				a.city, a.city_id, a.type AS category,
+				coalesce(a.location, '') AS location,
+				coalesce((SELECT url FROM accommodation_images
+					WHERE accommodation_id = a.id AND deleted_at IS NULL
+					ORDER BY id LIMIT 1), '') AS image_url,
+				GREATEST(a.updated_at, a.deleted_at) AS updated_at,
+				(a.is_published AND a.deleted_at IS NULL) AS visible
+			FROM accommodations a
+			WHERE GREATEST(a.updated_at, a.deleted_at) > ?`,
+	},
+	{
+		ItemType: models.TypeBlog,
+		Query: `SELECT b.id AS item_id, 0 AS parent_id, b.title,
+				coalesce(b.content, '') AS body,
+				'' AS city, NULL AS city_id, coalesce(b.category, '') AS category,
+				'' AS location,
+				coalesce(nullif(b.cover_image, ''), (SELECT url FROM blog_images
+					WHERE blog_id = b.id ORDER BY id LIMIT 1), '') AS image_url,
+				GREATEST(b.updated_at, b.deleted_at) AS updated_at,
+				(b.deleted_at IS NULL AND b.moderation_status = 'visible' AND b.status = 'published') AS visible
+			FROM blogs b
+			WHERE GREATEST(b.updated_at, b.deleted_at) > ?`,
+	},
+}
@@ -0,0 +1,177 @@
+package indexer
+
+import (
+	"search_service/internal/models"
+	"search_service/utils/db"
+
+	"gorm.io/gorm"
+)
+
+// suggestionWeights rank entity types against each other; a match on the
+// first word of a label adds to that, later words take a small penalty.
+var suggestionWeights = map[string]int{
+	models.SuggestionCity: 100,
+	models.TypeAttraction: 80,
+	models.TypePlace:      60,
+	models.TypeEvent:      50,
+	models.TypeDish:       40,
+}
+
+func suggestionWeight(kind string, position int) int {
+	if position > 5 {
+		position = 5
+	}
+	return suggestionWeights[kind] - position*5
+}
+
+// rebuildSuggestions regenerates the autocomplete table from the indexed
+// documents. It is cheap enough to rerun after every sync that changed data.
+func rebuildSuggestions() error {
+	var documents []models.SearchDocument
+	err := db.DB.Select("item_type", "item_id", "parent_id", "title", "city", "city_id").
+		Where("item_type IN ?", []string{models.TypeAttraction, models.TypePlace, models.TypeEvent, models.TypeDish}).
+		Find(&documents).Error
+	if err != nil {
+		return err
+	}
+
+	var suggestions []models.Suggestion
+	for _, document := range documents {
+		for _, key := range prefixKeys(document.Title) {
+			suggestions = append(suggestions, models.Suggestion{
+				Kind:     document.ItemType,
+				ItemID:   document.ItemID,
+				ParentID: document.ParentID,
+				Label:    document.Title,
+				City:     document.City,
+				CityID:   document.CityID,
+				CityKey:  CityKey(document.City),
+				Key:      key.Key,
+				Position: key.Position,
+				Weight:   suggestionWeight(document.ItemType, key.Position),
+			})
+		}
+	}
+
+	cities, err := collectCities()
+	if err != nil {
+		return err
+	}
+	for _, city := range cities {
+		for _, key := range prefixKeys(city.Label) {
+			suggestions = append(suggestions, models.Suggestion{
+				Kind:     models.SuggestionCity,
+				ItemID:   city.ID,
+				Label:    city.Label,
+				City:     city.Label,
+				CityID:   city.cityID(),
+				CityKey:  city.Key,
+				Key:      key.Key,
+				Position: key.Position,
+				Weight:   suggestionWeight(models.SuggestionCity, key.Position) + city.Popularity,
+			})
+		}
+		// Let the other script's spelling of the city find it too.
+		for _, variant := range city.Variants {
+			for _, key := range SearchKeys(variant) {
+				suggestions = append(suggestions, models.Suggestion{
+					Kind:    models.SuggestionCity,
+					ItemID:  city.ID,
+					Label:   city.Label,
+					City:    city.Label,
+					CityID:  city.cityID(),
+					CityKey: city.Key,
+					Key:     key,
+					Weight:  suggestionWeight(models.SuggestionCity, 0) + city.Popularity,
+				})
+			}
+		}
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.Suggestion{}).Error; err != nil {
+			return err
+		}
+		if len(suggestions) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(suggestions, 500).Error
+	})
+}
+
+type cityEntry struct {
+	ID         uint
+	Key        string
+	Label      string
+	Variants   []string
+	Popularity int
+}
+
+func (c cityEntry) cityID() *uint {
+	if c.ID == 0 {
+		return nil
+	}
+	id := c.ID
+	return &id
+}
+
+// collectCities merges the free-form city strings of all documents by their
+// transliterated key and picks the most used spelling as the label.
+func collectCities() ([]cityEntry, error) {
+	var rows []struct {
+		City   string
+		CityID uint
+		Total  int
+	}
+	err := db.DB.Model(&models.SearchDocument{}).
+		Select("TRIM(city) AS city, COALESCE(MAX(city_id), 0) AS city_id, COUNT(*) AS total").
+		Where("TRIM(city) <> ''").
+		Group("TRIM(city)").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*cityEntry)
+	bestCount := make(map[string]int)
+	var order []string
+	for _, row := range rows {
+		key := CityKey(row.City)
+		if key == "" {
+			continue
+		}
+		entry, exists := byKey[key]
+		if !exists {
+			entry = &cityEntry{Key: key}
+			byKey[key] = entry
+			order = append(order, key)
+		}
+		if entry.ID == 0 {
+			entry.ID = row.CityID
+		}
+		entry.Popularity += row.Total
+		entry.Variants = append(entry.Variants, row.City)
+		if row.Total > bestCount[key] {
+			bestCount[key] = row.Total
+			entry.Label = row.City
+		}
+	}
+
+	cities := make([]cityEntry, 0, len(order))
+	for _, key := range order {
+		entry := byKey[key]
+		if entry.Popularity > 20 {
+			entry.Popularity = 20
+		}
+		variants := entry.Variants[:0]
+		for _, variant := range entry.Variants {
+			if NormalizeText(variant) != NormalizeText(entry.Label) {
+				variants = append(variants, variant)
+			}
+		}
+		entry.Variants = variants
+		cities = append(cities, *entry)
+	}
+
+	return cities, nil
+}
@@ -0,0 +1,87 @@
+package indexer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// cyrillicToLatin covers Russian and Kazakh letters. Kazakh-specific letters
+// map to their closest common English spelling ("Қарағанды" -> "karagandy").
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'ә': "a", 'ғ': "g", 'қ': "k", 'ң': "n", 'ө': "o", 'ұ': "u", 'ү': "u",
+	'һ': "h", 'і': "i",
+}
+
+// NormalizeText lowercases, turns punctuation into spaces and collapses
+// whitespace, so "  Almaty, " and "almaty" compare equal.
+func NormalizeText(text string) string {
+	mapped := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(mapped), " ")
+}
+
+// Transliterate converts Cyrillic letters of normalized text to Latin and
+// leaves everything else untouched.
+func Transliterate(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if latin, ok := cyrillicToLatin[r]; ok {
+			b.WriteString(latin)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SearchKeys returns the normalized text and, when it differs, its Latin
+// transliteration.
+func SearchKeys(text string) []string {
+	normalized := NormalizeText(text)
+	if normalized == "" {
+		return nil
+	}
+	keys := []string{normalized}
+	if latin := Transliterate(normalized); latin != normalized {
+		keys = append(keys, latin)
+	}
+	return keys
+}
+
+// CityKey is the form cities are compared by, so spelling and script
+// variants of the same city collapse together.
+func CityKey(city string) string {
+	return Transliterate(NormalizeText(city))
+}
+
+type prefixKey struct {
+	Key      string
+	Position int
+}
+
+// prefixKeys lists every word-start suffix of the label in each script.
+func prefixKeys(label string) []prefixKey {
+	var keys []prefixKey
+	seen := make(map[string]bool)
+	for _, variant := range SearchKeys(label) {
+		words := strings.Split(variant, " ")
+		for i := range words {
+			key := strings.Join(words[i:], " ")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keys = append(keys, prefixKey{Key: key, Position: i})
+		}
+	}
+	return keys
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	TypeAttraction    = "attraction"
+	TypeEvent         = "event"
+	TypePlace         = "place"
+	TypeDish          = "dish"
+	TypeAccommodation = "accommodation"
+	TypeBlog          = "blog"
+)
+
+// SearchDocument is the denormalized copy of a catalog entity kept by the
+// indexer. The full-text vector lives in a generated search_vector column
+// created alongside the table.
+type SearchDocument struct {
+	ID              uint      `json:"-" gorm:"primaryKey"`
+	ItemType        string    `json:"type" gorm:"not null;uniqueIndex:idx_search_item"`
+	ItemID          uint      `json:"id" gorm:"not null;uniqueIndex:idx_search_item"`
+	ParentID        uint      `json:"parent_id,omitempty"`
+	Title           string    `json:"title" gorm:"not null"`
+	Body            string    `json:"-"`
+	City            string    `json:"city" gorm:"index"`
+	CityID          *uint     `json:"city_id,omitempty" gorm:"index"`
+	Category        string    `json:"category" gorm:"index"`
+	Latitude        *float64  `json:"latitude,omitempty" gorm:"index:idx_search_geo"`
+	Longitude       *float64  `json:"longitude,omitempty" gorm:"index:idx_search_geo"`
+	ImageURL        string    `json:"image_url"`
+	SourceUpdatedAt time.Time `json:"updated_at" gorm:"index"`
+	IndexedAt       time.Time `json:"-"`
+}
@@ -0,0 +1,20 @@
+package models
+
+const SuggestionCity = "city"
+
+// Suggestion is one prefix key for an autocomplete entry. Every label gets a
+// row per word start, both as typed and transliterated to Latin, so "lake",
+// "almaty lake" and "алматы" all reach "Almaty Lake"/"Алматы".
+type Suggestion struct {
+	ID       uint   `json:"-" gorm:"primaryKey"`
+	Kind     string `json:"type" gorm:"not null;index"`
+	ItemID   uint   `json:"id,omitempty" gorm:"not null"` // canonical city ID for city suggestions, when known
+	ParentID uint   `json:"parent_id,omitempty"`
+	Label    string `json:"label" gorm:"not null"`
+	City     string `json:"city,omitempty"`
+	CityID   *uint  `json:"city_id,omitempty" gorm:"index"`
+	CityKey  string `json:"-" gorm:"index"`
+	Key      string `json:"-" gorm:"not null"`
+	Position int    `json:"-"`
+	Weight   int    `json:"-"`
+}
@@ -0,0 +1,87 @@
+package tiles
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	MaxZoom = 20
+	// MaxLat is where Web Mercator tiles end.
+	MaxLat = 85.05112878
+)
+
+// Tile is a slippy-map (XYZ) tile, the scheme used by OSM, Mapbox and Leaflet.
+type Tile struct {
+	Z int
+	X int
+	Y int
+}
+
+func (t Tile) Valid() bool {
+	n := 1 << t.Z
+	return t.Z >= 0 && t.Z <= MaxZoom && t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Bounds returns the tile's min_lng, min_lat, max_lng, max_lat.
+func (t Tile) Bounds() (float64, float64, float64, float64) {
+	n := float64(int(1) << t.Z)
+	minLng := float64(t.X)/n*360 - 180
+	maxLng := float64(t.X+1)/n*360 - 180
+	maxLat := tileLat(float64(t.Y), n)
+	minLat := tileLat(float64(t.Y+1), n)
+	return minLng, minLat, maxLng, maxLat
+}
+
+// MercatorTop is the Web Mercator y (in radians) of the tile's top edge, and
+// MercatorSize the tile's height in the same units.
+func (t Tile) MercatorTop() float64 {
+	n := float64(int(1) << t.Z)
+	return math.Pi * (1 - 2*float64(t.Y)/n)
+}
+
+func (t Tile) MercatorSize() float64 {
+	return 2 * math.Pi / float64(int(1)<<t.Z)
+}
+
+func tileLat(y, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
+
+// At returns the tile containing the point at the given zoom.
+func At(lat, lng float64, z int) Tile {
+	lat = math.Max(math.Min(lat, MaxLat), -MaxLat)
+	n := float64(int(1) << z)
+	x := int(math.Floor((lng + 180) / 360 * n))
+	latRad := lat * math.Pi / 180
+	y := int(math.Floor((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n))
+
+	maxIndex := int(n) - 1
+	return Tile{
+		Z: z,
+		X: int(math.Max(0, math.Min(float64(x), float64(maxIndex)))),
+		Y: int(math.Max(0, math.Min(float64(y), float64(maxIndex)))),
+	}
+}
+
+// Cover lists the tiles at zoom z that intersect the bounding box. It
+// returns false instead when more than limit tiles would be needed.
+func Cover(minLng, minLat, maxLng, maxLat float64, z, limit int) ([]Tile, bool) {
+	topLeft := At(maxLat, minLng, z)
+	bottomRight := At(minLat, maxLng, z)
+	if (bottomRight.X-topLeft.X+1)*(bottomRight.Y-topLeft.Y+1) > limit {
+		return nil, false
+	}
+
+	var result []Tile
+	for x := topLeft.X; x <= bottomRight.X; x++ {
+		for y := topLeft.Y; y <= bottomRight.Y; y++ {
+			result = append(result, Tile{Z: z, X: x, Y: y})
+		}
+	}
+	return result, true
+}
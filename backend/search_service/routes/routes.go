@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"search_service/internal/controllers"
+	"search_service/internal/indexer"
+	"search_service/internal/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func SetupRoutes(ix *indexer.Indexer) *mux.Router {
+	r := mux.NewRouter()
+
+	searchController := controllers.SearchController{Indexer: ix}
+	mapController := controllers.NewMapController(ix)
+
+	r.HandleFunc("/search", searchController.Search).Methods("GET")
+	r.HandleFunc("/search/suggest", searchController.Suggest).Methods("GET")
+
+	r.HandleFunc("/map/features", mapController.Viewport).Methods("GET")
+	r.HandleFunc("/map/tiles/{z:[0-9]+}/{x:[0-9]+}/{y:[0-9]+}.geojson", mapController.Tile).Methods("GET")
+
+	admin := r.PathPrefix("/admin/search").Subrouter()
+	admin.Use(middleware.AdminAuthMiddleware)
+	admin.HandleFunc("/reindex", searchController.Reindex).Methods("POST")
+
+	return r
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"log"
+	"os"
+	"search_service/internal/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var DB *gorm.DB
+
+// searchIndexStatements set up full-text and trigram indexes that GORM tags
+// cannot express. The 'simple' configuration is used because catalog content
+// mixes Russian and English.
+var searchIndexStatements = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`ALTER TABLE search_documents ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(category, '') || ' ' || coalesce(city, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(body, '')), 'C')
+		) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_search_vector ON search_documents USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_search_title_trgm ON search_documents USING GIN (lower(title) gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_suggestion_key ON suggestions (key text_pattern_ops)`,
+}
+
+func ConnectDB() {
+	dsn := "host=db user=postgres password=123456 dbname=TravelApp port=5432 sslmode=disable"
+	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
+		dsn = dbURL
+	}
+
+	var err error
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	log.Println("Connected to the database")
+
+	if err := DB.AutoMigrate(&models.SearchDocument{}, &models.Suggestion{}); err != nil {
+		log.Fatalf("Failed to migrate database schemas: %v", err)
+	}
+
+	for _, statement := range searchIndexStatements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatalf("Failed to prepare search indexes: %v", err)
+		}
+	}
+
+	log.Println("Database migration completed")
+}